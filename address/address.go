@@ -0,0 +1,151 @@
+// Package address parses and validates the addresses of the clients of the Nym mixnet,
+// written "<client identity>.<client encryption key>@<gateway identity>" with base58 encoded keys
+package address
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/mr-tron/base58"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// Length of each of the keys making an Address
+	KeyLength = 32
+	// Length of an Address in its binary form
+	Length = 3 * KeyLength
+)
+
+// Address identifies a client of the mixnet
+type Address struct {
+	ClientIdentity      [KeyLength]byte
+	ClientEncryptionKey [KeyLength]byte
+	Gateway             [KeyLength]byte
+}
+
+// Parse reads an address of the "<client identity>.<client encryption key>@<gateway identity>" form
+func Parse(s string) (Address, error) {
+	var a Address
+
+	client, gateway, found := strings.Cut(s, "@")
+	if !found {
+		return a, xerrors.Errorf("invalid Nym address %q: missing '@' before the gateway", s)
+	}
+	identity, encryptionKey, found := strings.Cut(client, ".")
+	if !found {
+		return a, xerrors.Errorf("invalid Nym address %q: missing '.' between the client keys", s)
+	}
+
+	for _, key := range []struct {
+		name    string
+		encoded string
+		decoded *[KeyLength]byte
+	}{
+		{"client identity", identity, &a.ClientIdentity},
+		{"client encryption key", encryptionKey, &a.ClientEncryptionKey},
+		{"gateway identity", gateway, &a.Gateway},
+	} {
+		decoded, e := base58.Decode(key.encoded)
+		if nil != e {
+			return a, xerrors.Errorf("invalid Nym address %q: %v is not base58: %v", s, key.name, e)
+		}
+		if len(decoded) != KeyLength {
+			return a, xerrors.Errorf("invalid Nym address %q: %v is %d bytes instead of %d", s, key.name, len(decoded), KeyLength)
+		}
+		copy(key.decoded[:], decoded)
+	}
+
+	return a, nil
+}
+
+// Validate returns a descriptive error if s is not a valid address
+func Validate(s string) error {
+	_, e := Parse(s)
+	return e
+}
+
+// FromBytes reads an address in its binary form, the keys being concatenated
+func FromBytes(b []byte) (Address, error) {
+	var a Address
+	if len(b) != Length {
+		return a, xerrors.Errorf("invalid Nym address: %d bytes instead of %d", len(b), Length)
+	}
+	copy(a.ClientIdentity[:], b[:KeyLength])
+	copy(a.ClientEncryptionKey[:], b[KeyLength:2*KeyLength])
+	copy(a.Gateway[:], b[2*KeyLength:])
+	return a, nil
+}
+
+// Bytes returns the binary form of the address
+func (a Address) Bytes() []byte {
+	b := make([]byte, 0, Length)
+	b = append(b, a.ClientIdentity[:]...)
+	b = append(b, a.ClientEncryptionKey[:]...)
+	return append(b, a.Gateway[:]...)
+}
+
+func (a Address) String() string {
+	return base58.Encode(a.ClientIdentity[:]) + "." + base58.Encode(a.ClientEncryptionKey[:]) + "@" + base58.Encode(a.Gateway[:])
+}
+
+// Hex returns the binary form of the address, hex encoded
+func (a Address) Hex() string {
+	return hex.EncodeToString(a.Bytes())
+}
+
+// FromHex reads an address in its hex encoded binary form
+func FromHex(s string) (Address, error) {
+	b, e := hex.DecodeString(s)
+	if nil != e {
+		return Address{}, xerrors.Errorf("invalid Nym address %q: %v", s, e)
+	}
+	return FromBytes(b)
+}
+
+// ClientID returns the "<client identity>.<client encryption key>" part of the address
+func (a Address) ClientID() string {
+	return base58.Encode(a.ClientIdentity[:]) + "." + base58.Encode(a.ClientEncryptionKey[:])
+}
+
+// GatewayID returns the identity of the gateway of the address
+func (a Address) GatewayID() string {
+	return base58.Encode(a.Gateway[:])
+}
+
+// Normalize returns s in its canonical form, without surrounding spaces, or an error if it is not a valid address
+func Normalize(s string) (string, error) {
+	a, e := Parse(strings.TrimSpace(s))
+	if nil != e {
+		return "", e
+	}
+	return a.String(), nil
+}
+
+// Equal tells whether a and b are valid addresses of the same client
+func Equal(a string, b string) bool {
+	parsedA, e := Parse(strings.TrimSpace(a))
+	if nil != e {
+		return false
+	}
+	parsedB, e := Parse(strings.TrimSpace(b))
+	return nil == e && parsedA == parsedB
+}
+
+// Base58ToHex converts a base58 encoded key, e.g. a part of an address, to hex
+func Base58ToHex(s string) (string, error) {
+	b, e := base58.Decode(s)
+	if nil != e {
+		return "", xerrors.Errorf("invalid base58 %q: %v", s, e)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HexToBase58 converts a hex encoded key to base58, as used in the addresses
+func HexToBase58(s string) (string, error) {
+	b, e := hex.DecodeString(s)
+	if nil != e {
+		return "", xerrors.Errorf("invalid hex %q: %v", s, e)
+	}
+	return base58.Encode(b), nil
+}
@@ -0,0 +1,73 @@
+package address_test
+
+import (
+	"testing"
+
+	"github.com/notrustverify/nymsocketmanager/address"
+	"github.com/stretchr/testify/require"
+)
+
+const validAddress = "4wBqpZM9xaSheZzJSMawUKKwhdpChKbZ5eu5ky4Vigw.3ELeRTTg5W5hAYaEFznzFV1jknNFkjHqS8ytwvQEQP1Z@5Pk716N113awdSaUDZEPZVi9Zs6hJmG5KCJtp5qQK3LB"
+
+func TestParse(t *testing.T) {
+	a, e := address.Parse(validAddress)
+	require.NoError(t, e)
+	require.Equal(t, byte(1), a.ClientIdentity[0])
+	require.Equal(t, byte(33), a.ClientEncryptionKey[0])
+	require.Equal(t, byte(65), a.Gateway[0])
+	require.Equal(t, validAddress, a.String())
+
+	fromBytes, e := address.FromBytes(a.Bytes())
+	require.NoError(t, e)
+	require.Equal(t, a, fromBytes)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for s, expected := range map[string]string{
+		"": "missing '@'",
+		"4wBqpZM9xaSheZzJSMawUKKwhdpChKbZ5eu5ky4Vigw@5Pk716N113awdSaUDZEPZVi9Zs6hJmG5KCJtp5qQK3LB":       "missing '.'",
+		"0OIl.3ELeRTTg5W5hAYaEFznzFV1jknNFkjHqS8ytwvQEQP1Z@5Pk716N113awdSaUDZEPZVi9Zs6hJmG5KCJtp5qQK3LB": "client identity is not base58",
+		"4wBqpZM9xaSheZzJSMawUKKwhdpChKbZ5eu5ky4Vigw.3ELeRTTg5W5hAYaEFznzFV1jknNFkjHqS8ytwvQEQP1Z@abc":   "gateway identity is 3 bytes",
+	} {
+		e := address.Validate(s)
+		require.ErrorContains(t, e, expected, s)
+	}
+
+	_, e := address.FromBytes([]byte{1, 2, 3})
+	require.Error(t, e)
+}
+
+func TestNormalizeAndEqual(t *testing.T) {
+	normalized, e := address.Normalize("  " + validAddress + "\n")
+	require.NoError(t, e)
+	require.Equal(t, validAddress, normalized)
+
+	_, e = address.Normalize("not an address")
+	require.Error(t, e)
+
+	require.True(t, address.Equal(validAddress, " "+validAddress))
+	require.False(t, address.Equal(validAddress, "not an address"))
+}
+
+func TestHexConversions(t *testing.T) {
+	a, e := address.Parse(validAddress)
+	require.NoError(t, e)
+
+	fromHex, e := address.FromHex(a.Hex())
+	require.NoError(t, e)
+	require.Equal(t, a, fromHex)
+	require.Equal(t, "5Pk716N113awdSaUDZEPZVi9Zs6hJmG5KCJtp5qQK3LB", a.GatewayID())
+	require.Equal(t, validAddress, a.ClientID()+"@"+a.GatewayID())
+
+	gatewayHex, e := address.Base58ToHex(a.GatewayID())
+	require.NoError(t, e)
+	require.Equal(t, "41", gatewayHex[:2])
+	gateway, e := address.HexToBase58(gatewayHex)
+	require.NoError(t, e)
+	require.Equal(t, a.GatewayID(), gateway)
+
+	_, e = address.FromHex("zz")
+	require.Error(t, e)
+	_, e = address.Base58ToHex("0OIl")
+	require.Error(t, e)
+}
@@ -0,0 +1,54 @@
+package address
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/mr-tron/base58"
+	"golang.org/x/xerrors"
+)
+
+// Length of a SenderTag in its binary form
+const SenderTagLength = 16
+
+// SenderTag identifies, without disclosing its address, the sender of anonymous messages
+type SenderTag [SenderTagLength]byte
+
+// ParseSenderTag reads a base58 encoded sender tag, as found in the received messages
+func ParseSenderTag(s string) (SenderTag, error) {
+	var tag SenderTag
+
+	decoded, e := base58.Decode(strings.TrimSpace(s))
+	if nil != e {
+		return tag, xerrors.Errorf("invalid sender tag %q: not base58: %v", s, e)
+	}
+	if len(decoded) != SenderTagLength {
+		return tag, xerrors.Errorf("invalid sender tag %q: %d bytes instead of %d", s, len(decoded), SenderTagLength)
+	}
+	copy(tag[:], decoded)
+	return tag, nil
+}
+
+// SenderTagFromHex reads a sender tag in its hex encoded binary form
+func SenderTagFromHex(s string) (SenderTag, error) {
+	var tag SenderTag
+
+	decoded, e := hex.DecodeString(s)
+	if nil != e {
+		return tag, xerrors.Errorf("invalid sender tag %q: %v", s, e)
+	}
+	if len(decoded) != SenderTagLength {
+		return tag, xerrors.Errorf("invalid sender tag %q: %d bytes instead of %d", s, len(decoded), SenderTagLength)
+	}
+	copy(tag[:], decoded)
+	return tag, nil
+}
+
+func (t SenderTag) String() string {
+	return base58.Encode(t[:])
+}
+
+// Hex returns the binary form of the sender tag, hex encoded
+func (t SenderTag) Hex() string {
+	return hex.EncodeToString(t[:])
+}
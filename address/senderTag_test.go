@@ -0,0 +1,24 @@
+package address_test
+
+import (
+	"testing"
+
+	"github.com/notrustverify/nymsocketmanager/address"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSenderTag(t *testing.T) {
+	tag, e := address.ParseSenderTag("8DfbjXLth7APvt3qQPgtf")
+	require.NoError(t, e)
+	require.Equal(t, "8DfbjXLth7APvt3qQPgtf", tag.String())
+	require.Equal(t, "0102030405060708090a0b0c0d0e0f10", tag.Hex())
+
+	fromHex, e := address.SenderTagFromHex(tag.Hex())
+	require.NoError(t, e)
+	require.Equal(t, tag, fromHex)
+
+	_, e = address.ParseSenderTag("abc")
+	require.Error(t, e)
+	_, e = address.SenderTagFromHex("0102")
+	require.Error(t, e)
+}
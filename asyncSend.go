@@ -0,0 +1,151 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/xerrors"
+)
+
+// asyncSender writes the messages queued by Send from a single goroutine while the manager is started, see WithAsyncSend
+type asyncSender struct {
+	sync.Mutex
+
+	queueLength int
+	policy      OverflowPolicy
+	queue       chan bufferedMessage
+	stopChan    chan struct{}
+	dropped     atomic.Uint64
+}
+
+// startAsyncSender starts the writer goroutine, if asynchronous sends are configured
+// called from methods that already acquired the lock
+func (n *NymSocketManager) startAsyncSender() {
+	if nil == n.asyncSender {
+		return
+	}
+
+	n.asyncSender.Lock()
+	defer n.asyncSender.Unlock()
+
+	n.asyncSender.queue = make(chan bufferedMessage, n.asyncSender.queueLength)
+	n.asyncSender.stopChan = make(chan struct{})
+	go n.writeQueued(n.asyncSender.queue, n.asyncSender.stopChan)
+}
+
+// stopAsyncSender stops the writer goroutine, failing the messages still queued
+// called from methods that already acquired the lock
+func (n *NymSocketManager) stopAsyncSender() {
+	if nil == n.asyncSender {
+		return
+	}
+
+	n.asyncSender.Lock()
+	defer n.asyncSender.Unlock()
+
+	if nil == n.asyncSender.stopChan {
+		return
+	}
+	close(n.asyncSender.stopChan)
+	n.asyncSender.stopChan = nil
+
+	for {
+		select {
+		case queued := <-n.asyncSender.queue:
+			queued.options.complete(xerrors.Errorf("NymSocketManager stopped before %v could be sent", queued.msg.Name()))
+			n.sendGate.leave()
+		default:
+			n.asyncSender.queue = nil
+			return
+		}
+	}
+}
+
+func (n *NymSocketManager) writeQueued(queue chan bufferedMessage, stopChan chan struct{}) {
+	for {
+		select {
+		case <-stopChan:
+			return
+		case queued := <-queue:
+			queued.options.complete(n.writePrioritized(queued.msg, queued.options))
+			n.sendGate.leave()
+		}
+	}
+}
+
+// sendAsync queues a message for the writer goroutine, which notifies its completion.
+// Like the Sends in progress, the queued messages are flushed before the connection is closed
+func (n *NymSocketManager) sendAsync(msg NymMessage, options *sendOptions) error {
+	if !n.sendGate.enter() {
+		err := xerrors.Errorf("cannot send %v: %w", msg.Name(), ErrNotStarted)
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	dropped, e := n.asyncSender.push(bufferedMessage{msg, options})
+	for _, oldest := range dropped {
+		err := xerrors.Errorf("send queue is full (%d messages), dropped %v", n.asyncSender.queueLength, oldest.msg.Name())
+		n.logger.Warn().Str(MessageIDField, oldest.options.id()).Msg(err.Error())
+		n.asyncSender.dropped.Add(1)
+		n.messageDropped(DropSendQueue, oldest.msg, oldest.options.id(), nil, err.Error())
+		oldest.options.complete(err)
+		n.sendGate.leave()
+	}
+	if nil != e {
+		n.logger.Warn().Str(MessageIDField, options.id()).Msg(e.Error())
+		n.asyncSender.dropped.Add(1)
+		n.messageDropped(DropSendQueue, msg, options.id(), nil, e.Error())
+		n.sendGate.leave()
+		return e
+	}
+	n.messageLogger.Trace().Str(MessageIDField, options.id()).Msgf("queued %v for the writer", msg.Name())
+	return nil
+}
+
+// push queues a message, applying the overflow policy if the queue is full.
+// It returns the messages dropped to make room for it with OverflowDropOldest, if any
+func (s *asyncSender) push(queued bufferedMessage) ([]bufferedMessage, error) {
+	// Not kept locked while blocking, so that the writer can be stopped meanwhile
+	s.Lock()
+	queue, stopChan := s.queue, s.stopChan
+	s.Unlock()
+
+	if nil == stopChan {
+		return nil, xerrors.Errorf("send queue is stopped, cannot send %v", queued.msg.Name())
+	}
+
+	switch s.policy {
+	case OverflowBlock:
+		select {
+		case queue <- queued:
+			return nil, nil
+		case <-stopChan:
+			return nil, xerrors.Errorf("send queue stopped, cannot send %v", queued.msg.Name())
+		}
+
+	case OverflowDropNewest:
+		select {
+		case queue <- queued:
+			return nil, nil
+		default:
+			return nil, xerrors.Errorf("send queue is full (%d messages), cannot send %v", s.queueLength, queued.msg.Name())
+		}
+
+	default:
+		var dropped []bufferedMessage
+		for {
+			select {
+			case queue <- queued:
+				return dropped, nil
+			default:
+			}
+
+			// The writer may have taken the oldest message meanwhile, in which case nothing is dropped
+			select {
+			case oldest := <-queue:
+				dropped = append(dropped, oldest)
+			default:
+			}
+		}
+	}
+}
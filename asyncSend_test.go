@@ -0,0 +1,87 @@
+package nymsocketmanager_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerAsyncSend(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.readsHeld = make(chan struct{})
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithAsyncSend(1, lib.OverflowDropNewest))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	completions := make(chan error, 2)
+	onComplete := lib.WithCompletion(func(err error) { completions <- err })
+
+	// Large enough to fill the buffers of the connection, so that the writer goroutine is stuck until the nym-client reads again
+	large := strings.Repeat("a", 8<<20)
+	require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend(large, fakePeerAddress), onComplete))
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend("queued", fakePeerAddress), onComplete))
+	require.Error(t, nymSocketManager.Send(lib.NewNymSend("overflowing", fakePeerAddress)))
+	require.Empty(t, completions)
+	require.Equal(t, 1, nymSocketManager.Stats().SendQueueDepth)
+
+	close(fake.readsHeld)
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-completions:
+			require.NoError(t, e)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "queued message not written")
+		}
+	}
+
+	// The messages queued are flushed when stopping
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("last", fakePeerAddress)))
+	nymSocketManager.Stop()
+	require.Eventually(t, func() bool { return 3 == len(fake.receivedSends()) }, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"queued", "last"}, fake.receivedSends()[1:])
+
+	require.Error(t, nymSocketManager.Send(lib.NewNymSend("stopped", fakePeerAddress)))
+}
+
+func TestNymSocketManagerAsyncSendDropOldest(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.readsHeld = make(chan struct{})
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithAsyncSend(1, lib.OverflowDropOldest))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// Keeps the writer goroutine busy, as in TestNymSocketManagerAsyncSend
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(strings.Repeat("a", 8<<20), fakePeerAddress)))
+	time.Sleep(100 * time.Millisecond)
+
+	completions := make(chan error, 1)
+	require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend("oldest", fakePeerAddress), lib.WithCompletion(func(err error) { completions <- err })))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("newest", fakePeerAddress)))
+	require.Error(t, <-completions)
+	require.Equal(t, uint64(1), nymSocketManager.Stats().SendQueueDropped)
+
+	close(fake.readsHeld)
+	require.Eventually(t, func() bool { return 2 == len(fake.receivedSends()) }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "newest", fake.receivedSends()[1])
+}
+
+func TestWithAsyncSendPolicy(t *testing.T) {
+	logger := zerolog.Logger{}
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1:1977", emptyProcessing, lib.ZerologLogger(&logger), lib.WithAsyncSend(1, lib.OverflowPolicy(42)))
+	require.Error(t, e)
+	_, e = lib.NewNymSocketManager("ws://127.0.0.1:1977", emptyProcessing, lib.ZerologLogger(&logger), lib.WithAsyncSend(0, lib.OverflowBlock))
+	require.Error(t, e)
+}
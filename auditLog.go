@@ -0,0 +1,251 @@
+package nymsocketmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Size after which the audit log is rotated, unless set in the AuditLog
+const DefaultAuditLogMaxSize = 10 << 20
+
+// Layout of the suffix appended to the name of the rotated audit logs, sorting them by age
+const auditLogRotationLayout = "20060102T150405.000000000"
+
+// AuditLog configures the log of the messages sent and received, see WithAuditLog
+type AuditLog struct {
+	// File the records are appended to, created if needed
+	Path string
+	// Size in bytes after which the file is rotated, DefaultAuditLogMaxSize if 0
+	MaxSize int64
+	// Time after which the file is rotated, counted from when it was opened, never if 0
+	MaxAge time.Duration
+	// Number of rotated files kept, the oldest being removed, all of them if 0
+	MaxBackups int
+	// Whether the records hold the SHA-256 of the payloads
+	HashPayloads bool
+}
+
+// auditRecord is a line of the audit log
+type auditRecord struct {
+	At time.Time `json:"at"`
+	// "in" or "out"
+	Direction string `json:"direction"`
+	Type      string `json:"type"`
+	Recipient string `json:"recipient,omitempty"`
+	SenderTag string `json:"senderTag,omitempty"`
+	// Length of the payload, in bytes
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// auditLog appends the records to the file, rotating it
+// It has its own lock so that messages can be recorded while the NymSocketManager is busy (e.g. connecting)
+type auditLog struct {
+	sync.Mutex
+
+	config  AuditLog
+	enabled bool
+	// nil until the first record, and after close
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// EnableAuditLog resumes recording the messages in the audit log configured with WithAuditLog
+func (n *NymSocketManager) EnableAuditLog() error {
+	if nil == n.audit {
+		err := xerrors.Errorf("no audit log configured, see WithAuditLog")
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	n.audit.Lock()
+	defer n.audit.Unlock()
+	n.audit.enabled = true
+	return nil
+}
+
+// DisableAuditLog stops recording the messages in the audit log, and closes its file until EnableAuditLog is called
+func (n *NymSocketManager) DisableAuditLog() {
+	if nil == n.audit {
+		return
+	}
+
+	n.audit.Lock()
+	defer n.audit.Unlock()
+	n.audit.enabled = false
+	n.audit.close()
+}
+
+// IsAuditLogEnabled tells whether the messages are recorded in the audit log
+func (n *NymSocketManager) IsAuditLogEnabled() bool {
+	if nil == n.audit {
+		return false
+	}
+
+	n.audit.Lock()
+	defer n.audit.Unlock()
+	return n.audit.enabled
+}
+
+// closeAuditLog closes the file of the audit log when the manager stops, it is reopened by the next record
+func (n *NymSocketManager) closeAuditLog() {
+	if nil == n.audit {
+		return
+	}
+
+	n.audit.Lock()
+	defer n.audit.Unlock()
+	n.audit.close()
+}
+
+// auditSent records a message written to a nym-client, the requests other than send and reply are not recorded
+func (n *NymSocketManager) auditSent(msg NymMessage) {
+	if nil == n.audit {
+		return
+	}
+
+	record := auditRecord{Direction: DirectionOut.String()}
+	var payload string
+	switch m := msg.(type) {
+	case NymSend:
+		record.Type = NymSendType
+		record.Recipient = m.Recipient
+		payload = m.Message
+	case NymReply:
+		record.Type = NymReplyType
+		record.SenderTag = m.SenderTag
+		payload = m.Message
+	default:
+		return
+	}
+	n.auditRecord(record, payload)
+}
+
+// auditReceived records a message received from the mixnet
+func (n *NymSocketManager) auditReceived(msg NymReceived) {
+	if nil == n.audit {
+		return
+	}
+	n.auditRecord(auditRecord{Direction: DirectionIn.String(), Type: NymReceivedType, SenderTag: msg.SenderTag}, msg.Message)
+}
+
+func (n *NymSocketManager) auditRecord(record auditRecord, payload string) {
+	n.audit.Lock()
+	defer n.audit.Unlock()
+
+	if !n.audit.enabled || isInternalPayload(payload) {
+		return
+	}
+	record.At = time.Now()
+	record.Size = len(payload)
+	if n.audit.config.HashPayloads {
+		sum := sha256.Sum256([]byte(payload))
+		record.SHA256 = hex.EncodeToString(sum[:])
+	}
+
+	e := n.audit.write(record)
+	if nil != e {
+		n.logger.Warn().Msgf("failed to write the audit log: %v", e)
+	}
+}
+
+// isInternalPayload tells whether a payload is cover traffic or a latency probe, which are not worth auditing
+func isInternalPayload(payload string) bool {
+	return strings.HasPrefix(payload, coverTrafficPrefix) || strings.HasPrefix(payload, latencyProbePrefix)
+}
+
+// called from methods that already acquired the lock
+func (a *auditLog) write(record auditRecord) error {
+	line, e := json.Marshal(record)
+	if nil != e {
+		return e
+	}
+	line = append(line, '\n')
+
+	if nil != a.file && a.mustRotate(int64(len(line)), record.At) {
+		e = a.rotate(record.At)
+		if nil != e {
+			return e
+		}
+	}
+	if nil == a.file {
+		e = a.open(record.At)
+		if nil != e {
+			return e
+		}
+	}
+
+	written, e := a.file.Write(line)
+	a.size += int64(written)
+	return e
+}
+
+// called from methods that already acquired the lock
+func (a *auditLog) mustRotate(lineSize int64, now time.Time) bool {
+	if a.size > 0 && a.size+lineSize > a.config.MaxSize {
+		return true
+	}
+	return 0 != a.config.MaxAge && now.Sub(a.openedAt) >= a.config.MaxAge
+}
+
+// called from methods that already acquired the lock
+func (a *auditLog) open(now time.Time) error {
+	file, e := os.OpenFile(a.config.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if nil != e {
+		return e
+	}
+	info, e := file.Stat()
+	if nil != e {
+		file.Close()
+		return e
+	}
+	a.file = file
+	a.size = info.Size()
+	a.openedAt = now
+	return nil
+}
+
+// rotate renames the file after the time it is rotated at, and removes the oldest backups
+// called from methods that already acquired the lock
+func (a *auditLog) rotate(now time.Time) error {
+	a.close()
+	e := os.Rename(a.config.Path, a.config.Path+"."+now.UTC().Format(auditLogRotationLayout))
+	if nil != e {
+		return e
+	}
+	if 0 == a.config.MaxBackups {
+		return nil
+	}
+
+	backups, e := filepath.Glob(a.config.Path + ".*")
+	if nil != e {
+		return e
+	}
+	sort.Strings(backups)
+	for len(backups) > a.config.MaxBackups {
+		e = os.Remove(backups[0])
+		if nil != e {
+			return e
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// called from methods that already acquired the lock
+func (a *auditLog) close() {
+	if nil != a.file {
+		a.file.Close()
+		a.file = nil
+	}
+}
@@ -0,0 +1,136 @@
+package nymsocketmanager_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// readAuditLog returns the records of the audit log at path
+func readAuditLog(t *testing.T, path string) []map[string]interface{} {
+	content, e := os.ReadFile(path)
+	require.NoError(t, e)
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		if "" == line {
+			continue
+		}
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+// sendLoopback sends payload to the fake nym-client and waits for it to come back
+func sendLoopback(t *testing.T, nymSocketManager *lib.NymSocketManager, received chan lib.NymReceived, payload string) {
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(payload, fakeNymClientAddress)))
+	select {
+	case r := <-received:
+		require.Equal(t, payload, r.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+}
+
+func TestNymSocketManagerAuditLog(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	received := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(r lib.NymReceived, _ func(lib.NymMessage) error) {
+		received <- r
+	}, lib.ZerologLogger(&logger), lib.WithAuditLog(lib.AuditLog{Path: path, HashPayloads: true}))
+	require.NoError(t, e)
+	require.True(t, nymSocketManager.IsAuditLogEnabled())
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	sendLoopback(t, nymSocketManager, received, "hello")
+
+	records := readAuditLog(t, path)
+	require.Len(t, records, 2)
+	sum := sha256.Sum256([]byte("hello"))
+	require.Equal(t, "out", records[0]["direction"])
+	require.Equal(t, lib.NymSendType, records[0]["type"])
+	require.Equal(t, fakeNymClientAddress, records[0]["recipient"])
+	require.Equal(t, "in", records[1]["direction"])
+	require.Equal(t, lib.NymReceivedType, records[1]["type"])
+	for _, record := range records {
+		require.EqualValues(t, len("hello"), record["size"])
+		require.Equal(t, hex.EncodeToString(sum[:]), record["sha256"])
+	}
+
+	// The payloads themselves are never recorded
+	content, e := os.ReadFile(path)
+	require.NoError(t, e)
+	require.NotContains(t, string(content), "hello")
+
+	// Nothing is recorded while disabled
+	nymSocketManager.DisableAuditLog()
+	require.False(t, nymSocketManager.IsAuditLogEnabled())
+	sendLoopback(t, nymSocketManager, received, "unaudited")
+	require.Len(t, readAuditLog(t, path), 2)
+
+	require.NoError(t, nymSocketManager.EnableAuditLog())
+	sendLoopback(t, nymSocketManager, received, "audited")
+	records = readAuditLog(t, path)
+	require.Len(t, records, 4)
+	require.EqualValues(t, len("audited"), records[2]["size"])
+	require.EqualValues(t, len("audited"), records[3]["size"])
+}
+
+func TestNymSocketManagerAuditLogRotation(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	received := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(r lib.NymReceived, _ func(lib.NymMessage) error) {
+		received <- r
+	}, lib.ZerologLogger(&logger), lib.WithAuditLog(lib.AuditLog{Path: path, MaxSize: 1, MaxBackups: 2}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// Each record goes to a new file
+	for i := 0; i < 3; i++ {
+		sendLoopback(t, nymSocketManager, received, "hello")
+	}
+
+	require.Len(t, readAuditLog(t, path), 1)
+	backups, e := filepath.Glob(path + ".*")
+	require.NoError(t, e)
+	require.Len(t, backups, 2)
+	for _, backup := range backups {
+		require.Len(t, readAuditLog(t, backup), 1)
+	}
+}
+
+func TestWithAuditLogInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+	for _, audit := range []lib.AuditLog{{}, {Path: "audit.jsonl", MaxSize: -1}, {Path: "audit.jsonl", MaxBackups: -1}} {
+		_, e := lib.NewNymSocketManager("ws://127.0.0.1:1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithAuditLog(audit))
+		require.Error(t, e)
+	}
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://127.0.0.1:1", emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	require.Error(t, nymSocketManager.EnableAuditLog())
+	require.False(t, nymSocketManager.IsAuditLogEnabled())
+}
@@ -0,0 +1,86 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// replyWaiter is a SendAndAwaitReply waiting for a message accepted by its matcher
+type replyWaiter struct {
+	matcher   func(NymReceived) bool
+	replyChan chan NymReceived
+}
+
+// replyWaiters holds the calls to SendAndAwaitReply in progress
+type replyWaiters struct {
+	sync.Mutex
+	waiters []*replyWaiter
+}
+
+func (r *replyWaiters) register(matcher func(NymReceived) bool) *replyWaiter {
+	r.Lock()
+	defer r.Unlock()
+
+	waiter := &replyWaiter{matcher: matcher, replyChan: make(chan NymReceived, 1)}
+	r.waiters = append(r.waiters, waiter)
+	return waiter
+}
+
+func (r *replyWaiters) unregister(waiter *replyWaiter) {
+	r.Lock()
+	defer r.Unlock()
+
+	for i, registered := range r.waiters {
+		if waiter == registered {
+			r.waiters = append(r.waiters[:i], r.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// replyReceived hands received to the oldest waiter accepting it, and returns false if there is none
+func (r *replyWaiters) replyReceived(received NymReceived) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	for i, waiter := range r.waiters {
+		if waiter.matcher(received) {
+			waiter.replyChan <- received
+			r.waiters = append(r.waiters[:i], r.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SendAndAwaitReply sends msg and waits for the first received message accepted by matcher, which is then not passed
+// to the messageHandler. matcher is called from the goroutine handling each message until it accepts one: it must return quickly.
+// The wait is aborted when ctx is done
+func (n *NymSocketManager) SendAndAwaitReply(ctx context.Context, msg NymMessage, matcher func(NymReceived) bool) (NymReceived, error) {
+	if nil == matcher {
+		err := xerrors.Errorf("matcher needs to be defined")
+		n.logger.Warn().Msg(err.Error())
+		return NymReceived{}, err
+	}
+
+	// Registered first, so that a fast reply cannot be missed
+	waiter := n.replyWaiters.register(matcher)
+	defer n.replyWaiters.unregister(waiter)
+
+	e := n.Send(msg)
+	if nil != e {
+		return NymReceived{}, e
+	}
+
+	select {
+	case reply := <-waiter.replyChan:
+		return reply, nil
+
+	case <-ctx.Done():
+		err := xerrors.Errorf("no reply received to %v: %w", msg.Name(), ctx.Err())
+		n.logger.Warn().Msg(err.Error())
+		return NymReceived{}, err
+	}
+}
@@ -0,0 +1,52 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerSendAndAwaitReply(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handledChan := make(chan string, 2)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handledChan <- received.Message
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	isPong := func(received lib.NymReceived) bool { return strings.HasPrefix(received.Message, "pong") }
+
+	reply, e := nymSocketManager.SendAndAwaitReply(ctx, lib.NewNymSend("pong 1", nymSocketManager.GetNymClientId()), isPong)
+	require.NoError(t, e)
+	require.Equal(t, "pong 1", reply.Message)
+
+	// The messages not matched are passed to the messageHandler, the wait ending with the context
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer shortCancel()
+	_, e = nymSocketManager.SendAndAwaitReply(shortCtx, lib.NewNymSend("ping", nymSocketManager.GetNymClientId()), isPong)
+	require.ErrorIs(t, e, context.DeadlineExceeded)
+
+	select {
+	case message := <-handledChan:
+		require.Equal(t, "ping", message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+	require.Empty(t, handledChan)
+
+	_, e = nymSocketManager.SendAndAwaitReply(ctx, lib.NewNymSend("pong", nymSocketManager.GetNymClientId()), nil)
+	require.Error(t, e)
+}
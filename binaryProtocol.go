@@ -0,0 +1,153 @@
+package nymsocketmanager
+
+import (
+	"encoding/binary"
+
+	"github.com/notrustverify/nymsocketmanager/address"
+	"golang.org/x/xerrors"
+)
+
+// Tags of the requests of the nym-client binary protocol
+const (
+	binarySendRequestTag          byte = 0x00
+	binarySendAnonymousRequestTag byte = 0x01
+	binaryReplyRequestTag         byte = 0x02
+	binarySelfAddressRequestTag   byte = 0x03
+	binaryLaneQueueLengthTag      byte = 0x04
+)
+
+// Tags of the responses of the nym-client binary protocol
+const (
+	binaryErrorResponseTag       byte = 0x00
+	binaryReceivedResponseTag    byte = 0x01
+	binarySelfAddressResponseTag byte = 0x02
+	binaryLaneQueueResponseTag   byte = 0x03
+
+	maxBinaryResponseTag = binaryLaneQueueResponseTag
+)
+
+// isBinaryFrame tells whether a frame from the nym-client uses the binary protocol.
+// JSON documents start with '{' or a whitespace, while binary responses start with their tag
+func isBinaryFrame(frame []byte) bool {
+	return len(frame) > 0 && frame[0] <= maxBinaryResponseTag
+}
+
+// encodeBinaryRequest serialises msg according to the nym-client binary protocol
+func encodeBinaryRequest(msg NymMessage) ([]byte, error) {
+	switch m := msg.(type) {
+	case NymSelfAddressRequest:
+		return []byte{binarySelfAddressRequestTag}, nil
+
+	case NymSend:
+		recipient, e := address.Parse(m.Recipient)
+		if nil != e {
+			return nil, e
+		}
+		frame := append([]byte{binarySendRequestTag}, recipient.Bytes()...)
+		// No connection id
+		frame = binary.BigEndian.AppendUint64(frame, 0)
+		return appendPayload(frame, m.Message), nil
+
+	case NymSendAnonymous:
+		recipient, e := address.Parse(m.Recipient)
+		if nil != e {
+			return nil, e
+		}
+		frame := append([]byte{binarySendAnonymousRequestTag}, recipient.Bytes()...)
+		frame = binary.BigEndian.AppendUint64(frame, 0)
+		frame = binary.BigEndian.AppendUint32(frame, uint32(m.ReplySurbs))
+		return appendPayload(frame, m.Message), nil
+
+	case NymReply:
+		senderTag, e := address.ParseSenderTag(m.SenderTag)
+		if nil != e {
+			return nil, e
+		}
+		frame := append([]byte{binaryReplyRequestTag}, senderTag[:]...)
+		frame = binary.BigEndian.AppendUint64(frame, 0)
+		return appendPayload(frame, m.Message), nil
+
+	case NymLaneQueueLengthRequest:
+		return binary.BigEndian.AppendUint64([]byte{binaryLaneQueueLengthTag}, m.ConnectionID), nil
+
+	default:
+		return nil, xerrors.Errorf("%v cannot be sent with the binary protocol", msg.Name())
+	}
+}
+
+// decodeBinaryResponse parses a frame sent by the nym-client with the binary protocol
+func decodeBinaryResponse(frame []byte) (NymMessage, error) {
+	if len(frame) == 0 {
+		return nil, xerrors.Errorf("empty binary frame")
+	}
+	tag, body := frame[0], frame[1:]
+
+	switch tag {
+	case binarySelfAddressResponseTag:
+		selfAddress, e := address.FromBytes(body)
+		if nil != e {
+			return nil, e
+		}
+		return NewSelfAddressReply(selfAddress.String()), nil
+
+	case binaryReceivedResponseTag:
+		if len(body) < 1 {
+			return nil, xerrors.Errorf("truncated received response")
+		}
+		hasSenderTag, body := 1 == body[0], body[1:]
+
+		senderTag := ""
+		if hasSenderTag {
+			if len(body) < address.SenderTagLength {
+				return nil, xerrors.Errorf("truncated sender tag")
+			}
+			var tag address.SenderTag
+			copy(tag[:], body)
+			senderTag, body = tag.String(), body[address.SenderTagLength:]
+		}
+
+		payload, e := readPayload(body)
+		if nil != e {
+			return nil, e
+		}
+		return NewNymReceived(payload, senderTag), nil
+
+	case binaryLaneQueueResponseTag:
+		if len(body) != 16 {
+			return nil, xerrors.Errorf("laneQueueLength response of %d bytes instead of 16", len(body))
+		}
+		return NewNymLaneQueueLength(binary.BigEndian.Uint64(body), binary.BigEndian.Uint64(body[8:])), nil
+
+	case binaryErrorResponseTag:
+		if len(body) < 1 {
+			return nil, xerrors.Errorf("truncated error response")
+		}
+		// The first byte holds the kind of error
+		message, e := readPayload(body[1:])
+		if nil != e {
+			return nil, e
+		}
+		return NewNymError(message), nil
+
+	default:
+		return nil, xerrors.Errorf("unknown binary response tag %d", tag)
+	}
+}
+
+// appendPayload appends the length-prefixed payload to frame
+func appendPayload(frame []byte, payload string) []byte {
+	frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	return append(frame, payload...)
+}
+
+// readPayload reads a length-prefixed payload spanning the rest of body
+func readPayload(body []byte) (string, error) {
+	if len(body) < 8 {
+		return "", xerrors.Errorf("truncated payload length")
+	}
+	length := binary.BigEndian.Uint64(body)
+	if length != uint64(len(body)-8) {
+		return "", xerrors.Errorf("payload of %d bytes announced but %d received", length, len(body)-8)
+	}
+	return string(body[8:]), nil
+}
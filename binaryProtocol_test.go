@@ -0,0 +1,76 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerBinaryProtocol(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger), lib.WithBinaryProtocol())
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+
+	// Payloads do not need to be valid UTF-8
+	payload := string([]byte{0x00, 0xff, 0xfe, '{'})
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(payload, nymSocketManager.GetNymClientId())))
+
+	select {
+	case received := <-receivedChan:
+		require.Equal(t, payload, received.Message)
+		require.Empty(t, received.SenderTag)
+		require.True(t, received.Binary)
+		require.WithinDuration(t, time.Now(), received.ReceivedAt, time.Second)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not looped back")
+	}
+}
+
+func TestNymSocketManagerWireEncodingPerMessage(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger), lib.WithWireEncoding(lib.EncodingAuto))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The fake nym-client answers with the encoding of the request
+	for _, message := range []struct {
+		payload        string
+		options        []lib.SendOption
+		expectedBinary bool
+	}{
+		{"text", nil, false},
+		{string([]byte{0xff, 0x00}), nil, true},
+		{"text", []lib.SendOption{lib.WithEncoding(lib.EncodingBinary)}, true},
+	} {
+		require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend(message.payload, nymSocketManager.GetNymClientId()), message.options...))
+
+		select {
+		case received := <-receivedChan:
+			require.Equal(t, message.payload, received.Message)
+			require.Equal(t, message.expectedBinary, received.Binary)
+		case <-time.After(time.Second):
+			require.Fail(t, "message not looped back")
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package nymsocketmanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BroadcastError is returned by SendToMany when the payload could not be sent to some of the recipients
+type BroadcastError struct {
+	// Error of each recipient the payload could not be sent to
+	Failures map[string]error
+}
+
+func (b *BroadcastError) Error() string {
+	recipients := make([]string, 0, len(b.Failures))
+	for recipient := range b.Failures {
+		recipients = append(recipients, recipient)
+	}
+	sort.Strings(recipients)
+
+	failures := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		failures = append(failures, fmt.Sprintf("%v: %v", recipient, b.Failures[recipient]))
+	}
+	return fmt.Sprintf("failed to send to %d recipient(s): %v", len(failures), strings.Join(failures, "; "))
+}
+
+// SendToMany sends payload to each of the recipients, at most at the rate set with WithBroadcastRate.
+// It goes on when the payload cannot be sent to a recipient, and returns a *BroadcastError listing them
+func (n *NymSocketManager) SendToMany(recipients []string, payload []byte) error {
+	var interval time.Duration
+	if n.broadcastRate > 0 {
+		interval = time.Duration(float64(time.Second) / n.broadcastRate)
+	}
+
+	failures := make(map[string]error)
+	sent := make(map[string]bool, len(recipients))
+	var lastSendAt time.Time
+	for _, recipient := range recipients {
+		if sent[recipient] {
+			continue
+		}
+		sent[recipient] = true
+
+		if wait := interval - time.Since(lastSendAt); wait > 0 {
+			time.Sleep(wait)
+		}
+		lastSendAt = time.Now()
+
+		e := n.Send(NewNymSend(string(payload), recipient))
+		if nil != e {
+			failures[recipient] = e
+		}
+	}
+
+	if len(failures) != 0 {
+		return &BroadcastError{failures}
+	}
+	n.messageLogger.Debug().Msgf("sent payload to %d recipient(s)", len(sent))
+	return nil
+}
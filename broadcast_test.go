@@ -0,0 +1,34 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerSendToMany(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithBroadcastRate(20))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	startedAt := time.Now()
+	e = nymSocketManager.SendToMany([]string{fakeNymClientAddress, "not an address", fakePeerAddress, fakePeerAddress}, []byte("hello"))
+	// Three distinct recipients, paced 50ms apart
+	require.GreaterOrEqual(t, time.Since(startedAt), 100*time.Millisecond)
+
+	var broadcastError *lib.BroadcastError
+	require.ErrorAs(t, e, &broadcastError)
+	require.Len(t, broadcastError.Failures, 1)
+	require.Contains(t, broadcastError.Failures, "not an address")
+
+	require.Eventually(t, func() bool { return 2 == fake.sendRequests.Load() }, time.Second, 10*time.Millisecond)
+}
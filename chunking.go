@@ -0,0 +1,200 @@
+package nymsocketmanager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// Marks the messages holding a chunk, followed by "<id>:<index>:<total>:<data>"
+	chunkPrefix = "\x1eNSMCHUNK:"
+	// Bounds the memory used to reassemble a single message
+	maxChunksPerMessage = 1 << 16
+	// Bound the memory used to reassemble all the messages, the chunks of the messages beyond are dropped
+	maxPendingChunkedMessages = 256
+	maxReassemblyBytes        = 32 << 20
+)
+
+// chunkReassembler collects the chunks of the large messages until they are complete
+type chunkReassembler struct {
+	sync.Mutex
+
+	// How long to wait for the next chunk of a message before dropping it
+	timeout time.Duration
+	pending map[string]*pendingMessage
+	// Bytes of the chunks of all the pending messages
+	size int
+}
+
+type pendingMessage struct {
+	// Allocated as the chunks are received, total being set by the sender
+	chunks map[int]string
+	total  int
+	size   int
+	timer  *time.Timer
+}
+
+// add stores a chunk and returns the reassembled message once all its chunks were received.
+// It returns an error if the chunk is dropped for lack of room
+func (r *chunkReassembler) add(id string, index int, total int, data string, onTimeout func(id string)) (string, bool, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if nil == r.pending {
+		r.pending = make(map[string]*pendingMessage)
+	}
+	if r.size+len(data) > maxReassemblyBytes {
+		return "", false, xerrors.Errorf("%d bytes are being reassembled already, at most %d", r.size, maxReassemblyBytes)
+	}
+
+	pending, ok := r.pending[id]
+	if !ok {
+		if len(r.pending) >= maxPendingChunkedMessages {
+			return "", false, xerrors.Errorf("%d messages are being reassembled already, at most %d", len(r.pending), maxPendingChunkedMessages)
+		}
+		pending = &pendingMessage{chunks: make(map[int]string), total: total}
+		pending.timer = time.AfterFunc(r.timeout, func() {
+			r.Lock()
+			timedOut := pending == r.pending[id]
+			if timedOut {
+				r.forget(id)
+			}
+			r.Unlock()
+			if timedOut {
+				onTimeout(id)
+			}
+		})
+		r.pending[id] = pending
+	} else {
+		pending.timer.Reset(r.timeout)
+	}
+
+	if _, duplicated := pending.chunks[index]; duplicated || pending.total != total {
+		// Duplicated chunk, or inconsistent with the previous ones
+		return "", false, nil
+	}
+	pending.chunks[index] = data
+	pending.size += len(data)
+	r.size += len(data)
+
+	if len(pending.chunks) < total {
+		return "", false, nil
+	}
+
+	pending.timer.Stop()
+	r.forget(id)
+	var message strings.Builder
+	message.Grow(pending.size)
+	for i := 0; i < total; i++ {
+		message.WriteString(pending.chunks[i])
+	}
+	return message.String(), true, nil
+}
+
+// forget drops a pending message
+// called from methods that already acquired the lock
+func (r *chunkReassembler) forget(id string) {
+	r.size -= r.pending[id].size
+	delete(r.pending, id)
+}
+
+// SendLarge sends message to recipient, split in chunks of the size set with WithChunking.
+// The chunks are reassembled by the receiving NymSocketManager, which passes the whole message to its messageHandler
+func (n *NymSocketManager) SendLarge(message string, recipient string) error {
+	if 0 == n.chunkSize {
+		err := xerrors.Errorf("chunking is not enabled, see WithChunking")
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	if len(message) <= n.chunkSize {
+		return n.Send(NewNymSend(message, recipient))
+	}
+
+	chunks := splitInChunks(message, n.chunkSize)
+	if len(chunks) > maxChunksPerMessage {
+		err := xerrors.Errorf("message too large: %d chunks needed, %d at most", len(chunks), maxChunksPerMessage)
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	id, e := newRandomID()
+	if nil != e {
+		n.logger.Warn().Msg(e.Error())
+		return e
+	}
+
+	for index, chunk := range chunks {
+		e = n.Send(NewNymSend(fmt.Sprintf("%s%s:%d:%d:%s", chunkPrefix, id, index, len(chunks), chunk), recipient))
+		if nil != e {
+			return e
+		}
+	}
+	n.messageLogger.Debug().Msgf("sent message %v in %d chunks", id, len(chunks))
+
+	return nil
+}
+
+// reassemble returns the message to pass to the messageHandler, false if it is an incomplete chunked message
+func (n *NymSocketManager) reassemble(msg NymReceived) (NymReceived, bool) {
+	if 0 == n.chunkSize || !strings.HasPrefix(msg.Message, chunkPrefix) {
+		return msg, true
+	}
+
+	fields := strings.SplitN(strings.TrimPrefix(msg.Message, chunkPrefix), ":", 4)
+	if len(fields) != 4 {
+		n.logger.Warn().Msg("dropping malformed chunk")
+		n.messageDropped(DropMalformed, msg, msg.MessageID, nil, "malformed chunk")
+		return msg, false
+	}
+	index, e1 := strconv.Atoi(fields[1])
+	total, e2 := strconv.Atoi(fields[2])
+	if nil != e1 || nil != e2 || total <= 0 || total > maxChunksPerMessage || index < 0 || index >= total {
+		n.logger.Warn().Msg("dropping malformed chunk")
+		n.messageDropped(DropMalformed, msg, msg.MessageID, nil, "malformed chunk")
+		return msg, false
+	}
+
+	// Chunks of anonymous messages carry the sender tag, which avoids mixing up the chunks of different senders
+	message, complete, e := n.chunks.add(msg.SenderTag+"/"+fields[0], index, total, fields[3], func(id string) {
+		n.logger.Warn().Msgf("dropping message %v: chunks missing after %v", id, n.chunks.timeout)
+		n.messageDropped(DropReassembly, nil, "", nil, fmt.Sprintf("chunks of message %v missing after %v", id, n.chunks.timeout))
+	})
+	if nil != e {
+		n.logger.Warn().Msgf("dropping chunk %d of message %v: %v", index, fields[0], e)
+		n.messageDropped(DropReassembly, msg, "", nil, e.Error())
+		return msg, false
+	}
+	if !complete {
+		return msg, false
+	}
+
+	msg.Message = message
+	msg.Chunks = total
+	return msg, true
+}
+
+// splitInChunks splits s in chunks of at most size bytes, without splitting its UTF-8 characters
+// so that the chunks survive the JSON protocol
+func splitInChunks(s string, size int) []string {
+	var chunks []string
+	for len(s) > size {
+		cut := size
+		for back := 0; back < utf8.UTFMax && cut > 1 && !utf8.RuneStart(s[cut]); back++ {
+			cut--
+		}
+		if !utf8.RuneStart(s[cut]) {
+			// Not UTF-8, as for payloads sent with the binary protocol
+			cut = size
+		}
+		chunks = append(chunks, s[:cut])
+		s = s[cut:]
+	}
+	return append(chunks, s)
+}
@@ -0,0 +1,86 @@
+package nymsocketmanager_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerSendLarge(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 2)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger), lib.WithChunking(16, time.Second))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// Multi-byte characters are not split across chunks
+	message := strings.Repeat("héllo wörld ", 20)
+	require.NoError(t, nymSocketManager.SendLarge(message, nymSocketManager.GetNymClientId()))
+
+	select {
+	case received := <-receivedChan:
+		require.Equal(t, message, received.Message)
+		require.Greater(t, received.Chunks, 1)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "message not reassembled")
+	}
+	require.Greater(t, fake.sendRequests.Load(), int32(len(message)/16))
+
+	// Small messages are sent as is
+	require.NoError(t, nymSocketManager.SendLarge("small", nymSocketManager.GetNymClientId()))
+	select {
+	case received := <-receivedChan:
+		require.Equal(t, "small", received.Message)
+		require.Equal(t, 1, received.Chunks)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+}
+
+func TestNymSocketManagerSendLargeNeedsChunking(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	require.Error(t, nymSocketManager.SendLarge("hello", fakePeerAddress))
+
+	_, e = lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger), lib.WithChunking(0, time.Second))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerChunkReassemblyBounded(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithChunking(16, 500*time.Millisecond))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The first chunk of more messages than can be reassembled at once, with the largest total announced
+	for i := 0; i < 257; i++ {
+		fake.deliver(map[string]interface{}{"type": "received", "message": fmt.Sprintf("\x1eNSMCHUNK:%d:0:65536:data", i)})
+	}
+	require.Eventually(t, func() bool {
+		return 1 == nymSocketManager.Stats().MessagesDropped[lib.DropReassembly]
+	}, time.Second, 10*time.Millisecond)
+
+	// The incomplete messages are dropped at the timeout
+	require.Eventually(t, func() bool {
+		return 257 == nymSocketManager.Stats().MessagesDropped[lib.DropReassembly]
+	}, 2*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,68 @@
+package nymsocketmanager
+
+import (
+	"golang.org/x/xerrors"
+)
+
+// ClientEncoding identifies the encoding the nym-client answered the selfAddress request with.
+// Only the encoding is detected: the nym-client does not report the version of its websocket API, so none is negotiated,
+// and a schema the library does not understand fails Start with ErrSchemaMismatch
+type ClientEncoding int32
+
+const (
+	// Not detected yet
+	ClientEncodingUnknown ClientEncoding = iota
+	// JSON API of the nym-client
+	ClientEncodingJSON
+	// Binary API of the nym-client
+	ClientEncodingBinary
+)
+
+func (c ClientEncoding) String() string {
+	switch c {
+	case ClientEncodingUnknown:
+		return "Unknown"
+	case ClientEncodingJSON:
+		return "JSON"
+	case ClientEncodingBinary:
+		return "Binary"
+	default:
+		return "Unsupported"
+	}
+}
+
+// ErrUnsupportedEncoding is returned by Start when the nym-client answers with an encoding other than the one required with WithClientEncoding
+var ErrUnsupportedEncoding = xerrors.New("unsupported nym-client encoding")
+
+// ErrSchemaMismatch is returned by Start when the selfAddress reply of the nym-client does not match the schema of the library,
+// e.g. its address field was renamed or retyped by another nym-client release
+var ErrSchemaMismatch = xerrors.New("nym-client schema mismatch")
+
+// detectClientEncoding tells which encoding the nym-client used to answer the selfAddress request
+func detectClientEncoding(binaryFrame bool, reply NymSelfAddressReply) ClientEncoding {
+	switch {
+	case binaryFrame:
+		return ClientEncodingBinary
+	// A renamed or retyped address field leaves it empty
+	case len(reply.Address) != 0:
+		return ClientEncodingJSON
+	default:
+		return ClientEncodingUnknown
+	}
+}
+
+// checkClientEncoding validates the encoding detected during the handshake
+func (n *NymSocketManager) checkClientEncoding(encoding ClientEncoding) error {
+	if ClientEncodingUnknown == encoding {
+		return xerrors.Errorf("%w: selfAddress reply without address", ErrSchemaMismatch)
+	}
+	if ClientEncodingUnknown != n.requiredClientEncoding && encoding != n.requiredClientEncoding {
+		return xerrors.Errorf("%w: nym-client answered with %v, %v required", ErrUnsupportedEncoding, encoding, n.requiredClientEncoding)
+	}
+	return nil
+}
+
+// GetClientEncoding returns the encoding detected during the last handshake with the nym-client
+func (n *NymSocketManager) GetClientEncoding() ClientEncoding {
+	return ClientEncoding(n.clientEncoding.Load())
+}
@@ -0,0 +1,61 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerDetectsClientEncoding(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	require.Equal(t, lib.ClientEncodingUnknown, nymSocketManager.GetClientEncoding())
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	require.Equal(t, lib.ClientEncodingJSON, nymSocketManager.GetClientEncoding())
+	nymSocketManager.Stop()
+
+	nymSocketManager, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithBinaryProtocol(), lib.WithClientEncoding(lib.ClientEncodingBinary))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	require.Equal(t, lib.ClientEncodingBinary, nymSocketManager.GetClientEncoding())
+	nymSocketManager.Stop()
+}
+
+func TestNymSocketManagerRejectsUnsupportedEncoding(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	// The encoding answered does not match the one required
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithClientEncoding(lib.ClientEncodingBinary))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.ErrorIs(t, e, lib.ErrUnsupportedEncoding)
+	require.False(t, nymSocketManager.IsRunning())
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithClientEncoding(lib.ClientEncodingUnknown))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerRejectsSchemaMismatch(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	// The address field was renamed
+	fake.selfAddressReply = map[string]interface{}{"type": "selfAddress", "recipient": fakeNymClientAddress}
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.ErrorIs(t, e, lib.ErrSchemaMismatch)
+	require.NotErrorIs(t, e, lib.ErrUnsupportedEncoding)
+	require.Equal(t, lib.ClientEncodingUnknown, nymSocketManager.GetClientEncoding())
+}
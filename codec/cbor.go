@@ -0,0 +1,181 @@
+package codec
+
+import (
+	"math"
+
+	"golang.org/x/xerrors"
+)
+
+// Major types of the CBOR items
+const (
+	cborUint byte = iota
+	cborNegativeInt
+	cborBytes
+	cborString
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// cborFormat writes the CBOR items with definite lengths, in their shortest form.
+// The tags are ignored when reading, the items they apply to are decoded as is
+type cborFormat struct{}
+
+// appendHead appends the first bytes of an item of the major type, carrying n
+func appendHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return appendUint(append(b, major|24), n, 1)
+	case n <= math.MaxUint16:
+		return appendUint(append(b, major|25), n, 2)
+	case n <= math.MaxUint32:
+		return appendUint(append(b, major|26), n, 4)
+	default:
+		return appendUint(append(b, major|27), n, 8)
+	}
+}
+
+func (cborFormat) appendNil(b []byte) []byte {
+	return append(b, 0xf6)
+}
+
+func (cborFormat) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xf5)
+	}
+	return append(b, 0xf4)
+}
+
+func (cborFormat) appendInt(b []byte, v int64) []byte {
+	if v >= 0 {
+		return appendHead(b, cborUint, uint64(v))
+	}
+	// -1 - v, which cannot overflow unlike -v
+	return appendHead(b, cborNegativeInt, uint64(^v))
+}
+
+func (cborFormat) appendUint(b []byte, v uint64) []byte {
+	return appendHead(b, cborUint, v)
+}
+
+func (cborFormat) appendFloat(b []byte, v float64, bits int) []byte {
+	if 32 == bits {
+		return appendUint(append(b, 0xfa), uint64(math.Float32bits(float32(v))), 4)
+	}
+	return appendUint(append(b, 0xfb), math.Float64bits(v), 8)
+}
+
+func (cborFormat) appendString(b []byte, v string) []byte {
+	return append(appendHead(b, cborString, uint64(len(v))), v...)
+}
+
+func (cborFormat) appendBytes(b []byte, v []byte) []byte {
+	return append(appendHead(b, cborBytes, uint64(len(v))), v...)
+}
+
+func (cborFormat) appendArrayHeader(b []byte, n int) []byte {
+	return appendHead(b, cborArray, uint64(n))
+}
+
+func (cborFormat) appendMapHeader(b []byte, n int) []byte {
+	return appendHead(b, cborMap, uint64(n))
+}
+
+func (cborFormat) readItem(data []byte) (item, []byte, error) {
+	for {
+		if len(data) == 0 {
+			return item{}, nil, errTruncated
+		}
+		major, info := data[0]>>5, data[0]&0x1f
+		data = data[1:]
+
+		if cborSimple == major {
+			return readCBORSimple(info, data)
+		}
+
+		var n uint64
+		switch {
+		case info < 24:
+			n = uint64(info)
+		case info <= 27:
+			var e error
+			n, data, e = readUint(data, 1<<(info-24))
+			if nil != e {
+				return item{}, nil, e
+			}
+		case 31 == info:
+			return item{}, nil, xerrors.Errorf("indefinite lengths are not supported")
+		default:
+			return item{}, nil, xerrors.Errorf("invalid additional information %d", info)
+		}
+
+		switch major {
+		case cborUint:
+			return item{kind: kindUint, u: n}, data, nil
+		case cborNegativeInt:
+			if n > math.MaxInt64 {
+				return item{}, nil, xerrors.Errorf("-1-%d overflows int64", n)
+			}
+			return item{kind: kindInt, i: -1 - int64(n)}, data, nil
+		case cborBytes:
+			return readContent(kindBytes, n, data)
+		case cborString:
+			return readContent(kindString, n, data)
+		case cborArray:
+			return readContainer(kindArray, n, data)
+		case cborMap:
+			return readContainer(kindMap, n, data)
+		}
+		// Tag, followed by the item it applies to
+	}
+}
+
+// readCBORSimple reads the simple values and the floats
+func readCBORSimple(info byte, data []byte) (item, []byte, error) {
+	switch info {
+	case 20, 21:
+		return item{kind: kindBool, b: 21 == info}, data, nil
+	// null and undefined
+	case 22, 23:
+		return item{kind: kindNil}, data, nil
+	case 25:
+		u, data, e := readUint(data, 2)
+		return item{kind: kindFloat, f: float16(uint16(u))}, data, e
+	case 26:
+		u, data, e := readUint(data, 4)
+		return item{kind: kindFloat, f: float64(math.Float32frombits(uint32(u)))}, data, e
+	case 27:
+		u, data, e := readUint(data, 8)
+		return item{kind: kindFloat, f: math.Float64frombits(u)}, data, e
+	default:
+		return item{}, nil, xerrors.Errorf("unsupported simple value %d", info)
+	}
+}
+
+// float16 converts a half-precision float, which CBOR encoders use for the floats that fit
+func float16(h uint16) float64 {
+	exponent, mantissa := int(h>>10&0x1f), float64(h&0x3ff)
+
+	var f float64
+	switch exponent {
+	case 0:
+		f = math.Ldexp(mantissa, -24)
+	case 0x1f:
+		if 0 == mantissa {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mantissa+1024, exponent-25)
+	}
+
+	if 0 != h&0x8000 {
+		return -f
+	}
+	return f
+}
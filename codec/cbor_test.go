@@ -0,0 +1,67 @@
+package codec_test
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/notrustverify/nymsocketmanager/codec"
+	"github.com/stretchr/testify/require"
+)
+
+// Examples of the appendix A of RFC 8949
+func TestCBOREncoding(t *testing.T) {
+	for _, test := range []struct {
+		value   interface{}
+		encoded string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{1000, "1903e8"},
+		{uint64(18446744073709551615), "1bffffffffffffffff"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{1.1, "fb3ff199999999999a"},
+		{false, "f4"},
+		{nil, "f6"},
+		{"IETF", "6449455446"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]interface{}{1, []int{2, 3}}, "8201820203"},
+		{map[string]int{"a": 1}, "a1616101"},
+	} {
+		encoded, e := codec.CBOR.Marshal(test.value)
+		require.NoError(t, e)
+		require.Equal(t, test.encoded, hex.EncodeToString(encoded), test.value)
+	}
+}
+
+func TestCBORDecoding(t *testing.T) {
+	for encoded, expected := range map[string]interface{}{
+		"3bffffffffffffffff": nil,
+		"f93e00":             1.5,
+		"f90001":             5.960464477539063e-8,
+		"f9c400":             -4.0,
+		"f97c00":             math.Inf(1),
+		"fa47c35000":         100000.0,
+		// Tag 1, epoch-based date/time
+		"c11a514b67b0": int64(1363896240),
+		"f7":           nil,
+	} {
+		data, e := hex.DecodeString(encoded)
+		require.NoError(t, e)
+
+		var decoded interface{}
+		e = codec.CBOR.Unmarshal(data, &decoded)
+		if nil == expected && "f7" != encoded {
+			require.ErrorContains(t, e, "overflows", encoded)
+			continue
+		}
+		require.NoError(t, e, encoded)
+		require.Equal(t, expected, decoded, encoded)
+	}
+
+	var decoded interface{}
+	require.ErrorContains(t, codec.CBOR.Unmarshal([]byte{0x9f, 0x01, 0xff}, &decoded), "indefinite")
+	require.ErrorContains(t, codec.CBOR.Unmarshal([]byte{0xe0}, &decoded), "unsupported simple value")
+}
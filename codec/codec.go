@@ -0,0 +1,75 @@
+// Package codec encodes the application payloads carried by the messages of the mixnet.
+// Msgpack and CBOR are more compact than JSON, which matters given the size of the Sphinx packets
+package codec
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"golang.org/x/xerrors"
+)
+
+// Codec turns values into payloads and back
+type Codec interface {
+	// Name identifies the codec, e.g. "json"
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes data into v, which must be a non-nil pointer
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSON encodes the payloads with encoding/json
+	JSON Codec = jsonCodec{}
+	// Msgpack encodes the payloads in the MessagePack format, see https://msgpack.org
+	Msgpack Codec = binaryCodec{name: "msgpack", format: msgpackFormat{}}
+	// CBOR encodes the payloads in the Concise Binary Object Representation of RFC 8949
+	CBOR Codec = binaryCodec{name: "cbor", format: cborFormat{}}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// binaryCodec maps the Go values on the items of a binary format, the way encoding/json does:
+// structs are encoded as maps keyed by the name of their exported fields, which can be set with a `json` tag,
+// and decoded into interface{} as map[string]interface{}, []interface{}, int64, uint64, float64, string, []byte, bool or nil
+type binaryCodec struct {
+	name   string
+	format format
+}
+
+func (c binaryCodec) Name() string {
+	return c.name
+}
+
+func (c binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	return encode(c.format, nil, reflect.ValueOf(v))
+}
+
+func (c binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	value := reflect.ValueOf(v)
+	if reflect.Ptr != value.Kind() || value.IsNil() {
+		return xerrors.Errorf("%v: cannot decode into %T, a non-nil pointer is needed", c.name, v)
+	}
+
+	d := decoder{format: c.format, data: data}
+	e := d.decode(value.Elem())
+	if nil != e {
+		return xerrors.Errorf("%v: %w", c.name, e)
+	}
+	if len(d.data) != 0 {
+		return xerrors.Errorf("%v: %d bytes left after the payload", c.name, len(d.data))
+	}
+	return nil
+}
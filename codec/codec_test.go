@@ -0,0 +1,150 @@
+package codec_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/notrustverify/nymsocketmanager/codec"
+	"github.com/stretchr/testify/require"
+)
+
+type header struct {
+	Version int `json:"version"`
+}
+
+type payload struct {
+	header
+	Kind     string            `json:"kind"`
+	Count    int64             `json:"count"`
+	Ratio    float64           `json:"ratio"`
+	Small    float32           `json:"small"`
+	Unsigned uint16            `json:"unsigned"`
+	Enabled  bool              `json:"enabled"`
+	Data     []byte            `json:"data"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]int    `json:"labels"`
+	Next     *payload          `json:"next,omitempty"`
+	Any      interface{}       `json:"any"`
+	Ignored  string            `json:"-"`
+	Extra    map[string]string `json:"extra,omitempty"`
+}
+
+func samplePayload() payload {
+	return payload{
+		header:   header{Version: 2},
+		Kind:     "sample",
+		Count:    -1 << 40,
+		Ratio:    0.25,
+		Small:    1.5,
+		Unsigned: 65535,
+		Enabled:  true,
+		Data:     []byte{0, 1, 0xff},
+		Tags:     []string{"a", "b"},
+		Labels:   map[string]int{"x": 1, "y": -300},
+		Next:     &payload{Kind: "next", Tags: []string{}},
+		Any:      []interface{}{"c", int64(3)},
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	for _, c := range []codec.Codec{codec.JSON, codec.Msgpack, codec.CBOR} {
+		original := samplePayload()
+		original.Ignored = "dropped"
+
+		encoded, e := c.Marshal(original)
+		require.NoError(t, e, c.Name())
+
+		var decoded payload
+		require.NoError(t, c.Unmarshal(encoded, &decoded), c.Name())
+		expected := samplePayload()
+		if codec.JSON == c {
+			// encoding/json decodes the numbers into interface{} as float64
+			expected.Any = []interface{}{"c", float64(3)}
+		}
+		require.Equal(t, expected, decoded, c.Name())
+	}
+}
+
+func TestBinaryCodecsAreSmaller(t *testing.T) {
+	encoded, e := codec.JSON.Marshal(samplePayload())
+	require.NoError(t, e)
+	for _, c := range []codec.Codec{codec.Msgpack, codec.CBOR} {
+		binary, e := c.Marshal(samplePayload())
+		require.NoError(t, e)
+		require.Less(t, len(binary), len(encoded), c.Name())
+	}
+}
+
+func TestBinaryCodecsGeneric(t *testing.T) {
+	for _, c := range []codec.Codec{codec.Msgpack, codec.CBOR} {
+		encoded, e := c.Marshal(map[string]interface{}{
+			"kind":  "generic",
+			"list":  []interface{}{uint64(1 << 63), -2, 0.5, nil, []byte("raw")},
+			"inner": map[int]bool{1: true},
+		})
+		require.NoError(t, e, c.Name())
+
+		var decoded interface{}
+		require.NoError(t, c.Unmarshal(encoded, &decoded), c.Name())
+		require.Equal(t, map[string]interface{}{
+			"kind":  "generic",
+			"list":  []interface{}{uint64(1 << 63), int64(-2), 0.5, nil, []byte("raw")},
+			"inner": map[interface{}]interface{}{int64(1): true},
+		}, decoded, c.Name())
+	}
+}
+
+func TestBinaryCodecsDeterministic(t *testing.T) {
+	labels := map[string]int{}
+	for _, key := range []string{"d", "a", "c", "b", "e"} {
+		labels[key] = len(labels)
+	}
+	for _, c := range []codec.Codec{codec.Msgpack, codec.CBOR} {
+		first, e := c.Marshal(labels)
+		require.NoError(t, e)
+		for i := 0; i < 10; i++ {
+			again, e := c.Marshal(labels)
+			require.NoError(t, e)
+			require.True(t, bytes.Equal(first, again), c.Name())
+		}
+	}
+}
+
+func TestBinaryCodecsErrors(t *testing.T) {
+	for _, c := range []codec.Codec{codec.Msgpack, codec.CBOR} {
+		encoded, e := c.Marshal(samplePayload())
+		require.NoError(t, e)
+
+		var decoded payload
+		require.ErrorContains(t, c.Unmarshal(encoded, decoded), "non-nil pointer", c.Name())
+		require.ErrorContains(t, c.Unmarshal(encoded[:len(encoded)-1], &decoded), "truncated", c.Name())
+		require.ErrorContains(t, c.Unmarshal(append(encoded, encoded...), &decoded), "bytes left", c.Name())
+
+		var count int8
+		encoded, e = c.Marshal(1000)
+		require.NoError(t, e)
+		require.ErrorContains(t, c.Unmarshal(encoded, &count), "overflows", c.Name())
+
+		var kind string
+		require.ErrorContains(t, c.Unmarshal(encoded, &kind), "cannot decode integer into string", c.Name())
+
+		_, e = c.Marshal(make(chan int))
+		require.Error(t, e, c.Name())
+
+		cyclic := []interface{}{nil}
+		cyclic[0] = cyclic
+		_, e = c.Marshal(cyclic)
+		require.ErrorContains(t, e, "nested", c.Name())
+	}
+}
+
+func TestBinaryCodecsUnknownFields(t *testing.T) {
+	for _, c := range []codec.Codec{codec.Msgpack, codec.CBOR} {
+		encoded, e := c.Marshal(map[string]interface{}{"KIND": "folded", "unknown": []interface{}{map[string]int{"deep": 1}}})
+		require.NoError(t, e)
+
+		var decoded payload
+		require.NoError(t, c.Unmarshal(encoded, &decoded), c.Name())
+		require.Equal(t, "folded", decoded.Kind, c.Name())
+	}
+}
@@ -0,0 +1,190 @@
+package codec
+
+import (
+	"math"
+
+	"golang.org/x/xerrors"
+)
+
+// msgpackFormat writes the MessagePack items in their shortest form. The extension types are not supported
+type msgpackFormat struct{}
+
+func (msgpackFormat) appendNil(b []byte) []byte {
+	return append(b, 0xc0)
+}
+
+func (msgpackFormat) appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 0xc3)
+	}
+	return append(b, 0xc2)
+}
+
+func (m msgpackFormat) appendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return m.appendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return appendUint(append(b, 0xd0), uint64(v), 1)
+	case v >= math.MinInt16:
+		return appendUint(append(b, 0xd1), uint64(v), 2)
+	case v >= math.MinInt32:
+		return appendUint(append(b, 0xd2), uint64(v), 4)
+	default:
+		return appendUint(append(b, 0xd3), uint64(v), 8)
+	}
+}
+
+func (msgpackFormat) appendUint(b []byte, v uint64) []byte {
+	switch {
+	case v <= 0x7f:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return appendUint(append(b, 0xcc), v, 1)
+	case v <= math.MaxUint16:
+		return appendUint(append(b, 0xcd), v, 2)
+	case v <= math.MaxUint32:
+		return appendUint(append(b, 0xce), v, 4)
+	default:
+		return appendUint(append(b, 0xcf), v, 8)
+	}
+}
+
+func (msgpackFormat) appendFloat(b []byte, v float64, bits int) []byte {
+	if 32 == bits {
+		return appendUint(append(b, 0xca), uint64(math.Float32bits(float32(v))), 4)
+	}
+	return appendUint(append(b, 0xcb), math.Float64bits(v), 8)
+}
+
+func (msgpackFormat) appendString(b []byte, v string) []byte {
+	n := uint64(len(v))
+	switch {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = appendUint(append(b, 0xd9), n, 1)
+	case n <= math.MaxUint16:
+		b = appendUint(append(b, 0xda), n, 2)
+	default:
+		b = appendUint(append(b, 0xdb), n, 4)
+	}
+	return append(b, v...)
+}
+
+func (msgpackFormat) appendBytes(b []byte, v []byte) []byte {
+	n := uint64(len(v))
+	switch {
+	case n <= math.MaxUint8:
+		b = appendUint(append(b, 0xc4), n, 1)
+	case n <= math.MaxUint16:
+		b = appendUint(append(b, 0xc5), n, 2)
+	default:
+		b = appendUint(append(b, 0xc6), n, 4)
+	}
+	return append(b, v...)
+}
+
+func (msgpackFormat) appendArrayHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint(append(b, 0xdc), uint64(n), 2)
+	default:
+		return appendUint(append(b, 0xdd), uint64(n), 4)
+	}
+}
+
+func (msgpackFormat) appendMapHeader(b []byte, n int) []byte {
+	switch {
+	case n < 16:
+		return append(b, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		return appendUint(append(b, 0xde), uint64(n), 2)
+	default:
+		return appendUint(append(b, 0xdf), uint64(n), 4)
+	}
+}
+
+func (msgpackFormat) readItem(data []byte) (item, []byte, error) {
+	if len(data) == 0 {
+		return item{}, nil, errTruncated
+	}
+	c, data := data[0], data[1:]
+
+	switch {
+	case c <= 0x7f:
+		return item{kind: kindUint, u: uint64(c)}, data, nil
+	case c >= 0xe0:
+		return item{kind: kindInt, i: int64(int8(c))}, data, nil
+	case c <= 0x8f:
+		return readContainer(kindMap, uint64(c&0x0f), data)
+	case c <= 0x9f:
+		return readContainer(kindArray, uint64(c&0x0f), data)
+	case c <= 0xbf:
+		return readContent(kindString, uint64(c&0x1f), data)
+	}
+
+	switch c {
+	case 0xc0:
+		return item{kind: kindNil}, data, nil
+	case 0xc2, 0xc3:
+		return item{kind: kindBool, b: 0xc3 == c}, data, nil
+
+	case 0xc4, 0xc5, 0xc6:
+		n, data, e := readUint(data, 1<<(c-0xc4))
+		if nil != e {
+			return item{}, nil, e
+		}
+		return readContent(kindBytes, n, data)
+
+	case 0xca:
+		u, data, e := readUint(data, 4)
+		return item{kind: kindFloat, f: float64(math.Float32frombits(uint32(u)))}, data, e
+	case 0xcb:
+		u, data, e := readUint(data, 8)
+		return item{kind: kindFloat, f: math.Float64frombits(u)}, data, e
+
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, data, e := readUint(data, 1<<(c-0xcc))
+		return item{kind: kindUint, u: u}, data, e
+
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, data, e := readUint(data, size)
+		// Sign extension of the size bytes read
+		shift := 64 - 8*size
+		i := int64(u<<shift) >> shift
+		if i >= 0 {
+			return item{kind: kindUint, u: uint64(i)}, data, e
+		}
+		return item{kind: kindInt, i: i}, data, e
+
+	case 0xd9, 0xda, 0xdb:
+		n, data, e := readUint(data, 1<<(c-0xd9))
+		if nil != e {
+			return item{}, nil, e
+		}
+		return readContent(kindString, n, data)
+
+	case 0xdc, 0xdd:
+		n, data, e := readUint(data, 2<<(c-0xdc))
+		if nil != e {
+			return item{}, nil, e
+		}
+		return readContainer(kindArray, n, data)
+
+	case 0xde, 0xdf:
+		n, data, e := readUint(data, 2<<(c-0xde))
+		if nil != e {
+			return item{}, nil, e
+		}
+		return readContainer(kindMap, n, data)
+
+	default:
+		return item{}, nil, xerrors.Errorf("unsupported type 0x%02x", c)
+	}
+}
@@ -0,0 +1,57 @@
+package codec_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/notrustverify/nymsocketmanager/codec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMsgpackEncoding(t *testing.T) {
+	for _, test := range []struct {
+		value   interface{}
+		encoded string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{1, "01"},
+		{-1, "ff"},
+		{-33, "d0df"},
+		{256, "cd0100"},
+		{-40000, "d2ffff63c0"},
+		{uint64(1 << 32), "cf0000000100000000"},
+		{1.5, "cb3ff8000000000000"},
+		{float32(1.5), "ca3fc00000"},
+		{"a", "a161"},
+		{[]byte{1}, "c40101"},
+		{[]int{1, 2}, "920102"},
+		{map[string]int{"a": 1}, "81a16101"},
+	} {
+		encoded, e := codec.Msgpack.Marshal(test.value)
+		require.NoError(t, e)
+		require.Equal(t, test.encoded, hex.EncodeToString(encoded), test.value)
+	}
+}
+
+func TestMsgpackDecoding(t *testing.T) {
+	for encoded, expected := range map[string]interface{}{
+		"d1ff00":             int64(-256),
+		"d3ffffffffffffffff": int64(-1),
+		"d001":               int64(1),
+		"d90161":             "a",
+		"dc0001c3":           []interface{}{true},
+		"de0001a161c2":       map[string]interface{}{"a": false},
+	} {
+		data, e := hex.DecodeString(encoded)
+		require.NoError(t, e)
+
+		var decoded interface{}
+		require.NoError(t, codec.Msgpack.Unmarshal(data, &decoded), encoded)
+		require.Equal(t, expected, decoded, encoded)
+	}
+
+	var decoded interface{}
+	require.ErrorContains(t, codec.Msgpack.Unmarshal([]byte{0xd4, 0x01, 0x00}, &decoded), "unsupported type 0xd4")
+	require.ErrorContains(t, codec.Msgpack.Unmarshal([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}, &decoded), "truncated")
+}
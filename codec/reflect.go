@@ -0,0 +1,657 @@
+package codec
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Nesting depth beyond which the values are rejected, to bound the recursion on cyclic or hostile inputs
+const maxDepth = 1000
+
+var errTruncated = xerrors.New("payload is truncated")
+
+// format writes and reads the items of a binary format
+type format interface {
+	appendNil(b []byte) []byte
+	appendBool(b []byte, v bool) []byte
+	appendInt(b []byte, v int64) []byte
+	appendUint(b []byte, v uint64) []byte
+	// bits is 32 for the float32 values, which are written in single precision
+	appendFloat(b []byte, v float64, bits int) []byte
+	appendString(b []byte, v string) []byte
+	appendBytes(b []byte, v []byte) []byte
+	appendArrayHeader(b []byte, n int) []byte
+	appendMapHeader(b []byte, n int) []byte
+
+	// readItem reads the next item of data, and returns the data left after it.
+	// The elements of the arrays and the pairs of the maps follow their header
+	readItem(data []byte) (item, []byte, error)
+}
+
+type itemKind int
+
+const (
+	kindNil itemKind = iota
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindString
+	kindBytes
+	kindArray
+	kindMap
+)
+
+func (k itemKind) String() string {
+	switch k {
+	case kindNil:
+		return "nil"
+	case kindBool:
+		return "boolean"
+	case kindInt, kindUint:
+		return "integer"
+	case kindFloat:
+		return "float"
+	case kindString:
+		return "string"
+	case kindBytes:
+		return "bytes"
+	case kindArray:
+		return "array"
+	case kindMap:
+		return "map"
+	default:
+		return "unknown"
+	}
+}
+
+// item is a value read from a payload. Only the field matching its kind is set
+type item struct {
+	kind itemKind
+	b    bool
+	// Negative integers, the positive ones are kindUint
+	i int64
+	u uint64
+	f float64
+	// Content of the strings and bytes, pointing into the payload
+	s []byte
+	// Number of elements of the arrays, of pairs of the maps
+	n int
+}
+
+func (it item) int64() (int64, error) {
+	switch it.kind {
+	case kindInt:
+		return it.i, nil
+	case kindUint:
+		if it.u > math.MaxInt64 {
+			return 0, xerrors.Errorf("%d overflows int64", it.u)
+		}
+		return int64(it.u), nil
+	default:
+		return 0, xerrors.Errorf("expected an integer, got %v", it.kind)
+	}
+}
+
+func (it item) uint64() (uint64, error) {
+	switch it.kind {
+	case kindUint:
+		return it.u, nil
+	case kindInt:
+		return 0, xerrors.Errorf("%d is negative", it.i)
+	default:
+		return 0, xerrors.Errorf("expected an integer, got %v", it.kind)
+	}
+}
+
+func (it item) float64() (float64, error) {
+	switch it.kind {
+	case kindFloat:
+		return it.f, nil
+	case kindInt:
+		return float64(it.i), nil
+	case kindUint:
+		return float64(it.u), nil
+	default:
+		return 0, xerrors.Errorf("expected a number, got %v", it.kind)
+	}
+}
+
+// readUint reads a big-endian unsigned integer of size bytes
+func readUint(data []byte, size int) (uint64, []byte, error) {
+	if len(data) < size {
+		return 0, nil, errTruncated
+	}
+	var u uint64
+	for _, c := range data[:size] {
+		u = u<<8 | uint64(c)
+	}
+	return u, data[size:], nil
+}
+
+func appendUint(b []byte, u uint64, size int) []byte {
+	for shift := 8 * (size - 1); shift >= 0; shift -= 8 {
+		b = append(b, byte(u>>shift))
+	}
+	return b
+}
+
+// readContent reads the n bytes of a string or bytes item
+func readContent(kind itemKind, n uint64, data []byte) (item, []byte, error) {
+	if n > uint64(len(data)) {
+		return item{}, nil, errTruncated
+	}
+	return item{kind: kind, s: data[:n]}, data[n:], nil
+}
+
+// readContainer returns the header of an array or a map of n elements or pairs, each taking one byte at least
+func readContainer(kind itemKind, n uint64, data []byte) (item, []byte, error) {
+	available := uint64(len(data))
+	if kindMap == kind {
+		available /= 2
+	}
+	if n > available {
+		return item{}, nil, errTruncated
+	}
+	return item{kind: kind, n: int(n)}, data, nil
+}
+
+// field is an exported field of a struct, encoded as a key of its map
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldsCache sync.Map
+
+// fieldsOf returns the fields of a struct type, named after their `json` tag if any.
+// The fields of the embedded structs are promoted, unless the struct has a field of the same name
+func fieldsOf(t reflect.Type) []field {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.([]field)
+	}
+
+	var fields []field
+	var embedded []field
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+		tag := structField.Tag.Get("json")
+		if "-" == tag {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if structField.Anonymous && len(name) == 0 && reflect.Struct == structField.Type.Kind() {
+			for _, promoted := range fieldsOf(structField.Type) {
+				promoted.index = append([]int{i}, promoted.index...)
+				embedded = append(embedded, promoted)
+			}
+			continue
+		}
+		if !structField.IsExported() {
+			continue
+		}
+
+		if len(name) == 0 {
+			name = structField.Name
+		}
+		fields = append(fields, field{name: name, index: []int{i}, omitEmpty: strings.Contains(","+options+",", ",omitempty,")})
+	}
+
+	for _, promoted := range embedded {
+		if nil == findField(fields, promoted.name, false) {
+			fields = append(fields, promoted)
+		}
+	}
+
+	fieldsCache.Store(t, fields)
+	return fields
+}
+
+// findField returns the field of the given name, compared regardless of the case if foldCase
+func findField(fields []field, name string, foldCase bool) *field {
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	if foldCase {
+		for i := range fields {
+			if strings.EqualFold(fields[i].name, name) {
+				return &fields[i]
+			}
+		}
+	}
+	return nil
+}
+
+// isEmpty tells whether v is omitted by omitempty, as with encoding/json
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return 0 == v.Len()
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return 0 == v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return 0 == v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return 0 == v.Float()
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	default:
+		return false
+	}
+}
+
+// encode appends v to b in the given format
+func encode(f format, b []byte, v reflect.Value) ([]byte, error) {
+	return encodeValue(f, b, v, 0)
+}
+
+func encodeValue(f format, b []byte, v reflect.Value, depth int) ([]byte, error) {
+	if depth > maxDepth {
+		return nil, xerrors.Errorf("value is nested more than %d levels deep", maxDepth)
+	}
+	depth++
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return f.appendNil(b), nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return f.appendNil(b), nil
+		}
+		return encodeValue(f, b, v.Elem(), depth)
+	case reflect.Bool:
+		return f.appendBool(b, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return f.appendInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return f.appendUint(b, v.Uint()), nil
+	case reflect.Float32:
+		return f.appendFloat(b, v.Float(), 32), nil
+	case reflect.Float64:
+		return f.appendFloat(b, v.Float(), 64), nil
+	case reflect.String:
+		return f.appendString(b, v.String()), nil
+
+	case reflect.Slice, reflect.Array:
+		if reflect.Slice == v.Kind() && v.IsNil() {
+			return f.appendNil(b), nil
+		}
+		if reflect.Uint8 == v.Type().Elem().Kind() {
+			content := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(content), v)
+			return f.appendBytes(b, content), nil
+		}
+		b = f.appendArrayHeader(b, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			var e error
+			b, e = encodeValue(f, b, v.Index(i), depth)
+			if nil != e {
+				return nil, e
+			}
+		}
+		return b, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return f.appendNil(b), nil
+		}
+		// The keys are sorted, so that a value always gets the same payload
+		keys := make([][]byte, 0, v.Len())
+		values := make([]reflect.Value, 0, v.Len())
+		iterator := v.MapRange()
+		for iterator.Next() {
+			key, e := encodeValue(f, nil, iterator.Key(), depth)
+			if nil != e {
+				return nil, e
+			}
+			keys = append(keys, key)
+			values = append(values, iterator.Value())
+		}
+		order := make([]int, len(keys))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(i, j int) bool { return bytes.Compare(keys[order[i]], keys[order[j]]) < 0 })
+
+		b = f.appendMapHeader(b, len(keys))
+		for _, i := range order {
+			var e error
+			b, e = encodeValue(f, append(b, keys[i]...), values[i], depth)
+			if nil != e {
+				return nil, e
+			}
+		}
+		return b, nil
+
+	case reflect.Struct:
+		fields := fieldsOf(v.Type())
+		present := make([]reflect.Value, len(fields))
+		count := 0
+		for i, field := range fields {
+			value := v.FieldByIndex(field.index)
+			if field.omitEmpty && isEmpty(value) {
+				continue
+			}
+			present[i] = value
+			count++
+		}
+
+		b = f.appendMapHeader(b, count)
+		for i, field := range fields {
+			if !present[i].IsValid() {
+				continue
+			}
+			var e error
+			b, e = encodeValue(f, f.appendString(b, field.name), present[i], depth)
+			if nil != e {
+				return nil, e
+			}
+		}
+		return b, nil
+
+	default:
+		return nil, xerrors.Errorf("cannot encode %v", v.Type())
+	}
+}
+
+// decoder reads the values of a payload in the given format
+type decoder struct {
+	format format
+	data   []byte
+	depth  int
+}
+
+func (d *decoder) next() (item, error) {
+	if d.depth > maxDepth {
+		return item{}, xerrors.Errorf("payload is nested more than %d levels deep", maxDepth)
+	}
+	it, data, e := d.format.readItem(d.data)
+	if nil != e {
+		return item{}, e
+	}
+	d.data = data
+	return it, nil
+}
+
+// decode reads the next value into v
+func (d *decoder) decode(v reflect.Value) error {
+	it, e := d.next()
+	if nil != e {
+		return e
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	return d.decodeItem(it, v)
+}
+
+func (d *decoder) decodeItem(it item, v reflect.Value) error {
+	if reflect.Ptr == v.Kind() {
+		if kindNil == it.kind {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeItem(it, v.Elem())
+	}
+	if reflect.Interface == v.Kind() && 0 == v.NumMethod() {
+		generic, e := d.generic(it)
+		if nil != e {
+			return e
+		}
+		if nil == generic {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(generic))
+		}
+		return nil
+	}
+	if kindNil == it.kind {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	mismatch := func() error { return xerrors.Errorf("cannot decode %v into %v", it.kind, v.Type()) }
+	switch v.Kind() {
+	case reflect.Bool:
+		if kindBool != it.kind {
+			return mismatch()
+		}
+		v.SetBool(it.b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, e := it.int64()
+		if nil != e {
+			return xerrors.Errorf("cannot decode into %v: %w", v.Type(), e)
+		}
+		if v.OverflowInt(n) {
+			return xerrors.Errorf("%d overflows %v", n, v.Type())
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, e := it.uint64()
+		if nil != e {
+			return xerrors.Errorf("cannot decode into %v: %w", v.Type(), e)
+		}
+		if v.OverflowUint(n) {
+			return xerrors.Errorf("%d overflows %v", n, v.Type())
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, e := it.float64()
+		if nil != e {
+			return xerrors.Errorf("cannot decode into %v: %w", v.Type(), e)
+		}
+		v.SetFloat(f)
+
+	case reflect.String:
+		if kindString != it.kind {
+			return mismatch()
+		}
+		v.SetString(string(it.s))
+
+	case reflect.Slice:
+		if reflect.Uint8 == v.Type().Elem().Kind() && (kindBytes == it.kind || kindString == it.kind) {
+			content := reflect.MakeSlice(v.Type(), len(it.s), len(it.s))
+			reflect.Copy(content, reflect.ValueOf(it.s))
+			v.Set(content)
+			return nil
+		}
+		if kindArray != it.kind {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(v.Type(), it.n, it.n)
+		for i := 0; i < it.n; i++ {
+			e := d.decode(slice.Index(i))
+			if nil != e {
+				return e
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Array:
+		if reflect.Uint8 == v.Type().Elem().Kind() && (kindBytes == it.kind || kindString == it.kind) {
+			if len(it.s) != v.Len() {
+				return xerrors.Errorf("cannot decode %d bytes into %v", len(it.s), v.Type())
+			}
+			reflect.Copy(v, reflect.ValueOf(it.s))
+			return nil
+		}
+		if kindArray != it.kind {
+			return mismatch()
+		}
+		if it.n != v.Len() {
+			return xerrors.Errorf("cannot decode %d elements into %v", it.n, v.Type())
+		}
+		for i := 0; i < it.n; i++ {
+			e := d.decode(v.Index(i))
+			if nil != e {
+				return e
+			}
+		}
+
+	case reflect.Map:
+		if kindMap != it.kind {
+			return mismatch()
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMapWithSize(v.Type(), it.n))
+		}
+		for i := 0; i < it.n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			e := d.decode(key)
+			if nil != e {
+				return e
+			}
+			if !key.Comparable() {
+				return xerrors.Errorf("map key of %v cannot be a %v", v.Type(), key.Elem().Type())
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			e = d.decode(value)
+			if nil != e {
+				return e
+			}
+			v.SetMapIndex(key, value)
+		}
+
+	case reflect.Struct:
+		if kindMap != it.kind {
+			return mismatch()
+		}
+		fields := fieldsOf(v.Type())
+		for i := 0; i < it.n; i++ {
+			key, e := d.next()
+			if nil != e {
+				return e
+			}
+			if kindString != key.kind {
+				return xerrors.Errorf("cannot decode a %v key into a field of %v", key.kind, v.Type())
+			}
+
+			// The keys without field are ignored, as with encoding/json
+			field := findField(fields, string(key.s), true)
+			if nil == field {
+				e = d.skip()
+			} else {
+				e = d.decode(v.FieldByIndex(field.index))
+			}
+			if nil != e {
+				return e
+			}
+		}
+
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+// generic returns the value of it, with the elements of the arrays and the maps read from the payload
+func (d *decoder) generic(it item) (interface{}, error) {
+	switch it.kind {
+	case kindBool:
+		return it.b, nil
+	case kindInt:
+		return it.i, nil
+	case kindUint:
+		if it.u <= math.MaxInt64 {
+			return int64(it.u), nil
+		}
+		return it.u, nil
+	case kindFloat:
+		return it.f, nil
+	case kindString:
+		return string(it.s), nil
+	case kindBytes:
+		return append([]byte{}, it.s...), nil
+
+	case kindArray:
+		elements := make([]interface{}, it.n)
+		for i := range elements {
+			e := d.decode(reflect.ValueOf(&elements[i]).Elem())
+			if nil != e {
+				return nil, e
+			}
+		}
+		return elements, nil
+
+	case kindMap:
+		// map[interface{}]interface{} is only used for the maps with other keys than strings
+		byString := make(map[string]interface{}, it.n)
+		var byValue map[interface{}]interface{}
+		for i := 0; i < it.n; i++ {
+			var key, value interface{}
+			e := d.decode(reflect.ValueOf(&key).Elem())
+			if nil != e {
+				return nil, e
+			}
+			e = d.decode(reflect.ValueOf(&value).Elem())
+			if nil != e {
+				return nil, e
+			}
+
+			s, isString := key.(string)
+			if isString && nil == byValue {
+				byString[s] = value
+				continue
+			}
+			if nil != key && !reflect.ValueOf(key).Comparable() {
+				return nil, xerrors.Errorf("map key cannot be a %T", key)
+			}
+			if nil == byValue {
+				byValue = make(map[interface{}]interface{}, it.n)
+				for k, v := range byString {
+					byValue[k] = v
+				}
+			}
+			byValue[key] = value
+		}
+		if nil != byValue {
+			return byValue, nil
+		}
+		return byString, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// skip reads the next value without decoding it
+func (d *decoder) skip() error {
+	it, e := d.next()
+	if nil != e {
+		return e
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	elements := it.n
+	if kindMap == it.kind {
+		elements *= 2
+	}
+	if kindArray != it.kind && kindMap != it.kind {
+		elements = 0
+	}
+	for i := 0; i < elements; i++ {
+		e = d.skip()
+		if nil != e {
+			return e
+		}
+	}
+	return nil
+}
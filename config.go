@@ -0,0 +1,140 @@
+package nymsocketmanager
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/xerrors"
+)
+
+// Config gathers the tunables of the manager, so that large deployments can manage them declaratively, see NewFromConfig.
+// The fields left to zero take the default of the manager, or leave the matching feature disabled
+type Config struct {
+	// The nym-clients, by order of preference, at least one, see WithFallbackURIs
+	ConnectionURIs []string
+
+	// See WithSelfAddressTimeout and WithSelfAddressRetries
+	SelfAddressTimeout time.Duration
+	SelfAddressRetries uint
+	// See WithDrainTimeout and WithHandlerDrainTimeout
+	DrainTimeout        time.Duration
+	HandlerDrainTimeout time.Duration
+	// Deadline of the writes not setting their own, see WithDefaultWriteTimeout
+	WriteTimeout time.Duration
+	// Both set to enable the read timeout, see WithReadTimeout
+	ReadIdleTimeout  time.Duration
+	ReadProbeTimeout time.Duration
+
+	// Messages sent while the connection is down, kept until it is restored, see WithReplayBuffer
+	ReplayBufferCapacity int
+	ReplayBufferPolicy   OverflowPolicy
+
+	// Sizes of the websocket buffers, the defaults of gorilla if 0, see WithBufferSizes
+	ReadBufferSize  int
+	WriteBufferSize int
+	// Frames larger than this close the connection, see WithMaxMessageSize
+	MaxMessageSize int64
+
+	// See WithStartupRetryPolicy and WithReconnectPolicy
+	StartupRetryPolicy *RetryPolicy
+	ReconnectPolicy    *RetryPolicy
+
+	// Workers calling the messageHandler, a goroutine per message if nil, see WithHandlerPool
+	HandlerPool *HandlerPool
+	// Messages waiting for the writer goroutine, the Sends being synchronous if 0, see WithAsyncSend
+	SendQueueLength int
+	SendQueuePolicy OverflowPolicy
+	// Connections opened to the nym-client, see WithConnectionPool
+	ConnectionPoolSize int
+}
+
+// DefaultConfig returns the Config of a manager connecting to connectionURIs, with the defaults of the manager spelled out
+func DefaultConfig(connectionURIs ...string) Config {
+	return Config{
+		ConnectionURIs:      connectionURIs,
+		SelfAddressTimeout:  defaultSelfAddressTimeout,
+		DrainTimeout:        defaultDrainTimeout,
+		HandlerDrainTimeout: defaultHandlerDrainTimeout,
+	}
+}
+
+// Validate checks that a manager can be created from the config, with the checks of the matching options
+func (c Config) Validate() error {
+	if len(c.ConnectionURIs) == 0 {
+		return xerrors.Errorf("config needs at least one connection URI")
+	}
+	if len(c.ConnectionURIs[0]) == 0 {
+		return xerrors.Errorf("connection URI cannot be empty")
+	}
+
+	scratch := &NymSocketManager{dialer: &websocket.Dialer{}}
+	for _, option := range c.options() {
+		e := option(scratch)
+		if nil != e {
+			return e
+		}
+	}
+	return nil
+}
+
+// options returns the options matching the fields set
+func (c Config) options() []Option {
+	var options []Option
+	if len(c.ConnectionURIs) > 1 {
+		options = append(options, WithFallbackURIs(c.ConnectionURIs[1:]...))
+	}
+	if 0 != c.SelfAddressTimeout {
+		options = append(options, WithSelfAddressTimeout(c.SelfAddressTimeout))
+	}
+	if 0 != c.SelfAddressRetries {
+		options = append(options, WithSelfAddressRetries(c.SelfAddressRetries))
+	}
+	if 0 != c.DrainTimeout {
+		options = append(options, WithDrainTimeout(c.DrainTimeout))
+	}
+	if 0 != c.HandlerDrainTimeout {
+		options = append(options, WithHandlerDrainTimeout(c.HandlerDrainTimeout))
+	}
+	if 0 != c.WriteTimeout {
+		options = append(options, WithDefaultWriteTimeout(c.WriteTimeout))
+	}
+	if 0 != c.ReadIdleTimeout || 0 != c.ReadProbeTimeout {
+		options = append(options, WithReadTimeout(c.ReadIdleTimeout, c.ReadProbeTimeout))
+	}
+	if 0 != c.ReplayBufferCapacity {
+		options = append(options, WithReplayBuffer(c.ReplayBufferCapacity, c.ReplayBufferPolicy))
+	}
+	if 0 != c.ReadBufferSize || 0 != c.WriteBufferSize {
+		options = append(options, WithBufferSizes(c.ReadBufferSize, c.WriteBufferSize))
+	}
+	if 0 != c.MaxMessageSize {
+		options = append(options, WithMaxMessageSize(c.MaxMessageSize, OversizeClose, nil))
+	}
+	if nil != c.StartupRetryPolicy {
+		options = append(options, WithStartupRetryPolicy(*c.StartupRetryPolicy))
+	}
+	if nil != c.ReconnectPolicy {
+		options = append(options, WithReconnectPolicy(*c.ReconnectPolicy))
+	}
+	if nil != c.HandlerPool {
+		options = append(options, WithHandlerPool(*c.HandlerPool))
+	}
+	if 0 != c.SendQueueLength {
+		options = append(options, WithAsyncSend(c.SendQueueLength, c.SendQueuePolicy))
+	}
+	if 0 != c.ConnectionPoolSize {
+		options = append(options, WithConnectionPool(c.ConnectionPoolSize))
+	}
+	return options
+}
+
+// NewFromConfig is NewNymSocketManager configured by config, the options being applied after the config
+func NewFromConfig(config Config, messageHandler func(NymReceived, func(NymMessage) error), parentLogger Logger, options ...Option) (*NymSocketManager, error) {
+	e := config.Validate()
+	if nil != e {
+		err := xerrors.Errorf("invalid config: %w", e)
+		return nil, err
+	}
+
+	return NewNymSocketManager(config.ConnectionURIs[0], messageHandler, parentLogger, append(config.options(), options...)...)
+}
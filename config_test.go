@@ -0,0 +1,88 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, lib.DefaultConfig("ws://127.0.0.1:1977").Validate())
+	require.Error(t, lib.Config{}.Validate())
+	require.Error(t, lib.DefaultConfig("").Validate())
+	require.Error(t, lib.DefaultConfig("ws://127.0.0.1:1977", "").Validate())
+
+	config := lib.DefaultConfig("ws://127.0.0.1:1977")
+	config.DrainTimeout = -time.Second
+	require.Error(t, config.Validate())
+	config = lib.DefaultConfig("ws://127.0.0.1:1977")
+	config.HandlerDrainTimeout = -time.Second
+	require.Error(t, config.Validate())
+
+	// Both read timeouts are needed
+	config = lib.DefaultConfig("ws://127.0.0.1:1977")
+	config.ReadIdleTimeout = time.Second
+	require.Error(t, config.Validate())
+	config.ReadProbeTimeout = time.Second
+	require.NoError(t, config.Validate())
+
+	config = lib.DefaultConfig("ws://127.0.0.1:1977")
+	config.ReplayBufferCapacity = -1
+	require.Error(t, config.Validate())
+	config.ReplayBufferCapacity = 16
+	require.NoError(t, config.Validate())
+
+	config = lib.DefaultConfig("ws://127.0.0.1:1977")
+	config.HandlerPool = &lib.HandlerPool{}
+	require.Error(t, config.Validate())
+	config.HandlerPool.Workers = 2
+	require.NoError(t, config.Validate())
+
+	config = lib.DefaultConfig("ws://127.0.0.1:1977")
+	config.StartupRetryPolicy = &lib.RetryPolicy{MaxAttempts: 3}
+	require.Error(t, config.Validate())
+	config = lib.DefaultConfig("ws://127.0.0.1:1977")
+	config.ReconnectPolicy = &lib.RetryPolicy{BaseDelay: -time.Second}
+	require.Error(t, config.Validate())
+}
+
+func TestNewFromConfig(t *testing.T) {
+	logger := zerolog.Logger{}
+	unreachable := newFakeNymClient(t)
+	unreachable.server.Close()
+	fake := newFakeNymClient(t)
+
+	handled := make(chan lib.NymReceived, 1)
+	config := lib.DefaultConfig(unreachable.URI(), fake.URI())
+	config.HandlerPool = &lib.HandlerPool{Workers: 2, QueueLength: 4}
+	config.ReplayBufferCapacity = 8
+	config.SendQueueLength = 8
+	config.SendQueuePolicy = lib.OverflowBlock
+	nymSocketManager, e := lib.NewFromConfig(config, func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- received
+	}, lib.ZerologLogger(&logger), lib.WithSelfAddressTimeout(time.Second))
+	require.NoError(t, e)
+
+	// Falls back to the second connection URI
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", nymSocketManager.GetNymClientId())))
+	select {
+	case received := <-handled:
+		require.Equal(t, "hello", received.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+}
+
+func TestNewFromConfigInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewFromConfig(lib.Config{}, emptyProcessing, lib.ZerologLogger(&logger))
+	require.Error(t, e)
+}
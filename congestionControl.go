@@ -0,0 +1,152 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// Time after a slow down during which the congestion errors are ignored, as the packets queued meanwhile fail alike
+const congestionCooldown = time.Second
+
+// CongestionControl configures the adaptive throttling of the sends, see WithCongestionControl.
+// The rate is cut by Backoff on each lane congestion error reported by the nym-client, and raised by Recovery
+// every second without any, up to MaxRate
+type CongestionControl struct {
+	// Messages per second sent without congestion
+	MaxRate float64
+	// Messages per second the throttling never goes below, MaxRate/100 if 0
+	MinRate float64
+	// Factor applied to the rate on congestion, between 0 and 1, 0.5 if 0
+	Backoff float64
+	// Messages per second the rate recovers each second without congestion, MaxRate/10 if 0
+	Recovery float64
+	// Messages that can be sent at once, 1 if 0
+	Burst int
+}
+
+// withDefaults returns the configuration with the defaults set, or an error if it is invalid
+func (c CongestionControl) withDefaults() (CongestionControl, error) {
+	if c.MaxRate <= 0 {
+		return c, xerrors.Errorf("congestion control max rate must be positive, got %v", c.MaxRate)
+	}
+	if 0 == c.MinRate {
+		c.MinRate = c.MaxRate / 100
+	}
+	if 0 == c.Backoff {
+		c.Backoff = 0.5
+	}
+	if 0 == c.Recovery {
+		c.Recovery = c.MaxRate / 10
+	}
+	if 0 == c.Burst {
+		c.Burst = 1
+	}
+
+	if c.MinRate < 0 || c.MinRate > c.MaxRate {
+		return c, xerrors.Errorf("congestion control min rate must be between 0 and %v, got %v", c.MaxRate, c.MinRate)
+	}
+	if c.Backoff <= 0 || c.Backoff >= 1 {
+		return c, xerrors.Errorf("congestion control backoff must be between 0 and 1, got %v", c.Backoff)
+	}
+	if c.Recovery < 0 {
+		return c, xerrors.Errorf("congestion control recovery cannot be negative, got %v", c.Recovery)
+	}
+	if c.Burst < 0 {
+		return c, xerrors.Errorf("congestion control burst cannot be negative, got %d", c.Burst)
+	}
+	return c, nil
+}
+
+// congestionThrottle is a token bucket whose rate follows the congestion of the nym-client, additive increase multiplicative decrease
+type congestionThrottle struct {
+	sync.Mutex
+
+	config CongestionControl
+	// Current rate, along with the burst of the config
+	limit       RateLimit
+	bucket      tokenBucket
+	recoveredAt time.Time
+	slowedAt    time.Time
+	slowdowns   uint64
+}
+
+func newCongestionThrottle(config CongestionControl) *congestionThrottle {
+	return &congestionThrottle{config: config, limit: RateLimit{Rate: config.MaxRate, Burst: config.Burst}}
+}
+
+// reserve takes a token, and returns how long to wait for it to be available
+func (c *congestionThrottle) reserve(now time.Time) time.Duration {
+	c.Lock()
+	defer c.Unlock()
+
+	c.recover(now)
+	return c.bucket.reserve(&c.limit, now)
+}
+
+// congested slows the sends down, unless they were already shortly before. It returns the new rate, 0 if unchanged
+func (c *congestionThrottle) congested(now time.Time) float64 {
+	c.Lock()
+	defer c.Unlock()
+
+	c.recover(now)
+	if !c.slowedAt.IsZero() && now.Sub(c.slowedAt) < congestionCooldown {
+		return 0
+	}
+
+	c.limit.Rate *= c.config.Backoff
+	if c.limit.Rate < c.config.MinRate {
+		c.limit.Rate = c.config.MinRate
+	}
+	c.slowedAt = now
+	c.slowdowns++
+	return c.limit.Rate
+}
+
+// rate returns the current rate and how many times the sends were slowed down
+func (c *congestionThrottle) rate(now time.Time) (float64, uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.recover(now)
+	return c.limit.Rate, c.slowdowns
+}
+
+// recover raises the rate for the time elapsed since the last call.
+// called from methods that already acquired the lock
+func (c *congestionThrottle) recover(now time.Time) {
+	// The tokens earned so far are at the previous rate
+	c.bucket.refill(&c.limit, now)
+
+	if !c.recoveredAt.IsZero() {
+		c.limit.Rate += now.Sub(c.recoveredAt).Seconds() * c.config.Recovery
+		if c.limit.Rate > c.config.MaxRate {
+			c.limit.Rate = c.config.MaxRate
+		}
+	}
+	c.recoveredAt = now
+}
+
+// waitForCongestion waits until msg can be sent at the rate of the congestion control, if any
+func (n *NymSocketManager) waitForCongestion(msg NymMessage, messageID string) {
+	if nil == n.throttle {
+		return
+	}
+
+	if wait := n.throttle.reserve(time.Now()); wait > 0 {
+		n.messageLogger.Trace().Str(MessageIDField, messageID).Msgf("throttled on congestion, waiting %v to send %v", wait, msg.Name())
+		time.Sleep(wait)
+	}
+}
+
+// nymErrorThrottled slows the sends down if err reports a congested lane
+func (n *NymSocketManager) nymErrorThrottled(err NymError) {
+	if nil == n.throttle || !xerrors.Is(err, ErrNymLaneFull) {
+		return
+	}
+
+	if rate := n.throttle.congested(time.Now()); rate > 0 {
+		n.logger.Warn().Msgf("mixnet congested, sends throttled to %.2f messages per second", rate)
+	}
+}
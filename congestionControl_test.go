@@ -0,0 +1,81 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerCongestionControl(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithCongestionControl(lib.CongestionControl{MaxRate: 40, Recovery: 0.001}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, float64(40), nymSocketManager.Stats().SendThrottleRate)
+
+	// Errors of other classes do not slow down
+	fake.deliver(map[string]interface{}{"type": "error", "message": "invalid recipient"})
+	fake.deliver(map[string]interface{}{"type": "error", "message": "lane is full"})
+	fake.deliver(map[string]interface{}{"type": "error", "message": "lane is full"})
+	require.Eventually(t, func() bool { return 1 == nymSocketManager.Stats().CongestionSlowdowns }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	// Only once for the errors in a row
+	stats := nymSocketManager.Stats()
+	require.Equal(t, uint64(1), stats.CongestionSlowdowns)
+	require.InDelta(t, 20, stats.SendThrottleRate, 0.01)
+
+	// 20 messages per second
+	startedAt := time.Now()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)))
+	}
+	require.GreaterOrEqual(t, time.Since(startedAt), 150*time.Millisecond)
+}
+
+func TestNymSocketManagerCongestionControlRecovery(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithCongestionControl(lib.CongestionControl{MaxRate: 100, MinRate: 60, Recovery: 200}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	fake.deliver(map[string]interface{}{"type": "error", "message": "too many packets queued"})
+	require.Eventually(t, func() bool { return 1 == nymSocketManager.Stats().CongestionSlowdowns }, time.Second, time.Millisecond)
+	require.Less(t, nymSocketManager.Stats().SendThrottleRate, float64(100))
+	require.GreaterOrEqual(t, nymSocketManager.Stats().SendThrottleRate, float64(60))
+
+	// Back to the max rate after 0.2s without congestion
+	require.Eventually(t, func() bool { return 100 == nymSocketManager.Stats().SendThrottleRate }, time.Second, 10*time.Millisecond)
+}
+
+func TestWithCongestionControlInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	for _, config := range []lib.CongestionControl{
+		{},
+		{MaxRate: 10, MinRate: 20},
+		{MaxRate: 10, Backoff: 1},
+		{MaxRate: 10, Backoff: -0.5},
+		{MaxRate: 10, Recovery: -1},
+		{MaxRate: 10, Burst: -1},
+	} {
+		_, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithCongestionControl(config))
+		require.Error(t, e, "%+v", config)
+	}
+}
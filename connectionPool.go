@@ -0,0 +1,167 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/xerrors"
+)
+
+// pooledConnection is an additional connection to the nym-client, used to parallelise the writes
+type pooledConnection struct {
+	// Serialises the writes on this connection
+	sync.Mutex
+
+	connection               *websocket.Conn
+	socketListener           *SocketListener
+	closedSocketListenerChan chan struct{}
+}
+
+// connectionPool holds the connections opened in addition to the main one, Sends are spread over all of them
+type connectionPool struct {
+	sync.RWMutex
+
+	// Total number of connections, the main one included
+	size        int
+	connections []*pooledConnection
+	counter     atomic.Uint64
+}
+
+// next returns the connection to use for the next write, nil meaning the main connection
+func (p *connectionPool) next() *pooledConnection {
+	p.RLock()
+	defer p.RUnlock()
+
+	if 0 == len(p.connections) {
+		return nil
+	}
+
+	index := p.counter.Add(1) % uint64(len(p.connections)+1)
+	if 0 == index {
+		return nil
+	}
+	return p.connections[index-1]
+}
+
+// remove takes a connection out of the pool, returning false if it was not part of it
+func (p *connectionPool) remove(pooled *pooledConnection) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	for i, connection := range p.connections {
+		if pooled == connection {
+			p.connections = append(p.connections[:i], p.connections[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// openPool opens the additional connections to connectionURI, once the main one is established.
+// Failing to open some of them is not fatal, Sends are spread over the ones available
+// called from methods that already acquired the lock
+func (n *NymSocketManager) openPool(ctx context.Context, connectionURI string) {
+	for i := 1; i < n.pool.size; i++ {
+		pooled, e := n.openPooledConnection(ctx, connectionURI, n.pooledConnectionLost)
+		if nil != e {
+			n.logger.Warn().Msgf("failed to open pooled connection %d/%d to %v: %v", i+1, n.pool.size, connectionURI, e)
+			continue
+		}
+
+		n.pool.Lock()
+		n.pool.connections = append(n.pool.connections, pooled)
+		n.pool.Unlock()
+	}
+
+	n.logger.Debug().Msgf("%d connection(s) opened to %v", len(n.pool.connections)+1, connectionURI)
+}
+
+// openPooledConnection opens a connection to connectionURI in addition to the main one, and listens to it.
+// onLost is called once it is closed
+// called from methods that already acquired the lock
+func (n *NymSocketManager) openPooledConnection(ctx context.Context, connectionURI string, onLost func(*pooledConnection)) (*pooledConnection, error) {
+	connection, e := n.dial(ctx, connectionURI)
+	if nil != e {
+		return nil, e
+	}
+
+	pooled := &pooledConnection{connection: connection}
+	pooled.socketListener, pooled.closedSocketListenerChan, e = NewSocketListener(connection, func(s []byte) { n.dispatchMessage(s, pooled) }, func() { onLost(pooled) }, n.logger.output)
+	if nil != e {
+		connection.Close()
+		return nil, xerrors.Errorf("failed to initiate the socketListener: %v", e)
+	}
+	pooled.socketListener.SetMaxMessageSize(n.maxMessageSize, n.oversizePolicy, n.oversized)
+	pooled.socketListener.SetReadTimeout(n.readIdleTimeout, n.readProbeTimeout)
+	pooled.socketListener.ignoreAbnormalClosure = n.closeBehavior.IgnoreAbnormalClosure
+	pooled.socketListener.dispatchInline = nil != n.handlerPool
+	pooled.socketListener.SetLogSampler(n.logSampler)
+	pooled.socketListener.SetPayloadRedaction(n.redactPayloads)
+	go pooled.socketListener.Listen()
+
+	return pooled, nil
+}
+
+// pooledConnectionLost is called by the socketListener of a pooled connection once it is closed
+func (n *NymSocketManager) pooledConnectionLost(pooled *pooledConnection) {
+	// Otherwise the closure was requested by closePool
+	if n.pool.remove(pooled) {
+		pooled.connection.Close()
+		n.logger.Warn().Msgf("lost a pooled connection: %v", pooled.socketListener.closeReason)
+	}
+}
+
+// closePool closes the additional connections, with the same close handshake as the main one
+// called from methods that already acquired the lock
+func (n *NymSocketManager) closePool(ctx context.Context) {
+	n.pool.Lock()
+	connections := n.pool.connections
+	n.pool.connections = nil
+	n.pool.Unlock()
+
+	n.closePooledConnections(ctx, connections)
+}
+
+// closePooledConnections closes connections, with the same close handshake as the main one
+func (n *NymSocketManager) closePooledConnections(ctx context.Context, connections []*pooledConnection) {
+	for _, pooled := range connections {
+		if n.closeBehavior.SkipCloseFrame {
+			break
+		}
+		pooled.socketListener.closeRequested.Store(true)
+		pooled.Lock()
+		e := pooled.connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(n.closeCode(), ""))
+		pooled.Unlock()
+		if nil != e {
+			n.logger.Debug().Msgf("failed to write close on pooled connection: %v", e)
+		}
+	}
+
+	// Without close frame, there is no confirmation to wait for
+	if n.closeBehavior.SkipCloseFrame {
+		for _, pooled := range connections {
+			pooled.connection.Close()
+		}
+		return
+	}
+
+	closeCtx, cancel := withDefaultTimeout(ctx, n.closeTimeout())
+	defer cancel()
+	for _, pooled := range connections {
+		select {
+		case <-pooled.closedSocketListenerChan:
+		case <-closeCtx.Done():
+		}
+		pooled.connection.Close()
+	}
+}
+
+// writeOnPool writes a message on a pooled connection
+func (n *NymSocketManager) writeOnPool(pooled *pooledConnection, msg NymMessage, options *sendOptions) error {
+	pooled.Lock()
+	defer pooled.Unlock()
+
+	return n.writeMessage(pooled.connection, msg, options)
+}
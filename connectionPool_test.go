@@ -0,0 +1,48 @@
+package nymsocketmanager_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerConnectionPool(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithConnectionPool(3))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	require.Eventually(t, func() bool {
+		return fake.connectionCount() == 3
+	}, time.Second, 10*time.Millisecond)
+
+	var senders sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)))
+		}()
+	}
+	senders.Wait()
+
+	require.Eventually(t, func() bool {
+		return fake.sendRequests.Load() == 30
+	}, time.Second, 10*time.Millisecond)
+
+	require.Equal(t, lib.StopGraceful, nymSocketManager.StopWithTimeout(time.Second))
+}
+
+func TestNymSocketManagerConnectionPoolSizeMustBePositive(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithConnectionPool(0))
+	require.Error(t, e)
+}
@@ -0,0 +1,310 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// ErrBadQuality is the reason of the closure of a connection replaced because its quality became bad, see ConnectionQuality.ReplaceWhenBad
+var ErrBadQuality = xerrors.New("connection quality is bad")
+
+// Defaults of the ConnectionQuality fields left to 0
+const (
+	DefaultQualityPingInterval          = 10 * time.Second
+	DefaultDegradedRTT                  = 250 * time.Millisecond
+	DefaultBadRTT                       = time.Second
+	DefaultDegradedWriteLatency         = 100 * time.Millisecond
+	DefaultBadWriteLatency              = 500 * time.Millisecond
+	DefaultDegradedErrorRate    float64 = 0.1
+	DefaultBadErrorRate         float64 = 0.3
+)
+
+// Weights of the last sample in the smoothed estimates of the connection quality
+const (
+	qualityLatencyWeight = 1.0 / 8
+	qualityErrorWeight   = 1.0 / 16
+)
+
+// Quality sums up the estimates of the connection quality, see WithConnectionQuality
+type Quality int
+
+const (
+	// Nothing measured yet on the current connection, or the estimation is disabled
+	QualityUnknown Quality = iota
+	QualityGood
+	// One of the estimates crossed its degraded threshold
+	QualityDegraded
+	// One of the estimates crossed its bad threshold
+	QualityBad
+)
+
+func (q Quality) String() string {
+	switch q {
+	case QualityGood:
+		return "Good"
+	case QualityDegraded:
+		return "Degraded"
+	case QualityBad:
+		return "Bad"
+	default:
+		return "Unknown"
+	}
+}
+
+// ConnectionQuality configures the estimation of the quality of the connection to the nym-client, see WithConnectionQuality.
+// The thresholds are inclusive, the fields left to 0 take their default
+type ConnectionQuality struct {
+	// Time between two websocket pings measuring the round-trip time, also the time the pong is waited for
+	PingInterval time.Duration
+
+	DegradedRTT          time.Duration
+	BadRTT               time.Duration
+	DegradedWriteLatency time.Duration
+	BadWriteLatency      time.Duration
+	// Share of the writes and pings failing, between 0 and 1
+	DegradedErrorRate float64
+	BadErrorRate      float64
+
+	// Replace the connection once its quality is bad, trying the connection URIs from the next one,
+	// the closure having ErrBadQuality as reason
+	ReplaceWhenBad bool
+}
+
+// QualityChangedEvent is sent each time the Quality of the connection changes, see WithConnectionQuality
+type QualityChangedEvent struct {
+	EventCommon
+	From Quality
+	To   Quality
+}
+
+func (QualityChangedEvent) Name() string {
+	return "qualityChanged"
+}
+
+// qualityEstimator holds the estimates of the quality of the current connection
+// It has its own lock so that the writes and pings are accounted for while the NymSocketManager is busy (e.g. connecting)
+type qualityEstimator struct {
+	sync.Mutex
+
+	config ConnectionQuality
+	// Closed to stop pinging, nil while stopped
+	stopChan chan struct{}
+	// Incremented for each new connection, so that a verdict on a replaced connection is not acted on
+	generation uint64
+
+	// Smoothed estimates, in seconds for the durations
+	rtt          float64
+	writeLatency float64
+	errorRate    float64
+	hasRTT       bool
+	hasWrite     bool
+	quality      Quality
+}
+
+// withDefaults returns the configuration with the fields left to 0 set to their default
+func (c ConnectionQuality) withDefaults() ConnectionQuality {
+	if 0 == c.PingInterval {
+		c.PingInterval = DefaultQualityPingInterval
+	}
+	if 0 == c.DegradedRTT {
+		c.DegradedRTT = DefaultDegradedRTT
+	}
+	if 0 == c.BadRTT {
+		c.BadRTT = DefaultBadRTT
+	}
+	if 0 == c.DegradedWriteLatency {
+		c.DegradedWriteLatency = DefaultDegradedWriteLatency
+	}
+	if 0 == c.BadWriteLatency {
+		c.BadWriteLatency = DefaultBadWriteLatency
+	}
+	if 0 == c.DegradedErrorRate {
+		c.DegradedErrorRate = DefaultDegradedErrorRate
+	}
+	if 0 == c.BadErrorRate {
+		c.BadErrorRate = DefaultBadErrorRate
+	}
+	return c
+}
+
+// Quality returns the current quality of the connection to the nym-client, QualityUnknown unless WithConnectionQuality is set
+func (n *NymSocketManager) Quality() Quality {
+	if nil == n.quality {
+		return QualityUnknown
+	}
+
+	n.quality.Lock()
+	defer n.quality.Unlock()
+	return n.quality.quality
+}
+
+// resetQuality forgets the estimates of the previous connection
+func (n *NymSocketManager) resetQuality() {
+	if nil == n.quality {
+		return
+	}
+
+	n.quality.Lock()
+	defer n.quality.Unlock()
+	n.quality.generation++
+	n.quality.rtt, n.quality.writeLatency, n.quality.errorRate = 0, 0, 0
+	n.quality.hasRTT, n.quality.hasWrite = false, false
+	n.quality.quality = QualityUnknown
+}
+
+// qualityWritten accounts for a write of the connection which took latency, and failed if e is not nil
+func (n *NymSocketManager) qualityWritten(latency time.Duration, e error) {
+	if nil == n.quality {
+		return
+	}
+
+	n.quality.Lock()
+	if nil == e {
+		n.quality.writeLatency = smoothed(n.quality.writeLatency, latency.Seconds(), qualityLatencyWeight, n.quality.hasWrite)
+		n.quality.hasWrite = true
+	}
+	n.quality.failed(nil != e)
+	n.quality.Unlock()
+
+	n.qualityChanged()
+}
+
+// qualityPinged accounts for a ping of the connection answered after rtt, or failed if e is not nil
+func (n *NymSocketManager) qualityPinged(rtt time.Duration, e error) {
+	n.quality.Lock()
+	if nil == e {
+		n.quality.rtt = smoothed(n.quality.rtt, rtt.Seconds(), qualityLatencyWeight, n.quality.hasRTT)
+		n.quality.hasRTT = true
+	}
+	n.quality.failed(nil != e)
+	n.quality.Unlock()
+
+	n.qualityChanged()
+}
+
+// failed accounts for a write or ping, failed or not
+// called from methods that already acquired the lock
+func (q *qualityEstimator) failed(failed bool) {
+	sample := 0.0
+	if failed {
+		sample = 1
+	}
+	q.errorRate += qualityErrorWeight * (sample - q.errorRate)
+}
+
+// smoothed returns the estimate once the sample is accounted for, the sample itself if it is the first one
+func smoothed(estimate float64, sample float64, weight float64, hasSample bool) float64 {
+	if !hasSample {
+		return sample
+	}
+	return estimate + weight*(sample-estimate)
+}
+
+// evaluate returns the Quality matching the current estimates
+// called from methods that already acquired the lock
+func (q *qualityEstimator) evaluate() Quality {
+	if !q.hasRTT && !q.hasWrite && 0 == q.errorRate {
+		return QualityUnknown
+	}
+
+	rtt, writeLatency := seconds(q.rtt), seconds(q.writeLatency)
+	if q.errorRate >= q.config.BadErrorRate || (q.hasRTT && rtt >= q.config.BadRTT) || (q.hasWrite && writeLatency >= q.config.BadWriteLatency) {
+		return QualityBad
+	}
+	if q.errorRate >= q.config.DegradedErrorRate || (q.hasRTT && rtt >= q.config.DegradedRTT) ||
+		(q.hasWrite && writeLatency >= q.config.DegradedWriteLatency) {
+		return QualityDegraded
+	}
+	return QualityGood
+}
+
+// qualityChanged updates the Quality from the estimates, and reports its change if any
+func (n *NymSocketManager) qualityChanged() {
+	n.quality.Lock()
+	from, to := n.quality.quality, n.quality.evaluate()
+	n.quality.quality = to
+	generation := n.quality.generation
+	n.quality.Unlock()
+
+	if from == to {
+		return
+	}
+	n.logger.Info().Msgf("connection quality went from %v to %v", from, to)
+	n.events.publish(QualityChangedEvent{EventCommon{time.Now()}, from, to})
+
+	// The lock of the manager may be held by the caller, e.g. while writing the messages of the replay buffer
+	if QualityBad == to && n.quality.config.ReplaceWhenBad {
+		go n.replaceBadConnection(generation)
+	}
+}
+
+// replaceBadConnection replaces the connection whose quality became bad, unless it was replaced meanwhile
+func (n *NymSocketManager) replaceBadConnection(generation uint64) {
+	n.Lock()
+	defer n.Unlock()
+
+	n.quality.Lock()
+	replaced := generation != n.quality.generation
+	n.quality.Unlock()
+	if replaced || nil == n.socketListener {
+		return
+	}
+
+	reason := xerrors.Errorf("replacing connection to %v: %w", redactURI(n.connectionURIs[n.connectionURIIndex]), ErrBadQuality)
+	n.recoverConnection(reason, true)
+}
+
+// startQualityPings pings the connection until the manager stops
+// called from methods that already acquired the lock
+func (n *NymSocketManager) startQualityPings() {
+	if nil == n.quality {
+		return
+	}
+
+	n.quality.Lock()
+	defer n.quality.Unlock()
+	n.quality.stopChan = make(chan struct{})
+	go n.pingForQuality(n.quality.stopChan)
+}
+
+// stopQualityPings stops pinging the connection
+// called from methods that already acquired the lock
+func (n *NymSocketManager) stopQualityPings() {
+	if nil == n.quality {
+		return
+	}
+
+	n.quality.Lock()
+	defer n.quality.Unlock()
+	if nil != n.quality.stopChan {
+		close(n.quality.stopChan)
+		n.quality.stopChan = nil
+	}
+}
+
+func (n *NymSocketManager) pingForQuality(stopChan chan struct{}) {
+	interval := n.quality.config.PingInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		// There is no connection to measure while disconnected, nor reason to open a lazy connection for it
+		if !n.IsReady() {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		rtt, e := n.Ping(ctx)
+		cancel()
+		n.qualityPinged(rtt, e)
+	}
+}
@@ -0,0 +1,80 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerConnectionQuality(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithConnectionQuality(lib.ConnectionQuality{PingInterval: 10 * time.Millisecond}))
+	require.NoError(t, e)
+	require.Equal(t, lib.QualityUnknown, nymSocketManager.Quality())
+	events := nymSocketManager.Events()
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The selfAddress request is the first write measured
+	for {
+		if changed, ok := nextEvent(t, events).(lib.QualityChangedEvent); ok {
+			require.Equal(t, lib.QualityUnknown, changed.From)
+			require.Equal(t, lib.QualityGood, changed.To)
+			break
+		}
+	}
+	require.Equal(t, lib.QualityGood, nymSocketManager.Quality())
+
+	require.Eventually(t, func() bool { return nymSocketManager.Stats().PingRTT > 0 }, time.Second, time.Millisecond)
+	stats := nymSocketManager.Stats()
+	require.Equal(t, lib.QualityGood, stats.Quality)
+	require.Greater(t, stats.WriteLatency, time.Duration(0))
+	require.Zero(t, stats.ErrorRate)
+}
+
+func TestNymSocketManagerConnectionQualityReplaceWhenBad(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithConnectionQuality(lib.ConnectionQuality{
+		PingInterval:   10 * time.Millisecond,
+		DegradedRTT:    time.Nanosecond,
+		BadRTT:         time.Nanosecond,
+		ReplaceWhenBad: true,
+	}))
+	require.NoError(t, e)
+	events := nymSocketManager.Events()
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for {
+		if disconnected, ok := nextEvent(t, events).(lib.DisconnectedEvent); ok {
+			require.ErrorIs(t, disconnected.Reason, lib.ErrBadQuality)
+			break
+		}
+	}
+	// Replaced by a new connection, measured from scratch
+	for {
+		if _, ok := nextEvent(t, events).(lib.ConnectedEvent); ok {
+			break
+		}
+	}
+}
+
+func TestNymSocketManagerConnectionQualityInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1:1977", emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithConnectionQuality(lib.ConnectionQuality{DegradedRTT: time.Second, BadRTT: time.Millisecond}))
+	require.Error(t, e)
+}
@@ -0,0 +1,172 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"time"
+)
+
+// ConnectionState describes where the NymSocketManager is in its lifecycle
+type ConnectionState int
+
+const (
+	// Not connected to any nym-client, either not started yet or the connection was lost
+	StateDisconnected ConnectionState = iota
+	// Opening the websocket connection to a nym-client
+	StateConnecting
+	// Connected, collecting the clientID from the nym-client
+	StateHandshaking
+	// Connected and clientID collected, messages can be exchanged
+	StateRunning
+	// Shutting down the connection
+	StateDraining
+	// Stopped on request, or after all the nym-clients became unreachable
+	StateStopped
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "Disconnected"
+	case StateConnecting:
+		return "Connecting"
+	case StateHandshaking:
+		return "Handshaking"
+	case StateRunning:
+		return "Running"
+	case StateDraining:
+		return "Draining"
+	case StateStopped:
+		return "Stopped"
+	default:
+		return "Unknown"
+	}
+}
+
+// StateChange is sent to subscribers on each transition of the ConnectionState
+type StateChange struct {
+	From ConnectionState
+	To   ConnectionState
+	At   time.Time
+}
+
+// Size of the buffer of each subscription. Changes are dropped for subscribers lagging behind
+const stateChangesBufferSize = 16
+
+// connectionStateMachine holds the ConnectionState and notifies the subscribers of its changes
+// It has its own lock so that the state can be queried while the NymSocketManager is busy (e.g. connecting)
+type connectionStateMachine struct {
+	sync.Mutex
+
+	state       ConnectionState
+	subscribers []chan StateChange
+	// Closed while in StateRunning, replaced by a new one when leaving it
+	readyChan chan struct{}
+}
+
+func (c *connectionStateMachine) get() ConnectionState {
+	c.Lock()
+	defer c.Unlock()
+	return c.state
+}
+
+// set transitions to the given state and returns the notification sent to subscribers, if the state changed
+func (c *connectionStateMachine) set(state ConnectionState) (StateChange, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if state == c.state {
+		return StateChange{}, false
+	}
+
+	change := StateChange{From: c.state, To: state, At: time.Now()}
+	c.state = state
+
+	if StateRunning == state {
+		close(c.readyChanLocked())
+	} else if StateRunning == change.From {
+		c.readyChan = make(chan struct{})
+	}
+
+	for _, subscriber := range c.subscribers {
+		select {
+		case subscriber <- change:
+		default:
+		}
+	}
+
+	return change, true
+}
+
+// ready returns a channel closed once in StateRunning
+func (c *connectionStateMachine) ready() <-chan struct{} {
+	c.Lock()
+	defer c.Unlock()
+	return c.readyChanLocked()
+}
+
+// called with the state machine locked
+func (c *connectionStateMachine) readyChanLocked() chan struct{} {
+	if nil == c.readyChan {
+		c.readyChan = make(chan struct{})
+	}
+	return c.readyChan
+}
+
+func (c *connectionStateMachine) subscribe() <-chan StateChange {
+	c.Lock()
+	defer c.Unlock()
+
+	subscriber := make(chan StateChange, stateChangesBufferSize)
+	c.subscribers = append(c.subscribers, subscriber)
+	return subscriber
+}
+
+func (c *connectionStateMachine) unsubscribe(subscription <-chan StateChange) {
+	c.Lock()
+	defer c.Unlock()
+
+	for i, subscriber := range c.subscribers {
+		if subscription == subscriber {
+			close(subscriber)
+			c.subscribers = append(c.subscribers[:i], c.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetState returns the current ConnectionState of the manager
+func (n *NymSocketManager) GetState() ConnectionState {
+	return n.state.get()
+}
+
+// IsReady tells whether the clientID was collected from the nym-client, meaning that it is safe to Send.
+// Unlike IsRunning, it is false while the connection is open but the handshake is not done yet
+func (n *NymSocketManager) IsReady() bool {
+	return StateRunning == n.state.get()
+}
+
+// Ready returns a channel closed once the manager is ready, see IsReady.
+// If the connection is lost afterwards, the next call returns a new channel, closed once ready again
+func (n *NymSocketManager) Ready() <-chan struct{} {
+	return n.state.ready()
+}
+
+// SubscribeStateChanges returns a channel receiving every transition of the ConnectionState.
+// Transitions are dropped if the channel is not consumed fast enough.
+// The channel is closed by UnsubscribeStateChanges
+func (n *NymSocketManager) SubscribeStateChanges() <-chan StateChange {
+	return n.state.subscribe()
+}
+
+// UnsubscribeStateChanges stops sending transitions to a channel obtained with SubscribeStateChanges, and closes it
+func (n *NymSocketManager) UnsubscribeStateChanges(subscription <-chan StateChange) {
+	n.state.unsubscribe(subscription)
+}
+
+// setState transitions the manager to the given state
+func (n *NymSocketManager) setState(state ConnectionState) {
+	change, changed := n.state.set(state)
+	if changed {
+		n.logger.Debug().Msgf("state changed from %v to %v", change.From, change.To)
+	}
+}
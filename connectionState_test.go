@@ -0,0 +1,72 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionStateString(t *testing.T) {
+	require.Equal(t, "Running", lib.StateRunning.String())
+	require.Equal(t, "Unknown", lib.ConnectionState(-1).String())
+}
+
+func TestNymSocketManagerStateChanges(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	require.Equal(t, lib.StateDisconnected, nymSocketManager.GetState())
+
+	changes := nymSocketManager.SubscribeStateChanges()
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	require.Equal(t, lib.StateRunning, nymSocketManager.GetState())
+	nymSocketManager.Stop()
+	require.Equal(t, lib.StateStopped, nymSocketManager.GetState())
+
+	nymSocketManager.UnsubscribeStateChanges(changes)
+
+	var states []lib.ConnectionState
+	for change := range changes {
+		states = append(states, change.To)
+	}
+	require.Equal(t, []lib.ConnectionState{
+		lib.StateConnecting, lib.StateHandshaking, lib.StateRunning, lib.StateDraining, lib.StateStopped,
+	}, states)
+}
+
+func TestNymSocketManagerReady(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.upgradeDelay = 100 * time.Millisecond
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	require.False(t, nymSocketManager.IsReady())
+
+	ready := nymSocketManager.Ready()
+	go nymSocketManager.Start()
+
+	select {
+	case <-ready:
+		require.True(t, nymSocketManager.IsReady())
+		require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+	case <-time.After(time.Second):
+		t.Fatal("manager never got ready")
+	}
+
+	nymSocketManager.Stop()
+	require.False(t, nymSocketManager.IsReady())
+
+	select {
+	case <-nymSocketManager.Ready():
+		t.Fatal("a stopped manager should not be ready")
+	default:
+	}
+}
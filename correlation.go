@@ -0,0 +1,160 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// Mark the payloads of requests, followed by "<id>:<return address>:<payload>".
+	// The return address is empty for anonymous requests, which are answered through their reply SURBs
+	requestPrefix = "\x1eNSMREQ:"
+	// Mark the payloads of responses, followed by "<id>:<payload>"
+	responsePrefix = "\x1eNSMRSP:"
+)
+
+// requestTracker matches the responses received with the requests waiting for them
+type requestTracker struct {
+	sync.Mutex
+
+	waiting map[string]chan NymReceived
+}
+
+func (r *requestTracker) register(id string) chan NymReceived {
+	r.Lock()
+	defer r.Unlock()
+
+	if nil == r.waiting {
+		r.waiting = make(map[string]chan NymReceived)
+	}
+
+	responseChan := make(chan NymReceived, 1)
+	r.waiting[id] = responseChan
+	return responseChan
+}
+
+func (r *requestTracker) unregister(id string) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.waiting, id)
+}
+
+// responseReceived returns false if no request is waiting for the response, e.g. because it timed out
+func (r *requestTracker) responseReceived(id string, response NymReceived) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	responseChan, ok := r.waiting[id]
+	if ok {
+		responseChan <- response
+		delete(r.waiting, id)
+	}
+	return ok
+}
+
+// SendRequest sends msg, a NymSend or a NymSendAnonymous, and waits for the response of the recipient, sent with Respond.
+// The wait is aborted when ctx is done, a late response is then dropped
+func (n *NymSocketManager) SendRequest(ctx context.Context, msg NymMessage) (NymReceived, error) {
+	id, e := newRandomID()
+	if nil != e {
+		n.logger.Warn().Msg(e.Error())
+		return NymReceived{}, e
+	}
+
+	switch m := msg.(type) {
+	case NymSend:
+		m.Message = requestPrefix + id + ":" + n.GetNymClientId() + ":" + m.Message
+		msg = m
+	case NymSendAnonymous:
+		m.Message = requestPrefix + id + "::" + m.Message
+		msg = m
+	default:
+		err := xerrors.Errorf("%v cannot be sent as a request", msg.Name())
+		n.logger.Warn().Msg(err.Error())
+		return NymReceived{}, err
+	}
+
+	responseChan := n.requests.register(id)
+	defer n.requests.unregister(id)
+
+	// The response carries the same identifier, see Respond
+	e = n.SendWithOptions(msg, WithMessageID(id))
+	if nil != e {
+		return NymReceived{}, e
+	}
+
+	select {
+	case response := <-responseChan:
+		return response, nil
+
+	case <-ctx.Done():
+		err := xerrors.Errorf("no response received to request %v: %w", id, ctx.Err())
+		n.logger.Warn().Msg(err.Error())
+		return NymReceived{}, err
+	}
+}
+
+// Respond answers a request received from a peer using SendRequest
+func (n *NymSocketManager) Respond(request NymReceived, message string) error {
+	if len(request.RequestID) == 0 {
+		err := xerrors.Errorf("cannot respond to a message which is not a request")
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	message = responsePrefix + request.RequestID + ":" + message
+	if request.IsReplyable() {
+		return n.reply(request.SenderTag, message, WithMessageID(request.RequestID))
+	}
+	return n.SendWithOptions(NewNymSend(message, request.ReturnAddress), WithMessageID(request.RequestID))
+}
+
+// correlate strips the envelope of the requests, and hands the responses to the requests waiting for them.
+// It returns false if msg was consumed and must not be passed to the messageHandler
+func (n *NymSocketManager) correlate(msg *NymReceived) bool {
+	if strings.HasPrefix(msg.Message, requestPrefix) {
+		fields := strings.SplitN(strings.TrimPrefix(msg.Message, requestPrefix), ":", 3)
+		if len(fields) != 3 {
+			n.logger.Warn().Msg("dropping malformed request")
+			n.messageDropped(DropMalformed, *msg, msg.MessageID, nil, "malformed request")
+			return false
+		}
+		msg.RequestID, msg.ReturnAddress, msg.Message = fields[0], fields[1], fields[2]
+		msg.MessageID = msg.RequestID
+		return true
+	}
+
+	if strings.HasPrefix(msg.Message, responsePrefix) {
+		id, message, found := strings.Cut(strings.TrimPrefix(msg.Message, responsePrefix), ":")
+		if !found {
+			n.logger.Warn().Msg("dropping malformed response")
+			n.messageDropped(DropMalformed, *msg, msg.MessageID, nil, "malformed response")
+			return false
+		}
+		msg.Message = message
+		msg.MessageID = id
+		n.openEnvelope(msg)
+		if !n.requests.responseReceived(id, *msg) {
+			n.messageLogger.Debug().Str(MessageIDField, id).Msgf("dropping response to unknown request %v", id)
+			n.messageDropped(DropUnmatched, *msg, id, nil, "response to unknown request")
+		}
+		return false
+	}
+
+	return true
+}
+
+// newRandomID returns a random identifier, unique enough to tell apart the messages in flight
+func newRandomID() (string, error) {
+	idBytes := make([]byte, 8)
+	_, e := rand.Read(idBytes)
+	if nil != e {
+		return "", xerrors.Errorf("failed to generate an id: %v", e)
+	}
+	return hex.EncodeToString(idBytes), nil
+}
@@ -0,0 +1,60 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerSendRequest(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	// Requests are sent to ourselves, the handler plays the peer
+	var nymSocketManager *lib.NymSocketManager
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(request lib.NymReceived, _ func(lib.NymMessage) error) {
+		require.NotEmpty(t, request.RequestID)
+		require.NoError(t, nymSocketManager.Respond(request, "pong to "+request.Message))
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for _, request := range []lib.NymMessage{
+		lib.NewNymSend("ping", nymSocketManager.GetNymClientId()),
+		lib.NewNymSendAnonymous("ping", nymSocketManager.GetNymClientId(), 1),
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		response, e := nymSocketManager.SendRequest(ctx, request)
+		cancel()
+		require.NoError(t, e, request.Name())
+		require.Equal(t, "pong to ping", response.Message)
+	}
+
+	_, e = nymSocketManager.SendRequest(context.Background(), lib.NewSelfAddressRequest())
+	require.Error(t, e)
+	require.Error(t, nymSocketManager.Respond(lib.NymReceived{Message: "not a request"}, "pong"))
+}
+
+func TestNymSocketManagerSendRequestTimeout(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, e = nymSocketManager.SendRequest(ctx, lib.NewNymSend("ping", fakePeerAddress))
+	require.ErrorIs(t, e, context.DeadlineExceeded)
+}
@@ -0,0 +1,75 @@
+package nymsocketmanager
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Marks the dummy messages, so that the ones looped back are not passed to the messageHandler
+const coverTrafficPrefix = "\x1eNSMCOVER:"
+
+// CoverTraffic configures the dummy messages sent to flatten the traffic patterns
+type CoverTraffic struct {
+	// Mean time between two dummy messages. The actual delays follow an exponential distribution,
+	// as the cover traffic of the mixnet does, so that they cannot be told apart from real messages by their timing
+	Interval time.Duration
+	// Size of the dummy payloads, in bytes
+	PayloadSize int
+	// Address receiving the dummy messages, our own one if empty
+	Recipient string
+}
+
+// startCoverTraffic sends dummy messages until the manager stops
+// called from methods that already acquired the lock
+func (n *NymSocketManager) startCoverTraffic() {
+	if nil == n.coverTraffic {
+		return
+	}
+
+	n.coverTrafficStopChan = make(chan struct{})
+	go n.sendCoverTraffic(*n.coverTraffic, n.coverTrafficStopChan)
+}
+
+// stopCoverTraffic stops sending dummy messages
+// called from methods that already acquired the lock
+func (n *NymSocketManager) stopCoverTraffic() {
+	if nil != n.coverTrafficStopChan {
+		close(n.coverTrafficStopChan)
+		n.coverTrafficStopChan = nil
+	}
+}
+
+func (n *NymSocketManager) sendCoverTraffic(coverTraffic CoverTraffic, stopChan chan struct{}) {
+	padding := strings.Repeat("0", coverTraffic.PayloadSize)
+
+	for {
+		timer := time.NewTimer(time.Duration(rand.ExpFloat64() * float64(coverTraffic.Interval)))
+		select {
+		case <-stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// Nothing to hide while disconnected, and no reason to open a lazy connection for it
+		if !n.IsReady() {
+			continue
+		}
+
+		recipient := coverTraffic.Recipient
+		if len(recipient) == 0 {
+			recipient = n.GetNymClientId()
+		}
+
+		e := n.send(NewNymSend(coverTrafficPrefix+padding, recipient), nil)
+		if nil != e {
+			n.logger.Debug().Msgf("failed to send cover traffic: %v", e)
+		}
+	}
+}
+
+// isCoverTraffic tells whether a received message is a dummy one
+func isCoverTraffic(msg NymReceived) bool {
+	return strings.HasPrefix(msg.Message, coverTrafficPrefix)
+}
@@ -0,0 +1,44 @@
+package nymsocketmanager_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerCoverTraffic(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	var received atomic.Int32
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(lib.NymReceived, func(lib.NymMessage) error) {
+		received.Add(1)
+	}, lib.ZerologLogger(&logger), lib.WithCoverTraffic(lib.CoverTraffic{Interval: 10 * time.Millisecond, PayloadSize: 64}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	require.Eventually(t, func() bool { return fake.sendRequests.Load() >= 5 }, 2*time.Second, 10*time.Millisecond)
+	nymSocketManager.Stop()
+
+	// The dummy messages looped back are not passed to the handler
+	require.Zero(t, received.Load())
+
+	// Nothing is sent once stopped, once the last messages were read by the nym-client
+	time.Sleep(50 * time.Millisecond)
+	sent := fake.sendRequests.Load()
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, sent, fake.sendRequests.Load())
+}
+
+func TestNymSocketManagerCoverTrafficValidation(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger), lib.WithCoverTraffic(lib.CoverTraffic{}))
+	require.Error(t, e)
+}
@@ -0,0 +1,73 @@
+package nymsocketmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// debugStatus is the document served by DebugHandler
+type debugStatus struct {
+	// The fields logged by MarshalZerologObject, see debugManager
+	Manager json.RawMessage `json:"manager"`
+	Stats   Stats           `json:"stats"`
+	Errors  []debugError    `json:"errors"`
+}
+
+type debugError struct {
+	At       time.Time     `json:"at"`
+	Category ErrorCategory `json:"category"`
+	Error    string        `json:"error"`
+}
+
+// debugManager logs the manager like MarshalZerologObject, but without taking its lock, like HealthStatus,
+// so that the DebugHandler answers while the manager is busy (e.g. connecting).
+// The connection URI and clientID are the ones of the last connection established
+type debugManager struct {
+	*NymSocketManager
+}
+
+func (d debugManager) MarshalZerologObject(e *zerolog.Event) {
+	d.stats.Lock()
+	connectionURI, clientID := d.stats.connectionURI, d.stats.clientID
+	d.stats.Unlock()
+
+	d.marshalConnection(e, connectionURI, clientID)
+	d.marshalUnlocked(e)
+}
+
+// DebugHandler returns an http.Handler serving the status of the manager as JSON: its state and configuration, as logged with
+// MarshalZerologObject and thus without credentials nor payloads, its Stats and its ErrorHistory.
+// It is meant to be mounted on an admin mux, e.g. mux.Handle("/debug/nym", manager.DebugHandler()).
+// Like HealthStatus, it does not take the lock of the manager
+func (n *NymSocketManager) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if http.MethodGet != r.Method && http.MethodHead != r.Method {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var manager bytes.Buffer
+		logger := zerolog.New(&manager)
+		logger.Log().EmbedObject(debugManager{n}).Send()
+
+		status := debugStatus{Manager: manager.Bytes(), Stats: n.Stats(), Errors: []debugError{}}
+		for _, record := range n.ErrorHistory() {
+			status.Errors = append(status.Errors, debugError{At: record.At, Category: record.Category, Error: record.Err.Error()})
+		}
+
+		body, e := json.MarshalIndent(status, "", "  ")
+		if nil != e {
+			n.logger.Warn().Msgf("failed to encode the debug status: %v", e)
+			http.Error(w, e.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(body)
+	})
+}
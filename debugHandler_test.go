@@ -0,0 +1,114 @@
+package nymsocketmanager_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerDebugHandler(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI()+"?token=secret", emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithHeaders(header), lib.WithFallbackURIs("ws://user:secret@127.0.0.1:1"))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Error(t, nymSocketManager.Send(lib.NewNymSend("hello", "invalid")))
+
+	recorder := httptest.NewRecorder()
+	nymSocketManager.DebugHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/nym", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	require.NotContains(t, recorder.Body.String(), "secret")
+
+	var status struct {
+		Manager map[string]interface{}
+		Stats   lib.Stats
+		Errors  []struct {
+			Category string
+			Error    string
+		}
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	require.Equal(t, "Running", status.Manager["state"])
+	require.Equal(t, fakeNymClientAddress, status.Manager["clientID"])
+	require.Equal(t, []interface{}{"Authorization"}, status.Manager["requestHeaders"])
+	require.True(t, strings.HasSuffix(status.Manager["connectionURI"].(string), "?token=xxxxx"))
+	require.Equal(t, uint64(1), status.Stats.MessagesSent[lib.NymSelfAddressRequest{}.Name()])
+	require.Len(t, status.Errors, 1)
+	require.Equal(t, string(lib.ErrorCategorySend), status.Errors[0].Category)
+	require.NotEmpty(t, status.Errors[0].Error)
+
+	recorder = httptest.NewRecorder()
+	nymSocketManager.DebugHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/debug/nym", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestNymSocketManagerDebugHandlerRedactsConnectionErrors(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://user:secret@127.0.0.1:1/?token=secret", emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.Error(t, e)
+
+	recorder := httptest.NewRecorder()
+	nymSocketManager.DebugHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/nym", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.NotContains(t, recorder.Body.String(), "secret")
+
+	var status struct {
+		Errors []struct {
+			Category string
+			Error    string
+		}
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	require.NotEmpty(t, status.Errors)
+	require.Equal(t, string(lib.ErrorCategoryStart), status.Errors[0].Category)
+	require.Contains(t, status.Errors[0].Error, "127.0.0.1:1")
+}
+
+func TestNymSocketManagerDebugHandlerWhileConnecting(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.ignoreSelfAddress = true
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithSelfAddressTimeout(time.Second))
+	require.NoError(t, e)
+	startedChan := make(chan struct{})
+	go func() {
+		defer close(startedChan)
+		_, _ = nymSocketManager.Start()
+	}()
+	defer func() { <-startedChan }()
+	require.Eventually(t, func() bool { return lib.StateHandshaking == nymSocketManager.GetState() }, time.Second, time.Millisecond)
+
+	// Answered while Start holds the lock of the manager during the handshake
+	servedChan := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		recorder := httptest.NewRecorder()
+		nymSocketManager.DebugHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/nym", nil))
+		servedChan <- recorder
+	}()
+	select {
+	case recorder := <-servedChan:
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Contains(t, recorder.Body.String(), `"state": "Handshaking"`)
+	case <-time.After(500 * time.Millisecond):
+		require.Fail(t, "debug status not served while connecting")
+	}
+}
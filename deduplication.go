@@ -0,0 +1,51 @@
+package nymsocketmanager
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// deduplicator remembers the keys of the messages received within the window, see WithDeduplication
+type deduplicator struct {
+	sync.Mutex
+
+	window time.Duration
+	key    func(NymReceived) string
+	seen   map[string]time.Time
+	// Keys in the order they were seen, to forget them once out of the window
+	order []seenKey
+}
+
+type seenKey struct {
+	key string
+	at  time.Time
+}
+
+// ContentKey identifies a received message by the hash of its content, the default key of WithDeduplication
+func ContentKey(received NymReceived) string {
+	hash := sha256.Sum256([]byte(received.Message))
+	return string(hash[:])
+}
+
+// duplicate tells whether a message with the same key as received was seen within the window
+func (d *deduplicator) duplicate(received NymReceived) bool {
+	key := d.key(received)
+	now := time.Now()
+
+	d.Lock()
+	defer d.Unlock()
+
+	for len(d.order) > 0 && now.Sub(d.order[0].at) >= d.window {
+		delete(d.seen, d.order[0].key)
+		d.order[0] = seenKey{}
+		d.order = d.order[1:]
+	}
+
+	if _, ok := d.seen[key]; ok {
+		return true
+	}
+	d.seen[key] = now
+	d.order = append(d.order, seenKey{key, now})
+	return false
+}
@@ -0,0 +1,73 @@
+package nymsocketmanager_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerDeduplication(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handled := make(chan lib.NymReceived, 4)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- received
+	}, lib.ZerologLogger(&logger), lib.WithDeduplication(200*time.Millisecond, nil))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	waitHandled := func(message string) {
+		select {
+		case received := <-handled:
+			require.Equal(t, message, received.Message)
+		case <-time.After(time.Second):
+			t.Fatalf("%v was not handled", message)
+		}
+	}
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	waitHandled("hello")
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("world", fakeNymClientAddress)))
+	waitHandled("world")
+	require.Eventually(t, func() bool { return 1 == nymSocketManager.Stats().DuplicatesDropped }, time.Second, 10*time.Millisecond)
+
+	// Out of the window, the same content is handled again
+	time.Sleep(250 * time.Millisecond)
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	waitHandled("hello")
+	require.Empty(t, handled)
+}
+
+func TestWithDeduplicationKey(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handled := make(chan lib.NymReceived, 2)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- received
+	}, lib.ZerologLogger(&logger), lib.WithDeduplication(time.Minute, func(received lib.NymReceived) string {
+		id, _, _ := strings.Cut(received.Message, ":")
+		return id
+	}))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("1:hello", fakeNymClientAddress)))
+	require.Eventually(t, func() bool { return 1 == len(handled) }, time.Second, 10*time.Millisecond)
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("1:hello again", fakeNymClientAddress)))
+	require.Eventually(t, func() bool { return 1 == nymSocketManager.Stats().DuplicatesDropped }, time.Second, 10*time.Millisecond)
+	require.Len(t, handled, 1)
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithDeduplication(0, nil))
+	require.Error(t, e)
+}
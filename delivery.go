@@ -0,0 +1,195 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+const (
+	// Mark the payloads of the messages to acknowledge, followed by "<id>:<payload>".
+	// They are sent with a reply SURB, the acknowledgement going through their sender tag
+	confirmedPrefix = "\x1eNSMCONFIRM:"
+	// Mark the acknowledgements, followed by "<id>"
+	acknowledgementPrefix = "\x1eNSMACK:"
+)
+
+// ErrDeliveryNotConfirmed is reported when a message sent with WithConfirmedDelivery is not acknowledged in time
+var ErrDeliveryNotConfirmed = xerrors.New("delivery not confirmed")
+
+type deliveryConfirmation struct {
+	// No timeout if 0
+	timeout    time.Duration
+	onDelivery func(err error)
+	// Set once the delivery is registered
+	id string
+}
+
+// WithConfirmedDelivery makes the recipient NymSocketManager acknowledge the message, a NymSend or a NymSendAnonymous,
+// once its messageHandler handled it without error. A NymSend is sent as a NymSendAnonymous with a reply SURB, for the
+// acknowledgement to be sent back through it.
+// onDelivery is called with nil once the acknowledgement is received, or with an error wrapping ErrDeliveryNotConfirmed
+// if it is not within timeout (never if 0), or with the error of the send. Without this option, messages are sent fire-and-forget.
+// onDelivery is called from the goroutine handling the acknowledgement: it must not call Start, Stop or Restart
+func WithConfirmedDelivery(timeout time.Duration, onDelivery func(err error)) SendOption {
+	return func(o *sendOptions) {
+		o.confirmation = &deliveryConfirmation{timeout: timeout, onDelivery: onDelivery}
+	}
+}
+
+// deliveryTracker holds the messages waiting for their acknowledgement
+type deliveryTracker struct {
+	sync.Mutex
+
+	pending map[string]*pendingDelivery
+}
+
+type pendingDelivery struct {
+	onDelivery func(err error)
+	timer      *time.Timer
+}
+
+func (d *deliveryTracker) register(id string, confirmation *deliveryConfirmation) {
+	d.Lock()
+	defer d.Unlock()
+
+	if nil == d.pending {
+		d.pending = make(map[string]*pendingDelivery)
+	}
+
+	pending := &pendingDelivery{onDelivery: confirmation.onDelivery}
+	if 0 != confirmation.timeout {
+		pending.timer = time.AfterFunc(confirmation.timeout, func() {
+			d.done(id, xerrors.Errorf("no acknowledgement received within %v for message %v: %w", confirmation.timeout, id, ErrDeliveryNotConfirmed))
+		})
+	}
+	d.pending[id] = pending
+	confirmation.id = id
+}
+
+// remove forgets a delivery without notifying it, if it is still pending
+func (d *deliveryTracker) remove(id string) {
+	d.Lock()
+	pending, ok := d.pending[id]
+	delete(d.pending, id)
+	d.Unlock()
+
+	if ok && nil != pending.timer {
+		pending.timer.Stop()
+	}
+}
+
+// done notifies the outcome of the delivery, and returns false if it was notified already
+func (d *deliveryTracker) done(id string, err error) bool {
+	d.Lock()
+	pending, ok := d.pending[id]
+	delete(d.pending, id)
+	d.Unlock()
+
+	if !ok {
+		return false
+	}
+	if nil != pending.timer {
+		pending.timer.Stop()
+	}
+	if nil != pending.onDelivery {
+		pending.onDelivery(err)
+	}
+	return true
+}
+
+// requestConfirmation wraps the payload of msg so that the recipient acknowledges it, and returns the id of the delivery
+func (n *NymSocketManager) requestConfirmation(msg NymMessage, confirmation *deliveryConfirmation) (NymMessage, string, error) {
+	id, e := newRandomID()
+	if nil != e {
+		n.logger.Warn().Msg(e.Error())
+		return nil, "", e
+	}
+
+	switch m := msg.(type) {
+	case NymSend:
+		msg = NewNymSendAnonymous(confirmedPrefix+id+":"+m.Message, m.Recipient, 1)
+	case NymSendAnonymous:
+		m.Message = confirmedPrefix + id + ":" + m.Message
+		if 0 == m.ReplySurbs {
+			m.ReplySurbs = 1
+		}
+		msg = m
+	default:
+		err := xerrors.Errorf("delivery of %v cannot be confirmed", msg.Name())
+		n.logger.Warn().Msg(err.Error())
+		return nil, "", err
+	}
+
+	n.deliveries.register(id, confirmation)
+	return msg, id, nil
+}
+
+// SendConfirmed sends msg, a NymSend or a NymSendAnonymous, and waits until the recipient acknowledges it.
+// The wait is aborted when ctx is done
+func (n *NymSocketManager) SendConfirmed(ctx context.Context, msg NymMessage) error {
+	// Let the context decide when to stop waiting
+	deliveredChan := make(chan error, 1)
+	confirmation := &deliveryConfirmation{onDelivery: func(err error) { deliveredChan <- err }}
+	e := n.SendWithOptions(msg, func(o *sendOptions) { o.confirmation = confirmation })
+	defer n.deliveries.remove(confirmation.id)
+	if nil != e {
+		return e
+	}
+
+	select {
+	case e = <-deliveredChan:
+		return e
+	case <-ctx.Done():
+		err := xerrors.Errorf("delivery of %v not confirmed: %w", msg.Name(), ctx.Err())
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+}
+
+// acknowledge strips the envelope of the messages to acknowledge, which are acknowledged once handled, see acknowledgeHandled,
+// and notifies the acknowledgements received. It returns false if msg was consumed and must not be passed to the messageHandler
+func (n *NymSocketManager) acknowledge(msg *NymReceived) bool {
+	if strings.HasPrefix(msg.Message, confirmedPrefix) {
+		id, payload, ok := strings.Cut(strings.TrimPrefix(msg.Message, confirmedPrefix), ":")
+		if !ok {
+			n.logger.Warn().Msg("dropping malformed message to acknowledge")
+			n.messageDropped(DropMalformed, *msg, msg.MessageID, nil, "malformed message to acknowledge")
+			return false
+		}
+		msg.Message = payload
+		msg.MessageID = id
+		if msg.IsReplyable() {
+			msg.acknowledgement = id
+		} else {
+			n.logger.Warn().Str(MessageIDField, id).Msgf("cannot acknowledge message %v received without reply SURB", id)
+		}
+		return true
+	}
+
+	if strings.HasPrefix(msg.Message, acknowledgementPrefix) {
+		id := strings.TrimPrefix(msg.Message, acknowledgementPrefix)
+		if !n.deliveries.done(id, nil) {
+			n.messageLogger.Debug().Str(MessageIDField, id).Msgf("dropping acknowledgement of unknown message %v", id)
+			n.messageDropped(DropUnmatched, *msg, id, nil, "acknowledgement of unknown message")
+		}
+		return false
+	}
+
+	return true
+}
+
+// acknowledgeHandled acknowledges received once the messageHandler handled it, if it is to be acknowledged
+func (n *NymSocketManager) acknowledgeHandled(received NymReceived) {
+	if len(received.acknowledgement) == 0 {
+		return
+	}
+
+	e := n.reply(received.SenderTag, acknowledgementPrefix+received.acknowledgement, WithMessageID(received.acknowledgement))
+	if nil != e {
+		n.logger.Warn().Str(MessageIDField, received.acknowledgement).Msgf("failed to acknowledge message %v: %v", received.acknowledgement, e)
+	}
+}
@@ -0,0 +1,105 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerConfirmedDelivery(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan string, 2)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received.Message
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	deliveredChan := make(chan error, 1)
+	require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend("hello", nymSocketManager.GetNymClientId()),
+		lib.WithConfirmedDelivery(time.Second, func(err error) { deliveredChan <- err })))
+
+	select {
+	case e := <-deliveredChan:
+		require.NoError(t, e)
+	case <-time.After(time.Second):
+		require.Fail(t, "delivery not notified")
+	}
+	// The recipient handles the payload only, the acknowledgement being consumed by the sender
+	require.Equal(t, "hello", <-receivedChan)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, nymSocketManager.SendConfirmed(ctx, lib.NewNymSendAnonymous("anonymous", nymSocketManager.GetNymClientId(), 1)))
+	require.Equal(t, "anonymous", <-receivedChan)
+
+	// The peer never acknowledges
+	require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend("hello", fakePeerAddress),
+		lib.WithConfirmedDelivery(50*time.Millisecond, func(err error) { deliveredChan <- err })))
+	select {
+	case e := <-deliveredChan:
+		require.ErrorIs(t, e, lib.ErrDeliveryNotConfirmed)
+	case <-time.After(time.Second):
+		require.Fail(t, "delivery not notified")
+	}
+
+	require.Error(t, nymSocketManager.SendConfirmed(ctx, lib.NewNymReply(fakeNymClientSenderTag, "reply")))
+	require.Empty(t, receivedChan)
+}
+
+func TestNymSocketManagerSendConfirmedCancelled(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// Without deadline, the delivery is forgotten once the wait is aborted
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	require.ErrorIs(t, nymSocketManager.SendConfirmed(ctx, lib.NewNymSend("hello", fakePeerAddress)), context.Canceled)
+	require.Zero(t, nymSocketManager.PendingDeliveries())
+}
+
+func TestNymSocketManagerConfirmedDeliveryNotHandled(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	nymSocketManager.AddFilter("drop", func(received lib.NymReceived) bool { return "dropped" != received.Message })
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// Only the messages reaching the messageHandler are acknowledged
+	deliveredChan := make(chan error, 2)
+	for _, message := range []string{"dropped", "handled"} {
+		require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend(message, nymSocketManager.GetNymClientId()),
+			lib.WithConfirmedDelivery(200*time.Millisecond, func(err error) { deliveredChan <- err })))
+		select {
+		case e := <-deliveredChan:
+			if "dropped" == message {
+				require.ErrorIs(t, e, lib.ErrDeliveryNotConfirmed)
+			} else {
+				require.NoError(t, e)
+			}
+		case <-time.After(time.Second):
+			require.Fail(t, "delivery not notified")
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package nymsocketmanager
+
+import (
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// The payloads are not logged, only their length, so that diagnostics do not leak the content of the messages.
+// Neither are the credentials of the connection URIs and the values of the request headers
+
+// MarshalZerologObject logs the configuration and the state of the manager, e.g. with logger.Info().Object("manager", n).
+// It takes the lock of the manager: it must not be called from the lifecycle hooks
+func (n *NymSocketManager) MarshalZerologObject(e *zerolog.Event) {
+	n.Lock()
+	n.marshalConnection(e, redactURI(n.connectionURIs[n.connectionURIIndex]), n.clientID)
+	if nil != n.stopReason {
+		e.Str("stopReason", n.stopReason.Error())
+	}
+	listener := n.socketListener
+	n.Unlock()
+
+	n.marshalUnlocked(e)
+	if nil != listener {
+		e.Object("socketListener", listener)
+	}
+}
+
+// marshalConnection logs the connection URIs and the names of the request headers, with connectionURI and clientID the current ones
+func (n *NymSocketManager) marshalConnection(e *zerolog.Event, connectionURI string, clientID string) {
+	connectionURIs := make([]string, len(n.connectionURIs))
+	for i, uri := range n.connectionURIs {
+		connectionURIs[i] = redactURI(uri)
+	}
+	e.Strs("connectionURIs", connectionURIs).
+		Str("connectionURI", connectionURI).
+		Str("clientID", clientID)
+	if len(n.requestHeader) != 0 {
+		names := make([]string, 0, len(n.requestHeader))
+		for name := range n.requestHeader {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		e.Strs("requestHeaders", names)
+	}
+}
+
+// marshalUnlocked logs the fields of MarshalZerologObject that are read without the lock of the manager:
+// its configuration, set by the options only, and its state and Stats
+func (n *NymSocketManager) marshalUnlocked(e *zerolog.Event) {
+	e.Stringer("state", n.GetState()).
+		Bool("started", n.started.Load()).
+		Bool("paused", n.IsPaused()).
+		Stringer("clientEncoding", n.GetClientEncoding()).
+		Stringer("wireEncoding", n.wireEncoding).
+		Str("codec", n.codec.Name()).
+		Bool("lazyConnection", n.lazyConnection).
+		Int("poolSize", n.pool.size).
+		Bool("recipientSharding", n.shards.enabled).
+		Bool("compression", n.dialer.EnableCompression).
+		Int("chunkSize", n.chunkSize).
+		Uint("defaultReplySurbs", n.defaultReplySurbs).
+		Bool("coverTraffic", nil != n.coverTraffic).
+		Bool("latencyProbe", nil != n.probe).
+		Bool("connectionQuality", nil != n.quality).
+		Bool("auditLog", n.IsAuditLogEnabled()).
+		Bool("logSampling", nil != n.logSampler).
+		Bool("payloadRedaction", n.redactPayloads).
+		Bool("surbManagement", nil != n.surbManagement).
+		Bool("tracing", nil != n.tracer).
+		Bool("tracePropagation", n.tracePropagation).
+		Dur("selfAddressTimeout", n.selfAddressTimeout).
+		Dur("drainTimeout", n.drainTimeout).
+		Dur("handlerDrainTimeout", n.handlerDrainTimeout).
+		Dur("writeTimeout", n.writeTimeout).
+		Dur("readIdleTimeout", n.readIdleTimeout)
+	if nil != n.throttle {
+		e.Float64("maxSendRate", n.throttle.config.MaxRate)
+	}
+	if nil != n.replayBuffer {
+		e.Int("replayBufferCapacity", n.replayBuffer.capacity)
+	}
+	if nil != n.profiling {
+		e.Str("profiledHandler", n.profiling.HandlerName)
+	}
+	if nil != n.handlerPool {
+		e.Int("handlerWorkers", n.handlerPool.config.Workers)
+		e.Bool("handlerSharded", nil != n.handlerPool.config.ShardKey)
+	}
+	if nil != n.asyncSender {
+		e.Int("sendQueueLength", n.asyncSender.queueLength)
+	}
+	if nil != n.sendQueue {
+		e.Int("starvationLimit", n.sendQueue.starvationLimit)
+	}
+
+	stats := n.Stats()
+	e.Uint64("bytesSent", stats.BytesSent).
+		Uint64("bytesReceived", stats.BytesReceived).
+		Uint64("reconnects", stats.Reconnects).
+		Dur("handshakeDuration", stats.HandshakeDuration).
+		Int("replayBufferDepth", stats.ReplayBufferDepth).
+		Int("handlerQueueDepth", stats.HandlerQueueDepth).
+		Int("sendQueueDepth", stats.SendQueueDepth).
+		Int("pausedMessages", stats.PausedMessages)
+	if nil != n.throttle {
+		e.Float64("sendThrottleRate", stats.SendThrottleRate)
+	}
+	if !stats.LastSentAt.IsZero() {
+		e.Time("lastSentAt", stats.LastSentAt)
+	}
+	if !stats.LastReceivedAt.IsZero() {
+		e.Time("lastReceivedAt", stats.LastReceivedAt)
+	}
+}
+
+// redactURI hides the password and the query parameters of uri, which can hold credentials
+func redactURI(uri string) string {
+	u, e := url.Parse(uri)
+	if nil != e {
+		return "<unparsable URI>"
+	}
+	if len(u.RawQuery) != 0 {
+		query := u.Query()
+		for key := range query {
+			query.Set(key, "xxxxx")
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// MarshalZerologObject logs the state of the SocketListener
+func (s *SocketListener) MarshalZerologObject(e *zerolog.Event) {
+	e.Time("lastReadAt", time.Unix(0, s.lastReadAt.Load())).
+		Bool("closeRequested", s.closeRequested.Load()).
+		Bool("ignoreAbnormalClosure", s.ignoreAbnormalClosure).
+		Bool("dispatchInline", s.dispatchInline)
+	if s.readStallThreshold > 0 {
+		e.Dur("readStallThreshold", s.readStallThreshold)
+	}
+}
+
+func (n NymError) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Str("message", n.Message)
+}
+
+func (n NymSelfAddressRequest) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type)
+}
+
+func (n NymSelfAddressReply) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Str("address", n.Address)
+}
+
+func (n NymSend) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Str("recipient", n.Recipient).Int("messageLength", len(n.Message))
+}
+
+func (n NymSendAnonymous) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Str("recipient", n.Recipient).Uint("replySurbs", n.ReplySurbs).Int("messageLength", len(n.Message))
+}
+
+func (n NymReceived) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Int("messageLength", len(n.Message))
+	if n.IsReplyable() {
+		e.Str("senderTag", n.SenderTag)
+	}
+	if !n.ReceivedAt.IsZero() {
+		e.Time("receivedAt", n.ReceivedAt)
+	}
+	e.Bool("binary", n.Binary).Int("chunks", n.Chunks)
+	if len(n.RequestID) != 0 {
+		e.Str("requestID", n.RequestID).Str("returnAddress", n.ReturnAddress)
+	}
+}
+
+func (n NymReply) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Str("senderTag", n.SenderTag).Int("messageLength", len(n.Message))
+}
+
+func (n NymLaneQueueLengthRequest) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Uint64("connectionId", n.ConnectionID)
+}
+
+func (n NymLaneQueueLength) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Uint64("lane", n.Lane).Uint64("queueLength", n.QueueLength)
+}
+
+func (n NymControlMessage) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("type", n.Type).Int("rawLength", len(n.Raw))
+}
@@ -0,0 +1,58 @@
+package nymsocketmanager_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// logObject logs object with a JSON logger and returns the fields it produced
+func logObject(t *testing.T, object zerolog.LogObjectMarshaler) map[string]interface{} {
+	var buffer bytes.Buffer
+	logger := zerolog.New(&buffer)
+	logger.Info().Object("object", object).Send()
+
+	var line struct {
+		Object map[string]interface{} `json:"object"`
+	}
+	require.NoError(t, json.Unmarshal(buffer.Bytes(), &line))
+	return line.Object
+}
+
+func TestNymSocketManagerMarshalZerologObject(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithReplayBuffer(3, lib.OverflowDropOldest))
+	require.NoError(t, e)
+
+	fields := logObject(t, nymSocketManager)
+	require.Equal(t, "Disconnected", fields["state"])
+	require.Equal(t, fake.URI(), fields["connectionURI"])
+	require.Equal(t, float64(3), fields["replayBufferCapacity"])
+	require.NotContains(t, fields, "socketListener")
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	fields = logObject(t, nymSocketManager)
+	require.Equal(t, "Running", fields["state"])
+	require.Equal(t, fakeNymClientAddress, fields["clientID"])
+	require.Equal(t, true, fields["started"])
+	require.Contains(t, fields, "socketListener")
+	require.Contains(t, fields, "lastSentAt")
+}
+
+func TestNymMessageMarshalZerologObject(t *testing.T) {
+	fields := logObject(t, lib.NewNymSend("secret", fakePeerAddress).(lib.NymSend))
+	require.Equal(t, map[string]interface{}{"type": lib.NymSendType, "recipient": fakePeerAddress, "messageLength": float64(6)}, fields)
+
+	fields = logObject(t, lib.NymReceived{Message: "secret", SenderTag: fakeNymClientSenderTag, Chunks: 1})
+	require.Equal(t, fakeNymClientSenderTag, fields["senderTag"])
+	require.NotContains(t, fields, "message")
+}
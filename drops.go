@@ -0,0 +1,31 @@
+package nymsocketmanager
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DropHandler is called for each message dropped by the manager, see WithDropHandler.
+// msg is nil if the frame could not be parsed, raw is the frame read, or the JSON encoding of msg if the frame is not known.
+// Both are nil for the oversized frames, which are not kept in memory
+type DropHandler func(reason DropReason, msg NymMessage, raw []byte)
+
+// messageDropped accounts for a message dropped, raw being the frame it was read from if known
+func (n *NymSocketManager) messageDropped(reason DropReason, msg NymMessage, messageID string, raw []byte, detail string) {
+	n.stats.dropped(reason)
+	if nil != n.dropHandler {
+		if nil == raw && nil != msg {
+			raw, _ = json.Marshal(msg)
+		}
+		n.dropHandler(reason, msg, raw)
+	}
+	n.events.messageDropped(reason, n.loggable(msg), messageID, detail)
+}
+
+// oversized accounts for a frame larger than the maximum message size, before passing it to the callback of WithMaxMessageSize
+func (n *NymSocketManager) oversized(size int64) {
+	n.messageDropped(DropOversized, nil, "", nil, fmt.Sprintf("frame of %d bytes", size))
+	if nil != n.onOversized {
+		n.onOversized(size)
+	}
+}
@@ -0,0 +1,125 @@
+package nymsocketmanager_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// dropRecorder keeps the arguments of the calls to a DropHandler
+type dropRecorder struct {
+	sync.Mutex
+	reasons  []lib.DropReason
+	messages []lib.NymMessage
+	raws     []string
+}
+
+func (r *dropRecorder) onDrop(reason lib.DropReason, msg lib.NymMessage, raw []byte) {
+	r.Lock()
+	defer r.Unlock()
+	r.reasons = append(r.reasons, reason)
+	r.messages = append(r.messages, msg)
+	r.raws = append(r.raws, string(raw))
+}
+
+func (r *dropRecorder) count() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.reasons)
+}
+
+func TestNymSocketManagerDropAccounting(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	recorder := &dropRecorder{}
+	oversizedChan := make(chan int64, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithDropHandler(recorder.onDrop),
+		lib.WithMaxMessageSize(1024, lib.OversizeSkip, func(size int64) { oversizedChan <- size }))
+	require.NoError(t, e)
+	nymSocketManager.AddFilter("none", func(lib.NymReceived) bool { return false })
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// One at a time, as the frames are dispatched concurrently
+	frames := []interface{}{
+		[]int{1},
+		map[string]interface{}{"senderTag": fakeNymClientSenderTag},
+		map[string]interface{}{"type": "mystery"},
+		map[string]interface{}{"type": "received", "message": strings.Repeat("a", 2048)},
+		map[string]interface{}{"type": "received", "message": "filtered"},
+	}
+	for i, frame := range frames {
+		fake.deliver(frame)
+		require.Eventually(t, func() bool { return i+1 == recorder.count() }, time.Second, time.Millisecond)
+	}
+
+	// The caller of WithMaxMessageSize is still notified
+	require.Greater(t, <-oversizedChan, int64(2048))
+
+	require.Equal(t, map[lib.DropReason]uint64{
+		lib.DropMalformed:   2,
+		lib.DropUnknownType: 1,
+		lib.DropOversized:   1,
+		lib.DropFiltered:    1,
+	}, nymSocketManager.Stats().MessagesDropped)
+
+	recorder.Lock()
+	defer recorder.Unlock()
+	require.Equal(t, []lib.DropReason{lib.DropMalformed, lib.DropMalformed, lib.DropUnknownType, lib.DropOversized, lib.DropFiltered}, recorder.reasons)
+	// The frames which could not be parsed are passed as read
+	require.Nil(t, recorder.messages[0])
+	require.Equal(t, "[1]", strings.TrimSpace(recorder.raws[0]))
+	require.IsType(t, lib.NymControlMessage{}, recorder.messages[2])
+	require.Contains(t, recorder.raws[2], "mystery")
+	// The oversized frames are not kept
+	require.Nil(t, recorder.messages[3])
+	require.Empty(t, recorder.raws[3])
+	// The messages dropped once parsed are passed encoded
+	require.Equal(t, "filtered", recorder.messages[4].(lib.NymReceived).Message)
+	require.Contains(t, recorder.raws[4], `"message":"filtered"`)
+}
+
+func TestNymSocketManagerEnvelopeDrops(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	recorder := &dropRecorder{}
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithDropHandler(recorder.onDrop))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for i, message := range []string{
+		"\x1eNSMSTREAM:malformed",
+		"\x1eNSMRSP:malformed",
+		"\x1eNSMRSP:unknown:response",
+		"\x1eNSMACK:unknown",
+	} {
+		fake.deliver(map[string]interface{}{"type": "received", "message": message})
+		require.Eventually(t, func() bool { return i+1 == recorder.count() }, time.Second, time.Millisecond)
+	}
+
+	require.Equal(t, map[lib.DropReason]uint64{
+		lib.DropMalformed: 2,
+		lib.DropUnmatched: 2,
+	}, nymSocketManager.Stats().MessagesDropped)
+	recorder.Lock()
+	defer recorder.Unlock()
+	require.Equal(t, []lib.DropReason{lib.DropMalformed, lib.DropMalformed, lib.DropUnmatched, lib.DropUnmatched}, recorder.reasons)
+}
+
+func TestWithDropHandlerNil(t *testing.T) {
+	logger := zerolog.Logger{}
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1:1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithDropHandler(nil))
+	require.Error(t, e)
+}
@@ -0,0 +1,97 @@
+package nymsocketmanager
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Marks the payloads wrapped in an Envelope, followed by the url-encoded headers, ":" and the body
+const envelopePrefix = "\x1eNSMENV:"
+
+// Headers of the Envelope with a field of their own
+const (
+	ContentTypeHeader   = "content-type"
+	CorrelationIDHeader = "correlation-id"
+)
+
+// ErrNotEnvelope is returned by DecodeEnvelope for the payloads which were not encoded with EncodeEnvelope
+var ErrNotEnvelope = xerrors.New("payload is not an envelope")
+
+// Envelope wraps an application payload with headers, so that services built on this package can exchange correlation IDs
+// and content types whatever their payloads. It is encoded as "\x1eNSMENV:", the headers in URL query encoding, ":" and the body,
+// so that it can be produced and parsed in any language
+type Envelope struct {
+	// e.g. "application/json"
+	ContentType string
+	// Carried over from the request to the response, or through a whole workflow, see NymReceived.MessageID
+	CorrelationID string
+	// The other headers, the keys being case-sensitive
+	Headers map[string]string
+	Body    string
+}
+
+// EncodeEnvelope returns the payload carrying envelope, to be sent as the message of a NymSend, NymSendAnonymous or NymReply
+func EncodeEnvelope(envelope Envelope) string {
+	headers := make(url.Values, len(envelope.Headers)+2)
+	for key, value := range envelope.Headers {
+		headers.Set(key, value)
+	}
+	if len(envelope.ContentType) != 0 {
+		headers.Set(ContentTypeHeader, envelope.ContentType)
+	}
+	if len(envelope.CorrelationID) != 0 {
+		headers.Set(CorrelationIDHeader, envelope.CorrelationID)
+	}
+	return envelopePrefix + headers.Encode() + ":" + envelope.Body
+}
+
+// DecodeEnvelope returns the envelope carried by payload, failing with ErrNotEnvelope if payload does not carry one
+func DecodeEnvelope(payload string) (Envelope, error) {
+	if !strings.HasPrefix(payload, envelopePrefix) {
+		return Envelope{}, ErrNotEnvelope
+	}
+	encodedHeaders, body, found := strings.Cut(strings.TrimPrefix(payload, envelopePrefix), ":")
+	if !found {
+		return Envelope{}, xerrors.Errorf("malformed envelope: no end of headers")
+	}
+	headers, e := url.ParseQuery(encodedHeaders)
+	if nil != e {
+		return Envelope{}, xerrors.Errorf("malformed envelope headers: %v", e)
+	}
+
+	envelope := Envelope{
+		ContentType:   headers.Get(ContentTypeHeader),
+		CorrelationID: headers.Get(CorrelationIDHeader),
+		Body:          body,
+	}
+	headers.Del(ContentTypeHeader)
+	headers.Del(CorrelationIDHeader)
+	if len(headers) != 0 {
+		envelope.Headers = make(map[string]string, len(headers))
+		for key := range headers {
+			envelope.Headers[key] = headers.Get(key)
+		}
+	}
+	return envelope, nil
+}
+
+// openEnvelope replaces the payload of received by the body of its envelope, if any, keeping the envelope aside.
+// The messages with a malformed envelope are passed as is
+func (n *NymSocketManager) openEnvelope(received *NymReceived) {
+	if !strings.HasPrefix(received.Message, envelopePrefix) {
+		return
+	}
+	envelope, e := DecodeEnvelope(received.Message)
+	if nil != e {
+		n.messageLogger.Debug().Str(MessageIDField, received.MessageID).Msgf("passing message as is: %v", e)
+		return
+	}
+
+	received.Message = envelope.Body
+	received.Envelope = &envelope
+	if len(received.MessageID) == 0 {
+		received.MessageID = envelope.CorrelationID
+	}
+}
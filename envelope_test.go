@@ -0,0 +1,71 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestEnvelope(t *testing.T) {
+	envelope := lib.Envelope{
+		ContentType:   "application/json",
+		CorrelationID: "order-42",
+		Headers:       map[string]string{"service": "billing&co", "empty": ""},
+		Body:          `{"kind":"invoice","id":"a:b"}`,
+	}
+	payload := lib.EncodeEnvelope(envelope)
+
+	decoded, e := lib.DecodeEnvelope(payload)
+	require.NoError(t, e)
+	require.Equal(t, envelope, decoded)
+
+	decoded, e = lib.DecodeEnvelope(lib.EncodeEnvelope(lib.Envelope{Body: "plain"}))
+	require.NoError(t, e)
+	require.Equal(t, lib.Envelope{Body: "plain"}, decoded)
+
+	_, e = lib.DecodeEnvelope("plain")
+	require.True(t, xerrors.Is(e, lib.ErrNotEnvelope))
+	_, e = lib.DecodeEnvelope("\x1eNSMENV:no-end-of-headers")
+	require.Error(t, e)
+	require.False(t, xerrors.Is(e, lib.ErrNotEnvelope))
+}
+
+func TestNymSocketManagerEnvelope(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	payload := lib.EncodeEnvelope(lib.Envelope{ContentType: "text/plain", CorrelationID: "order-42", Body: "hello"})
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(payload, fakeNymClientAddress)))
+
+	// The messageHandler gets the body, the envelope being kept aside
+	var received lib.NymReceived
+	select {
+	case received = <-receivedChan:
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+	require.Equal(t, "hello", received.Message)
+	require.NotNil(t, received.Envelope)
+	require.Equal(t, "text/plain", received.Envelope.ContentType)
+	require.Equal(t, "order-42", received.MessageID)
+
+	// The other messages are passed as is
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("\x1eNSMENV:malformed", fakeNymClientAddress)))
+	received = <-receivedChan
+	require.Equal(t, "\x1eNSMENV:malformed", received.Message)
+	require.Nil(t, received.Envelope)
+}
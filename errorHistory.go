@@ -0,0 +1,79 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"time"
+)
+
+// Number of errors kept by default, see WithErrorHistory
+const DefaultErrorHistorySize = 32
+
+// ErrorCategory tells where an error recorded in the history comes from
+type ErrorCategory string
+
+const (
+	// Start or Restart failed
+	ErrorCategoryStart ErrorCategory = "start"
+	// A Send, or a method built on it, failed
+	ErrorCategorySend ErrorCategory = "send"
+	// The connection to a nym-client was lost, or could not be replaced
+	ErrorCategoryConnection ErrorCategory = "connection"
+	// The nym-client reported an error, see NymError
+	ErrorCategoryNym ErrorCategory = "nym"
+	// The messageHandler, or its reply function, failed
+	ErrorCategoryHandler ErrorCategory = "handler"
+)
+
+// ErrorRecord is an error kept in the history, see ErrorHistory
+type ErrorRecord struct {
+	At       time.Time
+	Category ErrorCategory
+	Err      error
+}
+
+// errorHistory is a ring buffer of the last errors
+// It has its own lock so that errors can be recorded while the NymSocketManager is busy (e.g. connecting)
+type errorHistory struct {
+	sync.Mutex
+
+	records []ErrorRecord
+	// Where the next record goes, once records is full
+	next int
+}
+
+func (h *errorHistory) record(category ErrorCategory, err error) {
+	h.Lock()
+	defer h.Unlock()
+
+	record := ErrorRecord{At: time.Now(), Category: category, Err: err}
+	if len(h.records) < cap(h.records) {
+		h.records = append(h.records, record)
+		return
+	}
+	h.records[h.next] = record
+	h.next = (h.next + 1) % len(h.records)
+}
+
+// LastError returns the last error recorded in the history, nil if none.
+// See ErrorHistory for when and where it occurred
+func (n *NymSocketManager) LastError() error {
+	n.errors.Lock()
+	defer n.errors.Unlock()
+
+	if len(n.errors.records) == 0 {
+		return nil
+	}
+	return n.errors.records[(n.errors.next+len(n.errors.records)-1)%len(n.errors.records)].Err
+}
+
+// ErrorHistory returns the last errors of the manager, the oldest first: the failures of Start and Send,
+// the connections lost, the errors reported by the nym-client and the failures of the messageHandler.
+// The number of errors kept is set with WithErrorHistory
+func (n *NymSocketManager) ErrorHistory() []ErrorRecord {
+	n.errors.Lock()
+	defer n.errors.Unlock()
+
+	history := make([]ErrorRecord, 0, len(n.errors.records))
+	history = append(history, n.errors.records[n.errors.next:]...)
+	return append(history, n.errors.records[:n.errors.next]...)
+}
@@ -0,0 +1,87 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerErrorHistory(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	require.NoError(t, nymSocketManager.LastError())
+	require.Empty(t, nymSocketManager.ErrorHistory())
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	sendError := nymSocketManager.Send(lib.NewNymSend("hello", "invalid"))
+	require.Error(t, sendError)
+	require.Equal(t, sendError, nymSocketManager.LastError())
+
+	fake.deliver(map[string]interface{}{"type": "error", "message": "lane is full"})
+	require.Eventually(t, func() bool { return len(nymSocketManager.ErrorHistory()) == 2 }, time.Second, 10*time.Millisecond)
+
+	history := nymSocketManager.ErrorHistory()
+	require.Equal(t, lib.ErrorCategorySend, history[0].Category)
+	require.Equal(t, sendError, history[0].Err)
+	require.Equal(t, lib.ErrorCategoryNym, history[1].Category)
+	require.ErrorIs(t, history[1].Err, lib.ErrNymLaneFull)
+	require.False(t, history[1].At.Before(history[0].At))
+	require.ErrorIs(t, nymSocketManager.LastError(), lib.ErrNymLaneFull)
+}
+
+func TestNymSocketManagerErrorHistoryStart(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://127.0.0.1:1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithErrorHistory(2))
+	require.NoError(t, e)
+
+	for i := 0; i < 3; i++ {
+		_, e = nymSocketManager.Start()
+		require.Error(t, e)
+	}
+
+	// Only the last ones are kept
+	history := nymSocketManager.ErrorHistory()
+	require.Len(t, history, 2)
+	for _, record := range history {
+		require.Equal(t, lib.ErrorCategoryStart, record.Category)
+	}
+	require.Equal(t, e, history[1].Err)
+	require.Equal(t, e, nymSocketManager.LastError())
+}
+
+func TestNymSocketManagerErrorHistoryConnectionLost(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	stopped, e := nymSocketManager.Start()
+	require.NoError(t, e)
+	fake.dropConnections()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "manager not stopped")
+	}
+	history := nymSocketManager.ErrorHistory()
+	require.NotEmpty(t, history)
+	require.Equal(t, lib.ErrorCategoryConnection, history[len(history)-1].Category)
+}
+
+func TestWithErrorHistoryInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1:1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithErrorHistory(0))
+	require.Error(t, e)
+}
@@ -0,0 +1,35 @@
+package nymsocketmanager
+
+import "golang.org/x/xerrors"
+
+// Failure modes of the manager, matched with errors.Is against the errors it returns
+var (
+	// The manager is not started, or is stopping
+	ErrNotStarted = xerrors.New("NymSocketManager is not started")
+	// The nym-client did not answer the selfAddress request, the error also matching context.DeadlineExceeded
+	ErrHandshakeTimeout = xerrors.New("handshake timed out")
+	// The connection to the nym-client is closed, or was lost
+	ErrConnectionClosed = xerrors.New("connection to the nym-client is closed")
+	// A message could not be encoded to be written
+	ErrMarshal = xerrors.New("failed to marshal message")
+	// A message could not be written on the connection
+	ErrWriteFailed = xerrors.New("failed to write message")
+)
+
+// timeoutError is a context error, also matching the failure mode which timed out
+type timeoutError struct {
+	mode error
+	err  error
+}
+
+func (t *timeoutError) Error() string {
+	return t.mode.Error() + ": " + t.err.Error()
+}
+
+func (t *timeoutError) Is(target error) bool {
+	return t.mode == target
+}
+
+func (t *timeoutError) Unwrap() error {
+	return t.err
+}
@@ -0,0 +1,96 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestNymSocketManagerErrNotStarted(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	require.ErrorIs(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)), lib.ErrNotStarted)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	nymSocketManager.Stop()
+	require.ErrorIs(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)), lib.ErrNotStarted)
+}
+
+func TestNymSocketManagerErrorsWithoutConnection(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithWriteCoalescing(time.Millisecond), lib.WithReconnectPolicy(lib.RetryPolicy{BaseDelay: time.Minute}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Ping(context.Background())
+	require.ErrorIs(t, e, lib.ErrNotStarted)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The nym-client goes away, the manager waits to reconnect
+	fake.server.Close()
+	fake.dropConnections()
+	require.Eventually(t, func() bool { return lib.StateRunning != nymSocketManager.GetState() }, time.Second, time.Millisecond)
+
+	_, e = nymSocketManager.Ping(context.Background())
+	require.ErrorIs(t, e, lib.ErrConnectionClosed)
+	require.ErrorIs(t, nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)), lib.ErrConnectionClosed)
+}
+
+func TestNymSocketManagerErrHandshakeTimeout(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.ignoreSelfAddress = true
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithSelfAddressTimeout(20*time.Millisecond), lib.WithSelfAddressRetries(1))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.ErrorIs(t, e, lib.ErrHandshakeTimeout)
+	require.ErrorIs(t, e, context.DeadlineExceeded)
+
+	// When the caller gives up, the nym-client is not to blame
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, e = nymSocketManager.StartContext(ctx)
+	require.ErrorIs(t, e, context.DeadlineExceeded)
+	require.NotErrorIs(t, e, lib.ErrHandshakeTimeout)
+}
+
+func TestNymSocketManagerNymRemoteError(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	fake.deliver(map[string]interface{}{"type": "error", "message": "lane 3 is full: too many packets"})
+	require.Eventually(t, func() bool { return nil != nymSocketManager.LastError() }, time.Second, time.Millisecond)
+
+	e = nymSocketManager.LastError()
+	var remote *lib.NymRemoteError
+	require.True(t, xerrors.As(e, &remote))
+	require.Equal(t, "lane 3 is full: too many packets", remote.Err.Message)
+	require.ErrorIs(t, e, lib.ErrNymLaneFull)
+	var nymError lib.NymError
+	require.True(t, xerrors.As(e, &nymError))
+}
@@ -0,0 +1,206 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"time"
+)
+
+// Size of the buffer of each channel returned by Events. Events are dropped for subscribers lagging behind
+const eventsBufferSize = 64
+
+// Event is one of the *Event types sent on the channels returned by Events
+type Event interface {
+	// Name identifies the type of the event, e.g. "connected"
+	Name() string
+	// Time is when the event occurred
+	Time() time.Time
+}
+
+// EventCommon holds the fields shared by all the events
+type EventCommon struct {
+	At time.Time
+}
+
+func (e EventCommon) Time() time.Time {
+	return e.At
+}
+
+// ConnectedEvent is sent each time the websocket connection to a nym-client is opened
+type ConnectedEvent struct {
+	EventCommon
+	ConnectionURI string
+}
+
+func (ConnectedEvent) Name() string {
+	return "connected"
+}
+
+// HandshakeDoneEvent is sent each time the clientID is collected from a newly connected nym-client
+type HandshakeDoneEvent struct {
+	EventCommon
+	ConnectionURI string
+	ClientID      string
+	// Time taken by the nym-client to answer the selfAddress request
+	Duration time.Duration
+}
+
+func (HandshakeDoneEvent) Name() string {
+	return "handshakeDone"
+}
+
+// DisconnectedEvent is sent each time the connection to a nym-client is closed
+type DisconnectedEvent struct {
+	EventCommon
+	ConnectionURI string
+	// nil if the closure was requested (e.g. by Stop), describes the failure otherwise
+	Reason error
+}
+
+func (DisconnectedEvent) Name() string {
+	return "disconnected"
+}
+
+// ReconnectAttemptEvent is sent before each connection attempt made to replace a lost connection, see also SubscribeReconnects
+type ReconnectAttemptEvent struct {
+	EventCommon
+	ConnectionURI string
+	// Starts at 1 for each connection lost
+	Attempt int
+	// Waited before this attempt, according to the reconnect policy, see WithReconnectPolicy
+	Delay time.Duration
+	// Why the previous attempt failed, or why the connection was lost for the first attempt
+	Err error
+}
+
+func (ReconnectAttemptEvent) Name() string {
+	return "reconnectAttempt"
+}
+
+// DropReason tells why a message was dropped, see MessageDroppedEvent
+type DropReason string
+
+const (
+	// Received messages rejected by a filter, see AddFilter
+	DropFiltered DropReason = "filtered"
+	// Received messages dropped as duplicates, see WithDeduplication
+	DropDuplicate DropReason = "duplicate"
+	// Received messages the handler pool had no room for, see WithHandlerPool
+	DropHandlerQueue DropReason = "handlerQueue"
+	// Received messages the pause buffer had no room for, see Pause and WithPauseBuffer
+	DropPauseBuffer DropReason = "pauseBuffer"
+	// Messages sent while the connection was down the replay buffer had no room for, see WithReplayBuffer
+	DropReplayBuffer DropReason = "replayBuffer"
+	// Messages sent the writer goroutine had no room for, see WithAsyncSend
+	DropSendQueue DropReason = "sendQueue"
+	// Messages received or buffered while stopping, which could not be handled or replayed
+	DropStopping DropReason = "stopping"
+	// Frames which could not be decoded, or do not match their schema, see WithSchemaValidation
+	DropMalformed DropReason = "malformed"
+	// Frames of a type the manager does not know, and no hook handled, see OnControlMessage
+	DropUnknownType DropReason = "unknownType"
+	// Frames larger than the maximum message size, see WithMaxMessageSize
+	DropOversized DropReason = "oversized"
+	// Chunks the reassembly had no room for, and messages still incomplete at the reassembly timeout, see WithChunking
+	DropReassembly DropReason = "reassembly"
+	// Responses, acknowledgements and stream frames matching no request, delivery or open stream, see Request, SendConfirmed and AcceptStream
+	DropUnmatched DropReason = "unmatched"
+	// Stream frames received too far ahead of the next one to read, failing their stream, see OpenStream
+	DropStreamWindow DropReason = "streamWindow"
+)
+
+// MessageDroppedEvent is sent for each message, received or sent, dropped by the manager, see also Stats.MessagesDropped and WithDropHandler
+type MessageDroppedEvent struct {
+	EventCommon
+	Reason DropReason
+	// The message dropped, nil if not known, its payload redacted with WithPayloadRedaction
+	Message NymMessage
+	// Its identifier, empty if not known, see MessageIDField
+	MessageID string
+	// Describes the drop, e.g. the name of the filter or the error the Send failed with
+	Detail string
+}
+
+func (MessageDroppedEvent) Name() string {
+	return "messageDropped"
+}
+
+// eventStream fans the events out to the subscribers
+// It has its own lock so that events can be sent while the NymSocketManager is busy (e.g. connecting)
+type eventStream struct {
+	sync.Mutex
+
+	subscribers []chan Event
+	// Receive the reconnection events only, see SubscribeReconnects
+	reconnectSubscribers []chan Event
+}
+
+// Events returns a channel receiving the lifecycle events of the manager, as typed values to switch on,
+// e.g. to feed dashboards or automation. Events are dropped if the channel is not consumed fast enough.
+// The channel is closed by UnsubscribeEvents
+func (n *NymSocketManager) Events() <-chan Event {
+	n.events.Lock()
+	defer n.events.Unlock()
+
+	subscriber := make(chan Event, eventsBufferSize)
+	n.events.subscribers = append(n.events.subscribers, subscriber)
+	return subscriber
+}
+
+// UnsubscribeEvents stops sending events to a channel obtained with Events, and closes it
+func (n *NymSocketManager) UnsubscribeEvents(subscription <-chan Event) {
+	n.events.Lock()
+	defer n.events.Unlock()
+
+	for i, subscriber := range n.events.subscribers {
+		if subscription == subscriber {
+			close(subscriber)
+			n.events.subscribers = append(n.events.subscribers[:i], n.events.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *eventStream) publish(event Event) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, subscriber := range s.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+	switch event.(type) {
+	case ReconnectAttemptEvent, ReconnectDoneEvent:
+		for _, subscriber := range s.reconnectSubscribers {
+			select {
+			case subscriber <- event:
+			default:
+			}
+		}
+	}
+}
+
+func (s *eventStream) connected(connectionURI string) {
+	s.publish(ConnectedEvent{EventCommon{time.Now()}, connectionURI})
+}
+
+func (s *eventStream) handshakeDone(connectionURI string, clientID string, duration time.Duration) {
+	s.publish(HandshakeDoneEvent{EventCommon{time.Now()}, connectionURI, clientID, duration})
+}
+
+func (s *eventStream) disconnected(connectionURI string, reason error) {
+	s.publish(DisconnectedEvent{EventCommon{time.Now()}, connectionURI, reason})
+}
+
+func (s *eventStream) reconnectAttempt(connectionURI string, attempt int, delay time.Duration, err error) {
+	s.publish(ReconnectAttemptEvent{EventCommon{time.Now()}, connectionURI, attempt, delay, err})
+}
+
+func (s *eventStream) reconnectDone(connectionURI string, attempts int, err error) {
+	s.publish(ReconnectDoneEvent{EventCommon{time.Now()}, connectionURI, attempts, err})
+}
+
+func (s *eventStream) messageDropped(reason DropReason, msg NymMessage, messageID string, detail string) {
+	s.publish(MessageDroppedEvent{EventCommon{time.Now()}, reason, msg, messageID, detail})
+}
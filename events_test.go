@@ -0,0 +1,99 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// nextEvent returns the next event received on events
+func nextEvent(t *testing.T, events <-chan lib.Event) lib.Event {
+	select {
+	case event := <-events:
+		require.False(t, event.Time().IsZero())
+		return event
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "no event received")
+		return nil
+	}
+}
+
+func TestNymSocketManagerEvents(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithFallbackURIs(second.URI()))
+	require.NoError(t, e)
+	events := nymSocketManager.Events()
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	connected, ok := nextEvent(t, events).(lib.ConnectedEvent)
+	require.True(t, ok)
+	require.Equal(t, first.URI(), connected.ConnectionURI)
+	handshake, ok := nextEvent(t, events).(lib.HandshakeDoneEvent)
+	require.True(t, ok)
+	require.Equal(t, first.URI(), handshake.ConnectionURI)
+	require.Equal(t, fakeNymClientAddress, handshake.ClientID)
+
+	first.dropConnections()
+
+	disconnected, ok := nextEvent(t, events).(lib.DisconnectedEvent)
+	require.True(t, ok)
+	require.Equal(t, first.URI(), disconnected.ConnectionURI)
+	require.Error(t, disconnected.Reason)
+	attempt, ok := nextEvent(t, events).(lib.ReconnectAttemptEvent)
+	require.True(t, ok)
+	require.Equal(t, lib.ReconnectAttemptEvent{EventCommon: attempt.EventCommon, ConnectionURI: second.URI(), Attempt: 1, Err: attempt.Err}, attempt)
+	require.ErrorIs(t, attempt.Err, lib.ErrConnectionClosed)
+	require.Equal(t, "connected", nextEvent(t, events).Name())
+	require.Equal(t, "handshakeDone", nextEvent(t, events).Name())
+	done, ok := nextEvent(t, events).(lib.ReconnectDoneEvent)
+	require.True(t, ok)
+	require.Equal(t, lib.ReconnectDoneEvent{EventCommon: done.EventCommon, ConnectionURI: second.URI(), Attempts: 1}, done)
+
+	nymSocketManager.Stop()
+	disconnected, ok = nextEvent(t, events).(lib.DisconnectedEvent)
+	require.True(t, ok)
+	require.Equal(t, second.URI(), disconnected.ConnectionURI)
+	require.NoError(t, disconnected.Reason)
+
+	nymSocketManager.UnsubscribeEvents(events)
+	_, open := <-events
+	require.False(t, open)
+}
+
+func TestNymSocketManagerMessageDroppedEvents(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithDeduplication(time.Minute, nil))
+	require.NoError(t, e)
+	nymSocketManager.AddFilter("noSpam", func(received lib.NymReceived) bool { return "spam" != received.Message })
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	events := nymSocketManager.Events()
+	defer nymSocketManager.UnsubscribeEvents(events)
+
+	fake.deliver(map[string]interface{}{"type": "received", "message": "spam"})
+	dropped, ok := nextEvent(t, events).(lib.MessageDroppedEvent)
+	require.True(t, ok)
+	require.Equal(t, lib.DropFiltered, dropped.Reason)
+	require.Equal(t, "noSpam", dropped.Detail)
+	require.Equal(t, "spam", dropped.Message.(lib.NymReceived).Message)
+
+	fake.deliver(map[string]interface{}{"type": "received", "message": "hello"})
+	fake.deliver(map[string]interface{}{"type": "received", "message": "hello"})
+	dropped, ok = nextEvent(t, events).(lib.MessageDroppedEvent)
+	require.True(t, ok)
+	require.Equal(t, lib.DropDuplicate, dropped.Reason)
+	require.Equal(t, "messageDropped", dropped.Name())
+}
@@ -29,7 +29,7 @@ func main() {
 	logger.Info().Msg("Starting the mixnet application")
 
 	// Create NymSocketManager
-	nymSocketManager, e := NymSocketManager.NewNymSocketManager(NYM_CLIENT_WS, msgHandler, &logger)
+	nymSocketManager, e := NymSocketManager.NewNymSocketManager(NYM_CLIENT_WS, msgHandler, NymSocketManager.ZerologLogger(&logger))
 	if nil != e {
 		logger.Error().Msgf("failed to create the NymSocketManager: %v", e)
 		return
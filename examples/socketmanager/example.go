@@ -43,7 +43,7 @@ func main() {
 		}
 
 		logger.Info().Msgf("Replied: \"%v\"", msg)
-	}, &logger)
+	}, NymSocketManager.ZerologLogger(&logger))
 	if nil != e {
 		logger.Error().Msgf("failed to create the SocketManager: %v", e)
 		return
@@ -0,0 +1,18 @@
+package nymsocketmanager
+
+// ParseTextMessage exposes parseTextMessage to the benchmarks
+func (n *NymSocketManager) ParseTextMessage(s []byte) NymMessage {
+	return n.parseTextMessage(s)
+}
+
+// PendingDeliveries returns the number of deliveries waiting for their acknowledgement
+func (n *NymSocketManager) PendingDeliveries() int {
+	n.deliveries.Lock()
+	defer n.deliveries.Unlock()
+	return len(n.deliveries.pending)
+}
+
+// HandOver exposes handOver, for the messages to be pushed to the handler pool concurrently
+func (n *NymSocketManager) HandOver(received NymReceived) {
+	n.handOver(received, false)
+}
@@ -0,0 +1,31 @@
+package nymsocketmanager
+
+import (
+	"expvar"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Serialises the checks of the names published, expvar.Publish panicking on the names already taken
+var expvarLock sync.Mutex
+
+// expvarStatus is the value published by WithExpvar
+type expvarStatus struct {
+	Stats  Stats        `json:"stats"`
+	Health HealthStatus `json:"health"`
+}
+
+// publishExpvar publishes the counters of the manager under name, failing if the name is taken
+func (n *NymSocketManager) publishExpvar(name string) error {
+	expvarLock.Lock()
+	defer expvarLock.Unlock()
+
+	if nil != expvar.Get(name) {
+		return xerrors.Errorf("expvar %v is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return expvarStatus{Stats: n.Stats(), Health: n.HealthStatus()}
+	}))
+	return nil
+}
@@ -0,0 +1,61 @@
+package nymsocketmanager_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerExpvar(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	// Unique, as the names cannot be unpublished
+	name := fmt.Sprintf("nymsocketmanager_test_%d", time.Now().UnixNano())
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger), lib.WithExpvar(name))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	<-receivedChan
+
+	published := expvar.Get(name)
+	require.NotNil(t, published)
+	var status struct {
+		Stats struct {
+			MessagesSent map[string]uint64
+		} `json:"stats"`
+		Health struct {
+			Ready bool `json:"ready"`
+		} `json:"health"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(published.String()), &status))
+	require.Equal(t, uint64(1), status.Stats.MessagesSent["NymSend"])
+	require.True(t, status.Health.Ready)
+
+	// The name is taken for the lifetime of the process
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithExpvar(name))
+	require.Error(t, e)
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithExpvar(""))
+	require.Error(t, e)
+
+	// Not published if the creation fails
+	unpublished := name + "_unpublished"
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithExpvar(unpublished), lib.WithDrainTimeout(-time.Second))
+	require.Error(t, e)
+	require.Nil(t, expvar.Get(unpublished))
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithExpvar(unpublished))
+	require.NoError(t, e)
+}
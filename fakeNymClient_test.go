@@ -0,0 +1,336 @@
+package nymsocketmanager_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mr-tron/base58"
+)
+
+const fakeNymClientAddress = "4wBqpZM9xaSheZzJSMawUKKwhdpChKbZ5eu5ky4Vigw.3ELeRTTg5W5hAYaEFznzFV1jknNFkjHqS8ytwvQEQP1Z@5Pk716N113awdSaUDZEPZVi9Zs6hJmG5KCJtp5qQK3LB"
+
+// Address of another client, whose messages are not looped back
+const fakePeerAddress = "7ppk9w8NHnH6ehajvJyU31VcMafwZ3ybRtJWumSyD2wd.9zECja2hDKnM7bayssQsM2C2AfQP75wqJwdWmvt97hGF@C9dfKCw28sHbaVbDqRrGf2tRyk8pf7v5BzxWe6KK2Mas"
+
+// Sender tag of the anonymous messages looped back
+const fakeNymClientSenderTag = "8DfbjXLth7APvt3qQPgtf"
+
+// fakeNymClient mimics the websocket API of a nym-client so that the managers can be tested without a mixnet
+type fakeNymClient struct {
+	server *httptest.Server
+
+	// If set, selfAddress requests are left unanswered
+	ignoreSelfAddress bool
+	// Number of selfAddress requests left unanswered before answering, as a slow nym-client would
+	selfAddressRequestsToIgnore int
+
+	// If set, sent instead of the regular selfAddress reply, as a nym-client with another API would
+	selfAddressReply map[string]interface{}
+
+	// If set, stops reading once the selfAddress request is answered, as a wedged nym-client would
+	hangAfterSelfAddress bool
+	// Closed when the test ends, to release the hanging connections
+	closedChan chan struct{}
+
+	// Delay before accepting a websocket connection, as a busy nym-client would
+	upgradeDelay time.Duration
+
+	// Number of send requests received
+	sendRequests atomic.Int32
+	// Messages of the send requests received, in order
+	sentMessagesMutex sync.Mutex
+	sentMessages      []string
+	// If set, stops reading once the selfAddress request is answered until closed, as a congested nym-client would
+	readsHeld chan struct{}
+	// If set, sent as soon as a connection is open, as a nym-client notifying its status would
+	greeting interface{}
+
+	// Number of packets reported in every lane
+	laneQueueLength int
+	// Number of reply SURBs of the last sendAnonymous request received
+	lastReplySurbs atomic.Int32
+	// Code of the last close frame received, websocket.CloseAbnormalClosure if the connection was dropped without one
+	lastCloseCode atomic.Int32
+
+	// If set, upgrade requests without this Authorization header are rejected, as an authenticating gateway would
+	requiredAuthorization string
+	// If set, permessage-deflate is accepted when offered
+	compression bool
+	// Header of the last upgrade request
+	lastUpgradeHeader atomic.Pointer[http.Header]
+
+	// Address of the client, fakeNymClientAddress if empty
+	address string
+	// If set, the messages sent to its address are delivered to its last connection, as the mixnet would
+	linked *fakeNymClient
+
+	// Serializes the writes, which can come from the connection of the linked client
+	writeMutex sync.Mutex
+
+	connectionsMutex sync.Mutex
+	connections      []*websocket.Conn
+}
+
+func newFakeNymClient(t testing.TB) *fakeNymClient {
+	f := &fakeNymClient{closedChan: make(chan struct{})}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	f.cleanupWith(t)
+	return f
+}
+
+// newFakeNymClientWithTLS starts a fake nym-client behind a self-signed certificate, reachable through "wss://"
+func newFakeNymClientWithTLS(t *testing.T) *fakeNymClient {
+	f := &fakeNymClient{closedChan: make(chan struct{})}
+	f.server = httptest.NewTLSServer(http.HandlerFunc(f.serve))
+	f.cleanupWith(t)
+	return f
+}
+
+// newFakeNymClientOnUnixSocket starts a fake nym-client listening on the Unix domain socket at socketPath
+func newFakeNymClientOnUnixSocket(t *testing.T, socketPath string) *fakeNymClient {
+	listener, e := net.Listen("unix", socketPath)
+	if nil != e {
+		t.Fatalf("failed to listen on %v: %v", socketPath, e)
+	}
+
+	f := &fakeNymClient{closedChan: make(chan struct{})}
+	f.server = httptest.NewUnstartedServer(http.HandlerFunc(f.serve))
+	f.server.Listener = listener
+	f.server.Start()
+	f.cleanupWith(t)
+	return f
+}
+
+// newLinkedFakeNymClients starts two fake nym-clients, at fakeNymClientAddress and fakePeerAddress, delivering the messages sent to each other
+func newLinkedFakeNymClients(t *testing.T) (*fakeNymClient, *fakeNymClient) {
+	first, second := newFakeNymClient(t), newFakeNymClient(t)
+	second.address = fakePeerAddress
+	first.linked, second.linked = second, first
+	return first, second
+}
+
+func (f *fakeNymClient) cleanupWith(t testing.TB) {
+	t.Cleanup(func() {
+		close(f.closedChan)
+		f.server.Close()
+	})
+}
+
+// URI returns the websocket URI to reach the fake nym-client
+func (f *fakeNymClient) URI() string {
+	return "ws" + strings.TrimPrefix(f.server.URL, "http")
+}
+
+// dropConnections abruptly closes all the connections opened so far, as a crashing nym-client would
+func (f *fakeNymClient) dropConnections() {
+	f.connectionsMutex.Lock()
+	defer f.connectionsMutex.Unlock()
+	for _, connection := range f.connections {
+		connection.Close()
+	}
+	f.connections = nil
+}
+
+func (f *fakeNymClient) selfAddress() string {
+	if len(f.address) == 0 {
+		return fakeNymClientAddress
+	}
+	return f.address
+}
+
+// deliver writes a received message on the last connection, if any
+func (f *fakeNymClient) deliver(received interface{}) {
+	f.connectionsMutex.Lock()
+	defer f.connectionsMutex.Unlock()
+	if len(f.connections) != 0 {
+		f.writeJSON(f.connections[len(f.connections)-1], received)
+	}
+}
+
+func (f *fakeNymClient) writeJSON(connection *websocket.Conn, v interface{}) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+	return connection.WriteJSON(v)
+}
+
+func (f *fakeNymClient) writeMessage(connection *websocket.Conn, messageType int, data []byte) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+	return connection.WriteMessage(messageType, data)
+}
+
+// receivedSends returns the messages of the send requests received so far
+func (f *fakeNymClient) receivedSends() []string {
+	f.sentMessagesMutex.Lock()
+	defer f.sentMessagesMutex.Unlock()
+	return append([]string(nil), f.sentMessages...)
+}
+
+// connectionCount returns the number of connections opened since the last dropConnections
+func (f *fakeNymClient) connectionCount() int {
+	f.connectionsMutex.Lock()
+	defer f.connectionsMutex.Unlock()
+	return len(f.connections)
+}
+
+func (f *fakeNymClient) serve(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(f.upgradeDelay)
+
+	if len(f.requiredAuthorization) != 0 && r.Header.Get("Authorization") != f.requiredAuthorization {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	header := r.Header.Clone()
+	f.lastUpgradeHeader.Store(&header)
+
+	upgrader := websocket.Upgrader{EnableCompression: f.compression}
+	connection, e := upgrader.Upgrade(w, r, nil)
+	if nil != e {
+		return
+	}
+	defer connection.Close()
+
+	f.connectionsMutex.Lock()
+	f.connections = append(f.connections, connection)
+	f.connectionsMutex.Unlock()
+
+	if nil != f.greeting && nil != f.writeJSON(connection, f.greeting) {
+		return
+	}
+
+	for {
+		messageType, msg, e := connection.ReadMessage()
+		if nil != e {
+			if closeError, ok := e.(*websocket.CloseError); ok {
+				f.lastCloseCode.Store(int32(closeError.Code))
+			}
+			return
+		}
+
+		if websocket.BinaryMessage == messageType {
+			if nil != f.serveBinary(connection, msg) {
+				return
+			}
+			continue
+		}
+
+		request := make(map[string]interface{})
+		if nil != json.Unmarshal(msg, &request) {
+			continue
+		}
+
+		switch request["type"] {
+		case "selfAddress":
+			if f.ignoreSelfAddress {
+				continue
+			}
+			if f.selfAddressRequestsToIgnore > 0 {
+				f.selfAddressRequestsToIgnore--
+				continue
+			}
+			if nil != f.selfAddressReply {
+				e = f.writeJSON(connection, f.selfAddressReply)
+			} else {
+				e = f.writeJSON(connection, map[string]string{"type": "selfAddress", "address": f.selfAddress()})
+			}
+			if f.hangAfterSelfAddress {
+				<-f.closedChan
+				return
+			}
+			if nil != f.readsHeld {
+				select {
+				case <-f.readsHeld:
+				case <-f.closedChan:
+				}
+			}
+
+		// Messages sent to ourselves are looped back, the ones to the linked client delivered to it
+		case "send":
+			f.sendRequests.Add(1)
+			f.sentMessagesMutex.Lock()
+			message, _ := request["message"].(string)
+			f.sentMessages = append(f.sentMessages, message)
+			f.sentMessagesMutex.Unlock()
+			if nil != f.linked && request["recipient"] == f.linked.selfAddress() {
+				f.linked.deliver(map[string]interface{}{"type": "received", "message": request["message"]})
+				continue
+			}
+			if request["recipient"] != f.selfAddress() {
+				continue
+			}
+			e = f.writeJSON(connection, map[string]interface{}{"type": "received", "message": request["message"]})
+
+		case "getLaneQueueLength":
+			e = f.writeJSON(connection, map[string]interface{}{"type": "laneQueueLength", "lane": request["connectionId"], "queueLength": f.laneQueueLength})
+
+		// Anonymous messages sent to ourselves are looped back with a sender tag
+		case "sendAnonymous":
+			f.sendRequests.Add(1)
+			if replySurbs, ok := request["replySurbs"].(float64); ok {
+				f.lastReplySurbs.Store(int32(replySurbs))
+			}
+			if request["recipient"] != f.selfAddress() {
+				continue
+			}
+			e = f.writeJSON(connection, map[string]interface{}{"type": "received", "message": request["message"], "senderTag": fakeNymClientSenderTag})
+
+		// Replies to our anonymous messages are looped back
+		case "reply":
+			f.sendRequests.Add(1)
+			if request["senderTag"] != fakeNymClientSenderTag {
+				continue
+			}
+			e = f.writeJSON(connection, map[string]interface{}{"type": "received", "message": request["message"]})
+		}
+		if nil != e {
+			return
+		}
+	}
+}
+
+// serveBinary answers a request of the binary protocol, supporting selfAddress and send
+func (f *fakeNymClient) serveBinary(connection *websocket.Conn, request []byte) error {
+	address := binaryAddress()
+
+	switch request[0] {
+	case 0x04:
+		reply := binary.BigEndian.AppendUint64(append([]byte{0x03}, request[1:9]...), uint64(f.laneQueueLength))
+		return f.writeMessage(connection, websocket.BinaryMessage, reply)
+
+	case 0x03:
+		return f.writeMessage(connection, websocket.BinaryMessage, append([]byte{0x02}, address...))
+
+	// Messages sent to ourselves are looped back, without sender tag
+	case 0x00:
+		f.sendRequests.Add(1)
+		recipient := request[1 : 1+len(address)]
+		if !bytes.Equal(recipient, address) {
+			return nil
+		}
+		// The length-prefixed payload follows the connection id
+		payload := request[1+len(address)+8:]
+		return f.writeMessage(connection, websocket.BinaryMessage, append([]byte{0x01, 0x00}, payload...))
+	}
+	return nil
+}
+
+// binaryAddress returns fakeNymClientAddress in its binary form
+func binaryAddress() []byte {
+	var address []byte
+	for _, key := range strings.FieldsFunc(fakeNymClientAddress, func(r rune) bool { return '.' == r || '@' == r }) {
+		decoded, _ := base58.Decode(key)
+		address = append(address, decoded...)
+	}
+	return address
+}
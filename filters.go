@@ -0,0 +1,63 @@
+package nymsocketmanager
+
+import (
+	"sync"
+)
+
+// Filter tells whether a received message should be kept, see AddFilter
+type Filter func(received NymReceived) bool
+
+// messageFilters holds the filters registered with AddFilter, in order
+type messageFilters struct {
+	sync.Mutex
+
+	names   []string
+	filters []Filter
+}
+
+// AddFilter registers a filter evaluated on every received message before the subscriptions, the middlewares and
+// the messageHandler: the messages it rejects are dropped without further cost. The filters run in the order they are
+// registered, until one rejects the message. The messages dropped are counted by name in Stats.MessagesFiltered
+func (n *NymSocketManager) AddFilter(name string, filter Filter) {
+	n.filters.Lock()
+	defer n.filters.Unlock()
+	n.filters.names = append(n.filters.names, name)
+	n.filters.filters = append(n.filters.filters, filter)
+}
+
+// filtered returns the name of the first filter rejecting received, if any
+func (f *messageFilters) filtered(received NymReceived) (string, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	for i, filter := range f.filters {
+		if !filter(received) {
+			return f.names[i], true
+		}
+	}
+	return "", false
+}
+
+// MaxMessageLength returns a Filter rejecting the messages longer than length bytes, once reassembled
+func MaxMessageLength(length int) Filter {
+	return func(received NymReceived) bool {
+		return len(received.Message) <= length
+	}
+}
+
+// AllowSenderTags returns a Filter rejecting the anonymous messages whose senderTag is not one of senderTags.
+// The messages without senderTag are kept
+func AllowSenderTags(senderTags ...string) Filter {
+	allowed := make(map[string]struct{}, len(senderTags))
+	for _, senderTag := range senderTags {
+		allowed[senderTag] = struct{}{}
+	}
+
+	return func(received NymReceived) bool {
+		if !received.IsReplyable() {
+			return true
+		}
+		_, ok := allowed[received.SenderTag]
+		return ok
+	}
+}
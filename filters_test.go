@@ -0,0 +1,53 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerAddFilter(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handled := make(chan lib.NymReceived, 3)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	nymSocketManager.AddFilter("oversized", lib.MaxMessageLength(5))
+	nymSocketManager.AddFilter("unknownSender", lib.AllowSenderTags("anotherSenderTag"))
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("too long", fakeNymClientAddress)))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSendAnonymous("hello", fakeNymClientAddress, 0)))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+
+	select {
+	case received := <-handled:
+		require.Equal(t, "hello", received.Message)
+		require.False(t, received.IsReplyable())
+	case <-time.After(time.Second):
+		t.Fatal("the message was not handled")
+	}
+
+	require.Eventually(t, func() bool {
+		return 2 == len(nymSocketManager.Stats().MessagesFiltered)
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, map[string]uint64{"oversized": 1, "unknownSender": 1}, nymSocketManager.Stats().MessagesFiltered)
+	require.Empty(t, handled)
+}
+
+func TestAllowSenderTags(t *testing.T) {
+	filter := lib.AllowSenderTags(fakeNymClientSenderTag)
+	require.True(t, filter(lib.NymReceived{Message: "hello", SenderTag: fakeNymClientSenderTag}))
+	require.False(t, filter(lib.NymReceived{Message: "hello", SenderTag: "anotherSenderTag"}))
+	require.True(t, filter(lib.NymReceived{Message: "hello"}))
+}
@@ -4,6 +4,7 @@ go 1.20
 
 require (
 	github.com/gorilla/websocket v1.5.0
+	github.com/mr-tron/base58 v1.2.0
 	github.com/rs/zerolog v1.29.1
 	github.com/stretchr/testify v1.8.2
 	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2
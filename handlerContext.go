@@ -0,0 +1,41 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"sync"
+)
+
+// handlerContext holds the context given to the messageHandler, cancelled when the manager stops
+type handlerContext struct {
+	sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (h *handlerContext) start() {
+	h.Lock()
+	defer h.Unlock()
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+}
+
+func (h *handlerContext) stop() {
+	h.Lock()
+	defer h.Unlock()
+	if nil != h.cancel {
+		h.cancel()
+	}
+}
+
+// get returns the context of the current start, cancelled if the manager is stopped
+func (h *handlerContext) get() context.Context {
+	h.Lock()
+	defer h.Unlock()
+
+	if nil == h.ctx {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+	return h.ctx
+}
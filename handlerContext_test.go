@@ -0,0 +1,85 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerHandlerContext(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handlingChan := make(chan struct{})
+	abortedChan := make(chan error, 1)
+	nymSocketManager, e := lib.NewNymSocketManagerWithContext(fake.URI(), func(ctx context.Context, received lib.NymReceived, _ func(lib.NymMessage) error) error {
+		close(handlingChan)
+		// As a long database query would
+		<-ctx.Done()
+		abortedChan <- ctx.Err()
+		return ctx.Err()
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("query", nymSocketManager.GetNymClientId())))
+	select {
+	case <-handlingChan:
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+
+	nymSocketManager.Stop()
+	select {
+	case e := <-abortedChan:
+		require.ErrorIs(t, e, context.Canceled)
+	case <-time.After(time.Second):
+		require.Fail(t, "messageHandler not aborted")
+	}
+
+	_, e = lib.NewNymSocketManagerWithContext(fake.URI(), nil, lib.ZerologLogger(&logger))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerStopWaitsForHandlers(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handlingChan := make(chan struct{})
+	replyErrChan := make(chan error, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, reply func(lib.NymMessage) error) {
+		close(handlingChan)
+		time.Sleep(200 * time.Millisecond)
+		replyErrChan <- reply(lib.NewNymSend("late reply", fakePeerAddress))
+	}, lib.ZerologLogger(&logger), lib.WithHandlerDrainTimeout(time.Second))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("query", nymSocketManager.GetNymClientId())))
+	select {
+	case <-handlingChan:
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+
+	nymSocketManager.Stop()
+	// Stop returned once the messageHandler replied
+	select {
+	case e := <-replyErrChan:
+		require.NoError(t, e)
+	default:
+		require.Fail(t, "Stop did not wait for the messageHandler")
+	}
+	require.Contains(t, fake.receivedSends(), "late reply")
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithHandlerDrainTimeout(-time.Second))
+	require.Error(t, e)
+}
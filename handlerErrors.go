@@ -0,0 +1,18 @@
+package nymsocketmanager
+
+import "fmt"
+
+// ReplyError is returned by the reply function given to the messageHandler when the reply could not be sent
+type ReplyError struct {
+	// Message which could not be sent
+	Message NymMessage
+	Err     error
+}
+
+func (r *ReplyError) Error() string {
+	return fmt.Sprintf("failed to reply with %v: %v", r.Message.Name(), r.Err)
+}
+
+func (r *ReplyError) Unwrap() error {
+	return r.Err
+}
@@ -0,0 +1,77 @@
+package nymsocketmanager_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestNymSocketManagerHandlerErrors(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	errFailed := xerrors.New("failed")
+	nymSocketManager, e := lib.NewNymSocketManagerWithErrors(fake.URI(), func(received lib.NymReceived, reply func(lib.NymMessage) error) error {
+		switch received.Message {
+		case "fail":
+			return errFailed
+		case "reply":
+			// Neither sender tag nor return address to reply to
+			return reply(lib.NewNymReply("", "answer"))
+		}
+		return nil
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	var mutex sync.Mutex
+	var failures []error
+	var failedMessages []string
+	nymSocketManager.OnHandlerError(func(received lib.NymReceived, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		failures = append(failures, err)
+		failedMessages = append(failedMessages, received.Message)
+	})
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for _, message := range []string{"fail", "succeed", "reply"} {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend(message, nymSocketManager.GetNymClientId())))
+	}
+
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(failures) >= 2
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// The error of the reply function is reported once, though returned by the messageHandler too
+	require.Len(t, failures, 2)
+	require.ElementsMatch(t, []string{"fail", "reply"}, failedMessages)
+	for i, failure := range failures {
+		if "fail" == failedMessages[i] {
+			require.ErrorIs(t, failure, errFailed)
+			continue
+		}
+		var replyError *lib.ReplyError
+		require.ErrorAs(t, failure, &replyError)
+		require.Equal(t, lib.NewNymReply("", "answer"), replyError.Message)
+	}
+}
+
+func TestNymSocketManagerWithErrorsNoHandler(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManagerWithErrors("ws://127.0.0.1", nil, lib.ZerologLogger(&logger))
+	require.Error(t, e)
+}
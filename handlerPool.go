@@ -0,0 +1,173 @@
+package nymsocketmanager
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/xerrors"
+)
+
+// HandlerPool configures the workers calling the messageHandler, see WithHandlerPool
+type HandlerPool struct {
+	// Number of messages handled concurrently
+	Workers int
+	// Number of received messages waiting for a worker
+	QueueLength int
+	// What happens to the messages received while the queue is full.
+	// OverflowBlock stops reading from the nym-client until a worker is available
+	Overflow OverflowPolicy
+	// If set, the messages with the same key are handled by the same worker, in the order they are received.
+	// Each worker then has its own queue of QueueLength messages, see SenderKey
+	ShardKey func(NymReceived) string
+}
+
+// SenderKey is a HandlerPool.ShardKey keeping the order of the messages of each sender:
+// their SenderTag, or their ReturnAddress if they were sent with SendRequest.
+// The messages of unknown senders are all handled by the same worker
+func SenderKey(received NymReceived) string {
+	if len(received.SenderTag) != 0 {
+		return received.SenderTag
+	}
+	return received.ReturnAddress
+}
+
+// handlerPool runs the workers calling the messageHandler while the manager is started.
+// The messages still queued when it stops are dropped
+type handlerPool struct {
+	sync.Mutex
+
+	config HandlerPool
+	// One queue shared by the workers, or one per worker if sharded
+	queues   []chan NymReceived
+	stopChan chan struct{}
+	dropped  atomic.Uint64
+}
+
+// startHandlerPool starts the workers, if a pool is configured
+// called from methods that already acquired the lock
+func (n *NymSocketManager) startHandlerPool() {
+	if nil == n.handlerPool {
+		return
+	}
+
+	n.handlerPool.Lock()
+	defer n.handlerPool.Unlock()
+
+	config := n.handlerPool.config
+	n.handlerPool.stopChan = make(chan struct{})
+	n.handlerPool.queues = nil
+	if nil == config.ShardKey {
+		n.handlerPool.queues = append(n.handlerPool.queues, make(chan NymReceived, config.QueueLength))
+	}
+	for i := 0; i < config.Workers; i++ {
+		if nil != config.ShardKey {
+			n.handlerPool.queues = append(n.handlerPool.queues, make(chan NymReceived, config.QueueLength))
+		}
+		go n.handleQueued(n.handlerPool.queues[len(n.handlerPool.queues)-1], n.handlerPool.stopChan)
+	}
+}
+
+// stopHandlerPool stops the workers without waiting for the messages being handled
+// called from methods that already acquired the lock
+func (n *NymSocketManager) stopHandlerPool() {
+	if nil == n.handlerPool {
+		return
+	}
+
+	n.handlerPool.Lock()
+	defer n.handlerPool.Unlock()
+
+	if nil != n.handlerPool.stopChan {
+		close(n.handlerPool.stopChan)
+		n.handlerPool.stopChan = nil
+		n.handlerPool.queues = nil
+	}
+}
+
+// depth returns the number of messages waiting for a worker
+func (p *handlerPool) depth() int {
+	p.Lock()
+	defer p.Unlock()
+
+	depth := 0
+	for _, queue := range p.queues {
+		depth += len(queue)
+	}
+	return depth
+}
+
+// queueFor returns the queue of the worker handling received
+// called with the pool locked
+func (p *handlerPool) queueFor(received NymReceived) chan NymReceived {
+	if 1 == len(p.queues) {
+		return p.queues[0]
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(p.config.ShardKey(received)))
+	return p.queues[hash.Sum32()%uint32(len(p.queues))]
+}
+
+func (n *NymSocketManager) handleQueued(queue chan NymReceived, stopChan chan struct{}) {
+	for {
+		select {
+		case <-stopChan:
+			return
+		case received := <-queue:
+			n.callHandler(received)
+		}
+	}
+}
+
+// push queues received for the workers, applying overflow if the queue is full.
+// It returns the older messages dropped to make room for received, and an error if received itself is dropped
+func (p *handlerPool) push(received NymReceived, overflow OverflowPolicy) ([]NymReceived, error) {
+	// Not kept locked while blocking, so that the pool can be stopped meanwhile
+	p.Lock()
+	stopChan := p.stopChan
+	if nil == stopChan {
+		p.Unlock()
+		return nil, xerrors.Errorf("handler pool is stopped, dropping message received")
+	}
+	queue := p.queueFor(received)
+	p.Unlock()
+
+	switch overflow {
+	case OverflowBlock:
+		select {
+		case queue <- received:
+			return nil, nil
+		case <-stopChan:
+			return nil, xerrors.Errorf("handler pool stopped, dropping message received")
+		}
+
+	case OverflowDropNewest:
+		select {
+		case queue <- received:
+			return nil, nil
+		default:
+			return nil, xerrors.Errorf("handler queue is full (%d messages), dropping message received", p.config.QueueLength)
+		}
+
+	default:
+		var dropped []NymReceived
+		for {
+			select {
+			case queue <- received:
+				return dropped, nil
+			default:
+			}
+
+			// Without queue, there is no older message to drop
+			if 0 == cap(queue) {
+				return dropped, xerrors.Errorf("no handler worker available, dropping message received")
+			}
+			// A worker may have taken the oldest message meanwhile, in which case nothing is dropped
+			select {
+			case oldest := <-queue:
+				dropped = append(dropped, oldest)
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerHandlerPool(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	startedChan := make(chan struct{}, 3)
+	releaseChan := make(chan struct{})
+	receivedChan := make(chan string, 3)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		startedChan <- struct{}{}
+		<-releaseChan
+		receivedChan <- received.Message
+	}, lib.ZerologLogger(&logger), lib.WithHandlerPool(lib.HandlerPool{Workers: 1, QueueLength: 1, Overflow: lib.OverflowDropNewest}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("first", nymSocketManager.GetNymClientId())))
+	select {
+	case <-startedChan:
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+
+	// The worker is busy: the second message is queued, the third one dropped
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("second", nymSocketManager.GetNymClientId())))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("third", nymSocketManager.GetNymClientId())))
+
+	// The connection is still read meanwhile
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, e = nymSocketManager.GetLaneQueueLength(ctx, 1)
+	require.NoError(t, e)
+
+	close(releaseChan)
+	require.Equal(t, "first", <-receivedChan)
+	require.Equal(t, "second", <-receivedChan)
+	select {
+	case message := <-receivedChan:
+		require.Fail(t, "unexpected message handled", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.Equal(t, uint64(1), nymSocketManager.Stats().HandlerQueueDropped)
+}
+
+func TestNymSocketManagerHandlerPoolDropOldest(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	startedChan := make(chan struct{}, 1)
+	releaseChan := make(chan struct{})
+	recorder := &dropRecorder{}
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(lib.NymReceived, func(lib.NymMessage) error) {
+		startedChan <- struct{}{}
+		<-releaseChan
+	}, lib.ZerologLogger(&logger), lib.WithDropHandler(recorder.onDrop),
+		lib.WithHandlerPool(lib.HandlerPool{Workers: 1, QueueLength: 1, Overflow: lib.OverflowDropOldest}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	defer close(releaseChan)
+
+	nymSocketManager.HandOver(lib.NymReceived{Message: "busy"})
+	<-startedChan
+
+	// Pushed concurrently, a push can evict several messages before its own is queued
+	const pushes = 200
+	var wg sync.WaitGroup
+	for i := 0; i < pushes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			nymSocketManager.HandOver(lib.NymReceived{Message: fmt.Sprint(i)})
+		}(i)
+	}
+	wg.Wait()
+
+	// All but the one left in the queue are dropped, each of them accounted for
+	require.Equal(t, 1, nymSocketManager.Stats().HandlerQueueDepth)
+	require.Equal(t, uint64(pushes-1), nymSocketManager.Stats().HandlerQueueDropped)
+	require.Equal(t, uint64(pushes-1), nymSocketManager.Stats().MessagesDropped[lib.DropHandlerQueue])
+	require.Equal(t, pushes-1, recorder.count())
+}
+
+func TestNymSocketManagerHandlerPoolSharded(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	releaseChan := make(chan struct{})
+	receivedChan := make(chan string, 4)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		if strings.HasPrefix(received.Message, "a") {
+			<-releaseChan
+		}
+		receivedChan <- received.Message
+	}, lib.ZerologLogger(&logger), lib.WithHandlerPool(lib.HandlerPool{Workers: 2, QueueLength: 4, Overflow: lib.OverflowBlock, ShardKey: func(received lib.NymReceived) string {
+		key, _, _ := strings.Cut(received.Message, "-")
+		return key
+	}}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for _, message := range []string{"a-1", "a-2", "b-1", "a-3"} {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend(message, nymSocketManager.GetNymClientId())))
+	}
+
+	// "b" is handled by the other worker while the one of "a" is busy
+	select {
+	case message := <-receivedChan:
+		require.Equal(t, "b-1", message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+
+	close(releaseChan)
+	for _, expected := range []string{"a-1", "a-2", "a-3"} {
+		require.Equal(t, expected, <-receivedChan)
+	}
+}
+
+func TestSenderKey(t *testing.T) {
+	require.Equal(t, "tag", lib.SenderKey(lib.NymReceived{SenderTag: "tag", ReturnAddress: fakePeerAddress}))
+	require.Equal(t, fakePeerAddress, lib.SenderKey(lib.NymReceived{ReturnAddress: fakePeerAddress}))
+	require.Empty(t, lib.SenderKey(lib.NymReceived{}))
+}
+
+func TestNymSocketManagerHandlerPoolInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithHandlerPool(lib.HandlerPool{}))
+	require.Error(t, e)
+
+	_, e = lib.NewNymSocketManager("ws://127.0.0.1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithHandlerPool(lib.HandlerPool{Workers: 1, QueueLength: -1}))
+	require.Error(t, e)
+}
@@ -0,0 +1,89 @@
+package nymsocketmanager
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// HealthStatus sums up the health of the manager, with JSON tags so that it can be written as is in readiness and liveness responses,
+// e.g. answering liveness probes with Live and readiness probes with Ready
+type HealthStatus struct {
+	// Whether the manager is started, connected or trying to reconnect
+	Live bool `json:"live"`
+	// Whether it is safe to Send, see IsReady
+	Ready bool   `json:"ready"`
+	State string `json:"state"`
+	// Time since the manager was started, zero while stopped. Written in JSON as uptimeSeconds, a number of seconds
+	Uptime time.Duration `json:"-"`
+	// Connections established after the first one, see Stats.Reconnects
+	Reconnects uint64 `json:"reconnects"`
+	// Zero if nothing happened yet
+	LastHandshakeAt time.Time `json:"lastHandshakeAt"`
+	LastSentAt      time.Time `json:"lastSentAt"`
+	LastReceivedAt  time.Time `json:"lastReceivedAt"`
+	// Share of the capacity used in the fullest of the replay buffer, handler pool and send queue, between 0 and 1,
+	// zero if none is configured
+	QueueSaturation float64 `json:"queueSaturation"`
+}
+
+// MarshalJSON writes the HealthStatus with its JSON tags, and Uptime as uptimeSeconds
+func (s HealthStatus) MarshalJSON() ([]byte, error) {
+	type status HealthStatus
+	return json.Marshal(struct {
+		status
+		UptimeSeconds float64 `json:"uptimeSeconds"`
+	}{status(s), s.Uptime.Seconds()})
+}
+
+// HealthStatus returns the current HealthStatus of the manager. Like GetState, it does not take the lock of the manager,
+// so that health probes are answered while it is busy (e.g. connecting)
+func (n *NymSocketManager) HealthStatus() HealthStatus {
+	state := n.state.get()
+	status := HealthStatus{
+		Live:  n.started.Load(),
+		Ready: StateRunning == state,
+		State: state.String(),
+	}
+
+	n.stats.Lock()
+	if !n.stats.startedAt.IsZero() {
+		status.Uptime = time.Since(n.stats.startedAt)
+	}
+	if n.stats.connections > 1 {
+		status.Reconnects = n.stats.connections - 1
+	}
+	status.LastHandshakeAt = n.stats.lastHandshakeAt
+	status.LastSentAt = n.stats.lastSentAt
+	status.LastReceivedAt = n.stats.lastReceivedAt
+	n.stats.Unlock()
+
+	if nil != n.replayBuffer {
+		n.replayBuffer.Lock()
+		status.saturated(len(n.replayBuffer.messages), n.replayBuffer.capacity)
+		n.replayBuffer.Unlock()
+	}
+	if nil != n.handlerPool {
+		n.handlerPool.Lock()
+		for _, queue := range n.handlerPool.queues {
+			status.saturated(len(queue), cap(queue))
+		}
+		n.handlerPool.Unlock()
+	}
+	if nil != n.asyncSender {
+		n.asyncSender.Lock()
+		status.saturated(len(n.asyncSender.queue), n.asyncSender.queueLength)
+		n.asyncSender.Unlock()
+	}
+
+	return status
+}
+
+// saturated accounts for a queue holding depth messages out of capacity
+func (s *HealthStatus) saturated(depth int, capacity int) {
+	if capacity <= 0 {
+		return
+	}
+	if saturation := float64(depth) / float64(capacity); saturation > s.QueueSaturation {
+		s.QueueSaturation = saturation
+	}
+}
@@ -0,0 +1,86 @@
+package nymsocketmanager_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerHealthStatus(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	status := nymSocketManager.HealthStatus()
+	require.False(t, status.Live)
+	require.False(t, status.Ready)
+	require.Equal(t, lib.StateDisconnected.String(), status.State)
+	require.Zero(t, status.Uptime)
+	require.True(t, status.LastHandshakeAt.IsZero())
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	<-receivedChan
+
+	status = nymSocketManager.HealthStatus()
+	require.True(t, status.Live)
+	require.True(t, status.Ready)
+	require.Equal(t, lib.StateRunning.String(), status.State)
+	require.Greater(t, status.Uptime, time.Duration(0))
+	require.Zero(t, status.Reconnects)
+	require.False(t, status.LastHandshakeAt.IsZero())
+	require.False(t, status.LastSentAt.IsZero())
+	require.False(t, status.LastReceivedAt.IsZero())
+	require.Zero(t, status.QueueSaturation)
+
+	body, e := json.Marshal(status)
+	require.NoError(t, e)
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &fields))
+	require.Equal(t, true, fields["ready"])
+	require.Equal(t, true, fields["live"])
+	require.Equal(t, lib.StateRunning.String(), fields["state"])
+	require.InDelta(t, status.Uptime.Seconds(), fields["uptimeSeconds"], 1e-9)
+	require.NotContains(t, fields, "Uptime")
+
+	nymSocketManager.Stop()
+	status = nymSocketManager.HealthStatus()
+	require.False(t, status.Live)
+	require.False(t, status.Ready)
+	require.Zero(t, status.Uptime)
+}
+
+func TestNymSocketManagerHealthStatusQueueSaturation(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	release := make(chan struct{})
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(lib.NymReceived, func(lib.NymMessage) error) {
+		<-release
+	}, lib.ZerologLogger(&logger), lib.WithHandlerPool(lib.HandlerPool{Workers: 1, QueueLength: 2}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	defer close(release)
+
+	// The worker blocks on the first message, the next ones fill the queue
+	for i := 0; i < 3; i++ {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	}
+	require.Eventually(t, func() bool {
+		return 1 == nymSocketManager.HealthStatus().QueueSaturation
+	}, time.Second, time.Millisecond)
+}
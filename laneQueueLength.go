@@ -0,0 +1,78 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// laneQueueTracker matches the laneQueueLength replies of the nym-client with the requests waiting for them
+type laneQueueTracker struct {
+	sync.Mutex
+
+	// The replies only carry the lane, concurrent requests for the same lane share the first reply
+	waiting map[uint64][]chan uint64
+}
+
+// register returns the channel receiving the queue length of lane
+func (l *laneQueueTracker) register(lane uint64) chan uint64 {
+	l.Lock()
+	defer l.Unlock()
+
+	if nil == l.waiting {
+		l.waiting = make(map[uint64][]chan uint64)
+	}
+
+	replyChan := make(chan uint64, 1)
+	l.waiting[lane] = append(l.waiting[lane], replyChan)
+	return replyChan
+}
+
+func (l *laneQueueTracker) unregister(lane uint64, replyChan chan uint64) {
+	l.Lock()
+	defer l.Unlock()
+
+	for i, waiting := range l.waiting[lane] {
+		if replyChan == waiting {
+			l.waiting[lane] = append(l.waiting[lane][:i], l.waiting[lane][i+1:]...)
+			break
+		}
+	}
+	if len(l.waiting[lane]) == 0 {
+		delete(l.waiting, lane)
+	}
+}
+
+// replyReceived is called from the messageDispatcher
+func (l *laneQueueTracker) replyReceived(reply NymLaneQueueLength) {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, replyChan := range l.waiting[reply.Lane] {
+		replyChan <- reply.QueueLength
+	}
+	delete(l.waiting, reply.Lane)
+}
+
+// GetLaneQueueLength asks the nym-client how many packets are still queued in the lane of connectionID,
+// so that callers can throttle their sends. The wait for the reply is aborted when ctx is done
+func (n *NymSocketManager) GetLaneQueueLength(ctx context.Context, connectionID uint64) (uint64, error) {
+	replyChan := n.laneQueues.register(connectionID)
+	defer n.laneQueues.unregister(connectionID, replyChan)
+
+	e := n.Send(NewNymLaneQueueLengthRequest(connectionID))
+	if nil != e {
+		return 0, e
+	}
+
+	select {
+	case queueLength := <-replyChan:
+		return queueLength, nil
+
+	case <-ctx.Done():
+		err := xerrors.Errorf("no laneQueueLength reply received from the nym-client: %w", ctx.Err())
+		n.logger.Warn().Msg(err.Error())
+		return 0, err
+	}
+}
@@ -0,0 +1,43 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerGetLaneQueueLength(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.laneQueueLength = 42
+
+	for _, options := range [][]lib.Option{nil, {lib.WithBinaryProtocol()}} {
+		nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), options...)
+		require.NoError(t, e)
+
+		_, e = nymSocketManager.Start()
+		require.NoError(t, e)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		queueLength, e := nymSocketManager.GetLaneQueueLength(ctx, 7)
+		cancel()
+		require.NoError(t, e)
+		require.Equal(t, uint64(42), queueLength)
+
+		nymSocketManager.Stop()
+	}
+}
+
+func TestNymSocketManagerGetLaneQueueLengthNotStarted(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.GetLaneQueueLength(context.Background(), 0)
+	require.Error(t, e)
+}
@@ -0,0 +1,221 @@
+package nymsocketmanager
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Marks the probes, followed by "<run id>:<sequence number>", so that the ones looped back are not passed to the messageHandler
+const latencyProbePrefix = "\x1eNSMPROBE:"
+
+// Time after which a probe not received back is counted as lost, unless set in the LatencyProbe
+const DefaultProbeTimeout = 30 * time.Second
+
+// Weights of the last sample in the smoothed estimates, as TCP does for its round-trip time and RTP for its jitter
+const (
+	probeRTTWeight    = 1.0 / 8
+	probeJitterWeight = 1.0 / 16
+	probeLossWeight   = 1.0 / 16
+)
+
+// LatencyProbe configures the probes sent to our own address to measure the round-trip time through the mixnet
+type LatencyProbe struct {
+	// Time between two probes
+	Interval time.Duration
+	// Time after which a probe not received back is counted as lost, DefaultProbeTimeout if 0
+	Timeout time.Duration
+}
+
+// ProbeEvent is sent each time a probe is received back, or counted as lost, see WithLatencyProbe
+type ProbeEvent struct {
+	EventCommon
+	Sequence uint64
+	// Zero if lost
+	RTT  time.Duration
+	Lost bool
+}
+
+func (ProbeEvent) Name() string {
+	return "probe"
+}
+
+// latencyProbe holds the probes in flight and the estimates computed from the ones received back
+type latencyProbe struct {
+	sync.Mutex
+
+	config LatencyProbe
+	// Tells the probes of this manager from the ones of another manager sharing the nym-client
+	id string
+	// Closed to stop sending probes, nil while stopped
+	stopChan chan struct{}
+
+	next    uint64
+	pending map[uint64]time.Time
+
+	// Smoothed estimates, in seconds for the durations
+	rtt       float64
+	jitter    float64
+	loss      float64
+	lastRTT   float64
+	hasSample bool
+	sent      uint64
+	lost      uint64
+}
+
+// startLatencyProbe sends probes until the manager stops
+// called from methods that already acquired the lock
+func (n *NymSocketManager) startLatencyProbe() {
+	if nil == n.probe {
+		return
+	}
+
+	n.probe.Lock()
+	defer n.probe.Unlock()
+	n.probe.stopChan = make(chan struct{})
+	go n.sendProbes(n.probe.stopChan)
+}
+
+// stopLatencyProbe stops sending probes, forgetting the ones in flight
+// called from methods that already acquired the lock
+func (n *NymSocketManager) stopLatencyProbe() {
+	if nil == n.probe {
+		return
+	}
+
+	n.probe.Lock()
+	defer n.probe.Unlock()
+	if nil != n.probe.stopChan {
+		close(n.probe.stopChan)
+		n.probe.stopChan = nil
+	}
+	n.probe.pending = nil
+}
+
+func (n *NymSocketManager) sendProbes(stopChan chan struct{}) {
+	ticker := time.NewTicker(n.probe.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+		}
+
+		for _, sequence := range n.probe.expire(time.Now()) {
+			n.logger.Debug().Msgf("latency probe %d lost", sequence)
+			n.events.publish(ProbeEvent{EventCommon: EventCommon{time.Now()}, Sequence: sequence, Lost: true})
+		}
+
+		// The mixnet cannot be probed while disconnected, and there is no reason to open a lazy connection for it
+		if !n.IsReady() {
+			continue
+		}
+
+		sequence := n.probe.sending(time.Now())
+		e := n.send(NewNymSend(latencyProbePrefix+n.probe.id+":"+strconv.FormatUint(sequence, 10), n.GetNymClientId()), nil)
+		if nil != e {
+			n.logger.Debug().Msgf("failed to send latency probe: %v", e)
+			n.probe.forget(sequence)
+		}
+	}
+}
+
+// sending registers a probe sent at sentAt and returns its sequence number
+func (p *latencyProbe) sending(sentAt time.Time) uint64 {
+	p.Lock()
+	defer p.Unlock()
+
+	if nil == p.pending {
+		p.pending = make(map[uint64]time.Time)
+	}
+	sequence := p.next
+	p.next++
+	p.pending[sequence] = sentAt
+	p.sent++
+	return sequence
+}
+
+// forget drops a probe which could not be sent
+func (p *latencyProbe) forget(sequence uint64) {
+	p.Lock()
+	defer p.Unlock()
+
+	delete(p.pending, sequence)
+	p.sent--
+}
+
+// expire counts the probes in flight for longer than the timeout as lost, and returns their sequence numbers
+func (p *latencyProbe) expire(now time.Time) []uint64 {
+	p.Lock()
+	defer p.Unlock()
+
+	var lost []uint64
+	for sequence, sentAt := range p.pending {
+		if now.Sub(sentAt) >= p.config.Timeout {
+			delete(p.pending, sequence)
+			p.lost++
+			p.loss += probeLossWeight * (1 - p.loss)
+			lost = append(lost, sequence)
+		}
+	}
+	return lost
+}
+
+// received updates the estimates with a probe received back, and returns its round-trip time.
+// It returns false if the probe is unknown, e.g. sent by another manager or already counted as lost
+func (p *latencyProbe) received(sequence uint64, receivedAt time.Time) (time.Duration, bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	sentAt, ok := p.pending[sequence]
+	if !ok {
+		return 0, false
+	}
+	delete(p.pending, sequence)
+
+	rtt := receivedAt.Sub(sentAt)
+	sample := rtt.Seconds()
+	if p.hasSample {
+		difference := sample - p.lastRTT
+		if difference < 0 {
+			difference = -difference
+		}
+		p.rtt += probeRTTWeight * (sample - p.rtt)
+		p.jitter += probeJitterWeight * (difference - p.jitter)
+	} else {
+		p.rtt = sample
+		p.hasSample = true
+	}
+	p.lastRTT = sample
+	p.loss -= probeLossWeight * p.loss
+	return rtt, true
+}
+
+// probeReceived consumes the probes looped back, and returns false for the other messages
+func (n *NymSocketManager) probeReceived(received NymReceived, receivedAt time.Time) bool {
+	if !strings.HasPrefix(received.Message, latencyProbePrefix) {
+		return false
+	}
+	if nil == n.probe {
+		return true
+	}
+
+	id, sequenceField, _ := strings.Cut(strings.TrimPrefix(received.Message, latencyProbePrefix), ":")
+	sequence, e := strconv.ParseUint(sequenceField, 10, 64)
+	if id != n.probe.id || nil != e {
+		return true
+	}
+
+	if rtt, ok := n.probe.received(sequence, receivedAt); ok {
+		n.events.publish(ProbeEvent{EventCommon: EventCommon{time.Now()}, Sequence: sequence, RTT: rtt})
+	}
+	return true
+}
+
+// seconds converts an estimate in seconds into a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
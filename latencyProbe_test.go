@@ -0,0 +1,87 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// nextProbeEvent skips the lifecycle events until the first ProbeEvent
+func nextProbeEvent(t *testing.T, events <-chan lib.Event) lib.ProbeEvent {
+	for {
+		if probe, ok := nextEvent(t, events).(lib.ProbeEvent); ok {
+			return probe
+		}
+	}
+}
+
+func TestNymSocketManagerLatencyProbe(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handled := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- received
+	}, lib.ZerologLogger(&logger), lib.WithLatencyProbe(lib.LatencyProbe{Interval: 20 * time.Millisecond}))
+	require.NoError(t, e)
+	events := nymSocketManager.Events()
+	defer nymSocketManager.UnsubscribeEvents(events)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	probe := nextProbeEvent(t, events)
+	require.False(t, probe.Lost)
+	require.Greater(t, probe.RTT, time.Duration(0))
+
+	stats := nymSocketManager.Stats()
+	require.GreaterOrEqual(t, stats.ProbesSent, uint64(1))
+	require.Zero(t, stats.ProbesLost)
+	require.Zero(t, stats.ProbeLoss)
+	require.Greater(t, stats.ProbeRTT, time.Duration(0))
+
+	// The probes are not passed to the messageHandler
+	select {
+	case received := <-handled:
+		require.Failf(t, "probe handled", "%v", received)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNymSocketManagerLatencyProbeLoss(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	// The probes are sent to the peer, and never come back
+	fake.selfAddressReply = map[string]interface{}{"type": "selfAddress", "address": fakePeerAddress}
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithLatencyProbe(lib.LatencyProbe{Interval: 10 * time.Millisecond, Timeout: 30 * time.Millisecond}))
+	require.NoError(t, e)
+	events := nymSocketManager.Events()
+	defer nymSocketManager.UnsubscribeEvents(events)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	lost := nextProbeEvent(t, events)
+	require.True(t, lost.Lost)
+	require.Zero(t, lost.RTT)
+
+	stats := nymSocketManager.Stats()
+	require.GreaterOrEqual(t, stats.ProbesLost, uint64(1))
+	require.Greater(t, stats.ProbeLoss, float64(0))
+	require.Zero(t, stats.ProbeRTT)
+}
+
+func TestWithLatencyProbeInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+	for _, probe := range []lib.LatencyProbe{{}, {Interval: time.Second, Timeout: -time.Second}} {
+		_, e := lib.NewNymSocketManager("ws://127.0.0.1:1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithLatencyProbe(probe))
+		require.Error(t, e)
+	}
+}
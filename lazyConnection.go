@@ -0,0 +1,74 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"time"
+)
+
+// connectLazily opens the connection if the manager is started but not connected yet,
+// and postpones the closure of an idle connection
+func (n *NymSocketManager) connectLazily() error {
+	n.Lock()
+	defer n.Unlock()
+
+	// Not started, let the Send fail
+	if nil == n.selfInstanceStoppedChan {
+		return nil
+	}
+
+	if nil == n.connection {
+		n.logger.Debug().Msg("opening connection on first Send")
+		e := n.connect(context.Background(), 0)
+		if nil != e {
+			return e
+		}
+	}
+
+	n.lastLazySendAt = time.Now()
+	n.resetIdleTimer()
+
+	return nil
+}
+
+// resetIdleTimer restarts the countdown to close the connection, if an idle timeout is set
+// called from methods that already acquired the lock
+func (n *NymSocketManager) resetIdleTimer() {
+	if 0 == n.idleTimeout {
+		return
+	}
+
+	if nil == n.idleTimer {
+		n.idleTimer = time.AfterFunc(n.idleTimeout, n.closeIdleConnection)
+		return
+	}
+	n.idleTimer.Reset(n.idleTimeout)
+}
+
+// stopIdleTimer cancels the countdown to close the connection
+// called from methods that already acquired the lock
+func (n *NymSocketManager) stopIdleTimer() {
+	if nil != n.idleTimer {
+		n.idleTimer.Stop()
+		n.idleTimer = nil
+	}
+}
+
+// closeIdleConnection closes the connection once nothing was sent for idleTimeout, without stopping the manager
+func (n *NymSocketManager) closeIdleConnection() {
+	n.Lock()
+	defer n.Unlock()
+
+	// Stopped meanwhile
+	if nil == n.idleTimer || nil == n.connection {
+		return
+	}
+
+	// A Send raced with the timer
+	if time.Since(n.lastLazySendAt) < n.idleTimeout {
+		return
+	}
+
+	n.logger.Debug().Msgf("nothing sent for %v, closing connection", n.idleTimeout)
+	n.disconnect(context.Background(), nil)
+	n.setState(StateDisconnected)
+}
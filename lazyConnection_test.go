@@ -0,0 +1,42 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerLazyConnection(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithLazyConnection(100*time.Millisecond))
+	require.NoError(t, e)
+
+	stopped, e := nymSocketManager.Start()
+	require.NoError(t, e)
+	require.NotNil(t, stopped)
+	require.False(t, nymSocketManager.IsRunning())
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)))
+	require.True(t, nymSocketManager.IsRunning())
+	require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+
+	// Closed once idle, but the manager keeps running
+	require.Eventually(t, func() bool {
+		return !nymSocketManager.IsRunning()
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, lib.StateDisconnected, nymSocketManager.GetState())
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello again", fakePeerAddress)))
+	require.Eventually(t, func() bool {
+		return fake.sendRequests.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+
+	nymSocketManager.Stop()
+	_, open := <-stopped
+	require.False(t, open)
+}
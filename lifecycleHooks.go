@@ -0,0 +1,156 @@
+package nymsocketmanager
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// lifecycleHooks holds the callbacks registered on the NymSocketManager lifecycle events
+type lifecycleHooks struct {
+	sync.Mutex
+
+	onConnect    []func(connectionURI string)
+	onHandshake  []func(clientID string)
+	onDisconnect []func(connectionURI string, reason error)
+	onError      []func(err error)
+
+	onControlMessage []func(msg NymControlMessage)
+	onNymError       []func(err NymError)
+	onRawMessage     []func(msg json.RawMessage)
+	onHandlerError   []func(received NymReceived, err error)
+}
+
+// OnConnect registers a hook called each time the websocket connection to a nym-client is opened
+// Hooks are called synchronously by the manager: they must return quickly and must not call Start, Stop or Restart
+func (n *NymSocketManager) OnConnect(hook func(connectionURI string)) {
+	n.hooks.Lock()
+	defer n.hooks.Unlock()
+	n.hooks.onConnect = append(n.hooks.onConnect, hook)
+}
+
+// OnHandshake registers a hook called each time the clientID is collected from a newly connected nym-client
+// Hooks are called synchronously by the manager: they must return quickly and must not call Start, Stop or Restart
+func (n *NymSocketManager) OnHandshake(hook func(clientID string)) {
+	n.hooks.Lock()
+	defer n.hooks.Unlock()
+	n.hooks.onHandshake = append(n.hooks.onHandshake, hook)
+}
+
+// OnDisconnect registers a hook called each time the connection to a nym-client is closed.
+// reason is nil if the closure was requested (e.g. by Stop), and describes the failure otherwise
+// Hooks are called synchronously by the manager: they must return quickly and must not call Start, Stop or Restart
+func (n *NymSocketManager) OnDisconnect(hook func(connectionURI string, reason error)) {
+	n.hooks.Lock()
+	defer n.hooks.Unlock()
+	n.hooks.onDisconnect = append(n.hooks.onDisconnect, hook)
+}
+
+// OnError registers a hook called on fatal errors, i.e. when the manager fails to start or stops on its own
+// Hooks are called synchronously by the manager: they must return quickly and must not call Start, Stop or Restart
+func (n *NymSocketManager) OnError(hook func(err error)) {
+	n.hooks.Lock()
+	defer n.hooks.Unlock()
+	n.hooks.onError = append(n.hooks.onError, hook)
+}
+
+// OnControlMessage registers a hook called on each message of the nym-client whose type is not parsed by the library,
+// such as status notifications. Without such hook, these messages are only logged.
+// Hooks are called from the goroutine handling the message: they must not call Start, Stop or Restart
+func (n *NymSocketManager) OnControlMessage(hook func(msg NymControlMessage)) {
+	n.hooks.Lock()
+	defer n.hooks.Unlock()
+	n.hooks.onControlMessage = append(n.hooks.onControlMessage, hook)
+}
+
+// OnNymError registers a hook called on each error reported by the nym-client, e.g. about an invalid recipient.
+// Use errors.Is with the ErrNym values to tell the errors apart.
+// Hooks are called from the goroutine handling the message: they must not call Start, Stop or Restart
+func (n *NymSocketManager) OnNymError(hook func(err NymError)) {
+	n.hooks.Lock()
+	defer n.hooks.Unlock()
+	n.hooks.onNymError = append(n.hooks.onNymError, hook)
+}
+
+// OnRawMessage registers a hook called with each JSON message received from the nym-client, before it is parsed.
+// Together with SendRaw, it allows using the messages not supported by the library yet. msg must not be modified.
+// Hooks are called from the goroutine handling the message: they must not call Start, Stop or Restart
+func (n *NymSocketManager) OnRawMessage(hook func(msg json.RawMessage)) {
+	n.hooks.Lock()
+	defer n.hooks.Unlock()
+	n.hooks.onRawMessage = append(n.hooks.onRawMessage, hook)
+}
+
+// OnHandlerError registers a hook called with the errors returned by the messageHandler given to NewNymSocketManagerWithErrors
+// or NewNymSocketManagerWithContext, and by the reply function given to any messageHandler, along with the message being handled.
+// Hooks are called from the goroutine handling the message: they must not call Start, Stop or Restart
+func (n *NymSocketManager) OnHandlerError(hook func(received NymReceived, err error)) {
+	n.hooks.Lock()
+	defer n.hooks.Unlock()
+	n.hooks.onHandlerError = append(n.hooks.onHandlerError, hook)
+}
+
+func (h *lifecycleHooks) connected(connectionURI string) {
+	h.Lock()
+	defer h.Unlock()
+	for _, hook := range h.onConnect {
+		hook(connectionURI)
+	}
+}
+
+func (h *lifecycleHooks) handshakeDone(clientID string) {
+	h.Lock()
+	defer h.Unlock()
+	for _, hook := range h.onHandshake {
+		hook(clientID)
+	}
+}
+
+func (h *lifecycleHooks) disconnected(connectionURI string, reason error) {
+	h.Lock()
+	defer h.Unlock()
+	for _, hook := range h.onDisconnect {
+		hook(connectionURI, reason)
+	}
+}
+
+func (h *lifecycleHooks) failed(err error) {
+	h.Lock()
+	defer h.Unlock()
+	for _, hook := range h.onError {
+		hook(err)
+	}
+}
+
+// controlMessageReceived returns false if no hook was registered to handle msg
+func (h *lifecycleHooks) controlMessageReceived(msg NymControlMessage) bool {
+	h.Lock()
+	defer h.Unlock()
+	for _, hook := range h.onControlMessage {
+		hook(msg)
+	}
+	return len(h.onControlMessage) != 0
+}
+
+func (h *lifecycleHooks) nymErrorReceived(err NymError) {
+	h.Lock()
+	defer h.Unlock()
+	for _, hook := range h.onNymError {
+		hook(err)
+	}
+}
+
+func (h *lifecycleHooks) rawMessageReceived(msg json.RawMessage) {
+	h.Lock()
+	defer h.Unlock()
+	for _, hook := range h.onRawMessage {
+		hook(msg)
+	}
+}
+
+func (h *lifecycleHooks) handlerFailed(received NymReceived, err error) {
+	h.Lock()
+	defer h.Unlock()
+	for _, hook := range h.onHandlerError {
+		hook(received, err)
+	}
+}
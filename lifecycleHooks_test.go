@@ -0,0 +1,149 @@
+package nymsocketmanager_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// hookRecorder collects the calls to the lifecycle hooks
+type hookRecorder struct {
+	sync.Mutex
+	events []string
+	errors []error
+}
+
+func (r *hookRecorder) record(event string, err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, event)
+	if nil != err {
+		r.errors = append(r.errors, err)
+	}
+}
+
+func (r *hookRecorder) get() ([]string, []error) {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string{}, r.events...), append([]error{}, r.errors...)
+}
+
+func registerHooks(nymSocketManager *lib.NymSocketManager, recorder *hookRecorder) {
+	nymSocketManager.OnConnect(func(string) { recorder.record("connect", nil) })
+	nymSocketManager.OnHandshake(func(string) { recorder.record("handshake", nil) })
+	nymSocketManager.OnDisconnect(func(_ string, reason error) { recorder.record("disconnect", reason) })
+	nymSocketManager.OnError(func(err error) { recorder.record("error", err) })
+}
+
+func TestNymSocketManagerLifecycleHooksOnStartStop(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	recorder := &hookRecorder{}
+	registerHooks(nymSocketManager, recorder)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	nymSocketManager.Stop()
+
+	events, errors := recorder.get()
+	require.Equal(t, []string{"connect", "handshake", "disconnect"}, events)
+	require.Empty(t, errors)
+}
+
+func TestNymSocketManagerLifecycleHooksOnConnectionLost(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	recorder := &hookRecorder{}
+	registerHooks(nymSocketManager, recorder)
+
+	stopped, e := nymSocketManager.Start()
+	require.NoError(t, e)
+
+	fake.dropConnections()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("manager did not stop after losing its connection")
+	}
+
+	events, errors := recorder.get()
+	require.Equal(t, []string{"connect", "handshake", "disconnect", "error"}, events)
+	require.Len(t, errors, 2)
+}
+
+func TestNymSocketManagerLifecycleHooksOnFailedStart(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.server.Close()
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	recorder := &hookRecorder{}
+	registerHooks(nymSocketManager, recorder)
+
+	_, e = nymSocketManager.Start()
+	require.Error(t, e)
+
+	events, errors := recorder.get()
+	require.Equal(t, []string{"error"}, events)
+	require.Equal(t, []error{e}, errors)
+}
+
+func TestNymSocketManagerOnControlMessage(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.greeting = map[string]interface{}{"type": "gatewayStatus", "connected": true}
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	controlChan := make(chan lib.NymControlMessage, 1)
+	nymSocketManager.OnControlMessage(func(msg lib.NymControlMessage) { controlChan <- msg })
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	select {
+	case msg := <-controlChan:
+		require.Equal(t, "gatewayStatus", msg.Type)
+		fields, e := msg.Fields()
+		require.NoError(t, e)
+		require.Equal(t, true, fields["connected"])
+	case <-time.After(time.Second):
+		require.Fail(t, "control message not received")
+	}
+}
+
+func TestNymSocketManagerOnNymError(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.greeting = map[string]interface{}{"type": "error", "message": "gateway is unreachable"}
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	errorChan := make(chan lib.NymError, 1)
+	nymSocketManager.OnNymError(func(err lib.NymError) { errorChan <- err })
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	select {
+	case err := <-errorChan:
+		require.ErrorIs(t, err, lib.ErrNymGatewayUnreachable)
+	case <-time.After(time.Second):
+		require.Fail(t, "error not received")
+	}
+}
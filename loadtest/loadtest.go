@@ -0,0 +1,258 @@
+// Package loadtest drives a NymSocketManager with synthetic traffic, to measure the throughput and the latency
+// a nym-client sustains. The messages are looped back to the manager, or sent to an echo peer running Echo
+package loadtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"golang.org/x/xerrors"
+)
+
+// Prefix of the payloads of the load test messages, followed by "<run id>:<sequence number>:" and the padding
+const payloadPrefix = "\x1eNSMLOAD:"
+
+// Time waited for the last messages once the sending is over, unless set in the Config
+const DefaultDrainTimeout = 10 * time.Second
+
+// Config describes the traffic of a load test
+type Config struct {
+	// Messages sent per second
+	Rate float64
+	// Size of the payloads in bytes, raised to fit the sequence number of the messages if needed
+	PayloadSize int
+	// How long the messages are sent for
+	Duration time.Duration
+	// Address of the peer running Echo. If empty, the messages are sent to the manager itself
+	Peer string
+	// Number of reply SURBs attached to the messages sent to Peer, for it to echo them, 1 if 0
+	ReplySurbs uint
+	// Time waited for the last messages once the sending is over, DefaultDrainTimeout if 0
+	DrainTimeout time.Duration
+}
+
+// Report describes what a load test achieved
+type Report struct {
+	Sent       int
+	SendErrors int
+	Received   int
+	// Messages sent but not received back by the end of the drain
+	Lost int
+
+	// Time the messages were sent for
+	Elapsed time.Duration
+	// Messages sent and received per second of Elapsed
+	SendRate    float64
+	ReceiveRate float64
+
+	// Time between the send of the messages and their reception, over the received ones
+	LatencyP50 time.Duration
+	LatencyP90 time.Duration
+	LatencyP99 time.Duration
+	LatencyMax time.Duration
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("sent %d (%d errors, %.1f/s), received %d (%.1f/s), lost %d, latency p50 %v p90 %v p99 %v max %v",
+		r.Sent, r.SendErrors, r.SendRate, r.Received, r.ReceiveRate, r.Lost, r.LatencyP50, r.LatencyP90, r.LatencyP99, r.LatencyMax)
+}
+
+// IsLoadTestMessage tells whether received is a message of a load test, for the messageHandlers to ignore them
+func IsLoadTestMessage(received lib.NymReceived) bool {
+	return strings.HasPrefix(received.Message, payloadPrefix)
+}
+
+// Echo is a messageHandler sending the load test messages back to their sender, through the reply SURBs they carry
+func Echo(received lib.NymReceived, reply func(lib.NymMessage) error) {
+	if IsLoadTestMessage(received) {
+		_ = reply(lib.NewNymReply("", received.Message))
+	}
+}
+
+// run is the state of a load test in progress
+type run struct {
+	sync.Mutex
+
+	id        string
+	sentAt    map[uint64]time.Time
+	latencies []time.Duration
+	// Signaled each time a message is received
+	receivedChan chan struct{}
+}
+
+// Run sends messages at config.Rate for config.Duration through manager, which must be started, and waits for them to be received back.
+// The messageHandler of manager is passed the messages received too, see IsLoadTestMessage.
+// It stops early when ctx is done, returning the report so far along with the error of ctx
+func Run(ctx context.Context, manager *lib.NymSocketManager, config Config) (Report, error) {
+	if config.Rate <= 0 {
+		return Report{}, xerrors.Errorf("rate must be positive, got %v", config.Rate)
+	}
+	if config.Duration <= 0 {
+		return Report{}, xerrors.Errorf("duration must be positive, got %v", config.Duration)
+	}
+	if config.PayloadSize < 0 {
+		return Report{}, xerrors.Errorf("payload size cannot be negative, got %d", config.PayloadSize)
+	}
+	if 0 == config.DrainTimeout {
+		config.DrainTimeout = DefaultDrainTimeout
+	}
+	if 0 == config.ReplySurbs {
+		config.ReplySurbs = 1
+	}
+
+	recipient := config.Peer
+	if len(recipient) == 0 {
+		recipient = manager.GetNymClientId()
+		if len(recipient) == 0 {
+			return Report{}, xerrors.Errorf("the manager has no address, is it started?")
+		}
+	}
+
+	id := make([]byte, 4)
+	if _, e := rand.Read(id); nil != e {
+		return Report{}, xerrors.Errorf("failed to draw the run id: %v", e)
+	}
+	r := &run{id: hex.EncodeToString(id), sentAt: make(map[uint64]time.Time), receivedChan: make(chan struct{}, 1)}
+
+	// Room for a second of traffic, so that a slow receiving goroutine does not lose messages
+	subscription := manager.Subscribe(int(config.Rate) + lib.DefaultSubscriptionBufferSize)
+	receiverDoneChan := make(chan struct{})
+	go func() {
+		defer close(receiverDoneChan)
+		for received := range subscription.Messages() {
+			r.received(received, time.Now())
+		}
+	}()
+	defer func() {
+		manager.Unsubscribe(subscription)
+		<-receiverDoneChan
+	}()
+
+	var report Report
+	interval := time.Duration(float64(time.Second) / config.Rate)
+	startedAt := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+sending:
+	for sequence := uint64(0); time.Since(startedAt) < config.Duration; {
+		// Catches up on the messages due, the ticker drops the ticks missed while sending
+		for due := uint64(time.Since(startedAt).Seconds() * config.Rate); sequence <= due && time.Since(startedAt) < config.Duration; sequence++ {
+			e := manager.Send(r.message(sequence, recipient, config))
+			if nil != e {
+				report.SendErrors++
+				r.forget(sequence)
+			}
+			report.Sent++
+		}
+
+		select {
+		case <-ctx.Done():
+			break sending
+		case <-ticker.C:
+		}
+	}
+	report.Elapsed = time.Since(startedAt)
+
+	// Waits for the messages in flight
+	drainTimer := time.NewTimer(config.DrainTimeout)
+	defer drainTimer.Stop()
+drain:
+	for r.inFlight() > 0 {
+		select {
+		case <-ctx.Done():
+			break drain
+		case <-drainTimer.C:
+			break drain
+		case <-r.receivedChan:
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	report.Received = len(r.latencies)
+	report.Lost = len(r.sentAt)
+	report.SendRate = float64(report.Sent) / report.Elapsed.Seconds()
+	report.ReceiveRate = float64(report.Received) / report.Elapsed.Seconds()
+	if len(r.latencies) != 0 {
+		sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+		report.LatencyP50 = percentile(r.latencies, 50)
+		report.LatencyP90 = percentile(r.latencies, 90)
+		report.LatencyP99 = percentile(r.latencies, 99)
+		report.LatencyMax = r.latencies[len(r.latencies)-1]
+	}
+	return report, ctx.Err()
+}
+
+// message returns the message of the given sequence number, and records when it is sent
+func (r *run) message(sequence uint64, recipient string, config Config) lib.NymMessage {
+	payload := payloadPrefix + r.id + ":" + strconv.FormatUint(sequence, 10) + ":"
+	if padding := config.PayloadSize - len(payload); padding > 0 {
+		payload += strings.Repeat("x", padding)
+	}
+
+	r.Lock()
+	r.sentAt[sequence] = time.Now()
+	r.Unlock()
+
+	if len(config.Peer) == 0 {
+		return lib.NewNymSend(payload, recipient)
+	}
+	return lib.NewNymSendAnonymous(payload, recipient, config.ReplySurbs)
+}
+
+// forget drops a message which could not be sent
+func (r *run) forget(sequence uint64) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.sentAt, sequence)
+}
+
+// received records the latency of a message of the run
+func (r *run) received(received lib.NymReceived, receivedAt time.Time) {
+	fields := strings.SplitN(strings.TrimPrefix(received.Message, payloadPrefix), ":", 3)
+	if !IsLoadTestMessage(received) || len(fields) != 3 || fields[0] != r.id {
+		return
+	}
+	sequence, e := strconv.ParseUint(fields[1], 10, 64)
+	if nil != e {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	sentAt, ok := r.sentAt[sequence]
+	if !ok {
+		return
+	}
+	delete(r.sentAt, sequence)
+	r.latencies = append(r.latencies, receivedAt.Sub(sentAt))
+
+	select {
+	case r.receivedChan <- struct{}{}:
+	default:
+	}
+}
+
+func (r *run) inFlight() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.sentAt)
+}
+
+// percentile returns the nearest-rank percentile of the sorted latencies
+func percentile(latencies []time.Duration, p int) time.Duration {
+	rank := (p*len(latencies) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return latencies[rank-1]
+}
@@ -0,0 +1,124 @@
+package loadtest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/notrustverify/nymsocketmanager/loadtest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+const loopbackAddress = "4wBqpZM9xaSheZzJSMawUKKwhdpChKbZ5eu5ky4Vigw.3ELeRTTg5W5hAYaEFznzFV1jknNFkjHqS8ytwvQEQP1Z@5Pk716N113awdSaUDZEPZVi9Zs6hJmG5KCJtp5qQK3LB"
+
+// newLoopbackNymClient starts a nym-client looping back the messages sent to its own address, returning its URI
+func newLoopbackNymClient(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connection, e := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if nil != e {
+			return
+		}
+		defer connection.Close()
+
+		for {
+			request := make(map[string]interface{})
+			if nil != connection.ReadJSON(&request) {
+				return
+			}
+			switch request["type"] {
+			case "selfAddress":
+				e = connection.WriteJSON(map[string]string{"type": "selfAddress", "address": loopbackAddress})
+			case "send":
+				if request["recipient"] == loopbackAddress {
+					e = connection.WriteJSON(map[string]interface{}{"type": "received", "message": request["message"]})
+				}
+			}
+			if nil != e {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestRun(t *testing.T) {
+	logger := zerolog.Logger{}
+	handled := make(chan bool, 1024)
+	manager, e := lib.NewNymSocketManager(newLoopbackNymClient(t), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- loadtest.IsLoadTestMessage(received)
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	_, e = manager.Start()
+	require.NoError(t, e)
+	defer manager.Stop()
+
+	report, e := loadtest.Run(context.Background(), manager, loadtest.Config{Rate: 200, PayloadSize: 256, Duration: 500 * time.Millisecond})
+	require.NoError(t, e)
+
+	require.InDelta(t, 100, report.Sent, 10)
+	require.Zero(t, report.SendErrors)
+	require.Equal(t, report.Sent, report.Received)
+	require.Zero(t, report.Lost)
+	require.InDelta(t, 200, report.SendRate, 40)
+	require.Greater(t, report.LatencyP50, time.Duration(0))
+	require.LessOrEqual(t, report.LatencyP50, report.LatencyP90)
+	require.LessOrEqual(t, report.LatencyP99, report.LatencyMax)
+	require.Contains(t, report.String(), "lost 0")
+	require.True(t, <-handled)
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	logger := zerolog.Logger{}
+	manager, e := lib.NewNymSocketManager(newLoopbackNymClient(t), func(lib.NymReceived, func(lib.NymMessage) error) {}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	_, e = manager.Start()
+	require.NoError(t, e)
+	defer manager.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	startedAt := time.Now()
+	report, e := loadtest.Run(ctx, manager, loadtest.Config{Rate: 50, Duration: time.Minute})
+	require.ErrorIs(t, e, context.DeadlineExceeded)
+	require.Less(t, time.Since(startedAt), 5*time.Second)
+	require.Greater(t, report.Sent, 0)
+}
+
+func TestRunInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+	manager, e := lib.NewNymSocketManager("ws://127.0.0.1:1977", func(lib.NymReceived, func(lib.NymMessage) error) {}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	for _, config := range []loadtest.Config{
+		{Rate: 0, Duration: time.Second},
+		{Rate: 1, Duration: 0},
+		{Rate: 1, Duration: time.Second, PayloadSize: -1},
+		// Not started, no address to loop back to
+		{Rate: 1, Duration: time.Second},
+	} {
+		_, e = loadtest.Run(context.Background(), manager, config)
+		require.Error(t, e, config)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	var replies []lib.NymMessage
+	reply := func(msg lib.NymMessage) error {
+		replies = append(replies, msg)
+		return nil
+	}
+	loadtest.Echo(lib.NymReceived{Message: "not a load test message", SenderTag: "tag"}, reply)
+	require.Empty(t, replies)
+
+	message := "\x1eNSMLOAD:0:0:"
+	loadtest.Echo(lib.NymReceived{Message: message, SenderTag: "tag"}, reply)
+	require.Len(t, replies, 1)
+	require.Equal(t, lib.NewNymReply("", message), replies[0])
+}
@@ -0,0 +1,30 @@
+package nymsocketmanager
+
+import (
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// LogSampling configures which of the Debug and Trace logs written for each message are kept, see WithLogSampling
+type LogSampling struct {
+	// Keep 1 in Every logs
+	Every uint32
+	// Keep the first Burst logs of each Period before sampling, none if 0
+	Burst  uint32
+	Period time.Duration
+}
+
+// sampler returns the zerolog.Sampler applying the sampling to the Debug and Trace levels only
+func (s LogSampling) sampler() zerolog.Sampler {
+	var sampler zerolog.Sampler = &zerolog.BasicSampler{N: s.Every}
+	if s.Burst > 0 {
+		sampler = &zerolog.BurstSampler{Burst: s.Burst, Period: s.Period, NextSampler: sampler}
+	}
+	return zerolog.LevelSampler{TraceSampler: sampler, DebugSampler: sampler}
+}
+
+// SetLogSampler samples the Debug and Trace logs written for each message read, the other logs are always written
+func (s *SocketListener) SetLogSampler(sampler zerolog.Sampler) {
+	s.messageLogger = s.logger.sampled(sampler)
+}
@@ -0,0 +1,96 @@
+package nymsocketmanager_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedBuffer is a bytes.Buffer safe to log to from several goroutines
+type lockedBuffer struct {
+	sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) count(s string) int {
+	b.Lock()
+	defer b.Unlock()
+	return strings.Count(b.buffer.String(), s)
+}
+
+func TestNymSocketManagerLogSampling(t *testing.T) {
+	var buffer lockedBuffer
+	logger := zerolog.New(&buffer).Level(zerolog.DebugLevel)
+	fake := newFakeNymClient(t)
+
+	received := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(r lib.NymReceived, _ func(lib.NymMessage) error) {
+		received <- r
+	}, lib.ZerologLogger(&logger), lib.WithLogSampling(lib.LogSampling{Every: 5}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			require.Fail(t, "message not received")
+		}
+	}
+
+	// 1 in 5 of the logs of the messages received is kept, the lifecycle ones all are
+	require.Equal(t, 2, buffer.count(`"got: `))
+	require.Equal(t, 1, buffer.count("started NymSocketManager"))
+}
+
+func TestNymSocketManagerLogSamplingBurst(t *testing.T) {
+	var buffer lockedBuffer
+	logger := zerolog.New(&buffer).Level(zerolog.DebugLevel)
+	fake := newFakeNymClient(t)
+
+	received := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(r lib.NymReceived, _ func(lib.NymMessage) error) {
+		received <- r
+	}, lib.ZerologLogger(&logger), lib.WithLogSampling(lib.LogSampling{Every: 100, Burst: 3, Period: time.Hour}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			require.Fail(t, "message not received")
+		}
+	}
+
+	// The burst is kept, then 1 in 100
+	require.Equal(t, 4, buffer.count(`"got: `))
+}
+
+func TestWithLogSamplingInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+	for _, sampling := range []lib.LogSampling{{}, {Every: 10, Burst: 5}} {
+		_, e := lib.NewNymSocketManager("ws://127.0.0.1:1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithLogSampling(sampling))
+		require.Error(t, e)
+	}
+}
@@ -1,3 +1,189 @@
 package nymsocketmanager
 
+import (
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
 const ComponentField = "component"
+
+// Field of the logs related to a single message, holding its identifier, see NymReceived.MessageID and WithMessageID
+const MessageIDField = "messageId"
+
+// Logger is the minimal interface of a structured logger, given to the constructors of this package,
+// so that projects standardized on another logging library (e.g. slog, zap or logrus) can plug theirs in.
+// fields can be nil. See ZerologLogger for zerolog
+type Logger interface {
+	Debug(msg string, fields map[string]interface{})
+	Info(msg string, fields map[string]interface{})
+	Warn(msg string, fields map[string]interface{})
+	Error(msg string, fields map[string]interface{})
+}
+
+// TraceLogger is implemented by the Loggers writing the Trace logs, written for each frame and message.
+// They are not written to the other Loggers
+type TraceLogger interface {
+	Trace(msg string, fields map[string]interface{})
+}
+
+// ZerologLogger returns the Logger writing to logger, nil if logger is nil. The entries below the level of logger are not built
+func ZerologLogger(logger *zerolog.Logger) Logger {
+	if nil == logger {
+		return nil
+	}
+	return zerologLogger{logger}
+}
+
+type zerologLogger struct {
+	logger *zerolog.Logger
+}
+
+func (l zerologLogger) Trace(msg string, fields map[string]interface{}) {
+	l.logger.Trace().Fields(fields).Msg(msg)
+}
+
+func (l zerologLogger) Debug(msg string, fields map[string]interface{}) {
+	l.logger.Debug().Fields(fields).Msg(msg)
+}
+
+func (l zerologLogger) Info(msg string, fields map[string]interface{}) {
+	l.logger.Info().Fields(fields).Msg(msg)
+}
+
+func (l zerologLogger) Warn(msg string, fields map[string]interface{}) {
+	l.logger.Warn().Fields(fields).Msg(msg)
+}
+
+func (l zerologLogger) Error(msg string, fields map[string]interface{}) {
+	l.logger.Error().Fields(fields).Msg(msg)
+}
+
+// componentLogger writes the logs of a component of the package to a Logger, with the field of the component
+type componentLogger struct {
+	output    Logger
+	component string
+	// Lowest level written
+	level zerolog.Level
+	// nil unless the logs are sampled, see WithLogSampling
+	sampler zerolog.Sampler
+}
+
+func newComponentLogger(output Logger, component string) *componentLogger {
+	level := zerolog.DebugLevel
+	if _, ok := output.(TraceLogger); ok {
+		level = zerolog.TraceLevel
+	}
+	if zerologger, ok := output.(zerologLogger); ok && zerologger.logger.GetLevel() > level {
+		level = zerologger.logger.GetLevel()
+	}
+	return &componentLogger{output: output, component: component, level: level}
+}
+
+// sampled returns the logger once sampled with sampler, the logger itself if sampler is nil
+func (l *componentLogger) sampled(sampler zerolog.Sampler) *componentLogger {
+	if nil == sampler {
+		return l
+	}
+	sampled := *l
+	sampled.sampler = sampler
+	return &sampled
+}
+
+func (l *componentLogger) Trace() *logEntry {
+	return l.entry(zerolog.TraceLevel)
+}
+
+func (l *componentLogger) Debug() *logEntry {
+	return l.entry(zerolog.DebugLevel)
+}
+
+func (l *componentLogger) Info() *logEntry {
+	return l.entry(zerolog.InfoLevel)
+}
+
+func (l *componentLogger) Warn() *logEntry {
+	return l.entry(zerolog.WarnLevel)
+}
+
+func (l *componentLogger) Error() *logEntry {
+	return l.entry(zerolog.ErrorLevel)
+}
+
+// entry returns nil if the entry is not to be written, its methods being then no-ops
+func (l *componentLogger) entry(level zerolog.Level) *logEntry {
+	if level < l.level || (nil != l.sampler && !l.sampler.Sample(level)) {
+		return nil
+	}
+	return &logEntry{logger: l, level: level, fields: map[string]interface{}{ComponentField: l.component}}
+}
+
+// logEntry collects the fields of an entry until it is written by Msg or Msgf
+type logEntry struct {
+	logger *componentLogger
+	level  zerolog.Level
+	fields map[string]interface{}
+}
+
+func (e *logEntry) Str(key string, value string) *logEntry {
+	if nil != e {
+		e.fields[key] = value
+	}
+	return e
+}
+
+func (e *logEntry) Int(key string, value int) *logEntry {
+	if nil != e {
+		e.fields[key] = value
+	}
+	return e
+}
+
+func (e *logEntry) Uint(key string, value uint) *logEntry {
+	if nil != e {
+		e.fields[key] = value
+	}
+	return e
+}
+
+func (e *logEntry) Uint64(key string, value uint64) *logEntry {
+	if nil != e {
+		e.fields[key] = value
+	}
+	return e
+}
+
+func (e *logEntry) Fields(fields map[string]interface{}) *logEntry {
+	if nil != e {
+		for key, value := range fields {
+			e.fields[key] = value
+		}
+	}
+	return e
+}
+
+func (e *logEntry) Msgf(format string, v ...interface{}) {
+	if nil != e {
+		e.Msg(fmt.Sprintf(format, v...))
+	}
+}
+
+func (e *logEntry) Msg(msg string) {
+	if nil == e {
+		return
+	}
+
+	output := e.logger.output
+	switch e.level {
+	case zerolog.TraceLevel:
+		output.(TraceLogger).Trace(msg, e.fields)
+	case zerolog.DebugLevel:
+		output.Debug(msg, e.fields)
+	case zerolog.InfoLevel:
+		output.Info(msg, e.fields)
+	case zerolog.WarnLevel:
+		output.Warn(msg, e.fields)
+	default:
+		output.Error(msg, e.fields)
+	}
+}
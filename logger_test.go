@@ -0,0 +1,86 @@
+package nymsocketmanager_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+type loggedEntry struct {
+	level  string
+	msg    string
+	fields map[string]interface{}
+}
+
+// recordingLogger is a Logger of another logging library, keeping the entries in memory
+type recordingLogger struct {
+	sync.Mutex
+	entries []loggedEntry
+}
+
+func (l *recordingLogger) log(level string, msg string, fields map[string]interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	l.entries = append(l.entries, loggedEntry{level, msg, fields})
+}
+
+func (l *recordingLogger) Debug(msg string, fields map[string]interface{}) {
+	l.log("debug", msg, fields)
+}
+func (l *recordingLogger) Info(msg string, fields map[string]interface{}) { l.log("info", msg, fields) }
+func (l *recordingLogger) Warn(msg string, fields map[string]interface{}) { l.log("warn", msg, fields) }
+func (l *recordingLogger) Error(msg string, fields map[string]interface{}) {
+	l.log("error", msg, fields)
+}
+
+func (l *recordingLogger) find(msg string) (loggedEntry, bool) {
+	l.Lock()
+	defer l.Unlock()
+	for _, entry := range l.entries {
+		if msg == entry.msg {
+			return entry, true
+		}
+	}
+	return loggedEntry{}, false
+}
+
+func TestNymSocketManagerLogger(t *testing.T) {
+	recording := &recordingLogger{}
+	fake := newFakeNymClient(t)
+
+	handledChan := make(chan struct{}, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(lib.NymReceived, func(lib.NymMessage) error) {
+		handledChan <- struct{}{}
+	}, recording)
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", nymSocketManager.GetNymClientId())))
+	<-handledChan
+	nymSocketManager.Stop()
+
+	started, ok := recording.find("started NymSocketManager")
+	require.True(t, ok)
+	require.Equal(t, "debug", started.level)
+	require.Equal(t, "NymSocketManager", started.fields[lib.ComponentField])
+
+	// recordingLogger does not implement TraceLogger
+	_, ok = recording.find("wrote NymSend")
+	require.False(t, ok)
+}
+
+func TestZerologLogger(t *testing.T) {
+	var output bytes.Buffer
+	zerologger := zerolog.New(&output)
+
+	logger := lib.ZerologLogger(&zerologger)
+	logger.Info("hello", map[string]interface{}{"peer": "alice"})
+	logger.Error("failed", nil)
+
+	require.Equal(t, `{"level":"info","peer":"alice","message":"hello"}`+"\n"+`{"level":"error","message":"failed"}`+"\n", output.String())
+}
@@ -0,0 +1,13 @@
+package nymsocketmanager
+
+// Manager is the part of the NymSocketManager used by most applications,
+// so that they can be tested against the mocks package instead of a live nym-client
+type Manager interface {
+	Start() (chan struct{}, error)
+	Stop()
+	Send(msg NymMessage) error
+	IsRunning() bool
+	GetNymClientId() string
+}
+
+var _ Manager = (*NymSocketManager)(nil)
@@ -0,0 +1,26 @@
+package nymsocketmanager
+
+// WithMessageID sets the identifier attached to the logs and events of the message, instead of the one generated by the manager,
+// e.g. to follow the identifier of the application through the send queue and the writer
+func WithMessageID(id string) SendOption {
+	return func(o *sendOptions) {
+		o.messageID = id
+	}
+}
+
+// newMessageID returns an identifier for a message which carries none, empty if none could be generated
+func (n *NymSocketManager) newMessageID() string {
+	id, e := newRandomID()
+	if nil != e {
+		n.logger.Warn().Msgf("failed to generate a message id: %v", e)
+	}
+	return id
+}
+
+// id returns the identifier of the message sent with the options, empty if not known
+func (o *sendOptions) id() string {
+	if nil == o {
+		return ""
+	}
+	return o.messageID
+}
@@ -0,0 +1,88 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerMessageID(t *testing.T) {
+	var buffer lockedBuffer
+	logger := zerolog.New(&buffer).Level(zerolog.TraceLevel)
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The identifier of the application is attached to the logs of the send
+	require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend("hello", fakeNymClientAddress), lib.WithMessageID("app-42")))
+	received := <-receivedChan
+	require.Equal(t, 1, buffer.count(`"messageId":"app-42","message":"wrote NymSend"`))
+
+	// The received messages get their own, attached to the logs of their handling
+	require.Len(t, received.MessageID, 16)
+	require.NotEqual(t, "app-42", received.MessageID)
+	require.Equal(t, 1, buffer.count(`"messageId":"`+received.MessageID+`","message":"handling message`))
+}
+
+func TestNymSocketManagerMessageIDShared(t *testing.T) {
+	var buffer lockedBuffer
+	logger := zerolog.New(&buffer).Level(zerolog.TraceLevel)
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	deliveredChan := make(chan error, 1)
+	require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend("hello", fakeNymClientAddress),
+		lib.WithConfirmedDelivery(time.Second, func(err error) { deliveredChan <- err })))
+	require.NoError(t, <-deliveredChan)
+
+	// The recipient extracts the identifier of the confirmation, which the sender and the acknowledgement use
+	received := <-receivedChan
+	require.Len(t, received.MessageID, 16)
+	require.Equal(t, 1, buffer.count(`"messageId":"`+received.MessageID+`","message":"wrote NymSendAnonymous"`))
+	require.Equal(t, 1, buffer.count(`"messageId":"`+received.MessageID+`","message":"wrote reply"`))
+}
+
+func TestNymSocketManagerMessageIDDropped(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	nymSocketManager.AddFilter("none", func(lib.NymReceived) bool { return false })
+	events := nymSocketManager.Events()
+	defer nymSocketManager.UnsubscribeEvents(events)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	for {
+		if dropped, ok := nextEvent(t, events).(lib.MessageDroppedEvent); ok {
+			require.Equal(t, lib.DropFiltered, dropped.Reason)
+			require.Equal(t, dropped.Message.(lib.NymReceived).MessageID, dropped.MessageID)
+			require.Len(t, dropped.MessageID, 16)
+			return
+		}
+	}
+}
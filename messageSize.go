@@ -0,0 +1,85 @@
+package nymsocketmanager
+
+import (
+	"io"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/xerrors"
+)
+
+// OversizePolicy defines what happens to the frames larger than the maximum message size, see WithMaxMessageSize
+type OversizePolicy int
+
+const (
+	// Close the connection, which is then handled as lost
+	OversizeClose OversizePolicy = iota
+	// Discard the frame while reading it, and keep reading the next ones
+	OversizeSkip
+)
+
+func (p OversizePolicy) String() string {
+	switch p {
+	case OversizeClose:
+		return "Close"
+	case OversizeSkip:
+		return "Skip"
+	default:
+		return "Unknown"
+	}
+}
+
+// ErrMessageTooLarge is the reason of the closure of a connection which received a frame larger than the maximum message size
+var ErrMessageTooLarge = xerrors.New("message exceeds the maximum message size")
+
+// SetMaxMessageSize makes the SocketListener apply policy to the frames larger than limit bytes, without holding more than limit bytes
+// of them in memory. onOversized, if not nil, is called with the size of each of them: the whole size with OversizeSkip,
+// and the size read before closing with OversizeClose. It must be called before Listen
+func (s *SocketListener) SetMaxMessageSize(limit int64, policy OversizePolicy, onOversized func(size int64)) {
+	s.maxMessageSize = limit
+	s.oversizePolicy = policy
+	s.onOversized = onOversized
+}
+
+// readMessage reads the next frame, applying the oversize policy if a maximum message size is set
+func (s *SocketListener) readMessage() (int, []byte, error) {
+	if 0 == s.maxMessageSize {
+		return s.socket.ReadMessage()
+	}
+
+	for {
+		messageType, reader, e := s.socket.NextReader()
+		if nil != e {
+			return messageType, nil, e
+		}
+
+		message, e := io.ReadAll(io.LimitReader(reader, s.maxMessageSize+1))
+		if nil != e {
+			return messageType, nil, e
+		}
+		if int64(len(message)) <= s.maxMessageSize {
+			return messageType, message, nil
+		}
+
+		size := int64(len(message))
+		if OversizeSkip == s.oversizePolicy {
+			skipped, e := io.Copy(io.Discard, reader)
+			if nil != e {
+				return messageType, nil, e
+			}
+			size += skipped
+		}
+		s.logger.Warn().Msgf("received a frame of %d bytes or more, exceeding the maximum message size of %d bytes (%v)", size, s.maxMessageSize, s.oversizePolicy)
+		if nil != s.onOversized {
+			s.onOversized(size)
+		}
+		if OversizeSkip == s.oversizePolicy {
+			continue
+		}
+
+		// Closed on our side right away, the rest of the frame is not read
+		_ = s.socket.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseMessageTooBig, ""), time.Now().Add(time.Second))
+		s.socket.Close()
+		return messageType, nil, ErrMessageTooLarge
+	}
+}
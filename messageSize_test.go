@@ -0,0 +1,71 @@
+package nymsocketmanager_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerMaxMessageSizeSkip(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handled := make(chan lib.NymReceived, 2)
+	oversized := make(chan int64, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- received
+	}, lib.ZerologLogger(&logger), lib.WithHandlerPool(lib.HandlerPool{Workers: 1, QueueLength: 2}),
+		lib.WithMaxMessageSize(1024, lib.OversizeSkip, func(size int64) { oversized <- size }))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for _, message := range []string{"before", strings.Repeat("a", 4096), "after"} {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend(message, fakeNymClientAddress)))
+	}
+
+	for _, message := range []string{"before", "after"} {
+		select {
+		case received := <-handled:
+			require.Equal(t, message, received.Message)
+		case <-time.After(time.Second):
+			require.Fail(t, "message not handled")
+		}
+	}
+	require.Greater(t, <-oversized, int64(4096))
+	require.True(t, nymSocketManager.IsRunning())
+}
+
+func TestNymSocketManagerMaxMessageSizeClose(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	oversized := make(chan int64, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithMaxMessageSize(1024, lib.OversizeClose, func(size int64) { oversized <- size }))
+	require.NoError(t, e)
+
+	disconnected := make(chan error, 1)
+	nymSocketManager.OnDisconnect(func(_ string, reason error) { disconnected <- reason })
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(strings.Repeat("a", 4096), fakeNymClientAddress)))
+	select {
+	case reason := <-disconnected:
+		require.ErrorContains(t, reason, lib.ErrMessageTooLarge.Error())
+	case <-time.After(time.Second):
+		require.Fail(t, "connection not closed")
+	}
+	require.Equal(t, int64(1025), <-oversized)
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithMaxMessageSize(0, lib.OversizeSkip, nil))
+	require.Error(t, e)
+}
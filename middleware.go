@@ -0,0 +1,165 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// Handler processes a message received from the mixnet, as the messageHandler given to NewNymSocketManager.
+// reply sends messages back, see ReplyTo for the messages it routes to the sender of received
+type Handler func(received NymReceived, reply func(NymMessage) error)
+
+// Middleware wraps a Handler, e.g. to decrypt, decompress, log or measure the received messages.
+// It can modify received before calling next, or not call next at all to drop the message
+type Middleware func(next Handler) Handler
+
+// contextHandler is the most general form of messageHandler, to which the others are converted
+type contextHandler func(context.Context, NymReceived, func(NymMessage) error) error
+
+// SetMessageHandler replaces the messageHandler, possibly while running, e.g. to switch to a maintenance mode.
+// The messages being handled keep the previous messageHandler, the next ones get the new one
+func (n *NymSocketManager) SetMessageHandler(messageHandler func(NymReceived, func(NymMessage) error)) error {
+	if nil == messageHandler {
+		err := xerrors.Errorf("processing function needs to be defined")
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	return n.SetMessageHandlerWithContext(func(_ context.Context, received NymReceived, reply func(NymMessage) error) error {
+		messageHandler(received, reply)
+		return nil
+	})
+}
+
+// SetMessageHandlerWithContext is SetMessageHandler for a messageHandler as given to NewNymSocketManagerWithContext
+func (n *NymSocketManager) SetMessageHandlerWithContext(messageHandler func(context.Context, NymReceived, func(NymMessage) error) error) error {
+	if nil == messageHandler {
+		err := xerrors.Errorf("processing function needs to be defined")
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	handler := contextHandler(messageHandler)
+	n.messageHandler.Store(&handler)
+	return nil
+}
+
+// middlewareChain holds the middlewares registered with Use and the messageHandler they wrap
+type middlewareChain struct {
+	sync.Mutex
+
+	middlewares []Middleware
+	// messageHandler wrapped by the middlewares, nil until computed
+	handler Handler
+}
+
+// Use adds middlewares on the receive path. The middlewares run in the order they are registered,
+// the first one receiving the messages first, and the messageHandler is called last
+func (n *NymSocketManager) Use(middlewares ...Middleware) {
+	n.middlewares.Lock()
+	defer n.middlewares.Unlock()
+	n.middlewares.middlewares = append(n.middlewares.middlewares, middlewares...)
+	n.middlewares.handler = nil
+}
+
+// handle drops received if a filter rejects it or if it is a duplicate, or publishes it to the subscriptions and passes it to the handler pool if any,
+// or directly through the middlewares to the messageHandler
+func (n *NymSocketManager) handle(received NymReceived) {
+	if name, filtered := n.filters.filtered(received); filtered {
+		n.messageLogger.Debug().Str(MessageIDField, received.MessageID).Msgf("message dropped by filter %v", name)
+		n.stats.filteredBy(name)
+		n.messageDropped(DropFiltered, received, received.MessageID, nil, name)
+		return
+	}
+	if nil != n.deduplicator && n.deduplicator.duplicate(received) {
+		n.messageLogger.Debug().Str(MessageIDField, received.MessageID).Msg("duplicate message dropped")
+		n.stats.duplicateDropped()
+		n.messageDropped(DropDuplicate, received, received.MessageID, nil, "")
+		return
+	}
+	n.subscribers.publish(received)
+	if n.holdWhilePaused(received) {
+		return
+	}
+	n.handOver(received, false)
+}
+
+// handOver passes received to the handler pool if any, to the messageHandler otherwise.
+// held tells whether received was held while paused, see holdWhilePaused
+func (n *NymSocketManager) handOver(received NymReceived, held bool) {
+	if nil == n.handlerPool {
+		n.callHandler(received)
+		return
+	}
+
+	overflow := n.handlerPool.config.Overflow
+	if held {
+		// Kept for the messageHandler already, waiting for a worker rather than being dropped by the burst on Resume
+		overflow = OverflowBlock
+	}
+	dropped, e := n.handlerPool.push(received, overflow)
+	for _, oldest := range dropped {
+		err := xerrors.Errorf("handler queue is full (%d messages), dropped the oldest message", n.handlerPool.config.QueueLength)
+		n.logger.Warn().Str(MessageIDField, oldest.MessageID).Msg(err.Error())
+		n.handlerPool.dropped.Add(1)
+		n.messageDropped(DropHandlerQueue, oldest, oldest.MessageID, nil, err.Error())
+	}
+	if nil != e {
+		n.logger.Warn().Str(MessageIDField, received.MessageID).Msg(e.Error())
+		n.handlerPool.dropped.Add(1)
+		n.messageDropped(DropHandlerQueue, received, received.MessageID, nil, e.Error())
+	}
+}
+
+// callHandler passes received through the middlewares to the messageHandler
+func (n *NymSocketManager) callHandler(received NymReceived) {
+	n.middlewares.Lock()
+	if nil == n.middlewares.handler {
+		handler := Handler(func(received NymReceived, reply func(NymMessage) error) {
+			ctx := n.handlerContext.get()
+			if nil != received.trace {
+				ctx = received.trace.ctx
+			}
+			e := (*n.messageHandler.Load())(ctx, received, reply)
+			if nil == e {
+				n.acknowledgeHandled(received)
+			}
+			if nil != e && nil != received.trace {
+				received.trace.span.SetError(e)
+			}
+			// The errors of the reply function were reported already
+			var replyError *ReplyError
+			if nil != e && !xerrors.As(e, &replyError) {
+				n.logger.Warn().Str(MessageIDField, received.MessageID).Msgf("messageHandler failed: %v", e)
+				n.errors.record(ErrorCategoryHandler, e)
+				n.hooks.handlerFailed(received, e)
+			}
+		})
+		for i := len(n.middlewares.middlewares) - 1; i >= 0; i-- {
+			handler = n.middlewares.middlewares[i](handler)
+		}
+		n.middlewares.handler = handler
+	}
+	handler := n.middlewares.handler
+	n.middlewares.Unlock()
+
+	if !n.handlerGate.enter() {
+		n.logger.Debug().Str(MessageIDField, received.MessageID).Msg("dropping message received while stopping")
+		n.messageDropped(DropStopping, received, received.MessageID, nil, "received while stopping")
+		return
+	}
+	defer n.handlerGate.leave()
+
+	span := n.traceHandled(&received)
+	defer span.End()
+	n.messageLogger.Trace().Str(MessageIDField, received.MessageID).Msgf("handling message from %v", received.SenderTag)
+	defer n.watchHandler(received)()
+
+	if nil == n.profiling {
+		handler(received, n.replyFuncFor(received))
+		return
+	}
+	n.profiling.do(n.handlerContext.get(), received, func() { handler(received, n.replyFuncFor(received)) })
+}
@@ -0,0 +1,101 @@
+package nymsocketmanager_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixing returns a middleware adding prefix to the received messages
+func prefixing(prefix string) lib.Middleware {
+	return func(next lib.Handler) lib.Handler {
+		return func(received lib.NymReceived, reply func(lib.NymMessage) error) {
+			received.Message = prefix + received.Message
+			next(received, reply)
+		}
+	}
+}
+
+func TestNymSocketManagerMiddlewares(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 2)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	nymSocketManager.Use(prefixing("b:"))
+	nymSocketManager.Use(prefixing("c:"), func(next lib.Handler) lib.Handler {
+		return func(received lib.NymReceived, reply func(lib.NymMessage) error) {
+			if !strings.HasSuffix(received.Message, "drop") {
+				next(received, reply)
+			}
+		}
+	})
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("drop", nymSocketManager.GetNymClientId())))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("a", nymSocketManager.GetNymClientId())))
+
+	select {
+	case received := <-receivedChan:
+		// The middlewares registered first wrap the ones registered after
+		require.Equal(t, "c:b:a", received.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+
+	// Middlewares can be added while running
+	nymSocketManager.Use(prefixing("d:"))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("a", nymSocketManager.GetNymClientId())))
+
+	select {
+	case received := <-receivedChan:
+		require.Equal(t, "d:c:b:a", received.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+}
+
+func TestNymSocketManagerSetMessageHandler(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan string, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- "regular: " + received.Message
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("a", nymSocketManager.GetNymClientId())))
+	require.Equal(t, "regular: a", <-receivedChan)
+
+	// Swapped without reconnecting
+	require.NoError(t, nymSocketManager.SetMessageHandler(func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- "maintenance: " + received.Message
+	}))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("b", nymSocketManager.GetNymClientId())))
+	select {
+	case message := <-receivedChan:
+		require.Equal(t, "maintenance: b", message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+	require.Equal(t, 1, fake.connectionCount())
+
+	require.Error(t, nymSocketManager.SetMessageHandler(nil))
+	require.Error(t, nymSocketManager.SetMessageHandlerWithContext(nil))
+}
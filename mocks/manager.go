@@ -0,0 +1,101 @@
+// Package mocks provides an in-memory nymsocketmanager.Manager, to unit-test applications without a nym-client
+package mocks
+
+import (
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+)
+
+// Manager records the messages sent and delivers the messages provided with Receive to its messageHandler
+type Manager struct {
+	sync.Mutex
+
+	// Returned by GetNymClientId
+	ClientID string
+	// If set, returned by Start instead of starting
+	StartErr error
+	// If set, returned by Send instead of recording the message
+	SendErr error
+
+	messageHandler func(lib.NymReceived, func(lib.NymMessage) error)
+	stoppedChan    chan struct{}
+	sent           []lib.NymMessage
+}
+
+var _ lib.Manager = (*Manager)(nil)
+
+// NewManager returns a stopped Manager, messageHandler can be nil if Receive is not used
+func NewManager(clientID string, messageHandler func(lib.NymReceived, func(lib.NymMessage) error)) *Manager {
+	return &Manager{
+		ClientID:       clientID,
+		messageHandler: messageHandler,
+	}
+}
+
+// Start returns a channel closed by Stop
+func (m *Manager) Start() (chan struct{}, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if nil != m.StartErr {
+		return nil, m.StartErr
+	}
+	if nil != m.stoppedChan {
+		return nil, xerrors.Errorf("Manager is already running")
+	}
+	m.stoppedChan = make(chan struct{})
+	return m.stoppedChan, nil
+}
+
+func (m *Manager) Stop() {
+	m.Lock()
+	defer m.Unlock()
+
+	if nil != m.stoppedChan {
+		close(m.stoppedChan)
+		m.stoppedChan = nil
+	}
+}
+
+// Send records msg, see Sent
+func (m *Manager) Send(msg lib.NymMessage) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if nil != m.SendErr {
+		return m.SendErr
+	}
+	if nil == m.stoppedChan {
+		return xerrors.Errorf("cannot send %v: %w", msg.Name(), lib.ErrNotStarted)
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *Manager) IsRunning() bool {
+	m.Lock()
+	defer m.Unlock()
+	return nil != m.stoppedChan
+}
+
+func (m *Manager) GetNymClientId() string {
+	m.Lock()
+	defer m.Unlock()
+	return m.ClientID
+}
+
+// Sent returns the messages sent so far, in order
+func (m *Manager) Sent() []lib.NymMessage {
+	m.Lock()
+	defer m.Unlock()
+	return append([]lib.NymMessage(nil), m.sent...)
+}
+
+// Receive calls the messageHandler with received, as the NymSocketManager does for the messages of the nym-client.
+// The messages sent by the messageHandler are recorded like the ones of Send
+func (m *Manager) Receive(received lib.NymReceived) {
+	m.messageHandler(received, m.Send)
+}
@@ -0,0 +1,37 @@
+package mocks_test
+
+import (
+	"testing"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/notrustverify/nymsocketmanager/mocks"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestManager(t *testing.T) {
+	manager := mocks.NewManager("client", func(received lib.NymReceived, reply func(lib.NymMessage) error) {
+		require.NoError(t, reply(lib.NewNymReply(received.SenderTag, "answer to "+received.Message)))
+	})
+	require.Equal(t, "client", manager.GetNymClientId())
+
+	require.Error(t, manager.Send(lib.NewNymSend("hello", "peer")))
+
+	stoppedChan, e := manager.Start()
+	require.NoError(t, e)
+	require.True(t, manager.IsRunning())
+
+	require.NoError(t, manager.Send(lib.NewNymSend("hello", "peer")))
+	manager.Receive(lib.NymReceived{Message: "question", SenderTag: "tag"})
+	require.Equal(t, []lib.NymMessage{lib.NewNymSend("hello", "peer"), lib.NewNymReply("tag", "answer to question")}, manager.Sent())
+
+	manager.Stop()
+	require.False(t, manager.IsRunning())
+	<-stoppedChan
+
+	manager.SendErr = xerrors.New("failed")
+	manager.StartErr = xerrors.New("failed")
+	_, e = manager.Start()
+	require.ErrorIs(t, e, manager.StartErr)
+	require.ErrorIs(t, manager.Send(lib.NewNymSend("hello", "peer")), manager.SendErr)
+}
@@ -0,0 +1,69 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// NymAddr is the net.Addr of a Nym client
+type NymAddr string
+
+func (NymAddr) Network() string {
+	return "nym"
+}
+
+func (a NymAddr) String() string {
+	return string(a)
+}
+
+// NymConn is a net.Conn over a Stream, for the libraries expecting a net.Conn to run over the mixnet
+type NymConn struct {
+	*Stream
+}
+
+var _ net.Conn = (*NymConn)(nil)
+
+// Dial opens a NymConn with the NymSocketManager at recipient, which gets it through Accept
+func (n *NymSocketManager) Dial(recipient string) (*NymConn, error) {
+	stream, e := n.OpenStream(recipient)
+	if nil != e {
+		return nil, e
+	}
+	return &NymConn{stream}, nil
+}
+
+// Accept waits for a NymConn opened by a peer with Dial, or a Stream opened with OpenStream, until ctx is done
+func (n *NymSocketManager) Accept(ctx context.Context) (*NymConn, error) {
+	stream, e := n.AcceptStream(ctx)
+	if nil != e {
+		return nil, e
+	}
+	return &NymConn{stream}, nil
+}
+
+// LocalAddr returns the address of the nym-client the manager is connected to
+func (c *NymConn) LocalAddr() net.Addr {
+	return NymAddr(c.manager.GetNymClientId())
+}
+
+func (c *NymConn) RemoteAddr() net.Addr {
+	return NymAddr(c.remote)
+}
+
+func (c *NymConn) SetDeadline(t time.Time) error {
+	c.setReadDeadline(t)
+	c.setWriteDeadline(t)
+	return nil
+}
+
+func (c *NymConn) SetReadDeadline(t time.Time) error {
+	c.setReadDeadline(t)
+	return nil
+}
+
+// SetWriteDeadline makes the next Writes fail after t. As Write only buffers up to a frame, it is not interrupted once started
+func (c *NymConn) SetWriteDeadline(t time.Time) error {
+	c.setWriteDeadline(t)
+	return nil
+}
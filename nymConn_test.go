@@ -0,0 +1,72 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymConn(t *testing.T) {
+	client, server := startLinkedManagers(t)
+
+	var conn net.Conn
+	conn, e := client.Dial(server.GetNymClientId())
+	require.NoError(t, e)
+	defer conn.Close()
+	require.Equal(t, "nym", conn.RemoteAddr().Network())
+	require.Equal(t, server.GetNymClientId(), conn.RemoteAddr().String())
+	require.Equal(t, client.GetNymClientId(), conn.LocalAddr().String())
+
+	_, e = conn.Write([]byte("hello"))
+	require.NoError(t, e)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	accepted, e := server.Accept(ctx)
+	require.NoError(t, e)
+	defer accepted.Close()
+
+	received := make([]byte, 5)
+	_, e = io.ReadFull(accepted, received)
+	require.NoError(t, e)
+	require.Equal(t, "hello", string(received))
+
+	// Nothing more to read before the deadline
+	require.NoError(t, accepted.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, e = accepted.Read(received)
+	require.ErrorIs(t, e, os.ErrDeadlineExceeded)
+
+	// A pending Read is released by a new deadline
+	require.NoError(t, accepted.SetReadDeadline(time.Time{}))
+	readErrChan := make(chan error, 1)
+	go func() {
+		_, e := accepted.Read(received)
+		readErrChan <- e
+	}()
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, accepted.SetDeadline(time.Now()))
+	select {
+	case e := <-readErrChan:
+		require.ErrorIs(t, e, os.ErrDeadlineExceeded)
+	case <-time.After(time.Second):
+		require.Fail(t, "read not released")
+	}
+
+	_, e = accepted.Write([]byte("late"))
+	require.ErrorIs(t, e, os.ErrDeadlineExceeded)
+	require.NoError(t, accepted.SetWriteDeadline(time.Time{}))
+	_, e = accepted.Write([]byte("on time"))
+	require.NoError(t, e)
+}
+
+func TestNymAddr(t *testing.T) {
+	var addr net.Addr = lib.NymAddr("address")
+	require.Equal(t, "nym", addr.Network())
+	require.Equal(t, "address", addr.String())
+}
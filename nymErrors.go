@@ -0,0 +1,57 @@
+package nymsocketmanager
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// Classes of the errors reported by the nym-client, matched with errors.Is against a NymError
+var (
+	// The recipient of a message could not be parsed or is unknown
+	ErrNymInvalidRecipient = xerrors.New("invalid recipient")
+	// The lane of a message has too many packets queued
+	ErrNymLaneFull = xerrors.New("lane full")
+	// The nym-client lost, or could not establish, the connection to its gateway
+	ErrNymGatewayUnreachable = xerrors.New("gateway unreachable")
+	// The nym-client could not understand a request
+	ErrNymMalformedRequest = xerrors.New("malformed request")
+)
+
+// Keywords of the nym-client error messages, for each class, checked in order
+var nymErrorKeywords = []struct {
+	class    error
+	keywords []string
+}{
+	{ErrNymInvalidRecipient, []string{"recipient"}},
+	{ErrNymLaneFull, []string{"lane", "queue full", "too many packets"}},
+	{ErrNymGatewayUnreachable, []string{"gateway"}},
+	{ErrNymMalformedRequest, []string{"malformed", "unknown request", "too short", "empty request", "failed to deserialize"}},
+}
+
+// classifyNymError returns the class of a nym-client error message, nil if it is not known
+func classifyNymError(message string) error {
+	message = strings.ToLower(message)
+	for _, candidate := range nymErrorKeywords {
+		for _, keyword := range candidate.keywords {
+			if strings.Contains(message, keyword) {
+				return candidate.class
+			}
+		}
+	}
+	return nil
+}
+
+// NymRemoteError is an error reported by the nym-client, as recorded in the ErrorHistory.
+// errors.Is matches its class (e.g. ErrNymLaneFull), and errors.As the NymError received
+type NymRemoteError struct {
+	Err NymError
+}
+
+func (r *NymRemoteError) Error() string {
+	return "nym-client reported an error: " + r.Err.Message
+}
+
+func (r *NymRemoteError) Unwrap() error {
+	return r.Err
+}
@@ -1,6 +1,10 @@
 package nymsocketmanager
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
 
 type NymMessageCommon struct {
 	Type string `json:"type"`
@@ -19,6 +23,15 @@ type NymMessage interface {
 
 const NymErrorType = "error"
 
+func NewNymError(message string) NymMessage {
+	return NymError{
+		NymMessageCommon{
+			Type: NymErrorType,
+		},
+		message,
+	}
+}
+
 type NymError struct {
 	NymMessageCommon
 
@@ -26,12 +39,7 @@ type NymError struct {
 }
 
 func (NymError) NewEmpty() NymMessage {
-	return NymError{
-		NymMessageCommon{
-			Type: "error",
-		},
-		"",
-	}
+	return NewNymError("")
 }
 
 func (NymError) Name() string {
@@ -43,6 +51,16 @@ func (n NymError) String() string {
 	return s
 }
 
+// Error makes NymError usable as an error
+func (n NymError) Error() string {
+	return n.String()
+}
+
+// Unwrap returns the class of the error (e.g. ErrNymLaneFull), or nil if it is not known, so that errors.Is can be used
+func (n NymError) Unwrap() error {
+	return classifyNymError(n.Message)
+}
+
 /*********************************************
  * NymSelfAddressRequest
  *********************************************/
@@ -161,7 +179,7 @@ const NymSendAnonymousType = "sendAnonymous"
 func NewNymSendAnonymous(message string, recipient string, nbReplySurbs uint) NymMessage {
 	return NymSendAnonymous{
 		NymMessageCommon{
-			Type: NymSendType,
+			Type: NymSendAnonymousType,
 		},
 		message, recipient, nbReplySurbs,
 	}
@@ -201,10 +219,11 @@ const NymReceivedType = "received"
 
 func NewNymReceived(message string, senderTag string) NymMessage {
 	return NymReceived{
-		NymMessageCommon{
+		NymMessageCommon: NymMessageCommon{
 			Type: NymReceivedType,
 		},
-		message, senderTag,
+		Message:   message,
+		SenderTag: senderTag,
 	}
 }
 
@@ -213,15 +232,36 @@ type NymReceived struct {
 
 	Message   string `json:"message"`
 	SenderTag string `json:"senderTag"`
+
+	// Metadata set by the NymSocketManager before calling the messageHandler
+	// When the message (or its last chunk) was dispatched
+	ReceivedAt time.Time `json:"-"`
+	// Whether it was received with the binary protocol
+	Binary bool `json:"-"`
+	// Number of chunks it was reassembled from, 1 if it was sent in one piece
+	Chunks int `json:"-"`
+	// Set on the requests sent with SendRequest, to be answered with Respond
+	RequestID     string `json:"-"`
+	ReturnAddress string `json:"-"`
+	// Attached to the logs and events of the message: the identifier of the request or of the delivery confirmation if any,
+	// shared with the sender, or one generated by the manager
+	MessageID string `json:"-"`
+	// Set if the payload was wrapped in an Envelope, Message being then its body
+	Envelope *Envelope `json:"-"`
+
+	// Set while it is processed if tracing is enabled, see WithTracing
+	trace *receivedTrace
+	// Identifier of the delivery to acknowledge once handled, see WithConfirmedDelivery
+	acknowledgement string
 }
 
 func (NymReceived) NewEmpty() NymMessage {
-	return NymReceived{
-		NymMessageCommon{
-			Type: NymReceivedType,
-		},
-		"", "",
-	}
+	return NewNymReceived("", "")
+}
+
+// IsReplyable tells whether the message was sent anonymously with reply SURBs, and can thus be answered using its SenderTag
+func (n NymReceived) IsReplyable() bool {
+	return len(n.SenderTag) != 0
 }
 
 func (NymReceived) Name() string {
@@ -272,3 +312,129 @@ func (n NymReply) String() string {
 	s := fmt.Sprintf("NymReply for %s: \"%s\"", n.SenderTag, n.Message)
 	return s
 }
+
+/*********************************************
+ * NymLaneQueueLengthRequest
+ *********************************************/
+
+const NymLaneQueueLengthRequestType = "getLaneQueueLength"
+
+func NewNymLaneQueueLengthRequest(connectionID uint64) NymMessage {
+	return NymLaneQueueLengthRequest{
+		NymMessageCommon{
+			Type: NymLaneQueueLengthRequestType,
+		},
+		connectionID,
+	}
+}
+
+type NymLaneQueueLengthRequest struct {
+	NymMessageCommon
+
+	ConnectionID uint64 `json:"connectionId"`
+}
+
+func (NymLaneQueueLengthRequest) NewEmpty() NymMessage {
+	return NewNymLaneQueueLengthRequest(0)
+}
+
+func (NymLaneQueueLengthRequest) Name() string {
+	return "NymLaneQueueLengthRequest"
+}
+
+func (n NymLaneQueueLengthRequest) String() string {
+	s := fmt.Sprintf("NymLaneQueueLengthRequest for connection %d", n.ConnectionID)
+	return s
+}
+
+/*********************************************
+ * NymLaneQueueLength
+ *********************************************/
+
+const NymLaneQueueLengthType = "laneQueueLength"
+
+func NewNymLaneQueueLength(lane uint64, queueLength uint64) NymMessage {
+	return NymLaneQueueLength{
+		NymMessageCommon{
+			Type: NymLaneQueueLengthType,
+		},
+		lane, queueLength,
+	}
+}
+
+type NymLaneQueueLength struct {
+	NymMessageCommon
+
+	Lane        uint64 `json:"lane"`
+	QueueLength uint64 `json:"queueLength"`
+}
+
+func (NymLaneQueueLength) NewEmpty() NymMessage {
+	return NewNymLaneQueueLength(0, 0)
+}
+
+func (NymLaneQueueLength) Name() string {
+	return "NymLaneQueueLength"
+}
+
+func (n NymLaneQueueLength) String() string {
+	s := fmt.Sprintf("NymLaneQueueLength: %d packets queued in lane %d", n.QueueLength, n.Lane)
+	return s
+}
+
+/*********************************************
+ * NymControlMessage
+ *********************************************/
+
+// NymControlMessage holds a message of the nym-client whose type is not parsed by the library, e.g. a status notification
+type NymControlMessage struct {
+	NymMessageCommon
+
+	// The message as sent by the nym-client
+	Raw json.RawMessage `json:"-"`
+}
+
+func (NymControlMessage) NewEmpty() NymMessage {
+	return NymControlMessage{}
+}
+
+// Fields unmarshals the raw message
+func (n NymControlMessage) Fields() (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	e := json.Unmarshal(n.Raw, &fields)
+	return fields, e
+}
+
+func (NymControlMessage) Name() string {
+	return "NymControlMessage"
+}
+
+func (n NymControlMessage) String() string {
+	s := fmt.Sprintf("NymControlMessage of type %v: %s", n.Type, n.Raw)
+	return s
+}
+
+/*********************************************
+ * rawNymMessage
+ *********************************************/
+
+// rawNymMessage is sent as is, see SendRaw
+type rawNymMessage struct {
+	raw json.RawMessage
+}
+
+func (r rawNymMessage) MarshalJSON() ([]byte, error) {
+	return r.raw, nil
+}
+
+func (rawNymMessage) NewEmpty() NymMessage {
+	return rawNymMessage{}
+}
+
+func (rawNymMessage) Name() string {
+	return "rawNymMessage"
+}
+
+func (r rawNymMessage) String() string {
+	return string(r.raw)
+}
@@ -1,6 +1,8 @@
 package nymsocketmanager_test
 
 import (
+	"encoding/json"
+	"errors"
 	"math/rand"
 	"testing"
 	"time"
@@ -55,6 +57,21 @@ func TestNymSelfAddressReplyNewEmtpy(t *testing.T) {
 	require.Equal(t, n.(lib.NymSelfAddressReply).Type, lib.NymSelfAddressType)
 }
 
+/*********************************************
+ * NymSendAnonymous
+ *********************************************/
+
+func TestNewNymSendAnonymousCorrectlySetsValues(t *testing.T) {
+	message := RandStringBytes(5)
+	recipient := RandStringBytes(5)
+
+	n := lib.NewNymSendAnonymous(message, recipient, 3)
+	require.Equal(t, n.(lib.NymSendAnonymous).Type, lib.NymSendAnonymousType)
+	require.Equal(t, n.(lib.NymSendAnonymous).Message, message)
+	require.Equal(t, n.(lib.NymSendAnonymous).Recipient, recipient)
+	require.Equal(t, n.(lib.NymSendAnonymous).ReplySurbs, uint(3))
+}
+
 /*********************************************
  * NymMessage
  *********************************************/
@@ -83,3 +100,70 @@ func TestNewNymReplyCorrectlySetsValues(t *testing.T) {
 	require.Equal(t, n.(lib.NymReply).SenderTag, senderTag)
 	require.Equal(t, n.(lib.NymReply).Message, message)
 }
+
+/*********************************************
+ * JSON round-trips
+ *********************************************/
+
+// requireJSONRoundTrip checks that msg is marshalled as expectedJSON, and unmarshalled back unchanged
+func requireJSONRoundTrip[T lib.NymMessage](t *testing.T, msg T, expectedJSON string) {
+	msgBytes, e := json.Marshal(msg)
+	require.NoError(t, e)
+	require.JSONEq(t, expectedJSON, string(msgBytes))
+
+	var decoded T
+	require.NoError(t, json.Unmarshal(msgBytes, &decoded))
+	require.Equal(t, msg, decoded)
+}
+
+func TestNymMessagesJSONRoundTrip(t *testing.T) {
+	requireJSONRoundTrip(t, lib.NewNymSend("hello", "recipient").(lib.NymSend),
+		`{"type":"send","message":"hello","recipient":"recipient"}`)
+	requireJSONRoundTrip(t, lib.NewNymSendAnonymous("hello", "recipient", 5).(lib.NymSendAnonymous),
+		`{"type":"sendAnonymous","message":"hello","recipient":"recipient","replySurbs":5}`)
+	requireJSONRoundTrip(t, lib.NewNymReply("senderTag", "hello").(lib.NymReply),
+		`{"type":"reply","message":"hello","senderTag":"senderTag"}`)
+	requireJSONRoundTrip(t, lib.NewSelfAddressRequest().(lib.NymSelfAddressRequest),
+		`{"type":"selfAddress"}`)
+	requireJSONRoundTrip(t, lib.NewSelfAddressReply("address").(lib.NymSelfAddressReply),
+		`{"type":"selfAddress","address":"address"}`)
+	requireJSONRoundTrip(t, lib.NewNymError("oops").(lib.NymError),
+		`{"type":"error","message":"oops"}`)
+	requireJSONRoundTrip(t, lib.NewNymReceived("hello", "senderTag").(lib.NymReceived),
+		`{"type":"received","message":"hello","senderTag":"senderTag"}`)
+	requireJSONRoundTrip(t, lib.NewNymLaneQueueLengthRequest(3).(lib.NymLaneQueueLengthRequest),
+		`{"type":"getLaneQueueLength","connectionId":3}`)
+	requireJSONRoundTrip(t, lib.NewNymLaneQueueLength(3, 12).(lib.NymLaneQueueLength),
+		`{"type":"laneQueueLength","lane":3,"queueLength":12}`)
+}
+
+func TestNymMessagesNewEmptyKeepsType(t *testing.T) {
+	for _, msg := range []lib.NymMessage{
+		lib.NewNymSend("hello", "recipient"),
+		lib.NewNymSendAnonymous("hello", "recipient", 5),
+		lib.NewNymReply("senderTag", "hello"),
+		lib.NewSelfAddressRequest(),
+		lib.NewSelfAddressReply("address"),
+		lib.NewNymError("oops"),
+		lib.NewNymReceived("hello", "senderTag"),
+		lib.NewNymLaneQueueLengthRequest(3),
+		lib.NewNymLaneQueueLength(3, 12),
+	} {
+		require.IsType(t, msg, msg.NewEmpty(), msg.Name())
+	}
+}
+
+func TestNymErrorClassification(t *testing.T) {
+	for message, class := range map[string]error{
+		"failed to parse recipient: malformed key": lib.ErrNymInvalidRecipient,
+		"Lane 3 is full":            lib.ErrNymLaneFull,
+		"gateway connection closed": lib.ErrNymGatewayUnreachable,
+		"malformed request":         lib.ErrNymMalformedRequest,
+	} {
+		var err error = lib.NewNymError(message).(lib.NymError)
+		require.ErrorIs(t, err, class, message)
+	}
+
+	var err error = lib.NewNymError("something unexpected").(lib.NymError)
+	require.Nil(t, errors.Unwrap(err))
+}
@@ -1,66 +1,279 @@
 package nymsocketmanager
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"net"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/notrustverify/nymsocketmanager/address"
+	"github.com/notrustverify/nymsocketmanager/codec"
 	"github.com/rs/zerolog"
 	"golang.org/x/xerrors"
 )
 
+const (
+	// Maximum time to wait for the nym-client to answer a selfAddress request, unless set with WithSelfAddressTimeout
+	defaultSelfAddressTimeout = 5 * time.Second
+	// Maximum time to wait for the socketListener to confirm the closure when no deadline is provided, unless set with WithCloseBehavior
+	defaultCloseTimeout = 5 * time.Second
+	// Maximum time to wait for the pending Sends to complete on shutdown, unless set with WithDrainTimeout
+	defaultDrainTimeout = 5 * time.Second
+	// Maximum time to wait for the messageHandlers in progress to return on shutdown, unless set with WithHandlerDrainTimeout
+	defaultHandlerDrainTimeout = 5 * time.Second
+	// Maximum number of messages received while paused kept for Resume, unless set with WithPauseBuffer
+	defaultPauseBufferCapacity = 1024
+)
+
 /*
  * This class is managing a listening socket which will hang on listening packets as well as manage a routine to send receiving packets to the Nym mixnet
  * The goal is to be more performant in case of high demand. Also, packets to the mixnet can come from both directions.
  */
 
-func NewNymSocketManager(connectionURI string, messageHandler func(NymReceived, func(NymMessage) error), parentLogger *zerolog.Logger) (*NymSocketManager, error) {
-	if len(connectionURI) == 0 {
-		err := xerrors.Errorf("connection URI cannot be empty")
+func NewNymSocketManager(connectionURI string, messageHandler func(NymReceived, func(NymMessage) error), parentLogger Logger, options ...Option) (*NymSocketManager, error) {
+	if nil == messageHandler {
+		err := xerrors.Errorf("processing function needs to be defined")
+		return nil, err
+	}
+
+	return newNymSocketManager(connectionURI, func(_ context.Context, received NymReceived, reply func(NymMessage) error) error {
+		messageHandler(received, reply)
+		return nil
+	}, parentLogger, options...)
+}
+
+// NewNymSocketManagerWithErrors is NewNymSocketManager with a messageHandler returning an error.
+// The errors of the messageHandler and of its reply function are passed to the hooks registered with OnHandlerError
+func NewNymSocketManagerWithErrors(connectionURI string, messageHandler func(NymReceived, func(NymMessage) error) error, parentLogger Logger, options ...Option) (*NymSocketManager, error) {
+	if nil == messageHandler {
+		err := xerrors.Errorf("processing function needs to be defined")
 		return nil, err
 	}
 
+	return newNymSocketManager(connectionURI, func(_ context.Context, received NymReceived, reply func(NymMessage) error) error {
+		return messageHandler(received, reply)
+	}, parentLogger, options...)
+}
+
+// NewNymSocketManagerWithContext is NewNymSocketManagerWithErrors with a messageHandler receiving a context,
+// cancelled when the manager stops so that the long operations of the messageHandler can be aborted
+func NewNymSocketManagerWithContext(connectionURI string, messageHandler func(context.Context, NymReceived, func(NymMessage) error) error, parentLogger Logger, options ...Option) (*NymSocketManager, error) {
 	if nil == messageHandler {
 		err := xerrors.Errorf("processing function needs to be defined")
 		return nil, err
 	}
 
+	return newNymSocketManager(connectionURI, messageHandler, parentLogger, options...)
+}
+
+func newNymSocketManager(connectionURI string, messageHandler contextHandler, parentLogger Logger, options ...Option) (*NymSocketManager, error) {
+	if len(connectionURI) == 0 {
+		err := xerrors.Errorf("connection URI cannot be empty")
+		return nil, err
+	}
+
 	if nil == parentLogger {
 		err := xerrors.Errorf("logger needs to be defined")
 		return nil, err
 	}
 
-	localLogger := parentLogger.With().Str(ComponentField, "NymSocketManager").Logger()
+	localLogger := newComponentLogger(parentLogger, "NymSocketManager")
+
+	// Copied so that options can tune it without impacting other users of gorilla's default
+	dialer := *websocket.DefaultDialer
+
+	n := &NymSocketManager{
+		connectionURIs:      []string{connectionURI},
+		dialer:              &dialer,
+		selfAddressTimeout:  defaultSelfAddressTimeout,
+		drainTimeout:        defaultDrainTimeout,
+		handlerDrainTimeout: defaultHandlerDrainTimeout,
+		pauseGate:           pauseGate{capacity: defaultPauseBufferCapacity},
+		codec:               codec.JSON,
+		logger:              localLogger,
+		messageLogger:       localLogger,
+	}
+
+	n.messageHandler.Store(&messageHandler)
+	n.errors.records = make([]ErrorRecord, 0, DefaultErrorHistorySize)
 
-	return &NymSocketManager{
-		connectionURI:  connectionURI,
-		messageHandler: messageHandler,
-		logger:         &localLogger,
-	}, nil
+	for _, option := range options {
+		e := option(n)
+		if nil != e {
+			err := xerrors.Errorf("invalid option: %v", e)
+			return nil, err
+		}
+	}
+
+	if OverflowBlock == n.pauseGate.policy && nil == n.handlerPool {
+		err := xerrors.Errorf("invalid option: the pause buffer can block only with WithHandlerPool, for the reads to be held back")
+		return nil, err
+	}
+
+	// Last, as it cannot be undone if the creation fails
+	if len(n.expvarName) != 0 {
+		e := n.publishExpvar(n.expvarName)
+		if nil != e {
+			err := xerrors.Errorf("invalid option: %v", e)
+			return nil, err
+		}
+	}
+
+	return n, nil
 }
 
 type NymSocketManager struct {
 	sync.Mutex
 
 	clientID string
+	state    connectionStateMachine
+
+	// All the nym-clients we can connect to, by order of preference
+	connectionURIs []string
+	// The one currently in use
+	connectionURIIndex int
+	dialer             *websocket.Dialer
+	// Attempts in progress to replace a lost connection
+	reconnection reconnection
+	// Retries the passes over the connection URIs when none could replace a lost connection, nil to give up after one pass
+	reconnectPolicy *RetryPolicy
+	// Sent with the websocket upgrade request, e.g. to authenticate against a gateway in front of the nym-client
+	requestHeader http.Header
+	// Level of the permessage-deflate compression, if enabled on the dialer
+	compressionLevel int
+	// Related to the chunking of large messages, disabled while chunkSize is 0
+	chunkSize int
+	chunks    chunkReassembler
+
+	coverTraffic         *CoverTraffic
+	coverTrafficStopChan chan struct{}
+	probe                *latencyProbe
+	quality              *qualityEstimator
+	// nil if no audit log is configured, see WithAuditLog
+	audit *auditLog
+
+	surbManagement *SurbManagement
+	surbs          surbTracker
+
+	// Maximum number of messages per second sent by SendToMany, 0 meaning no limit
+	broadcastRate float64
+
+	// Related to the validation of the incoming messages
+	schemaValidation bool
+	schemaPolicy     SchemaPolicy
+
+	// Number of reply SURBs attached to the anonymous messages not specifying it
+	defaultReplySurbs uint
+	// How messages are written on the connection, with the JSON or the binary protocol of the nym-client
+	wireEncoding WireEncoding
+	// Encodes the payloads of SendValue and DecodeValue
+	codec codec.Codec
 
-	connectionURI           string
 	connection              *websocket.Conn
 	selfInstanceStoppedChan chan struct{}
+	// Set between a successful start and the shutdown, readable without the lock
+	started atomic.Bool
+	// Why the manager stopped on its own, nil if it was stopped on request
+	stopReason error
+	errors     errorHistory
+
+	closeBehavior CloseBehavior
 
 	// Related to listening
-	socketListener           *SocketListener
-	messageHandler           func(NymReceived, func(NymMessage) error)
+	socketListener *SocketListener
+	messageHandler atomic.Pointer[contextHandler]
+	handlerContext handlerContext
+	pauseGate      pauseGate
+	middlewares    middlewareChain
+	handlerPool    *handlerPool
+	profiling      *Profiling
+	slowHandler    *SlowHandler
+	// Spans of the sends, dispatches and handlings, nil if not traced
+	tracer           Tracer
+	tracePropagation bool
+	// Keeps track of the messageHandlers in progress, so that Stop waits for them
+	handlerGate              sendGate
+	handlerDrainTimeout      time.Duration
 	closedSocketListenerChan chan struct{}
 
 	// Related to sender
-	senderMutex sync.Mutex
-
-	selfAddressReceivedChan chan struct{}
-
-	logger *zerolog.Logger
+	senderMutex      sync.Mutex
+	sendInterceptors sendInterceptors
+	sendQueue        *priorityQueue
+	asyncSender      *asyncSender
+	rateLimiter      rateLimiter
+	throttle         *congestionThrottle
+	coalescer        *writeCoalescer
+	sendGate         sendGate
+	drainTimeout     time.Duration
+	replayBuffer     *replayBuffer
+	// Deadline of the writes not setting their own with WithWriteTimeout, 0 meaning none
+	writeTimeout time.Duration
+
+	pings        pingTracker
+	laneQueues   laneQueueTracker
+	requests     requestTracker
+	replyWaiters replyWaiters
+	streams      streamTracker
+	deliveries   deliveryTracker
+	pool         connectionPool
+	shards       recipientShards
+
+	// Related to the read-stall watchdog
+	readStallThreshold time.Duration
+	onReadStall        func(idle time.Duration)
+	// Related to the read timeout, disabled while readIdleTimeout is 0
+	readIdleTimeout  time.Duration
+	readProbeTimeout time.Duration
+
+	// Related to the maximum size of the received messages, not enforced while maxMessageSize is 0
+	maxMessageSize int64
+	oversizePolicy OversizePolicy
+	onOversized    func(size int64)
+	hooks          lifecycleHooks
+	taps           frameTaps
+	events         eventStream
+	dropHandler    DropHandler
+
+	// Related to lazy connection
+	lazyConnection bool
+	idleTimeout    time.Duration
+	idleTimer      *time.Timer
+	lastLazySendAt time.Time
+
+	// Related to the wait for the nym-client to be up
+	readyMaxWait       time.Duration
+	startupRetryPolicy *RetryPolicy
+
+	// Related to the collection of the clientID
+	selfAddressReceivedChan chan error
+	selfAddressTimeout      time.Duration
+	selfAddressRetries      uint
+
+	// Related to the encoding of the nym-client
+	clientEncoding         atomic.Int32
+	requiredClientEncoding ClientEncoding
+
+	stats        statsCollector
+	filters      messageFilters
+	deduplicator *deduplicator
+	subscribers  receivedSubscribers
+
+	logger *componentLogger
+	// logger, sampled for the Debug and Trace logs written for each message, see WithLogSampling
+	messageLogger *componentLogger
+	// nil unless set with WithLogSampling, passed on to the socketListeners
+	logSampler zerolog.Sampler
+	// Keep the payloads out of the logs and events, see WithPayloadRedaction
+	redactPayloads bool
+	// Published once the manager is created, see WithExpvar
+	expvarName string
 }
 
 func (n *NymSocketManager) IsRunning() bool {
@@ -69,124 +282,535 @@ func (n *NymSocketManager) IsRunning() bool {
 	return nil != n.connection
 }
 
+// GetConnectionURI returns the URI of the nym-client currently (or last) in use
+func (n *NymSocketManager) GetConnectionURI() string {
+	n.Lock()
+	defer n.Unlock()
+	return n.connectionURIs[n.connectionURIIndex]
+}
+
+// Start opens the connection to the nym-client and collects the clientID.
+// It is equivalent to StartContext(context.Background())
 func (n *NymSocketManager) Start() (chan struct{}, error) {
+	return n.StartContext(context.Background())
+}
+
+// StartContext opens the connection to the nym-client and collects the clientID.
+// The connection URIs are tried in order until one of them succeeds.
+// The dial and the wait for the selfAddress reply are aborted when ctx is done.
+// Each selfAddress request is bounded by the selfAddress timeout, see WithSelfAddressTimeout and WithSelfAddressRetries
+func (n *NymSocketManager) StartContext(ctx context.Context) (chan struct{}, error) {
 	n.Lock()
 	defer n.Unlock()
 
 	n.logger.Debug().Msg("starting NymSocketManager")
 
 	// Do not start if already started
-	if nil != n.connection {
-		n.logger.Warn().Msgf("connection to websocket %s already established. Resuming...", n.connectionURI)
+	if nil != n.selfInstanceStoppedChan {
+		n.logger.Warn().Msgf("NymSocketManager already started with websocket %s. Resuming...", n.connectionURIs[n.connectionURIIndex])
 		return nil, nil
 	}
 
+	channel, e := n.start(ctx)
+	if nil != e {
+		n.errors.record(ErrorCategoryStart, e)
+		n.hooks.failed(e)
+	}
+	return channel, e
+}
+
+// Restart closes the current connection, if any, and opens a new one, keeping the messageHandler.
+// It is equivalent to RestartContext(context.Background())
+func (n *NymSocketManager) Restart() (chan struct{}, error) {
+	return n.RestartContext(context.Background())
+}
+
+// RestartContext closes the current connection, if any, and opens a new one, keeping the messageHandler.
+// The channel returned by the previous Start is closed, and a fresh one is returned.
+// ctx bounds both the shutdown and the start, as in StopContext and StartContext
+func (n *NymSocketManager) RestartContext(ctx context.Context) (chan struct{}, error) {
+	n.Lock()
+	defer n.Unlock()
+
+	n.logger.Debug().Msg("restarting NymSocketManager")
+
+	if nil != n.selfInstanceStoppedChan {
+		n.selfDestruct(ctx)
+	}
+
+	channel, e := n.start(ctx)
+	if nil != e {
+		n.errors.record(ErrorCategoryStart, e)
+		n.hooks.failed(e)
+	}
+	return channel, e
+}
+
+// start connects to the nym-clients and creates the channel indicating when the manager stops
+// called from methods that already acquired the lock
+func (n *NymSocketManager) start(ctx context.Context) (chan struct{}, error) {
+	n.sendGate.open()
+	n.handlerGate.open()
+	// Started first, as messages can be received during the handshake
+	n.handlerContext.start()
+	n.startHandlerPool()
+	n.startAsyncSender()
+
+	// The connection will be opened by the first Send
+	if !n.lazyConnection {
+		e := n.connectWithRetries(ctx)
+		if nil != e {
+			n.stopAsyncSender()
+			n.stopHandlerPool()
+			n.handlerContext.stop()
+			return nil, e
+		}
+	}
+
+	n.selfInstanceStoppedChan = make(chan struct{}, 1)
+	n.started.Store(true)
+	n.stats.setStarted(time.Now())
+	n.stopReason = nil
+	n.startCoverTraffic()
+	n.startLatencyProbe()
+	n.startQualityPings()
+
+	n.logger.Debug().Msg("started NymSocketManager")
+
+	return n.selfInstanceStoppedChan, nil
+}
+
+// connect tries the connection URIs in order, starting at firstURIIndex and wrapping around,
+// until a connection is established and the clientID collected
+// called from methods that already acquired the lock
+func (n *NymSocketManager) connect(ctx context.Context, firstURIIndex int) error {
+	var err error
+	for i := 0; i < len(n.connectionURIs); i++ {
+		index := (firstURIIndex + i) % len(n.connectionURIs)
+
+		err = n.connectTo(ctx, index)
+		if nil == err {
+			return nil
+		}
+		n.reconnectFailed(err)
+
+		// No need to try the other ones if the caller gave up
+		if nil != ctx.Err() {
+			break
+		}
+	}
+
+	if len(n.connectionURIs) > 1 {
+		err = xerrors.Errorf("failed to connect to any of the %d nym-clients, last error: %w", len(n.connectionURIs), err)
+		n.logger.Warn().Msg(err.Error())
+	}
+	return err
+}
+
+// dial opens a websocket connection to connectionURI, compressed if negotiated with the nym-client
+func (n *NymSocketManager) dial(ctx context.Context, connectionURI string) (*websocket.Conn, error) {
+	connection, _, e := n.dialer.DialContext(ctx, connectionURI, n.requestHeader)
+	if nil != e {
+		return nil, e
+	}
+	if n.dialer.EnableCompression {
+		// The level was validated by WithCompression
+		_ = connection.SetCompressionLevel(n.compressionLevel)
+	}
+	return connection, nil
+}
+
+// connectTo opens the connection to the nym-client at connectionURIs[index], starts the socketListener and collects the clientID
+// On failure, everything opened so far is closed
+// called from methods that already acquired the lock
+func (n *NymSocketManager) connectTo(ctx context.Context, index int) error {
+	connectionURI := n.connectionURIs[index]
+	n.connectionURIIndex = index
+
+	n.reconnectAttempted(connectionURI)
+	n.setState(StateConnecting)
+
 	// Open WS connection
-	var e error
-	n.connection, _, e = websocket.DefaultDialer.Dial(n.connectionURI, nil)
+	connection, e := n.dial(ctx, connectionURI)
 	if nil != e {
-		err := xerrors.Errorf("failed to open connection to %v (%v). Is the websocket up and running?", n.connectionURI, e)
+		err := xerrors.Errorf("failed to open connection to %v (%v). Is the websocket up and running?", redactURI(connectionURI), e)
 		n.logger.Warn().Msg(err.Error())
-		return nil, err
+		n.setState(StateDisconnected)
+		return err
 	}
+	connection.SetPongHandler(n.pings.pongReceived)
+	n.setConnection(connection)
+	n.resetQuality()
+	n.hooks.connected(connectionURI)
+	n.events.connected(connectionURI)
 
 	// After which we start a listener for the packets
-	n.socketListener, n.closedSocketListenerChan, e = NewSocketListener(n.connection, n.messageDispatcher, n.Stop, n.logger)
+	var listener *SocketListener
+	listener, n.closedSocketListenerChan, e = NewSocketListener(connection, n.messageDispatcher, func() { n.connectionLost(listener) }, n.logger.output)
 	if nil != e {
 		err := xerrors.Errorf("failed to initiate the socketListener: %v", e)
 		n.logger.Warn().Msg(err.Error())
 		// Cancel progress so far
-		n.selfDestruct()
-		return nil, err
+		n.disconnect(ctx, err)
+		n.setState(StateDisconnected)
+		return err
+	}
+	if n.readStallThreshold > 0 {
+		listener.SetReadStallWatchdog(n.readStallThreshold, func(idle time.Duration) { n.readStalled(listener, idle) })
 	}
+	listener.SetMaxMessageSize(n.maxMessageSize, n.oversizePolicy, n.oversized)
+	listener.SetReadTimeout(n.readIdleTimeout, n.readProbeTimeout)
+	listener.ignoreAbnormalClosure = n.closeBehavior.IgnoreAbnormalClosure
+	listener.dispatchInline = nil != n.handlerPool
+	listener.SetLogSampler(n.logSampler)
+	listener.SetPayloadRedaction(n.redactPayloads)
+	n.socketListener = listener
 	go n.socketListener.Listen()
 
+	n.setState(StateHandshaking)
+
 	// To ensure everything works as expected, collect clientID
+	previousClientID := n.clientID
+
+	handshakeStartedAt := time.Now()
+	e = n.requestSelfAddress(ctx, connectionURI)
+	if nil != e {
+		// Cancel progress so far. The caller's ctx may be done already, so do not bind the cleanup to it
+		n.disconnect(context.Background(), e)
+		n.setState(StateDisconnected)
+		return e
+	}
+
+	// Each nym-client has its own address, peers need to be made aware of the new one
+	if len(previousClientID) != 0 && previousClientID != n.clientID {
+		n.logger.Warn().Msgf("clientID changed from %v to %v", previousClientID, n.clientID)
+	}
+
+	n.logger.Debug().Msgf("connected to %v", connectionURI)
+	if n.pool.size > 1 {
+		n.openPool(ctx, connectionURI)
+	}
+	n.openShards(ctx)
+
+	handshakeDuration := time.Since(handshakeStartedAt)
+	n.stats.connected(connectionURI, n.clientID, handshakeDuration)
+	n.setState(StateRunning)
+	n.hooks.handshakeDone(n.clientID)
+	n.events.handshakeDone(connectionURI, n.clientID, handshakeDuration)
+
+	n.replay()
 
+	return nil
+}
+
+// requestSelfAddress asks the nym-client for its address until it answers, at most selfAddressRetries+1 times.
+// Each attempt waits for selfAddressTimeout at most, the whole exchange is aborted when ctx is done
+// called from methods that already acquired the lock
+func (n *NymSocketManager) requestSelfAddress(ctx context.Context, connectionURI string) error {
 	// Create chan for messageDispatcher to indicate when response received
-	n.selfAddressReceivedChan = make(chan struct{})
+	n.selfAddressReceivedChan = make(chan error, 1)
 
-	e = n.Send(NewSelfAddressRequest())
-	if nil != e {
-		err := xerrors.Errorf("failed to send SelfAddressRequest: %v", e)
-		n.logger.Warn().Msg(err.Error())
+	for attempt := uint(1); ; attempt++ {
+		e := n.send(NewSelfAddressRequest(), &sendOptions{priority: PriorityControl})
+		if nil != e {
+			err := xerrors.Errorf("failed to send SelfAddressRequest: %v", e)
+			n.logger.Warn().Msg(err.Error())
+			return err
+		}
 
-		// Cancel progress so far
-		n.selfDestruct()
-		return nil, err
+		attemptCtx, cancel := context.WithTimeout(ctx, n.selfAddressTimeout)
+		select {
+		case e = <-n.selfAddressReceivedChan:
+			cancel()
+			if nil != e {
+				err := xerrors.Errorf("failed to handshake with %v: %w", redactURI(connectionURI), e)
+				n.logger.Warn().Msg(err.Error())
+				return err
+			}
+			n.logger.Debug().Msgf("successfully collected clientID with socketListener")
+			return nil
+
+		case <-attemptCtx.Done():
+			cancel()
+		}
+
+		// Fail if out of retries or if the caller gave up
+		if attempt > n.selfAddressRetries || nil != ctx.Err() {
+			// Unless the caller gave up, the nym-client is to blame
+			var cause error = &timeoutError{ErrHandshakeTimeout, attemptCtx.Err()}
+			if nil != ctx.Err() {
+				cause = ctx.Err()
+			}
+			err := xerrors.Errorf("failed to collect clientID from %v after %d attempt(s): %w", redactURI(connectionURI), attempt, cause)
+			n.logger.Warn().Msg(err.Error())
+			return err
+		}
+
+		n.logger.Debug().Msgf("no reply to SelfAddressRequest from %v within %v, retrying (%d/%d)", connectionURI, n.selfAddressTimeout, attempt, n.selfAddressRetries)
 	}
+}
 
-	timeout := time.After(5 * time.Second)
-	select {
-	case <-n.selfAddressReceivedChan:
-		n.logger.Debug().Msgf("successfully collected clientID with socketListener")
-		n.selfAddressReceivedChan = nil
+// connectionLost is called by a socketListener once its connection is closed.
+// If several connection URIs are configured, it fails over to the next reachable one, otherwise the manager is stopped,
+// unless the connection was closed by the read timeout or a reconnect policy is set
+func (n *NymSocketManager) connectionLost(listener *SocketListener) {
+	n.Lock()
+	defer n.Unlock()
 
-	// Fail
-	case <-timeout:
-		err := xerrors.Errorf("failed to collect clientID from %v", n.connectionURI)
-		n.logger.Warn().Msg(err.Error())
-		// Cancel progress so far
-		n.selfDestruct()
-		return nil, err
+	// The closure was requested by the manager itself, which already took care of the cleaning
+	if listener != n.socketListener {
+		return
 	}
 
-	n.selfInstanceStoppedChan = make(chan struct{}, 1)
+	reason := xerrors.Errorf("lost connection to %v: %v: %w", redactURI(n.connectionURIs[n.connectionURIIndex]), listener.closeReason, ErrConnectionClosed)
+	// A dead connection is replaced, even when there is no other nym-client to fail over to
+	n.recoverConnection(reason, len(n.connectionURIs) > 1 || nil != n.reconnectPolicy || xerrors.Is(listener.closeReason, ErrReadTimeout))
+}
 
-	n.logger.Debug().Msg("started NymSocketManager")
+// readStalled is called by a socketListener when nothing was read for the read-stall threshold.
+// Unless a callback is registered to handle it, the connection is replaced by a new one
+func (n *NymSocketManager) readStalled(listener *SocketListener, idle time.Duration) {
+	if nil != n.onReadStall {
+		n.onReadStall(idle)
+		return
+	}
 
-	return n.selfInstanceStoppedChan, nil
+	n.Lock()
+	defer n.Unlock()
+
+	if listener != n.socketListener {
+		return
+	}
+
+	reason := xerrors.Errorf("nothing read from %v for %v", redactURI(n.connectionURIs[n.connectionURIIndex]), idle)
+	n.recoverConnection(reason, true)
+}
+
+// recoverConnection closes the current connection because of reason and, if reconnect is set,
+// opens a new one trying the connection URIs from the next one. The manager stops if no connection could be opened
+// called from methods that already acquired the lock
+func (n *NymSocketManager) recoverConnection(reason error, reconnect bool) {
+	n.logger.Warn().Msg(reason.Error())
+	n.errors.record(ErrorCategoryConnection, reason)
+
+	// The connection is deemed broken, no need to wait for the close handshake
+	brokenCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n.disconnect(brokenCtx, reason)
+	n.setState(StateDisconnected)
+
+	// The next Send will reconnect
+	if n.lazyConnection {
+		return
+	}
+
+	if reconnect {
+		n.logger.Info().Msg("reconnecting, starting with the next nym-client")
+		stopped, e := n.reconnect(reason)
+		if stopped || nil == e {
+			return
+		}
+		reason = e
+		n.errors.record(ErrorCategoryConnection, reason)
+	}
+
+	n.hooks.failed(reason)
+	n.selfDestruct(context.Background())
+	n.stopReason = reason
 }
 
+// Stop closes the connection to the nym-client.
+// It is equivalent to StopContext(context.Background())
 func (n *NymSocketManager) Stop() {
+	n.StopContext(context.Background())
+}
+
+// StopContext closes the connection to the nym-client.
+// The messageHandlers in progress are given the handler drain timeout to return, while they can still send (see WithHandlerDrainTimeout).
+// New Sends are then refused, while the pending ones are given the drain timeout to complete (see WithDrainTimeout).
+// The drain and the wait for the socketListener to confirm the closure are aborted when ctx is done.
+// If ctx has no deadline, the latter is bounded by the close timeout (see WithCloseBehavior)
+func (n *NymSocketManager) StopContext(ctx context.Context) {
+	n.stop(ctx)
+}
+
+// StopOutcome tells how the connection was closed on Stop
+type StopOutcome int
+
+const (
+	// The manager was not started, nothing had to be closed
+	StopNotStarted StopOutcome = iota
+	// The close handshake completed, or the connection was closed already
+	StopGraceful
+	// The socketListener did not confirm the closure in time, the connection was closed abruptly
+	StopForced
+)
+
+func (o StopOutcome) String() string {
+	switch o {
+	case StopNotStarted:
+		return "NotStarted"
+	case StopGraceful:
+		return "Graceful"
+	case StopForced:
+		return "Forced"
+	default:
+		return "Unknown"
+	}
+}
+
+// StopWithTimeout closes the connection to the nym-client, as StopContext does, within timeout.
+// If the close handshake does not complete in time, the underlying connection is force-closed.
+// The returned StopOutcome tells which path was taken
+func (n *NymSocketManager) StopWithTimeout(timeout time.Duration) StopOutcome {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return n.stop(ctx)
+}
+
+func (n *NymSocketManager) stop(ctx context.Context) StopOutcome {
 	n.Lock()
 	defer n.Unlock()
 
 	n.logger.Debug().Msg("stopping NymSocketManager")
 
-	// Check if not already fully stopped (setting connection to nil is last step of self-destruction)
-	if nil == n.connection {
-		return
+	// Check if not already fully stopped (setting selfInstanceStoppedChan to nil is last step of self-destruction)
+	if nil == n.selfInstanceStoppedChan {
+		return StopNotStarted
 	}
 
-	n.selfDestruct()
+	outcome := n.selfDestruct(ctx)
+
+	n.logger.Debug().Msgf("stopped NymSocketManager (%v)", outcome)
 
-	n.logger.Debug().Msg("stopped NymSocketManager")
+	return outcome
 }
 
 // selfDestruct will close all channel and free resources when requested
 // called from methods that already acquired the lock
-func (n *NymSocketManager) selfDestruct() {
+func (n *NymSocketManager) selfDestruct(ctx context.Context) StopOutcome {
 
 	n.logger.Debug().Msg("selfDestructing")
 
 	// Ensure we do not close everthing if everything is closed already
 	if nil == n.selfInstanceStoppedChan {
 		n.logger.Debug().Msg("already selfDestructed")
-		return
+		return StopNotStarted
 	}
 
+	n.setState(StateDraining)
+	n.started.Store(false)
+	n.stats.setStarted(time.Time{})
+
+	n.stopIdleTimer()
+	n.stopCoverTraffic()
+	n.stopLatencyProbe()
+	n.stopQualityPings()
+	// The messageHandlers are told to wrap up, and can still reply until they return
+	n.handlerContext.stop()
+	n.dropPaused()
+	n.drainHandlers(ctx)
+	n.drain(ctx)
+
+	outcome := StopForced
+	if n.disconnect(ctx, nil) {
+		outcome = StopGraceful
+	}
+	n.stopAsyncSender()
+	n.stopHandlerPool()
+	n.streams.closeAll()
+	n.clearReplayBuffer()
+	n.closeAuditLog()
+
+	// If initialized, we close the selfInstanceStoppedChan
+	if nil != n.selfInstanceStoppedChan {
+		n.logger.Trace().Msg("closing channel to indicate upstream that closed")
+		close(n.selfInstanceStoppedChan)
+		n.selfInstanceStoppedChan = nil
+	}
+
+	n.setState(StateStopped)
+
+	n.logger.Debug().Msg("selfDestructed")
+
+	return outcome
+}
+
+// drain refuses any new Send and waits for the pending ones to be written on the connection
+// called from methods that already acquired the lock
+func (n *NymSocketManager) drain(ctx context.Context) {
+	drainCtx, cancel := context.WithTimeout(ctx, n.drainTimeout)
+	defer cancel()
+
+	select {
+	case <-n.sendGate.close():
+		n.logger.Trace().Msg("pending sends flushed")
+	case <-drainCtx.Done():
+		n.logger.Warn().Msgf("stopped waiting for pending sends to complete: %v", drainCtx.Err())
+	}
+}
+
+// drainHandlers refuses any new message and waits for the messageHandlers in progress to return, while they can still send
+// called from methods that already acquired the lock
+func (n *NymSocketManager) drainHandlers(ctx context.Context) {
+	drainCtx, cancel := context.WithTimeout(ctx, n.handlerDrainTimeout)
+	defer cancel()
+
+	select {
+	case <-n.handlerGate.close():
+		n.logger.Trace().Msg("messageHandlers in progress returned")
+	case <-drainCtx.Done():
+		n.logger.Warn().Msgf("stopped waiting for messageHandlers in progress to return: %v", drainCtx.Err())
+	}
+}
+
+// disconnect closes the current connection and its socketListener, if any
+// reason is nil if the closure is requested, or describes why the connection is being closed
+// It returns false if the socketListener did not confirm the closure in time, meaning that the connection was force-closed
+// called from methods that already acquired the lock
+func (n *NymSocketManager) disconnect(ctx context.Context, reason error) bool {
+	graceful := true
+
+	n.closePool(ctx)
+	n.closeShards(ctx)
+
 	// How to properly close the connection (well, almost):
 	///////////////////////////////////////////////////////
 	/* This method properly close it from the other end's perspective
 	 * on this side, it results in an abnormal closure, while we send a CloseNormalClosure message
-	 * It seems to be an issue in this lib (ref: https://github.com/gorilla/websocket/pull/487).
+	 * It seems to be an issue in this lib (ref: https://github.com/gorilla/websocket/pull/487).
 	 */
 
 	// If socketListener is defined, we close it
 	if nil != n.socketListener {
 
-		// This will close the socketListener
-		n.logger.Trace().Msg("sending close signal on socket and waiting for confirmation from socketListener")
-		n.sendCloseSignal()
-
-		// Waiting for confirmation (or timeout)
-		deadline := 5 * time.Second
 		select {
+		// No need to say goodbye on a connection which is gone already
 		case <-n.closedSocketListenerChan:
-			n.logger.Debug().Msg("underlying connection closed")
-		case <-time.After(deadline):
-			n.logger.Debug().Msgf("timed-out (%v) on waiting for underlying connection to close", deadline)
+			n.logger.Debug().Msg("underlying connection already closed")
+
+		default:
+			if n.closeBehavior.SkipCloseFrame {
+				n.logger.Trace().Msg("closing socket without sending close signal")
+				break
+			}
+
+			// This will close the socketListener
+			n.logger.Trace().Msg("sending close signal on socket and waiting for confirmation from socketListener")
+			n.socketListener.closeRequested.Store(true)
+			n.sendCloseSignal()
+
+			// Waiting for confirmation (or timeout)
+			closeCtx, cancel := withDefaultTimeout(ctx, n.closeTimeout())
+			select {
+			case <-n.closedSocketListenerChan:
+				n.logger.Debug().Msg("underlying connection closed")
+			case <-closeCtx.Done():
+				n.logger.Debug().Msgf("stopped waiting for underlying connection to close: %v", closeCtx.Err())
+				graceful = false
+			}
+			cancel()
 		}
 
 		n.logger.Trace().Msg("removing socketListener")
@@ -199,47 +823,403 @@ func (n *NymSocketManager) selfDestruct() {
 		if e != nil {
 			n.logger.Warn().Msgf("error while closing connection: %v", e)
 		}
-		n.connection = nil
+		n.setConnection(nil)
+		n.hooks.disconnected(n.connectionURIs[n.connectionURIIndex], reason)
+		n.events.disconnected(n.connectionURIs[n.connectionURIIndex], reason)
 	}
 
-	// If initialized, we close the selfInstanceStoppedChan
-	if nil != n.selfInstanceStoppedChan {
-		n.logger.Trace().Msg("closing channel to indicate upstream that closed")
-		close(n.selfInstanceStoppedChan)
-		n.selfInstanceStoppedChan = nil
-	}
+	return graceful
+}
 
-	n.logger.Debug().Msg("selfDestructed")
+// isConnected tells whether Send has a connection to write on
+func (n *NymSocketManager) isConnected() bool {
+	n.senderMutex.Lock()
+	defer n.senderMutex.Unlock()
+	return nil != n.connection
+}
+
+// setConnection replaces the connection used by Send
+// called from methods that already acquired the lock
+func (n *NymSocketManager) setConnection(connection *websocket.Conn) {
+	n.senderMutex.Lock()
+	defer n.senderMutex.Unlock()
+	n.connection = connection
 }
 
 // Send a message to the underlying connection
+// With a lazy connection, the connection is opened first if needed
 func (n *NymSocketManager) Send(msg NymMessage) error {
+	return n.SendWithOptions(msg)
+}
+
+// SendWithOptions sends a message like Send, with options overriding the manager-wide behaviour for this message only
+func (n *NymSocketManager) SendWithOptions(msg NymMessage, options ...SendOption) error {
+	o := newSendOptions(options)
+	ctx, span := n.startSpan(o.traceContext, SendSpanName, sendSpanAttributes(msg))
+
+	var deliveryID string
+	if nil != o.confirmation {
+		var e error
+		msg, deliveryID, e = n.requestConfirmation(msg, o.confirmation)
+		if nil != e {
+			o.complete(e)
+			n.errors.record(ErrorCategorySend, e)
+			endSpan(span, e)
+			return e
+		}
+	}
+	// The receiver extracts the identifier of the confirmation, so that both sides log the same one
+	if len(o.messageID) == 0 {
+		o.messageID = deliveryID
+	}
+	if len(o.messageID) == 0 {
+		o.messageID = n.newMessageID()
+	}
+
+	// Outside of the envelope of the confirmation, so that the receiver strips it first
+	buffered, e := n.sendWithOptions(n.injectTraceContext(ctx, msg), o)
+	if !buffered {
+		o.complete(e)
+	}
+	if nil != e && len(deliveryID) != 0 {
+		n.deliveries.done(deliveryID, e)
+	}
+	if nil != e {
+		n.errors.record(ErrorCategorySend, e)
+	}
+	endSpan(span, e)
+	return e
+}
+
+// sendWithOptions returns true if the message was buffered for replay, its completion being then notified later
+func (n *NymSocketManager) sendWithOptions(msg NymMessage, options *sendOptions) (bool, error) {
+	buffered := false
+	e := n.intercepted(func(msg NymMessage) error {
+		var e error
+		buffered, e = n.sendIntercepted(msg, options)
+		return e
+	})(msg)
+	return buffered, e
+}
+
+// sendIntercepted sends a message which went through the interceptors, see sendWithOptions
+func (n *NymSocketManager) sendIntercepted(msg NymMessage, options *sendOptions) (bool, error) {
+	e := n.validateRecipient(msg)
+	if nil != e {
+		return false, e
+	}
+
+	n.waitForCongestion(msg, options.id())
+
+	if anonymous, ok := msg.(NymSendAnonymous); ok && 0 == anonymous.ReplySurbs {
+		anonymous.ReplySurbs = n.defaultReplySurbs
+		msg = anonymous
+	}
+
+	if n.lazyConnection {
+		e = n.connectLazily()
+		if nil != e {
+			return false, e
+		}
+	}
+
+	if !n.rateLimited() {
+		return n.sendOrReplay(msg, options)
+	}
+
+	// Pending while rate limited, so that Stop waits for the wait to be aborted
+	if !n.sendGate.enter() {
+		err := xerrors.Errorf("cannot send %v: %w", msg.Name(), ErrNotStarted)
+		n.logger.Warn().Msg(err.Error())
+		return false, err
+	}
+	defer n.sendGate.leave()
+	e = n.waitForRate(msg, options.id())
+	if nil != e {
+		return false, e
+	}
+
+	buffered, e := n.sendOrReplay(msg, options)
+	// The token is for a message written
+	if xerrors.Is(e, ErrNotStarted) || xerrors.Is(e, ErrConnectionClosed) {
+		n.rateLimiter.refund(msg)
+	}
+	return buffered, e
+}
+
+// sendOrReplay sends a message, or keeps it for the replay while reconnecting if a replay buffer is configured
+func (n *NymSocketManager) sendOrReplay(msg NymMessage, options *sendOptions) (bool, error) {
+	if nil != n.replayBuffer && n.started.Load() {
+		return n.sendOrBuffer(msg, options)
+	}
+	return n.sendOrQueue(msg, options)
+}
+
+// sendOrQueue queues a message for the writer goroutine if asynchronous sends are configured, or sends it.
+// It returns true if the message was queued, its completion being then notified later
+func (n *NymSocketManager) sendOrQueue(msg NymMessage, options *sendOptions) (bool, error) {
+	if nil != n.asyncSender {
+		e := n.sendAsync(msg, options)
+		return nil == e, e
+	}
+	return false, n.send(msg, options)
+}
+
+// validateRecipient fails on messages to malformed addresses, instead of letting the mixnet bounce them
+func (n *NymSocketManager) validateRecipient(msg NymMessage) error {
+	var recipient string
+	switch m := msg.(type) {
+	case NymSend:
+		recipient = m.Recipient
+	case NymSendAnonymous:
+		recipient = m.Recipient
+	default:
+		return nil
+	}
+
+	e := address.Validate(recipient)
+	if nil != e {
+		err := xerrors.Errorf("cannot send %v: %v", msg.Name(), e)
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+	return nil
+}
+
+// SendRaw sends a JSON message as is, e.g. to use a request of the nym-client not supported by the library yet.
+// It is always sent in a text frame, even with WithBinaryProtocol. See OnRawMessage for the receiving side
+func (n *NymSocketManager) SendRaw(msg json.RawMessage) error {
+	if !json.Valid(msg) {
+		err := xerrors.Errorf("raw message is not valid JSON")
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+	return n.Send(rawNymMessage{msg})
+}
+
+// SendAnonymous sends message to recipient without disclosing our address.
+// The replySurbs reply SURBs attached let the recipient answer with Reply, using the SenderTag of the NymReceived.
+// If replySurbs is 0, the default set with WithDefaultReplySurbs applies
+func (n *NymSocketManager) SendAnonymous(message string, recipient string, replySurbs uint) error {
+	return n.Send(NewNymSendAnonymous(message, recipient, replySurbs))
+}
+
+// Reply answers an anonymous message, identified by the SenderTag of its NymReceived, through the reply SURBs it carried
+func (n *NymSocketManager) Reply(senderTag string, message string) error {
+	return n.reply(senderTag, message)
+}
+
+// reply is Reply with options for the reply sent
+func (n *NymSocketManager) reply(senderTag string, message string, options ...SendOption) error {
+	if len(senderTag) == 0 {
+		err := xerrors.Errorf("cannot reply to a message without sender tag")
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+	e := n.SendWithOptions(NewNymReply(senderTag, message), options...)
+	if nil != e {
+		return e
+	}
+	n.trackConsumedSurbs(senderTag, message)
+	return nil
+}
+
+// ReplyTo answers a received message through the reply SURBs it carried if it was anonymous,
+// or to its return address if it was a request sent with SendRequest
+func (n *NymSocketManager) ReplyTo(received NymReceived, message string) error {
+	return n.replyTo(received, message)
+}
+
+// replyTo is ReplyTo with options for the reply sent
+func (n *NymSocketManager) replyTo(received NymReceived, message string, options ...SendOption) error {
+	if received.IsReplyable() {
+		return n.reply(received.SenderTag, message, options...)
+	}
+	if len(received.ReturnAddress) != 0 {
+		return n.SendWithOptions(NewNymSend(message, received.ReturnAddress), options...)
+	}
+
+	err := xerrors.Errorf("cannot reply: the message carries neither sender tag nor return address")
+	n.logger.Warn().Msg(err.Error())
+	return err
+}
+
+// replyFuncFor returns the function passed to the messageHandler along with received.
+// It sends the messages as is, except for the NymSend without recipient and the NymReply without sender tag,
+// which are sent back to the sender of received with ReplyTo. Its errors are *ReplyError, passed to the OnHandlerError hooks too.
+// The messages are sent within the trace of received, if traced
+func (n *NymSocketManager) replyFuncFor(received NymReceived) func(NymMessage) error {
+	var options []SendOption
+	if nil != received.trace {
+		options = append(options, traceContextOption(received.trace.ctx))
+	}
+
+	return func(msg NymMessage) error {
+		var e error
+		switch m := msg.(type) {
+		case NymSend:
+			if len(m.Recipient) == 0 {
+				e = n.replyTo(received, m.Message, options...)
+			} else {
+				e = n.SendWithOptions(msg, options...)
+			}
+		case NymReply:
+			if len(m.SenderTag) == 0 {
+				e = n.replyTo(received, m.Message, options...)
+			} else {
+				e = n.SendWithOptions(msg, options...)
+			}
+		default:
+			e = n.SendWithOptions(msg, options...)
+		}
+		if nil == e {
+			return nil
+		}
+
+		err := &ReplyError{Message: msg, Err: e}
+		n.errors.record(ErrorCategoryHandler, err)
+		n.hooks.handlerFailed(received, err)
+		return err
+	}
+}
+
+// send writes a message on the underlying connection, options can be nil
+func (n *NymSocketManager) send(msg NymMessage, options *sendOptions) error {
+	if !n.sendGate.enter() {
+		err := xerrors.Errorf("cannot send %v: %w", msg.Name(), ErrNotStarted)
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+	defer n.sendGate.leave()
+
+	return n.writePrioritized(msg, options)
+}
+
+// writePrioritized writes a message through the priority queue if any, or directly
+func (n *NymSocketManager) writePrioritized(msg NymMessage, options *sendOptions) error {
+	if nil != n.sendQueue {
+		return n.sendQueue.write(msg, options, n.write)
+	}
+	return n.write(msg, options)
+}
+
+// write writes a message on the connection of its shard, on the next pooled connection, or on the underlying connection,
+// along with the messages sent meanwhile if write coalescing is configured
+func (n *NymSocketManager) write(msg NymMessage, options *sendOptions) error {
+	if shard := n.shards.connectionFor(msg); nil != shard {
+		return n.writeOnPool(shard, msg, options)
+	}
+	if nil != n.coalescer {
+		return n.coalescer.write(msg, options, n.writeBatch)
+	}
+
+	if pooled := n.pool.next(); nil != pooled {
+		return n.writeOnPool(pooled, msg, options)
+	}
+
 	n.senderMutex.Lock()
 	defer n.senderMutex.Unlock()
 
 	if nil == n.connection {
-		err := xerrors.Errorf("connection is undefined. Is the NymSocketManager started?")
+		err := xerrors.Errorf("cannot write %v: %w", msg.Name(), n.disconnectedMode())
 		n.logger.Warn().Msg(err.Error())
 		return err
 	}
 
-	msgBytes, e := json.Marshal(msg)
+	return n.writeMessage(n.connection, msg, options)
+}
+
+// disconnectedMode returns the failure mode of the operations needing the connection while there is none:
+// ErrNotStarted, or ErrConnectionClosed if the manager is started and the connection was lost
+func (n *NymSocketManager) disconnectedMode() error {
+	if !n.started.Load() {
+		return ErrNotStarted
+	}
+	return ErrConnectionClosed
+}
+
+// Buffers in which the messages are marshalled, reused to spare allocations under sustained send rates
+var marshalBuffers = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// Buffers grown larger are not reused, so that a few large messages do not pin their memory
+const maxPooledMarshalBufferSize = 1 << 20
+
+// writeMessage marshals a message and writes it on connection
+// called from methods that already serialised the writes on connection
+func (n *NymSocketManager) writeMessage(connection *websocket.Conn, msg NymMessage, options *sendOptions) error {
+	messageType := websocket.TextMessage
+	var msgBytes []byte
+	var e error
+	if n.useBinaryEncoding(msg, options) {
+		messageType = websocket.BinaryMessage
+		msgBytes, e = encodeBinaryRequest(msg)
+	} else {
+		buffer := marshalBuffers.Get().(*bytes.Buffer)
+		buffer.Reset()
+		defer func() {
+			if buffer.Cap() <= maxPooledMarshalBufferSize {
+				marshalBuffers.Put(buffer)
+			}
+		}()
+
+		e = json.NewEncoder(buffer).Encode(msg)
+		// Without the newline terminating the encoded value
+		msgBytes = bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+	}
 	if nil != e {
-		err := xerrors.Errorf("failed to marshal NymMessage: %v", msg)
-		n.logger.Warn().Msg(err.Error())
+		err := xerrors.Errorf("%w %v: %v", ErrMarshal, n.loggable(msg), e)
+		n.logger.Warn().Str(MessageIDField, options.id()).Msg(err.Error())
 		return err
 	}
 
-	e = n.connection.WriteMessage(websocket.TextMessage, msgBytes)
+	writeTimeout := n.writeTimeout
+	if nil != options && 0 != options.writeTimeout {
+		writeTimeout = options.writeTimeout
+	}
+	if 0 != writeTimeout {
+		_ = connection.SetWriteDeadline(time.Now().Add(writeTimeout))
+		defer connection.SetWriteDeadline(time.Time{})
+	}
+
+	writtenAt := time.Now()
+	e = writeFrame(connection, messageType, msgBytes)
+	n.qualityWritten(time.Since(writtenAt), e)
 	if nil != e {
-		err := xerrors.Errorf("failed to send message: %v", e)
-		n.logger.Warn().Msg(err.Error())
+		// A timed out write may have left a partial frame: the connection is closed, so that it is replaced as if it was lost
+		var netError net.Error
+		if xerrors.As(e, &netError) && netError.Timeout() {
+			connection.Close()
+		}
+		mode := ErrWriteFailed
+		if xerrors.Is(e, websocket.ErrCloseSent) || xerrors.Is(e, net.ErrClosed) {
+			mode = ErrConnectionClosed
+		}
+		err := xerrors.Errorf("%w: %v", mode, e)
+		n.logger.Warn().Str(MessageIDField, options.id()).Msg(err.Error())
 		return err
 	}
+	n.messageLogger.Trace().Str(MessageIDField, options.id()).Msgf("wrote %v", msg.Name())
+	n.stats.sent(msg, len(msgBytes))
+	n.taps.observe(DirectionOut, msgBytes)
+	n.auditSent(msg)
 
 	return nil
 }
 
+// writeFrame writes data in a single frame, directly into the write buffer of connection
+func writeFrame(connection *websocket.Conn, messageType int, data []byte) error {
+	w, e := connection.NextWriter(messageType)
+	if nil != e {
+		return e
+	}
+	_, e = w.Write(data)
+	if nil != e {
+		w.Close()
+		return e
+	}
+	return w.Close()
+}
+
 // Send message to properly close the socket connection
 // This will close any listener connected to this socket
 func (n *NymSocketManager) sendCloseSignal() error {
@@ -247,12 +1227,12 @@ func (n *NymSocketManager) sendCloseSignal() error {
 	defer n.senderMutex.Unlock()
 
 	if nil == n.connection {
-		err := xerrors.Errorf("connection is undefined. Is the NymSocketManager started?")
+		err := xerrors.Errorf("cannot write close: %w", ErrConnectionClosed)
 		n.logger.Warn().Msg(err.Error())
 		return err
 	}
 
-	e := n.connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	e := n.connection.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(n.closeCode(), ""))
 	if nil != e {
 		err := xerrors.Errorf("failed to write close: %v", e)
 		n.logger.Warn().Msg(err.Error())
@@ -264,12 +1244,33 @@ func (n *NymSocketManager) sendCloseSignal() error {
 	return nil
 }
 
+// closeCode returns the code of the close frames sent to the nym-client
+func (n *NymSocketManager) closeCode() int {
+	if 0 == n.closeBehavior.CloseCode {
+		return websocket.CloseNormalClosure
+	}
+	return n.closeBehavior.CloseCode
+}
+
+// closeTimeout returns how long to wait for the closure to be confirmed when no deadline is provided
+func (n *NymSocketManager) closeTimeout() time.Duration {
+	if 0 == n.closeBehavior.Timeout {
+		return defaultCloseTimeout
+	}
+	return n.closeBehavior.Timeout
+}
+
 func (n *NymSocketManager) GetNymClientId() string {
 	n.Lock()
 	defer n.Unlock()
 	return n.clientID
 }
 
+// GetNymAddress returns the parsed address of the nym-client, see GetNymClientId
+func (n *NymSocketManager) GetNymAddress() (address.Address, error) {
+	return address.Parse(n.GetNymClientId())
+}
+
 func (n *NymSocketManager) GetConnectedGateway() string {
 	n.Lock()
 	defer n.Unlock()
@@ -279,57 +1280,182 @@ func (n *NymSocketManager) GetConnectedGateway() string {
 	return strings.Split(n.clientID, "@")[1]
 }
 
-// messageDispatcher is provided to the socketListener to process the incoming messages.
+// messageDispatcher is provided to the socketListener to process the incoming messages, in either the JSON or the binary protocol.
 // It calls the provided messageHandler on received messages (except on errors and on selfAddress reply)
 func (n *NymSocketManager) messageDispatcher(s []byte) {
+	n.dispatchMessage(s, nil)
+}
 
-	receivedMessageJSON := make(map[string]interface{})
-	e := json.Unmarshal(s, &receivedMessageJSON)
-	if nil != e {
-		n.logger.Warn().Msgf("failed to unmarshal message: %v\n", e)
-		return
-	}
-
-	if _, ok := receivedMessageJSON["type"]; !ok {
-		n.logger.Warn().Msgf("message from mixnet have no \"type\" attribute. Message: %v", receivedMessageJSON)
-		return
-	}
+// dispatchMessage handles a message received on origin, nil meaning the main connection
+func (n *NymSocketManager) dispatchMessage(s []byte, origin *pooledConnection) {
+	receivedAt := time.Now()
+	n.taps.observe(DirectionIn, s)
 
-	switch receivedMessageJSON["type"] {
-	case NymSelfAddressReplyType:
-		reply := NymSelfAddressReply{}
-		e = json.Unmarshal(s, &reply)
+	var msg NymMessage
+	binaryFrame := isBinaryFrame(s)
+	if binaryFrame {
+		decoded, e := decodeBinaryResponse(s)
 		if nil != e {
-			n.logger.Warn().Msgf("failed to unmarshal SelfAddressReply: %v", e)
+			n.logger.Warn().Msgf("failed to decode binary message: %v", e)
+			n.messageDropped(DropMalformed, nil, "", s, e.Error())
+			return
+		}
+		msg = decoded
+	} else {
+		n.hooks.rawMessageReceived(s)
+		msg = n.parseTextMessage(s)
+		if nil == msg {
 			return
 		}
-		n.clientID = reply.Address
-		n.logger.Debug().Msgf("Got %v reply: Address is %v", reply.Type, reply.Address)
-		if nil != n.selfAddressReceivedChan {
-			close(n.selfAddressReceivedChan)
+	}
+	n.stats.received(msg, len(s), receivedAt)
+
+	switch m := msg.(type) {
+	case NymSelfAddressReply:
+		encoding := detectClientEncoding(binaryFrame, m)
+		e := n.checkClientEncoding(encoding)
+		if nil == e {
+			n.clientID = m.Address
+			n.clientEncoding.Store(int32(encoding))
+			n.logger.Debug().Msgf("Got %v reply: Address is %v, encoding is %v", m.Type, m.Address, encoding)
+		}
+		// Replies to retried requests may come in late, only the first one matters
+		select {
+		case n.selfAddressReceivedChan <- e:
+		default:
 		}
 
-	case NymErrorType:
-		reply := NymError{}
-		e = json.Unmarshal(s, &reply)
-		if nil != e {
-			n.logger.Warn().Msgf("failed to unmarshal errorMessage: %v", e)
+	case NymError:
+		n.logger.Error().Msgf("Got error from mixnet: %v", m.Message)
+		n.nymErrorThrottled(m)
+		n.errors.record(ErrorCategoryNym, &NymRemoteError{m})
+		n.hooks.nymErrorReceived(m)
+
+	case NymControlMessage:
+		if !n.hooks.controlMessageReceived(m) {
+			n.logger.Warn().Msgf("encountered unparsed type of message: %s", loggableFrame(m.Raw, n.redactPayloads))
+			n.messageDropped(DropUnknownType, m, "", m.Raw, m.Type)
+		}
+
+	case NymLaneQueueLength:
+		n.messageLogger.Debug().Msgf("got: %v", m)
+		n.laneQueues.replyReceived(m)
+
+	case NymReceived:
+		n.messageLogger.Debug().Msgf("got: %v", n.loggable(m))
+		n.shards.received(m, origin)
+
+		if isCoverTraffic(m) || n.probeReceived(m, receivedAt) || !n.trackReceivedSurbs(m) {
 			return
 		}
-		n.logger.Error().Msgf("Got error from mixnet: %v", reply.Message)
+		m.ReceivedAt = receivedAt
+		m.Binary = binaryFrame
+		m.Chunks = 1
+		n.auditReceived(m)
 
-	case NymReceivedType:
-		msg := NymReceived{}
-		e = json.Unmarshal(s, &msg)
-		if nil != e {
-			n.logger.Warn().Msgf("failed to unmarshal NymMessage: %v", e)
+		m, complete := n.reassemble(m)
+		if !complete {
+			return
+		}
+		span := n.traceReceived(&m)
+		defer span.End()
+
+		if n.receiveStreamFrame(m) || !n.acknowledge(&m) || !n.correlate(&m) {
+			return
+		}
+		// Inside the envelopes of the library, so that it is opened last
+		n.openEnvelope(&m)
+		if n.replyWaiters.replyReceived(m) {
 			return
 		}
-		n.logger.Debug().Msgf("got: %v", msg)
+		if len(m.MessageID) == 0 {
+			m.MessageID = n.newMessageID()
+		}
+		n.handle(m)
+	}
+}
+
+// textEnvelope holds the fields of all the messages of the JSON protocol handled by the library, so that they are decoded in one pass.
+// The messages of the nym-client being flat objects, their body is made of the fields other than the type
+type textEnvelope struct {
+	Type        *string `json:"type"`
+	Message     string  `json:"message"`
+	SenderTag   string  `json:"senderTag"`
+	Address     string  `json:"address"`
+	Lane        uint64  `json:"lane"`
+	QueueLength uint64  `json:"queueLength"`
+}
 
-		n.messageHandler(msg, n.Send)
+// message returns the message held by the envelope, s being the frame it was decoded from
+func (t textEnvelope) message(s []byte) NymMessage {
+	common := NymMessageCommon{Type: *t.Type}
+	switch common.Type {
+	case NymSelfAddressReplyType:
+		return NymSelfAddressReply{NymMessageCommon: common, Address: t.Address}
+	case NymErrorType:
+		return NymError{NymMessageCommon: common, Message: t.Message}
+	case NymLaneQueueLengthType:
+		return NymLaneQueueLength{NymMessageCommon: common, Lane: t.Lane, QueueLength: t.QueueLength}
+	case NymReceivedType:
+		return NymReceived{NymMessageCommon: common, Message: t.Message, SenderTag: t.SenderTag}
+	default:
+		raw := make(json.RawMessage, len(s))
+		copy(raw, s)
+		return NymControlMessage{common, raw}
+	}
+}
+
+// parseTextMessage unmarshals a message of the JSON protocol, returns nil if it cannot be handled
+func (n *NymSocketManager) parseTextMessage(s []byte) NymMessage {
+	// The schema validation needs the exact type, and the messages not fitting in the envelope are decoded by type
+	var envelope textEnvelope
+	if !n.schemaValidation && nil == json.Unmarshal(s, &envelope) && nil != envelope.Type {
+		return envelope.message(s)
+	}
+
+	var probe struct {
+		Type json.RawMessage `json:"type"`
+	}
+	e := json.Unmarshal(s, &probe)
+	if nil != e {
+		n.logger.Warn().Msgf("failed to unmarshal message: %v\n", e)
+		n.messageDropped(DropMalformed, nil, "", s, e.Error())
+		return nil
+	}
+
+	if len(probe.Type) == 0 {
+		n.logger.Warn().Msgf("message from mixnet have no \"type\" attribute. Message: %s", s)
+		n.messageDropped(DropMalformed, nil, "", s, "no type attribute")
+		return nil
+	}
+
+	// Non-string types are handled as an empty one
+	var msgType string
+	_ = json.Unmarshal(probe.Type, &msgType)
+	switch msgType {
+	case NymSelfAddressReplyType:
+		return parseTextMessageAs[NymSelfAddressReply](n, s, msgType)
+
+	case NymErrorType:
+		return parseTextMessageAs[NymError](n, s, msgType)
+
+	case NymLaneQueueLengthType:
+		return parseTextMessageAs[NymLaneQueueLength](n, s, msgType)
+
+	case NymReceivedType:
+		return parseTextMessageAs[NymReceived](n, s, msgType)
 
 	default:
-		n.logger.Warn().Msgf("encountered unparsed type of message: %v", receivedMessageJSON)
+		raw := make(json.RawMessage, len(s))
+		copy(raw, s)
+		return NymControlMessage{NymMessageCommon{Type: msgType}, raw}
+	}
+}
+
+// withDefaultTimeout bounds ctx by timeout, unless ctx already carries its own deadline
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, timeout)
 }
@@ -1,8 +1,19 @@
 package nymsocketmanager_test
 
 import (
+	"compress/flate"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	lib "github.com/notrustverify/nymsocketmanager"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
@@ -14,7 +25,7 @@ func TestNymSocketManagerShouldHaveANonEmptyConnectionURI(t *testing.T) {
 
 	logger := zerolog.Logger{}
 
-	_, e := lib.NewNymSocketManager("", emptyProcessing, &logger)
+	_, e := lib.NewNymSocketManager("", emptyProcessing, lib.ZerologLogger(&logger))
 	require.Error(t, e)
 }
 
@@ -22,7 +33,7 @@ func TestNymSocketManagerShouldHaveAValidProcessingFunction(t *testing.T) {
 
 	logger := zerolog.Logger{}
 
-	_, e := lib.NewNymSocketManager("ws://127.0.0.1", nil, &logger)
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1", nil, lib.ZerologLogger(&logger))
 	require.Error(t, e)
 }
 
@@ -34,7 +45,7 @@ func TestNymSocketManagerShouldHaveAValidLogger(t *testing.T) {
 func TestNymSocketManagerShouldNotStartWithWrongClientIDWS(t *testing.T) {
 	logger := zerolog.Logger{}
 
-	nymSocketManager, e := lib.NewNymSocketManager("aaaaaaaaaaaa", emptyProcessing, &logger)
+	nymSocketManager, e := lib.NewNymSocketManager("aaaaaaaaaaaa", emptyProcessing, lib.ZerologLogger(&logger))
 	require.NoError(t, e)
 
 	_, e = nymSocketManager.Start()
@@ -45,7 +56,7 @@ func TestNymSocketManagerGetGateway(t *testing.T) {
 
 	logger := zerolog.Logger{}
 
-	nymSocketManager, e := lib.NewNymSocketManager("ws://127.0.0.1:10977", func(_ lib.NymReceived, _ func(lib.NymMessage) error) {}, &logger)
+	nymSocketManager, e := lib.NewNymSocketManager("ws://127.0.0.1:10977", func(_ lib.NymReceived, _ func(lib.NymMessage) error) {}, lib.ZerologLogger(&logger))
 	require.NoError(t, e)
 
 	_, e = nymSocketManager.Start()
@@ -55,3 +66,605 @@ func TestNymSocketManagerGetGateway(t *testing.T) {
 	gatewayAddr := nymSocketManager.GetConnectedGateway()
 	require.NotEmpty(t, gatewayAddr)
 }
+
+func TestNymSocketManagerStartStopContext(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	stopped, e := nymSocketManager.StartContext(ctx)
+	require.NoError(t, e)
+	require.True(t, nymSocketManager.IsRunning())
+	require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+
+	nymSocketManager.StopContext(ctx)
+	require.False(t, nymSocketManager.IsRunning())
+	_, open := <-stopped
+	require.False(t, open)
+}
+
+func TestNymSocketManagerStartContextAbortsSelfAddressWait(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.ignoreSelfAddress = true
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, e = nymSocketManager.StartContext(ctx)
+	require.ErrorIs(t, e, context.DeadlineExceeded)
+	require.False(t, nymSocketManager.IsRunning())
+}
+
+func TestNymSocketManagerStartFallsBackToNextURI(t *testing.T) {
+	logger := zerolog.Logger{}
+	unreachable := newFakeNymClient(t)
+	unreachable.server.Close()
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(unreachable.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithFallbackURIs(fake.URI()))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, fake.URI(), nymSocketManager.GetConnectionURI())
+}
+
+func TestNymSocketManagerFailsOverWhenConnectionLost(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithFallbackURIs(second.URI()))
+	require.NoError(t, e)
+
+	stopped, e := nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, first.URI(), nymSocketManager.GetConnectionURI())
+
+	first.dropConnections()
+
+	require.Eventually(t, func() bool {
+		return nymSocketManager.GetConnectionURI() == second.URI() && nymSocketManager.IsRunning()
+	}, 2*time.Second, 10*time.Millisecond)
+
+	select {
+	case <-stopped:
+		t.Fatal("manager should not stop while a fallback nym-client is reachable")
+	default:
+	}
+}
+
+func TestNymSocketManagerEmptyFallbackURI(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithFallbackURIs(""))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerRetriesSelfAddressRequest(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.selfAddressRequestsToIgnore = 2
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithSelfAddressTimeout(50*time.Millisecond), lib.WithSelfAddressRetries(2))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+}
+
+func TestNymSocketManagerFailsWhenSelfAddressRetriesExhausted(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.selfAddressRequestsToIgnore = 2
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithSelfAddressTimeout(50*time.Millisecond), lib.WithSelfAddressRetries(1))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.ErrorIs(t, e, context.DeadlineExceeded)
+	require.False(t, nymSocketManager.IsRunning())
+}
+
+func TestNymSocketManagerSelfAddressTimeoutMustBePositive(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithSelfAddressTimeout(0))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerRestartKeepsHandler(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	received := make(chan string, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(msg lib.NymReceived, _ func(lib.NymMessage) error) {
+		received <- msg.Message
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	firstStopped, e := nymSocketManager.Start()
+	require.NoError(t, e)
+
+	secondStopped, e := nymSocketManager.Restart()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	_, open := <-firstStopped
+	require.False(t, open)
+	require.NotEqual(t, firstStopped, secondStopped)
+	require.True(t, nymSocketManager.IsRunning())
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	select {
+	case msg := <-received:
+		require.Equal(t, "hello", msg)
+	case <-time.After(time.Second):
+		t.Fatal("handler was not called after restart")
+	}
+}
+
+func TestNymSocketManagerStopDrainsPendingSends(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithDrainTimeout(time.Second))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	var sent atomic.Int32
+	var senders sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			if nil == nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)) {
+				sent.Add(1)
+			}
+		}()
+	}
+
+	nymSocketManager.Stop()
+	senders.Wait()
+
+	require.Error(t, nymSocketManager.Send(lib.NewNymSend("too late", fakePeerAddress)))
+	require.Eventually(t, func() bool {
+		return fake.sendRequests.Load() == sent.Load()
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNymSocketManagerStopWithTimeoutGraceful(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	require.Equal(t, lib.StopNotStarted, nymSocketManager.StopWithTimeout(time.Second))
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	require.Equal(t, lib.StopGraceful, nymSocketManager.StopWithTimeout(time.Second))
+	require.False(t, nymSocketManager.IsRunning())
+}
+
+func TestNymSocketManagerStopWithTimeoutForced(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.hangAfterSelfAddress = true
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	stoppedAt := time.Now()
+	require.Equal(t, lib.StopForced, nymSocketManager.StopWithTimeout(100*time.Millisecond))
+	require.Less(t, time.Since(stoppedAt), time.Second)
+	require.False(t, nymSocketManager.IsRunning())
+}
+
+func TestNymSocketManagerUnixSocket(t *testing.T) {
+	logger := zerolog.Logger{}
+	socketPath := filepath.Join(t.TempDir(), "nym-client.sock")
+	newFakeNymClientOnUnixSocket(t, socketPath)
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger), lib.WithUnixSocket(socketPath))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+}
+
+func TestNymSocketManagerBufferSizes(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger), lib.WithBufferSizes(64<<10, 64<<10), lib.WithWriteBufferPool(&sync.Pool{}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	large := strings.Repeat("a", 256<<10)
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(large, fakeNymClientAddress)))
+	select {
+	case received := <-receivedChan:
+		require.Equal(t, large, received.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithBufferSizes(-1, 0))
+	require.Error(t, e)
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithWriteBufferPool(nil))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerCompression(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.compression = true
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger), lib.WithCompression(flate.BestSpeed))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Contains(t, fake.lastUpgradeHeader.Load().Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	large := strings.Repeat("a", 64<<10)
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(large, fakeNymClientAddress)))
+	select {
+	case received := <-receivedChan:
+		require.Equal(t, large, received.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithCompression(10))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerTLS(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClientWithTLS(t)
+	require.True(t, strings.HasPrefix(fake.URI(), "wss://"))
+
+	// The self-signed certificate is rejected by default
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.Error(t, e)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(fake.server.Certificate())
+	for _, option := range []lib.Option{lib.WithRootCAs(rootCAs), lib.WithInsecureSkipVerify()} {
+		nymSocketManager, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), option)
+		require.NoError(t, e)
+
+		_, e = nymSocketManager.Start()
+		require.NoError(t, e)
+		require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+		nymSocketManager.Stop()
+	}
+}
+
+func TestNymSocketManagerAuthenticationHeaders(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.requiredAuthorization = "Bearer secret"
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.Error(t, e)
+
+	for _, option := range []lib.Option{lib.WithBearerToken("secret"), lib.WithHeaders(http.Header{"Authorization": {"Bearer secret"}})} {
+		nymSocketManager, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), option)
+		require.NoError(t, e)
+
+		_, e = nymSocketManager.Start()
+		require.NoError(t, e)
+		nymSocketManager.Stop()
+	}
+}
+
+func TestNymSocketManagerCloseCode(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithCloseBehavior(lib.CloseBehavior{CloseCode: websocket.CloseGoingAway, IgnoreAbnormalClosure: true}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	require.Equal(t, lib.StopGraceful, nymSocketManager.StopWithTimeout(time.Second))
+
+	require.Eventually(t, func() bool {
+		return fake.lastCloseCode.Load() == websocket.CloseGoingAway
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNymSocketManagerSkipCloseFrame(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithCloseBehavior(lib.CloseBehavior{SkipCloseFrame: true}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	nymSocketManager.Stop()
+
+	require.Eventually(t, func() bool {
+		return fake.lastCloseCode.Load() == websocket.CloseAbnormalClosure
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestNymSocketManagerInvalidCloseCode(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithCloseBehavior(lib.CloseBehavior{CloseCode: 42}))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerAnonymousSendAndReply(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	answerChan := make(chan lib.NymReceived, 1)
+	var nymSocketManager *lib.NymSocketManager
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		if received.IsReplyable() {
+			require.NoError(t, nymSocketManager.Reply(received.SenderTag, "answer to "+received.Message))
+			return
+		}
+		answerChan <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.SendAnonymous("question", nymSocketManager.GetNymClientId(), 2))
+
+	select {
+	case answer := <-answerChan:
+		require.Equal(t, "answer to question", answer.Message)
+		require.False(t, answer.Binary)
+		require.False(t, answer.IsReplyable())
+	case <-time.After(time.Second):
+		require.Fail(t, "reply not received")
+	}
+
+	require.Error(t, nymSocketManager.Reply("", "no sender tag"))
+}
+
+func TestNymSocketManagerReplyFunction(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	answerChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, reply func(lib.NymMessage) error) {
+		if received.IsReplyable() {
+			// Without sender tag, the reply goes back through the SURBs of the received message
+			require.NoError(t, reply(lib.NewNymReply("", "answer to "+received.Message)))
+			return
+		}
+		answerChan <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.SendAnonymous("question", nymSocketManager.GetNymClientId(), 2))
+
+	select {
+	case answer := <-answerChan:
+		require.Equal(t, "answer to question", answer.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "reply not received")
+	}
+
+	// Neither sender tag nor return address to reply to
+	require.Error(t, nymSocketManager.ReplyTo(lib.NymReceived{Message: "addressed"}, "answer"))
+}
+
+func TestNymSocketManagerDefaultReplySurbs(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithDefaultReplySurbs(10))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The count of the message prevails over the default
+	require.NoError(t, nymSocketManager.SendAnonymous("hello", fakePeerAddress, 3))
+	require.Eventually(t, func() bool { return 3 == fake.lastReplySurbs.Load() }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSendAnonymous("hello", fakePeerAddress, 0)))
+	require.Eventually(t, func() bool { return 10 == fake.lastReplySurbs.Load() }, time.Second, 10*time.Millisecond)
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithDefaultReplySurbs(0))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerSendRaw(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	for _, options := range [][]lib.Option{nil, {lib.WithBinaryProtocol()}} {
+		nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), options...)
+		require.NoError(t, e)
+
+		rawChan := make(chan json.RawMessage, 1)
+		nymSocketManager.OnRawMessage(func(msg json.RawMessage) {
+			// The selfAddress reply of the handshake goes through the hooks too
+			if strings.Contains(string(msg), "laneQueueLength") {
+				rawChan <- append(json.RawMessage{}, msg...)
+			}
+		})
+
+		_, e = nymSocketManager.Start()
+		require.NoError(t, e)
+
+		require.Error(t, nymSocketManager.SendRaw(json.RawMessage(`{"type":`)))
+		require.NoError(t, nymSocketManager.SendRaw(json.RawMessage(`{"type":"getLaneQueueLength","connectionId":1}`)))
+
+		select {
+		case msg := <-rawChan:
+			require.JSONEq(t, `{"type":"laneQueueLength","lane":1,"queueLength":0}`, string(msg))
+		case <-time.After(time.Second):
+			require.Fail(t, "raw message not received")
+		}
+		nymSocketManager.Stop()
+	}
+}
+
+func TestNymSocketManagerSendValidatesRecipient(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.ErrorContains(t, nymSocketManager.Send(lib.NewNymSend("hello", "fakeClientID.fakeClientEncKey@fakeGateway")), "invalid Nym address")
+	require.Error(t, nymSocketManager.SendAnonymous("hello", "not an address", 1))
+	require.Zero(t, fake.sendRequests.Load())
+}
+
+func TestNymSocketManagerGetNymAddress(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.GetNymAddress()
+	require.Error(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	nymAddress, e := nymSocketManager.GetNymAddress()
+	require.NoError(t, e)
+	require.Equal(t, nymSocketManager.GetConnectedGateway(), nymAddress.GatewayID())
+}
+
+func BenchmarkNymSocketManagerSend(b *testing.B) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(b)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(b, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(b, e)
+	defer nymSocketManager.Stop()
+
+	msg := lib.NewNymSend(strings.Repeat("a", 1024), fakePeerAddress)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if e := nymSocketManager.Send(msg); nil != e {
+			b.Fatal(e)
+		}
+	}
+}
+
+func BenchmarkParseTextMessage(b *testing.B) {
+	logger := zerolog.Logger{}
+	nymSocketManager, e := lib.NewNymSocketManager("ws://127.0.0.1:1977", emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(b, e)
+
+	frame, e := json.Marshal(map[string]string{"type": "received", "message": strings.Repeat("a", 1024), "senderTag": fakeNymClientSenderTag})
+	require.NoError(b, e)
+
+	b.Run("envelope", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if nil == nymSocketManager.ParseTextMessage(frame) {
+				b.Fatal("message not parsed")
+			}
+		}
+	})
+
+	// As messages were parsed before, for comparison
+	b.Run("mapThenStruct", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fields := make(map[string]interface{})
+			var received lib.NymReceived
+			if nil != json.Unmarshal(frame, &fields) || nil != json.Unmarshal(frame, &received) {
+				b.Fatal("message not parsed")
+			}
+		}
+	})
+}
+
+func TestNymSocketManagerDefaultWriteTimeout(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.readsHeld = make(chan struct{})
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithDefaultWriteTimeout(200*time.Millisecond))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The fake nym-client does not read anymore: the write blocks once the socket buffers are full
+	sentAt := time.Now()
+	require.Error(t, nymSocketManager.Send(lib.NewNymSend(strings.Repeat("a", 32<<20), fakePeerAddress)))
+	require.Less(t, time.Since(sentAt), 5*time.Second)
+
+	// The connection is replaced, here by none as there is no other nym-client
+	require.Eventually(t, func() bool {
+		return nymSocketManager.GetState() == lib.StateStopped
+	}, time.Second, 10*time.Millisecond)
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithDefaultWriteTimeout(0))
+	require.Error(t, e)
+}
@@ -0,0 +1,837 @@
+package nymsocketmanager
+
+import (
+	"compress/flate"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/notrustverify/nymsocketmanager/address"
+	"github.com/notrustverify/nymsocketmanager/codec"
+	"golang.org/x/xerrors"
+)
+
+// Option configures an optional behaviour of the NymSocketManager
+type Option func(*NymSocketManager) error
+
+// WithFallbackURIs adds nym-clients to use, in the given order, when the previous ones are unreachable.
+// They are tried at Start as well as when the connection in use is lost
+func WithFallbackURIs(connectionURIs ...string) Option {
+	return func(n *NymSocketManager) error {
+		for _, connectionURI := range connectionURIs {
+			if len(connectionURI) == 0 {
+				return xerrors.Errorf("fallback connection URI cannot be empty")
+			}
+		}
+		n.connectionURIs = append(n.connectionURIs, connectionURIs...)
+		return nil
+	}
+}
+
+// WithSelfAddressTimeout sets how long to wait for the nym-client to answer a selfAddress request (defaults to 5 seconds)
+func WithSelfAddressTimeout(timeout time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if timeout <= 0 {
+			return xerrors.Errorf("selfAddress timeout must be positive, got %v", timeout)
+		}
+		n.selfAddressTimeout = timeout
+		return nil
+	}
+}
+
+// WithSelfAddressRetries sets how many times the selfAddress request is sent again when the nym-client does not answer in time (defaults to 0)
+func WithSelfAddressRetries(retries uint) Option {
+	return func(n *NymSocketManager) error {
+		n.selfAddressRetries = retries
+		return nil
+	}
+}
+
+// WithDrainTimeout sets how long Stop waits for the pending Sends to complete before closing the connection (defaults to 5 seconds)
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if timeout < 0 {
+			return xerrors.Errorf("drain timeout cannot be negative, got %v", timeout)
+		}
+		n.drainTimeout = timeout
+		return nil
+	}
+}
+
+// WithHandlerDrainTimeout sets how long Stop waits for the messageHandlers in progress to return, once their context is cancelled,
+// before draining the pending Sends and closing the connection, so that they can still reply (defaults to 5 seconds).
+// The messages received meanwhile are dropped. Note that a messageHandler calling Stop makes it wait for the whole timeout
+func WithHandlerDrainTimeout(timeout time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if timeout < 0 {
+			return xerrors.Errorf("handler drain timeout cannot be negative, got %v", timeout)
+		}
+		n.handlerDrainTimeout = timeout
+		return nil
+	}
+}
+
+// WithLazyConnection postpones the connection to the nym-client until the first Send, instead of opening it at Start.
+// If idleTimeout is not zero, the connection is closed once nothing was sent for that long, and opened again by the next Send.
+// Note that messages can only be received while the connection is open
+func WithLazyConnection(idleTimeout time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if idleTimeout < 0 {
+			return xerrors.Errorf("idle timeout cannot be negative, got %v", idleTimeout)
+		}
+		n.lazyConnection = true
+		n.idleTimeout = idleTimeout
+		return nil
+	}
+}
+
+// WithReplayBuffer keeps up to capacity messages sent while the connection is down (e.g. during a failover),
+// and sends them once the connection is restored. policy defines what happens when the buffer is full: with OverflowBlock,
+// Send waits for the connection to be restored. The messages dropped are counted in Stats.ReplayBufferDropped.
+// Buffered messages are dropped if the manager stops before the connection is restored
+func WithReplayBuffer(capacity int, policy OverflowPolicy) Option {
+	return func(n *NymSocketManager) error {
+		if capacity <= 0 {
+			return xerrors.Errorf("replay buffer capacity must be positive, got %d", capacity)
+		}
+		n.replayBuffer = &replayBuffer{
+			capacity: capacity,
+			policy:   policy,
+		}
+		return nil
+	}
+}
+
+// WithPauseBuffer keeps up to capacity messages received while dispatch is paused, 1024 by default, see Pause.
+// policy defines what happens when the buffer is full, OverflowDropOldest by default. OverflowBlock needs WithHandlerPool,
+// the reads from the nym-client being then held back until Resume. The messages dropped are counted in Stats.MessagesDropped
+func WithPauseBuffer(capacity int, policy OverflowPolicy) Option {
+	return func(n *NymSocketManager) error {
+		if capacity <= 0 {
+			return xerrors.Errorf("pause buffer capacity must be positive, got %d", capacity)
+		}
+		n.pauseGate.capacity = capacity
+		n.pauseGate.policy = policy
+		return nil
+	}
+}
+
+// WithReadStallWatchdog replaces the connection when nothing is read from the nym-client for threshold,
+// detecting gateways that stop delivering traffic without closing the socket.
+// As an idle connection looks stalled too, Ping can be called periodically to keep traffic flowing.
+// If onStall is defined, it is called instead of replacing the connection
+func WithReadStallWatchdog(threshold time.Duration, onStall func(idle time.Duration)) Option {
+	return func(n *NymSocketManager) error {
+		if threshold <= 0 {
+			return xerrors.Errorf("read-stall threshold must be positive, got %v", threshold)
+		}
+		n.readStallThreshold = threshold
+		n.onReadStall = onStall
+		return nil
+	}
+}
+
+// WithReadTimeout tells the idle connections from the dead ones: once nothing was read from a nym-client for idleTimeout,
+// the connection is probed with a ping, and replaced if nothing, not even the pong, is read within probeTimeout.
+// Unlike the read-stall watchdog, the idle connections are kept. See SocketListener.SetReadTimeout
+func WithReadTimeout(idleTimeout, probeTimeout time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if idleTimeout <= 0 || probeTimeout <= 0 {
+			return xerrors.Errorf("read timeouts must be positive, got %v and %v", idleTimeout, probeTimeout)
+		}
+		n.readIdleTimeout = idleTimeout
+		n.readProbeTimeout = probeTimeout
+		return nil
+	}
+}
+
+// WithMaxMessageSize bounds the size of the frames read from the nym-clients to limit bytes, protecting the memory from
+// pathological payloads. The larger frames are handled according to policy, and passed to onOversized if not nil, see SocketListener.SetMaxMessageSize.
+// Note that the chunks of the messages reassembled with WithChunking are bounded individually
+func WithMaxMessageSize(limit int64, policy OversizePolicy, onOversized func(size int64)) Option {
+	return func(n *NymSocketManager) error {
+		if limit <= 0 {
+			return xerrors.Errorf("maximum message size must be positive, got %d", limit)
+		}
+		if OversizeClose != policy && OversizeSkip != policy {
+			return xerrors.Errorf("unsupported oversize policy %v", policy)
+		}
+		n.maxMessageSize = limit
+		n.oversizePolicy = policy
+		n.onOversized = onOversized
+		return nil
+	}
+}
+
+// WithConnectionPool opens size connections to the nym-client instead of a single one, and spreads the Sends over them
+// so that they are not all serialised on the same connection. The nym-client must accept concurrent websocket connections.
+// Only the main connection is used for the clientID collection and the failover
+func WithConnectionPool(size int) Option {
+	return func(n *NymSocketManager) error {
+		if size < 1 {
+			err := xerrors.Errorf("connection pool size must be at least 1, got %d", size)
+			return err
+		}
+		n.pool.size = size
+		return nil
+	}
+}
+
+// WithRecipientSharding opens a connection to each of the nym-clients set with WithFallbackURIs besides the main one,
+// and spreads the Sends over them by the hash of their recipient, so that each conversation sticks to one nym-client.
+// Replies go through the nym-client which received the messages of their sender tag, holding their SURBs.
+// The recipients of the nym-clients which cannot be reached go through the main connection.
+// Note that each nym-client has its own address, which the peers see as the sender of the anonymous messages
+func WithRecipientSharding() Option {
+	return func(n *NymSocketManager) error {
+		n.shards.enabled = true
+		return nil
+	}
+}
+
+// WithUnixSocket connects to a nym-client listening on the Unix domain socket at socketPath instead of a TCP port.
+// The connection URIs are still used for the websocket handshake (e.g. "ws://localhost"), but all of them go through socketPath
+func WithUnixSocket(socketPath string) Option {
+	return func(n *NymSocketManager) error {
+		if len(socketPath) == 0 {
+			return xerrors.Errorf("unix socket path cannot be empty")
+		}
+		n.dialer.NetDialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+		return nil
+	}
+}
+
+// WithBufferSizes sets the size in bytes of the read and write buffers of the websocket connections, e.g. larger for large payloads.
+// A size of 0 keeps the default of gorilla/websocket (4096 bytes). The buffer sizes do not limit the size of the messages
+func WithBufferSizes(readBufferSize int, writeBufferSize int) Option {
+	return func(n *NymSocketManager) error {
+		if readBufferSize < 0 || writeBufferSize < 0 {
+			return xerrors.Errorf("buffer sizes cannot be negative, got %d and %d", readBufferSize, writeBufferSize)
+		}
+		n.dialer.ReadBufferSize = readBufferSize
+		n.dialer.WriteBufferSize = writeBufferSize
+		return nil
+	}
+}
+
+// WithWriteBufferPool shares the write buffers among the websocket connections, instead of holding one per connection, see websocket.Dialer
+func WithWriteBufferPool(pool websocket.BufferPool) Option {
+	return func(n *NymSocketManager) error {
+		if nil == pool {
+			return xerrors.Errorf("write buffer pool cannot be nil")
+		}
+		n.dialer.WriteBufferPool = pool
+		return nil
+	}
+}
+
+// WithCompression offers the nym-client to compress the messages with permessage-deflate, at the given flate level
+// (e.g. flate.BestSpeed), reducing the bandwidth used by large payloads. The messages are sent uncompressed if it declines
+func WithCompression(level int) Option {
+	return func(n *NymSocketManager) error {
+		if level < flate.HuffmanOnly || level > flate.BestCompression {
+			return xerrors.Errorf("invalid compression level %d", level)
+		}
+		n.dialer.EnableCompression = true
+		n.compressionLevel = level
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to reach nym-clients behind "wss://" URIs, e.g. behind a TLS-terminating reverse proxy.
+// The configuration is cloned, so that later changes of config do not apply. Other TLS options refine it and must come after
+func WithTLSConfig(config *tls.Config) Option {
+	return func(n *NymSocketManager) error {
+		if nil == config {
+			return xerrors.Errorf("TLS config cannot be nil")
+		}
+		n.dialer.TLSClientConfig = config.Clone()
+		return nil
+	}
+}
+
+// WithRootCAs sets the certificate authorities used to verify the nym-clients reached through "wss://" URIs, instead of the system ones
+func WithRootCAs(rootCAs *x509.CertPool) Option {
+	return func(n *NymSocketManager) error {
+		if nil == rootCAs {
+			return xerrors.Errorf("root CAs cannot be nil")
+		}
+		n.tlsConfig().RootCAs = rootCAs
+		return nil
+	}
+}
+
+// WithClientCertificates sets the certificates presented to the nym-clients reached through "wss://" URIs requiring mutual TLS
+func WithClientCertificates(certificates ...tls.Certificate) Option {
+	return func(n *NymSocketManager) error {
+		if len(certificates) == 0 {
+			return xerrors.Errorf("at least one client certificate is needed")
+		}
+		n.tlsConfig().Certificates = certificates
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify disables the verification of the certificates of the nym-clients reached through "wss://" URIs.
+// This makes the connection vulnerable to man-in-the-middle attacks, and is only meant for lab setups with self-signed certificates
+func WithInsecureSkipVerify() Option {
+	return func(n *NymSocketManager) error {
+		n.tlsConfig().InsecureSkipVerify = true
+		return nil
+	}
+}
+
+// tlsConfig returns the TLS configuration of the dialer, creating it if needed
+func (n *NymSocketManager) tlsConfig() *tls.Config {
+	if nil == n.dialer.TLSClientConfig {
+		n.dialer.TLSClientConfig = &tls.Config{}
+	}
+	return n.dialer.TLSClientConfig
+}
+
+// WithHeaders adds HTTP headers to the websocket upgrade requests, e.g. API keys expected by an authenticating gateway in front of the nym-client.
+// It can be used several times, the values of a header already set are extended
+func WithHeaders(header http.Header) Option {
+	return func(n *NymSocketManager) error {
+		if nil == n.requestHeader {
+			n.requestHeader = http.Header{}
+		}
+		for key, values := range header {
+			for _, value := range values {
+				n.requestHeader.Add(key, value)
+			}
+		}
+		return nil
+	}
+}
+
+// WithBearerToken sets the Authorization header of the websocket upgrade requests to the given bearer token
+func WithBearerToken(token string) Option {
+	return func(n *NymSocketManager) error {
+		if len(token) == 0 {
+			return xerrors.Errorf("bearer token cannot be empty")
+		}
+		if nil == n.requestHeader {
+			n.requestHeader = http.Header{}
+		}
+		n.requestHeader.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// WithBinaryProtocol sends the messages in binary frames, using the binary protocol of the nym-client instead of the JSON one.
+// Payloads are sent as is, which avoids the overhead of JSON for large ones. Binary and JSON frames are both accepted from the nym-client
+func WithBinaryProtocol() Option {
+	return WithWireEncoding(EncodingBinary)
+}
+
+// WithWireEncoding sets how the messages are written on the connection, unless overridden with WithEncoding
+func WithWireEncoding(encoding WireEncoding) Option {
+	return func(n *NymSocketManager) error {
+		if encoding < EncodingDefault || encoding > EncodingAuto {
+			return xerrors.Errorf("unknown wire encoding %v", encoding)
+		}
+		n.wireEncoding = encoding
+		return nil
+	}
+}
+
+// WithCodec sets how SendValue and DecodeValue encode the payloads, codec.JSON by default.
+// codec.Msgpack and codec.CBOR make smaller payloads, which are sent with the binary protocol
+func WithCodec(c codec.Codec) Option {
+	return func(n *NymSocketManager) error {
+		if nil == c {
+			return xerrors.Errorf("codec needs to be defined")
+		}
+		n.codec = c
+		return nil
+	}
+}
+
+// WithDefaultWriteTimeout bounds the time to write each message not sending with its own WithWriteTimeout,
+// so that a wedged nym-client cannot block Send, nor the messageHandlers replying, indefinitely.
+// The connection is replaced as if it was lost once a write timed out
+func WithDefaultWriteTimeout(timeout time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if timeout <= 0 {
+			return xerrors.Errorf("write timeout must be positive, got %v", timeout)
+		}
+		n.writeTimeout = timeout
+		return nil
+	}
+}
+
+// WithDefaultReplySurbs sets how many reply SURBs are attached to the anonymous messages sent with 0 of them,
+// so that request/response applications do not have to size each message. Each reply of the recipient consumes SURBs
+func WithDefaultReplySurbs(count uint) Option {
+	return func(n *NymSocketManager) error {
+		if 0 == count {
+			return xerrors.Errorf("default reply SURBs count must be positive")
+		}
+		n.defaultReplySurbs = count
+		return nil
+	}
+}
+
+// WithChunking lets SendLarge split messages larger than chunkSize bytes, and reassembles the chunked messages received.
+// An incomplete message is dropped when no chunk of it was received for reassemblyTimeout.
+// The chunk size should fit the plaintext of a Sphinx packet (around 2KB) to avoid the fragmentation by the nym-client
+func WithChunking(chunkSize int, reassemblyTimeout time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if chunkSize <= 0 || reassemblyTimeout <= 0 {
+			return xerrors.Errorf("chunk size and reassembly timeout must be positive, got %d and %v", chunkSize, reassemblyTimeout)
+		}
+		n.chunkSize = chunkSize
+		n.chunks.timeout = reassemblyTimeout
+		return nil
+	}
+}
+
+// WithCoverTraffic makes the manager send dummy messages while it is connected, so that observers of the traffic
+// cannot tell when real messages are exchanged. Dummy messages received by a NymSocketManager are dropped
+func WithCoverTraffic(coverTraffic CoverTraffic) Option {
+	return func(n *NymSocketManager) error {
+		if coverTraffic.Interval <= 0 {
+			return xerrors.Errorf("cover traffic interval must be positive, got %v", coverTraffic.Interval)
+		}
+		if coverTraffic.PayloadSize < 0 {
+			return xerrors.Errorf("cover traffic payload size cannot be negative, got %d", coverTraffic.PayloadSize)
+		}
+		if len(coverTraffic.Recipient) != 0 {
+			e := address.Validate(coverTraffic.Recipient)
+			if nil != e {
+				return e
+			}
+		}
+		n.coverTraffic = &coverTraffic
+		return nil
+	}
+}
+
+// WithProfiling sets runtime/pprof labels while the messageHandler runs, so that CPU profiles attribute the time spent
+// to the handler and to the type of the messages handled, see HandlerLabel and MessageTypeLabel
+func WithProfiling(profiling Profiling) Option {
+	return func(n *NymSocketManager) error {
+		if len(profiling.HandlerName) == 0 {
+			profiling.HandlerName = "messageHandler"
+		}
+		if nil == profiling.MessageType {
+			profiling.MessageType = routedMessageType
+		}
+		n.profiling = &profiling
+		return nil
+	}
+}
+
+// WithHandlerPool calls the messageHandler from a bounded pool of workers, instead of a new goroutine per message.
+// The messages are queued in the order they are received, so that a single worker handles them in that order.
+// With a HandlerPool.ShardKey, the messages of each sender keep that order while the others are handled concurrently.
+// The other messages of the nym-client (e.g. replies to the requests of the library) are not queued
+func WithHandlerPool(pool HandlerPool) Option {
+	return func(n *NymSocketManager) error {
+		if pool.Workers <= 0 {
+			return xerrors.Errorf("handler pool needs at least one worker, got %d", pool.Workers)
+		}
+		if pool.QueueLength < 0 {
+			return xerrors.Errorf("handler pool queue length cannot be negative, got %d", pool.QueueLength)
+		}
+		n.handlerPool = &handlerPool{config: pool}
+		return nil
+	}
+}
+
+// WithPriorityQueue orders the messages waiting for the connection, e.g. while it is slow, by their priority:
+// PriorityControl first, then PriorityHigh, then PriorityNormal, then PriorityBulk, see WithPriority.
+// Once starvationLimit messages of higher classes were sent while a message of a lower class waited, that message is sent next.
+// If starvationLimit is 0, DefaultStarvationLimit applies
+func WithPriorityQueue(starvationLimit int) Option {
+	return func(n *NymSocketManager) error {
+		if starvationLimit < 0 {
+			return xerrors.Errorf("starvation limit cannot be negative, got %d", starvationLimit)
+		}
+		if 0 == starvationLimit {
+			starvationLimit = DefaultStarvationLimit
+		}
+		n.sendQueue = &priorityQueue{starvationLimit: starvationLimit}
+		return nil
+	}
+}
+
+// WithDeduplication drops the messages received within window of a message with the same key, e.g. retransmitted by
+// the sender. The key identifies the messages by their content hash with ContentKey if nil, or e.g. by an ID they embed.
+// The messages dropped are counted in Stats.DuplicatesDropped
+func WithDeduplication(window time.Duration, key func(NymReceived) string) Option {
+	return func(n *NymSocketManager) error {
+		if window <= 0 {
+			return xerrors.Errorf("deduplication window must be positive, got %v", window)
+		}
+		if nil == key {
+			key = ContentKey
+		}
+		n.deduplicator = &deduplicator{window: window, key: key, seen: make(map[string]time.Time)}
+		return nil
+	}
+}
+
+// WithAsyncSend makes Send return once the message is queued, instead of once it is written on the connection.
+// A single goroutine writes the queued messages, so that the callers neither contend for the connection nor wait for the network.
+// The write errors are reported to the callback of WithCompletion. While queueLength messages are queued, Send waits
+// for room with OverflowBlock, fails with OverflowDropNewest, or drops the oldest message queued with OverflowDropOldest,
+// failing its completion. The messages dropped are counted in Stats.SendQueueDropped. The messages still queued when stopping are flushed first,
+// within the drain timeout (see WithDrainTimeout). Note that the messages queued when the connection is lost fail, even with WithReplayBuffer
+func WithAsyncSend(queueLength int, policy OverflowPolicy) Option {
+	return func(n *NymSocketManager) error {
+		if queueLength <= 0 {
+			return xerrors.Errorf("send queue length must be positive, got %d", queueLength)
+		}
+		if OverflowBlock != policy && OverflowDropNewest != policy && OverflowDropOldest != policy {
+			return xerrors.Errorf("send queue does not support the %v overflow policy", policy)
+		}
+		n.asyncSender = &asyncSender{queueLength: queueLength, policy: policy}
+		return nil
+	}
+}
+
+// WithWriteCoalescing gathers the messages sent within window after the first one, and writes them in a row on the connection.
+// This improves the throughput of bursty senders, at the cost of up to window of latency for each message
+func WithWriteCoalescing(window time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if window <= 0 {
+			return xerrors.Errorf("write coalescing window must be positive, got %v", window)
+		}
+		n.coalescer = &writeCoalescer{window: window}
+		return nil
+	}
+}
+
+// WithRateLimit limits the messages sent by the manager with a token bucket of the given rate and burst.
+// Send waits for its turn once the burst is used up. The messages of the library itself (e.g. cover traffic) are not limited
+func WithRateLimit(limit RateLimit) Option {
+	return func(n *NymSocketManager) error {
+		e := limit.validate()
+		if nil != e {
+			return e
+		}
+		n.rateLimiter.limit = &limit
+		return nil
+	}
+}
+
+// WithRecipientRateLimit limits the messages sent to each recipient, or as replies to each sender tag, like WithRateLimit
+func WithRecipientRateLimit(limit RateLimit) Option {
+	return func(n *NymSocketManager) error {
+		e := limit.validate()
+		if nil != e {
+			return e
+		}
+		n.rateLimiter.recipientLimit = &limit
+		return nil
+	}
+}
+
+// WithCongestionControl throttles the messages sent, slowing down each time the nym-client reports a congested lane
+// and recovering gradually afterwards, see CongestionControl. Like WithRateLimit, the messages of the library are not throttled
+func WithCongestionControl(config CongestionControl) Option {
+	return func(n *NymSocketManager) error {
+		config, e := config.withDefaults()
+		if nil != e {
+			return e
+		}
+		n.throttle = newCongestionThrottle(config)
+		return nil
+	}
+}
+
+// WithTracing starts a span with tracer around each Send, each dispatch of a message received and each call of the messageHandler,
+// see SendSpanName, DispatchSpanName and HandleSpanName. The context given to the messageHandler holds its span
+func WithTracing(tracer Tracer) Option {
+	return func(n *NymSocketManager) error {
+		if nil == tracer {
+			return xerrors.Errorf("tracer cannot be nil")
+		}
+		n.tracer = tracer
+		return nil
+	}
+}
+
+// WithTracePropagation carries the trace context of the Sends along with their payload, and continues the trace of the messages
+// received with one, so that a trace follows a request across the mixnet. The header is stripped from the messages received
+// whether set or not. It needs WithTracing
+func WithTracePropagation() Option {
+	return func(n *NymSocketManager) error {
+		n.tracePropagation = true
+		return nil
+	}
+}
+
+// WithErrorHistory sets how many of the last errors ErrorHistory returns, DefaultErrorHistorySize by default
+func WithErrorHistory(size int) Option {
+	return func(n *NymSocketManager) error {
+		if size < 1 {
+			return xerrors.Errorf("error history size must be at least 1, got %d", size)
+		}
+		n.errors.records = make([]ErrorRecord, 0, size)
+		return nil
+	}
+}
+
+// WithTapRedaction passes the frames through redact before the taps registered with RegisterTap, e.g. RedactPayloads.
+// redact must not modify raw, and returns the frame given to the taps
+func WithTapRedaction(redact func(direction Direction, raw []byte) []byte) Option {
+	return func(n *NymSocketManager) error {
+		if nil == redact {
+			return xerrors.Errorf("tap redaction cannot be nil")
+		}
+		n.taps.redact = redact
+		return nil
+	}
+}
+
+// WithLatencyProbe makes the manager send probes to its own address while it is connected, to estimate the round-trip time,
+// its jitter and the share of the messages lost through the mixnet. The estimates are in Stats, and each probe yields a ProbeEvent
+func WithLatencyProbe(probe LatencyProbe) Option {
+	return func(n *NymSocketManager) error {
+		if probe.Interval <= 0 {
+			return xerrors.Errorf("latency probe interval must be positive, got %v", probe.Interval)
+		}
+		if probe.Timeout < 0 {
+			return xerrors.Errorf("latency probe timeout cannot be negative, got %v", probe.Timeout)
+		}
+		if 0 == probe.Timeout {
+			probe.Timeout = DefaultProbeTimeout
+		}
+		id, e := newRandomID()
+		if nil != e {
+			return e
+		}
+		n.probe = &latencyProbe{config: probe, id: id}
+		return nil
+	}
+}
+
+// WithConnectionQuality estimates the quality of the connection to the nym-client from the round-trip time of periodic websocket pings,
+// the latency of the writes and the share of both failing. The Quality is returned by Quality and in Stats, and its changes yield a
+// QualityChangedEvent. With ReplaceWhenBad, a connection whose quality became bad is replaced
+func WithConnectionQuality(quality ConnectionQuality) Option {
+	return func(n *NymSocketManager) error {
+		if quality.PingInterval < 0 {
+			return xerrors.Errorf("connection quality ping interval cannot be negative, got %v", quality.PingInterval)
+		}
+		quality = quality.withDefaults()
+		if quality.DegradedRTT > quality.BadRTT || quality.DegradedWriteLatency > quality.BadWriteLatency ||
+			quality.DegradedErrorRate > quality.BadErrorRate {
+			return xerrors.Errorf("connection quality degraded thresholds cannot exceed the bad ones")
+		}
+		n.quality = &qualityEstimator{config: quality}
+		return nil
+	}
+}
+
+// WithAuditLog records the metadata of the messages sent and received in a JSON Lines file, e.g. for compliance: the type, recipient or
+// sender tag and size of the payload, and its SHA-256 if HashPayloads is set, but never the payload itself. Cover traffic and latency probes
+// are not recorded. The file is rotated once it reaches MaxSize or MaxAge. Recording can be toggled with EnableAuditLog and DisableAuditLog
+func WithAuditLog(audit AuditLog) Option {
+	return func(n *NymSocketManager) error {
+		if "" == audit.Path {
+			return xerrors.Errorf("audit log path cannot be empty")
+		}
+		if audit.MaxSize < 0 || audit.MaxAge < 0 || audit.MaxBackups < 0 {
+			return xerrors.Errorf("audit log limits cannot be negative, got %+v", audit)
+		}
+		if 0 == audit.MaxSize {
+			audit.MaxSize = DefaultAuditLogMaxSize
+		}
+		n.audit = &auditLog{config: audit, enabled: true}
+		return nil
+	}
+}
+
+// WithLogSampling keeps only some of the Debug and Trace logs written for each message sent or received, e.g. so that verbose logging
+// can stay enabled in production through traffic spikes. The logs of the lifecycle, and the ones of level Info and above, are all kept
+func WithLogSampling(sampling LogSampling) Option {
+	return func(n *NymSocketManager) error {
+		if sampling.Every < 1 {
+			return xerrors.Errorf("log sampling must keep 1 in at least 1 logs, got %d", sampling.Every)
+		}
+		if sampling.Burst > 0 && sampling.Period <= 0 {
+			return xerrors.Errorf("log sampling period must be positive with a burst, got %v", sampling.Period)
+		}
+		n.logSampler = sampling.sampler()
+		n.messageLogger = n.logger.sampled(n.logSampler)
+		return nil
+	}
+}
+
+// WithExpvar publishes the Stats and the HealthStatus of the manager under name with the expvar package, and thus on /debug/vars
+// once its handler is mounted, for the environments without Prometheus. The expvar package has no way to unpublish a variable:
+// the name stays taken, and the manager referenced, for the lifetime of the process. It is published once the manager is created
+func WithExpvar(name string) Option {
+	return func(n *NymSocketManager) error {
+		if "" == name {
+			return xerrors.Errorf("expvar name cannot be empty")
+		}
+		n.expvarName = name
+		return nil
+	}
+}
+
+// WithDropHandler calls onDrop for each message dropped by the manager, received or sent, e.g. to keep the frames which could not be
+// decoded. It is called synchronously by the manager: it must return quickly, and copy raw to keep it
+func WithDropHandler(onDrop DropHandler) Option {
+	return func(n *NymSocketManager) error {
+		if nil == onDrop {
+			return xerrors.Errorf("drop handler cannot be nil")
+		}
+		n.dropHandler = onDrop
+		return nil
+	}
+}
+
+// WithSlowHandlerDetection logs a warning, counts Stats.SlowHandlerCalls and sends a SlowHandlerEvent for each call of the messageHandler
+// taking longer than slowHandler.Threshold, with the type and size of the message, to find the handler stalling the dispatch
+func WithSlowHandlerDetection(slowHandler SlowHandler) Option {
+	return func(n *NymSocketManager) error {
+		if slowHandler.Threshold <= 0 {
+			return xerrors.Errorf("slow handler threshold must be positive, got %v", slowHandler.Threshold)
+		}
+		if nil == slowHandler.MessageType {
+			slowHandler.MessageType = routedMessageType
+		}
+		n.slowHandler = &slowHandler
+		return nil
+	}
+}
+
+// WithPayloadRedaction keeps the payloads of the messages out of the logs and events, e.g. the "got:" Debug log of each message received,
+// describing them by their size and SHA-256 instead. The payloads are still passed to the messageHandler, the hooks and the DropHandler
+func WithPayloadRedaction() Option {
+	return func(n *NymSocketManager) error {
+		n.redactPayloads = true
+		return nil
+	}
+}
+
+// WithClientEncoding makes Start fail with ErrUnsupportedEncoding unless the nym-client answers with the given encoding
+func WithClientEncoding(encoding ClientEncoding) Option {
+	return func(n *NymSocketManager) error {
+		if encoding != ClientEncodingJSON && encoding != ClientEncodingBinary {
+			return xerrors.Errorf("unsupported client encoding %v", encoding)
+		}
+		n.requiredClientEncoding = encoding
+		return nil
+	}
+}
+
+// WithSurbManagement estimates the reply SURBs left to answer each peer sending anonymous messages, see GetSurbBudget.
+// Once a peer runs low, Reply asks it for more, which another NymSocketManager with SURB management answers automatically
+func WithSurbManagement(surbManagement SurbManagement) Option {
+	return func(n *NymSocketManager) error {
+		if 0 == surbManagement.SurbsPerMessage || 0 == surbManagement.TopUp {
+			return xerrors.Errorf("reply SURBs per message and top-up must be positive")
+		}
+		n.surbManagement = &surbManagement
+		return nil
+	}
+}
+
+// WithBroadcastRate limits the number of messages per second sent by SendToMany, to avoid flooding the nym-client
+func WithBroadcastRate(messagesPerSecond float64) Option {
+	return func(n *NymSocketManager) error {
+		if messagesPerSecond <= 0 {
+			return xerrors.Errorf("broadcast rate must be positive, got %v", messagesPerSecond)
+		}
+		n.broadcastRate = messagesPerSecond
+		return nil
+	}
+}
+
+// WithSchemaValidation checks the incoming messages against the schemas of the nym-client API,
+// rejecting unknown fields and wrong types, to catch protocol drift early. policy defines what happens to the invalid ones
+func WithSchemaValidation(policy SchemaPolicy) Option {
+	return func(n *NymSocketManager) error {
+		if policy < SchemaPolicyLog || policy > SchemaPolicyDeliver {
+			return xerrors.Errorf("unknown schema policy %v", policy)
+		}
+		n.schemaValidation = true
+		n.schemaPolicy = policy
+		return nil
+	}
+}
+
+// WithReconnectPolicy keeps replacing a lost connection according to policy, one attempt being a pass over the connection URIs,
+// instead of stopping the manager once a pass failed. The connection is then replaced even if there is a single connection URI.
+// The progress is reported with ReconnectAttemptEvent and ReconnectDoneEvent, see SubscribeReconnects
+func WithReconnectPolicy(policy RetryPolicy) Option {
+	return func(n *NymSocketManager) error {
+		e := policy.Validate()
+		if nil != e {
+			return e
+		}
+		n.reconnectPolicy = &policy
+		return nil
+	}
+}
+
+// WithWaitForReady makes Start poll the nym-clients every pollInterval until one of them accepts the connection,
+// for at most maxWait, instead of failing on the first attempt. This suits services booting together with their nym-client.
+// It replaces any policy set with WithStartupRetryPolicy
+func WithWaitForReady(maxWait time.Duration, pollInterval time.Duration) Option {
+	return func(n *NymSocketManager) error {
+		if maxWait <= 0 || pollInterval <= 0 {
+			return xerrors.Errorf("wait for ready durations must be positive, got %v and %v", maxWait, pollInterval)
+		}
+		n.readyMaxWait = maxWait
+		n.startupRetryPolicy = &RetryPolicy{
+			BaseDelay: pollInterval,
+			MaxDelay:  pollInterval,
+		}
+		return nil
+	}
+}
+
+// WithStartupRetryPolicy makes Start retry the connection and the clientID collection according to policy,
+// instead of failing on the first error. It replaces the polling set with WithWaitForReady, but keeps its max wait
+func WithStartupRetryPolicy(policy RetryPolicy) Option {
+	return func(n *NymSocketManager) error {
+		e := policy.Validate()
+		if nil != e {
+			return e
+		}
+		n.startupRetryPolicy = &policy
+		return nil
+	}
+}
+
+// CloseBehavior defines how the connection to the nym-client is closed. Its zero value is the default behaviour
+type CloseBehavior struct {
+	// Close the connection without sending a close frame first, nor waiting for the confirmation
+	SkipCloseFrame bool
+	// Code of the close frame, defaults to websocket.CloseNormalClosure
+	CloseCode int
+	// Maximum time to wait for the socketListener to confirm the closure when no deadline is provided, defaults to 5 seconds
+	Timeout time.Duration
+	// Consider the abnormal closure reported by gorilla/websocket after a requested closure as a normal one
+	// (ref: https://github.com/gorilla/websocket/pull/487)
+	IgnoreAbnormalClosure bool
+}
+
+// WithCloseBehavior sets how the connection to the nym-client is closed
+func WithCloseBehavior(behavior CloseBehavior) Option {
+	return func(n *NymSocketManager) error {
+		if behavior.Timeout < 0 {
+			return xerrors.Errorf("close timeout cannot be negative, got %v", behavior.Timeout)
+		}
+		if 0 != behavior.CloseCode && (behavior.CloseCode < 1000 || behavior.CloseCode > 4999) {
+			return xerrors.Errorf("invalid close code %d", behavior.CloseCode)
+		}
+		n.closeBehavior = behavior
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package nymsocketmanager
+
+import (
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// pauseGate holds back the messages to handle while dispatch is paused
+type pauseGate struct {
+	sync.Mutex
+
+	paused   bool
+	capacity int
+	policy   OverflowPolicy
+	// Messages received while paused, by order of reception
+	held []NymReceived
+	// Set while the messages held are handed over after Resume, the ones received meanwhile being held behind them
+	draining bool
+	// Signaled when room is made in the buffer, or when the manager stops, nil until needed
+	roomCond *sync.Cond
+}
+
+// Pause stops calling the messageHandler, keeping the connection to the nym-client open.
+// The messages received meanwhile are held in order for Resume, up to the capacity of the pause buffer, see WithPauseBuffer.
+// The messages still held are dropped if the manager stops
+func (n *NymSocketManager) Pause() {
+	n.pauseGate.Lock()
+	defer n.pauseGate.Unlock()
+
+	if !n.pauseGate.paused {
+		n.pauseGate.paused = true
+		n.logger.Debug().Msg("paused dispatch")
+	}
+}
+
+// Resume calls the messageHandler again, starting with the messages received while paused, in order
+func (n *NymSocketManager) Resume() {
+	n.pauseGate.Lock()
+	defer n.pauseGate.Unlock()
+
+	if n.pauseGate.paused {
+		n.pauseGate.paused = false
+		if len(n.pauseGate.held) > 0 && !n.pauseGate.draining {
+			n.pauseGate.draining = true
+			go n.handOverHeld()
+		}
+		n.logger.Debug().Msgf("resumed dispatch, %d message(s) held", len(n.pauseGate.held))
+	}
+}
+
+// IsPaused tells whether dispatch is paused, see Pause
+func (n *NymSocketManager) IsPaused() bool {
+	n.pauseGate.Lock()
+	defer n.pauseGate.Unlock()
+	return n.pauseGate.paused
+}
+
+// holdWhilePaused keeps received for Resume if dispatch is paused, or if the messages held are being handed over,
+// applying the overflow policy of the pause buffer. It returns false if received is to be handled now
+func (n *NymSocketManager) holdWhilePaused(received NymReceived) bool {
+	n.pauseGate.Lock()
+	defer n.pauseGate.Unlock()
+
+	for n.pauseGate.holding() && OverflowBlock == n.pauseGate.policy && len(n.pauseGate.held) >= n.pauseGate.capacity && n.started.Load() {
+		n.pauseGate.room().Wait()
+	}
+	if !n.pauseGate.holding() {
+		return false
+	}
+	if !n.started.Load() {
+		n.logger.Debug().Str(MessageIDField, received.MessageID).Msg("dropping message received while paused, the manager stopped")
+		n.messageDropped(DropStopping, received, received.MessageID, nil, "stopped while paused")
+		return true
+	}
+
+	if len(n.pauseGate.held) >= n.pauseGate.capacity {
+		dropped := received
+		if OverflowDropOldest == n.pauseGate.policy {
+			dropped = n.pauseGate.held[0]
+			n.pauseGate.held = append(n.pauseGate.held[1:], received)
+		}
+		err := xerrors.Errorf("pause buffer is full (%d messages), dropping message received", n.pauseGate.capacity)
+		n.logger.Warn().Str(MessageIDField, dropped.MessageID).Msg(err.Error())
+		n.messageDropped(DropPauseBuffer, dropped, dropped.MessageID, nil, err.Error())
+		return true
+	}
+	n.pauseGate.held = append(n.pauseGate.held, received)
+	return true
+}
+
+// handOverHeld hands the messages held over to the messageHandler in order, until none is left or dispatch is paused again
+func (n *NymSocketManager) handOverHeld() {
+	for {
+		n.pauseGate.Lock()
+		if n.pauseGate.paused || 0 == len(n.pauseGate.held) {
+			n.pauseGate.draining = false
+			n.pauseGate.signalRoom()
+			n.pauseGate.Unlock()
+			return
+		}
+		received := n.pauseGate.held[0]
+		n.pauseGate.held = n.pauseGate.held[1:]
+		n.pauseGate.signalRoom()
+		n.pauseGate.Unlock()
+
+		n.handOver(received, true)
+	}
+}
+
+// dropPaused drops the messages held, the manager stopping
+// called from methods that already acquired the lock
+func (n *NymSocketManager) dropPaused() {
+	n.pauseGate.Lock()
+	defer n.pauseGate.Unlock()
+
+	for _, received := range n.pauseGate.held {
+		n.messageDropped(DropStopping, received, received.MessageID, nil, "stopped while paused")
+	}
+	if len(n.pauseGate.held) > 0 {
+		n.logger.Debug().Msgf("dropped %d message(s) received while paused, the manager stopped", len(n.pauseGate.held))
+	}
+	n.pauseGate.held = nil
+	n.pauseGate.signalRoom()
+}
+
+// holding tells whether the messages received are to be held
+// called with the gate locked
+func (g *pauseGate) holding() bool {
+	return g.paused || g.draining
+}
+
+// room returns the condition signaled when room is made in the buffer
+// called with the gate locked
+func (g *pauseGate) room() *sync.Cond {
+	if nil == g.roomCond {
+		g.roomCond = sync.NewCond(&g.Mutex)
+	}
+	return g.roomCond
+}
+
+// signalRoom wakes up the deliveries waiting for room in the buffer, if any
+// called with the gate locked
+func (g *pauseGate) signalRoom() {
+	if nil != g.roomCond {
+		g.roomCond.Broadcast()
+	}
+}
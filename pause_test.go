@@ -0,0 +1,123 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerPauseResume(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan string, 2)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received.Message
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	nymSocketManager.Pause()
+	require.True(t, nymSocketManager.IsPaused())
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("held", nymSocketManager.GetNymClientId())))
+
+	select {
+	case message := <-receivedChan:
+		require.Fail(t, "message handled while paused", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// The connection stays up meanwhile
+	require.True(t, nymSocketManager.IsReady())
+
+	nymSocketManager.Resume()
+	require.False(t, nymSocketManager.IsPaused())
+	select {
+	case message := <-receivedChan:
+		require.Equal(t, "held", message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled once resumed")
+	}
+}
+
+func TestNymSocketManagerPausedMessagesDroppedOnStop(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan string, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received.Message
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	nymSocketManager.Pause()
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("held", nymSocketManager.GetNymClientId())))
+	require.Eventually(t, func() bool { return 1 == fake.sendRequests.Load() }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	nymSocketManager.Stop()
+
+	nymSocketManager.Resume()
+	select {
+	case message := <-receivedChan:
+		require.Fail(t, "message handled after stop", message)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNymSocketManagerPauseBuffer(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan string, 5)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received.Message
+	}, lib.ZerologLogger(&logger), lib.WithHandlerPool(lib.HandlerPool{Workers: 1}), lib.WithPauseBuffer(3, lib.OverflowDropNewest))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	nymSocketManager.Pause()
+	for _, message := range []string{"first", "second", "third", "fourth", "fifth"} {
+		fake.deliver(map[string]interface{}{"type": "received", "message": message})
+	}
+	require.Eventually(t, func() bool {
+		return 2 == nymSocketManager.Stats().MessagesDropped[lib.DropPauseBuffer]
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 3, nymSocketManager.Stats().PausedMessages)
+
+	// Handled in the order they were received
+	nymSocketManager.Resume()
+	for _, expected := range []string{"first", "second", "third"} {
+		select {
+		case message := <-receivedChan:
+			require.Equal(t, expected, message)
+		case <-time.After(time.Second):
+			require.Fail(t, "message not handled once resumed")
+		}
+	}
+	require.Empty(t, receivedChan)
+	require.Zero(t, nymSocketManager.Stats().PausedMessages)
+}
+
+func TestNymSocketManagerPauseBufferBlockNeedsHandlerPool(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger), lib.WithPauseBuffer(8, lib.OverflowBlock))
+	require.Error(t, e)
+	_, e = lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger), lib.WithPauseBuffer(0, lib.OverflowDropOldest))
+	require.Error(t, e)
+
+	_, e = lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithPauseBuffer(8, lib.OverflowBlock), lib.WithHandlerPool(lib.HandlerPool{Workers: 1}))
+	require.NoError(t, e)
+}
@@ -0,0 +1,28 @@
+package nymsocketmanager
+
+import "golang.org/x/xerrors"
+
+// SendValue encodes v with the codec of the manager, codec.JSON unless set with WithCodec, and sends it to recipient.
+// The payloads of the binary codecs are not valid UTF-8: unless a wire encoding is set, they are sent with EncodingAuto
+func (n *NymSocketManager) SendValue(recipient string, v interface{}, options ...SendOption) error {
+	payload, e := n.codec.Marshal(v)
+	if nil != e {
+		err := xerrors.Errorf("failed to encode payload with %v: %v", n.codec.Name(), e)
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	if EncodingDefault == n.wireEncoding {
+		options = append([]SendOption{WithEncoding(EncodingAuto)}, options...)
+	}
+	return n.SendWithOptions(NewNymSend(string(payload), recipient), options...)
+}
+
+// DecodeValue decodes the payload of received into v with the codec of the manager, see SendValue
+func (n *NymSocketManager) DecodeValue(received NymReceived, v interface{}) error {
+	e := n.codec.Unmarshal([]byte(received.Message), v)
+	if nil != e {
+		return xerrors.Errorf("failed to decode payload with %v: %w", n.codec.Name(), e)
+	}
+	return nil
+}
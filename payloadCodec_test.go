@@ -0,0 +1,60 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/notrustverify/nymsocketmanager/codec"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+type codecPayload struct {
+	Kind  string `json:"kind"`
+	Count int    `json:"count"`
+	Raw   []byte `json:"raw"`
+}
+
+func TestNymSocketManagerSendValue(t *testing.T) {
+	for _, c := range []codec.Codec{codec.JSON, codec.Msgpack, codec.CBOR} {
+		logger := zerolog.Logger{}
+		fake := newFakeNymClient(t)
+
+		receivedChan := make(chan lib.NymReceived, 1)
+		nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+			receivedChan <- received
+		}, lib.ZerologLogger(&logger), lib.WithCodec(c))
+		require.NoError(t, e)
+
+		_, e = nymSocketManager.Start()
+		require.NoError(t, e)
+
+		sent := codecPayload{Kind: "ping", Count: 3, Raw: []byte{0xff, 0x00}}
+		require.NoError(t, nymSocketManager.SendValue(nymSocketManager.GetNymClientId(), sent))
+
+		select {
+		case received := <-receivedChan:
+			require.Equal(t, codec.JSON != c, received.Binary, c.Name())
+			var decoded codecPayload
+			require.NoError(t, nymSocketManager.DecodeValue(received, &decoded), c.Name())
+			require.Equal(t, sent, decoded, c.Name())
+		case <-time.After(time.Second):
+			require.Fail(t, "message not received", c.Name())
+		}
+		nymSocketManager.Stop()
+	}
+}
+
+func TestNymSocketManagerSendValueInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1:1977", emptyProcessing, lib.ZerologLogger(&logger), lib.WithCodec(nil))
+	require.Error(t, e)
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://127.0.0.1:1977", emptyProcessing, lib.ZerologLogger(&logger), lib.WithCodec(codec.CBOR))
+	require.NoError(t, e)
+	require.ErrorContains(t, nymSocketManager.SendValue(fakePeerAddress, make(chan int)), "cbor")
+
+	var decoded codecPayload
+	require.ErrorContains(t, nymSocketManager.DecodeValue(lib.NymReceived{Message: "{}"}, &decoded), "cbor")
+}
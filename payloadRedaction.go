@@ -0,0 +1,62 @@
+package nymsocketmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// redactedPayload describes payload by its size and SHA-256 instead of its content, see WithPayloadRedaction
+func redactedPayload(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return fmt.Sprintf("<%d bytes, sha256 %s>", len(payload), hex.EncodeToString(sum[:]))
+}
+
+// loggableFrame returns frame as it can be logged, described by redactedPayload if redact is set
+func loggableFrame(frame []byte, redact bool) string {
+	if redact {
+		return redactedPayload(frame)
+	}
+	return string(frame)
+}
+
+// redactMessage returns a copy of msg with its payload described by redactedPayload, msg itself if it carries none
+func redactMessage(msg NymMessage) NymMessage {
+	switch m := msg.(type) {
+	case NymSend:
+		m.Message = redactedPayload([]byte(m.Message))
+		return m
+	case NymSendAnonymous:
+		m.Message = redactedPayload([]byte(m.Message))
+		return m
+	case NymReply:
+		m.Message = redactedPayload([]byte(m.Message))
+		return m
+	case NymReceived:
+		m.Message = redactedPayload([]byte(m.Message))
+		if nil != m.Envelope {
+			// The content type and correlation ID are kept to follow the message, the other headers may be as sensitive as the body
+			envelope := Envelope{ContentType: m.Envelope.ContentType, CorrelationID: m.Envelope.CorrelationID, Body: redactedPayload([]byte(m.Envelope.Body))}
+			m.Envelope = &envelope
+		}
+		return m
+	case NymControlMessage:
+		m.Raw = nil
+		return m
+	default:
+		return msg
+	}
+}
+
+// loggable returns msg as it can be written to the logs and events, without its payload if payloads are redacted
+func (n *NymSocketManager) loggable(msg NymMessage) NymMessage {
+	if !n.redactPayloads {
+		return msg
+	}
+	return redactMessage(msg)
+}
+
+// SetPayloadRedaction makes the SocketListener log the size and SHA-256 of the frames read instead of their content
+func (s *SocketListener) SetPayloadRedaction(redact bool) {
+	s.redactPayloads = redact
+}
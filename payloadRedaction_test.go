@@ -0,0 +1,56 @@
+package nymsocketmanager_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerPayloadRedaction(t *testing.T) {
+	output := &lockedBuffer{}
+	logger := zerolog.New(output).Level(zerolog.TraceLevel)
+	fake := newFakeNymClient(t)
+
+	handled := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- received
+	}, lib.ZerologLogger(&logger), lib.WithPayloadRedaction())
+	require.NoError(t, e)
+	nymSocketManager.AddFilter("noDrop", func(received lib.NymReceived) bool { return "drop me" != received.Message })
+	events := nymSocketManager.Events()
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("my secret", nymSocketManager.GetNymClientId())))
+	select {
+	case received := <-handled:
+		// The messageHandler still gets the payload
+		require.Equal(t, "my secret", received.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("drop me", nymSocketManager.GetNymClientId())))
+	var dropped lib.MessageDroppedEvent
+	for {
+		var ok bool
+		if dropped, ok = nextEvent(t, events).(lib.MessageDroppedEvent); ok {
+			break
+		}
+	}
+	sum := sha256.Sum256([]byte("drop me"))
+	require.Equal(t, "<7 bytes, sha256 "+hex.EncodeToString(sum[:])+">", dropped.Message.(lib.NymReceived).Message)
+
+	sum = sha256.Sum256([]byte("my secret"))
+	require.Equal(t, 2, output.count("got: "))
+	require.Equal(t, 0, output.count("my secret"))
+	require.Equal(t, 0, output.count("drop me"))
+	require.Positive(t, output.count(hex.EncodeToString(sum[:])))
+}
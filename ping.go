@@ -0,0 +1,92 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/xerrors"
+)
+
+// pingTracker matches the pongs received from the nym-client with the pings waiting for them
+type pingTracker struct {
+	sync.Mutex
+
+	counter uint64
+	waiting map[string]chan struct{}
+}
+
+// register returns the payload of a new ping, and the channel closed when its pong is received
+func (p *pingTracker) register() (string, chan struct{}) {
+	p.Lock()
+	defer p.Unlock()
+
+	if nil == p.waiting {
+		p.waiting = make(map[string]chan struct{})
+	}
+
+	p.counter++
+	payload := strconv.FormatUint(p.counter, 10)
+	pongChan := make(chan struct{})
+	p.waiting[payload] = pongChan
+	return payload, pongChan
+}
+
+func (p *pingTracker) unregister(payload string) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.waiting, payload)
+}
+
+// pongReceived is used as pong handler on the connection, it is called from the socketListener
+func (p *pingTracker) pongReceived(payload string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if pongChan, ok := p.waiting[payload]; ok {
+		close(pongChan)
+		delete(p.waiting, payload)
+	}
+	return nil
+}
+
+// Ping sends a websocket ping to the nym-client and returns the time it took to receive the pong.
+// It can be used as a health-check of the connection. The wait for the pong is aborted when ctx is done
+func (n *NymSocketManager) Ping(ctx context.Context) (time.Duration, error) {
+	n.senderMutex.Lock()
+	connection := n.connection
+	n.senderMutex.Unlock()
+
+	if nil == connection {
+		err := xerrors.Errorf("cannot ping the nym-client: %w", n.disconnectedMode())
+		n.logger.Warn().Msg(err.Error())
+		return 0, err
+	}
+
+	payload, pongChan := n.pings.register()
+	defer n.pings.unregister(payload)
+
+	// WriteControl can be called concurrently with the other writes, no need for senderMutex
+	deadline, _ := ctx.Deadline()
+	sentAt := time.Now()
+	e := connection.WriteControl(websocket.PingMessage, []byte(payload), deadline)
+	if nil != e {
+		err := xerrors.Errorf("failed to send ping: %v", e)
+		n.logger.Warn().Msg(err.Error())
+		return 0, err
+	}
+
+	select {
+	case <-pongChan:
+		latency := time.Since(sentAt)
+		n.logger.Trace().Msgf("got pong after %v", latency)
+		return latency, nil
+
+	case <-ctx.Done():
+		err := xerrors.Errorf("no pong received from the nym-client: %w", ctx.Err())
+		n.logger.Warn().Msg(err.Error())
+		return 0, err
+	}
+}
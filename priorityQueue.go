@@ -0,0 +1,119 @@
+package nymsocketmanager
+
+import (
+	"sync"
+)
+
+// Default number of consecutive messages of higher classes sent while a message of a lower class waits, see WithPriorityQueue
+const DefaultStarvationLimit = 8
+
+// Classes of the priority queue, the highest first
+const (
+	controlClass = iota
+	highClass
+	interactiveClass
+	bulkClass
+	classCount
+)
+
+// priorityQueue orders the messages waiting for the connection by class of priority, see WithPriorityQueue
+type priorityQueue struct {
+	sync.Mutex
+
+	starvationLimit int
+	classes         [classCount][]*queuedMessage
+	// Messages of higher classes sent since the first message of each class started waiting
+	skipped [classCount]int
+	// Whether a goroutine is draining the queue
+	draining bool
+}
+
+type queuedMessage struct {
+	msg     NymMessage
+	options *sendOptions
+	done    chan error
+}
+
+// classOf returns the class of a message sent with options, which can be nil
+func classOf(options *sendOptions) int {
+	if nil == options {
+		return interactiveClass
+	}
+	switch options.priority {
+	case PriorityControl:
+		return controlClass
+	case PriorityHigh:
+		return highClass
+	case PriorityBulk:
+		return bulkClass
+	default:
+		return interactiveClass
+	}
+}
+
+// write queues a message and waits until it is written with write, by a goroutine draining the queue
+func (q *priorityQueue) write(msg NymMessage, options *sendOptions, write func(NymMessage, *sendOptions) error) error {
+	queued := &queuedMessage{msg, options, make(chan error, 1)}
+
+	q.Lock()
+	class := classOf(options)
+	q.classes[class] = append(q.classes[class], queued)
+	if !q.draining {
+		q.draining = true
+		go q.drain(write)
+	}
+	q.Unlock()
+
+	return <-queued.done
+}
+
+// drain writes the queued messages until the queue is empty
+func (q *priorityQueue) drain(write func(NymMessage, *sendOptions) error) {
+	for {
+		q.Lock()
+		queued := q.pop()
+		if nil == queued {
+			q.draining = false
+			q.Unlock()
+			return
+		}
+		q.Unlock()
+
+		queued.done <- write(queued.msg, queued.options)
+	}
+}
+
+// pop removes the next message to write: the first one of the highest class, unless a lower class waited for too long
+// called with the queue locked
+func (q *priorityQueue) pop() *queuedMessage {
+	class := -1
+	for c := controlClass + 1; c < classCount; c++ {
+		if len(q.classes[c]) != 0 && q.skipped[c] >= q.starvationLimit {
+			class = c
+			break
+		}
+	}
+	if -1 == class {
+		for c := controlClass; c < classCount; c++ {
+			if len(q.classes[c]) != 0 {
+				class = c
+				break
+			}
+		}
+	}
+	if -1 == class {
+		return nil
+	}
+
+	queued := q.classes[class][0]
+	q.classes[class][0] = nil
+	q.classes[class] = q.classes[class][1:]
+
+	q.skipped[class] = 0
+	for c := class + 1; c < classCount; c++ {
+		if len(q.classes[c]) != 0 {
+			q.skipped[c]++
+		}
+	}
+	return queued
+}
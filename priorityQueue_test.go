@@ -0,0 +1,92 @@
+package nymsocketmanager_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerPriorityQueue(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.readsHeld = make(chan struct{})
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithPriorityQueue(1))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// Large enough to fill the buffers of the connection, so that the next messages wait in the queue
+	large := strings.Repeat("a", 8<<20)
+
+	var wg sync.WaitGroup
+	for _, message := range []struct {
+		message  string
+		priority lib.SendPriority
+	}{{large, lib.PriorityBulk}, {"bulk", lib.PriorityBulk}, {"control", lib.PriorityControl}, {"control again", lib.PriorityControl}} {
+		message := message
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend(message.message, fakePeerAddress), lib.WithPriority(message.priority)))
+		}()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	close(fake.readsHeld)
+	wg.Wait()
+
+	require.Eventually(t, func() bool { return 4 == len(fake.receivedSends()) }, 5*time.Second, 10*time.Millisecond)
+	sends := fake.receivedSends()
+	require.Equal(t, large, sends[0])
+	// The bulk message waited for one message of a higher class, so it is sent before the next one
+	require.Equal(t, []string{"control", "bulk", "control again"}, sends[1:])
+}
+
+func TestWithPriorityQueueNegativeLimit(t *testing.T) {
+	logger := zerolog.Logger{}
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1:1977", emptyProcessing, lib.ZerologLogger(&logger), lib.WithPriorityQueue(-1))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerPriorityQueueHighBeforeNormal(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.readsHeld = make(chan struct{})
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithPriorityQueue(0))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	large := strings.Repeat("a", 8<<20)
+
+	var wg sync.WaitGroup
+	for _, message := range []struct {
+		message  string
+		priority lib.SendPriority
+	}{{large, lib.PriorityNormal}, {"normal", lib.PriorityNormal}, {"bulk", lib.PriorityBulk}, {"high", lib.PriorityHigh}, {"control", lib.PriorityControl}} {
+		message := message
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend(message.message, fakePeerAddress), lib.WithPriority(message.priority)))
+		}()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	close(fake.readsHeld)
+	wg.Wait()
+
+	require.Eventually(t, func() bool { return 5 == len(fake.receivedSends()) }, 5*time.Second, 10*time.Millisecond)
+	sends := fake.receivedSends()
+	require.Equal(t, large, sends[0])
+	require.Equal(t, []string{"control", "high", "normal", "bulk"}, sends[1:])
+}
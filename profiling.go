@@ -0,0 +1,48 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"runtime/pprof"
+	"time"
+)
+
+// runtime/pprof labels set while the messageHandler runs, see WithProfiling.
+// e.g. `go tool pprof -tagfocus=nym.message=ping` keeps the samples taken while handling the "ping" messages
+const (
+	HandlerLabel     = "nym.handler"
+	MessageTypeLabel = "nym.message"
+)
+
+// Used as message type when there is no Router kind
+const unroutedMessageType = "unrouted"
+
+// Profiling configures how the calls of the messageHandler are attributed in CPU profiles, see WithProfiling
+type Profiling struct {
+	// Value of the HandlerLabel, "messageHandler" if empty
+	HandlerName string
+	// Returns the value of the MessageTypeLabel, the kind the messages are routed by (see RouteKind) if nil
+	MessageType func(NymReceived) string
+	// If set, called with the time each message took to be handled, middlewares included.
+	// It is called from the goroutine handling the message: it must return quickly
+	OnHandled func(received NymReceived, messageType string, duration time.Duration)
+}
+
+// do calls handle with the profiling labels of received
+func (p *Profiling) do(ctx context.Context, received NymReceived, handle func()) {
+	messageType := p.MessageType(received)
+	handledAt := time.Now()
+	pprof.Do(ctx, pprof.Labels(HandlerLabel, p.HandlerName, MessageTypeLabel, messageType), func(context.Context) {
+		handle()
+	})
+	if nil != p.OnHandled {
+		p.OnHandled(received, messageType, time.Since(handledAt))
+	}
+}
+
+// routedMessageType is the default Profiling.MessageType
+func routedMessageType(received NymReceived) string {
+	if kind := RouteKind(received); len(kind) != 0 {
+		return kind
+	}
+	return unroutedMessageType
+}
@@ -0,0 +1,66 @@
+package nymsocketmanager_test
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerProfiling(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	type handled struct {
+		messageType string
+		duration    time.Duration
+	}
+	handledChan := make(chan handled, 2)
+	startedChan := make(chan struct{}, 2)
+	releaseChan := make(chan struct{})
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(lib.NymReceived, func(lib.NymMessage) error) {
+		startedChan <- struct{}{}
+		<-releaseChan
+	}, lib.ZerologLogger(&logger), lib.WithProfiling(lib.Profiling{
+		HandlerName: "pinger",
+		OnHandled: func(_ lib.NymReceived, messageType string, duration time.Duration) {
+			handledChan <- handled{messageType, duration}
+		},
+	}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(`{"kind":"ping"}`, nymSocketManager.GetNymClientId())))
+	select {
+	case <-startedChan:
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+
+	// The goroutine running the handler carries the labels
+	var profile bytes.Buffer
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(&profile, 1))
+	require.Contains(t, profile.String(), `"nym.handler":"pinger"`)
+	require.Contains(t, profile.String(), `"nym.message":"ping"`)
+
+	time.Sleep(10 * time.Millisecond)
+	close(releaseChan)
+	first := <-handledChan
+	require.Equal(t, "ping", first.messageType)
+	require.GreaterOrEqual(t, first.duration, 10*time.Millisecond)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("not JSON", nymSocketManager.GetNymClientId())))
+	select {
+	case second := <-handledChan:
+		require.Equal(t, "unrouted", second.messageType)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+}
@@ -0,0 +1,169 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// RateLimit configures a token bucket limiting the messages sent, see WithRateLimit
+type RateLimit struct {
+	// Messages per second sent in the long run
+	Rate float64
+	// Messages that can be sent at once, after enough time without sending
+	Burst int
+}
+
+func (l RateLimit) validate() error {
+	if l.Rate <= 0 {
+		return xerrors.Errorf("rate limit must be positive, got %v", l.Rate)
+	}
+	if l.Burst < 1 {
+		return xerrors.Errorf("rate limit burst must be at least 1, got %d", l.Burst)
+	}
+	return nil
+}
+
+// Number of buckets per recipient kept before the full ones are forgotten
+const maxIdleRecipientBuckets = 1024
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// reserve takes a token, and returns how long to wait for it to be available
+func (b *tokenBucket) reserve(limit *RateLimit, now time.Time) time.Duration {
+	b.refill(limit, now)
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / limit.Rate * float64(time.Second))
+}
+
+// refund gives back a token taken by reserve
+func (b *tokenBucket) refund() {
+	b.tokens++
+}
+
+func (b *tokenBucket) refill(limit *RateLimit, now time.Time) {
+	if !b.updatedAt.IsZero() {
+		b.tokens += now.Sub(b.updatedAt).Seconds() * limit.Rate
+	}
+	if b.updatedAt.IsZero() || b.tokens > float64(limit.Burst) {
+		b.tokens = float64(limit.Burst)
+	}
+	b.updatedAt = now
+}
+
+// rateLimiter holds the token buckets of the manager and of each recipient, see WithRateLimit and WithRecipientRateLimit
+type rateLimiter struct {
+	sync.Mutex
+
+	// nil if not limited
+	limit          *RateLimit
+	recipientLimit *RateLimit
+
+	bucket     tokenBucket
+	recipients map[string]*tokenBucket
+}
+
+// reserve takes the tokens needed to send msg, and returns how long to wait for them to be available
+func (r *rateLimiter) reserve(msg NymMessage) time.Duration {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	if nil != r.limit {
+		wait = r.bucket.reserve(r.limit, now)
+	}
+
+	recipient := recipientOf(msg)
+	if nil == r.recipientLimit || len(recipient) == 0 {
+		return wait
+	}
+
+	if nil == r.recipients {
+		r.recipients = make(map[string]*tokenBucket)
+	}
+	bucket, ok := r.recipients[recipient]
+	if !ok {
+		if len(r.recipients) >= maxIdleRecipientBuckets {
+			r.forgetFullBuckets(now)
+		}
+		bucket = &tokenBucket{}
+		r.recipients[recipient] = bucket
+	}
+	if recipientWait := bucket.reserve(r.recipientLimit, now); recipientWait > wait {
+		wait = recipientWait
+	}
+	return wait
+}
+
+// refund gives back the tokens taken by reserve for msg, which was not written
+func (r *rateLimiter) refund(msg NymMessage) {
+	r.Lock()
+	defer r.Unlock()
+
+	if nil != r.limit {
+		r.bucket.refund()
+	}
+	if bucket, ok := r.recipients[recipientOf(msg)]; ok && nil != r.recipientLimit {
+		bucket.refund()
+	}
+}
+
+// forgetFullBuckets removes the buckets of the recipients nothing was sent to for long enough, as new ones would be full too
+// called with the limiter locked
+func (r *rateLimiter) forgetFullBuckets(now time.Time) {
+	for recipient, bucket := range r.recipients {
+		bucket.refill(r.recipientLimit, now)
+		if bucket.tokens >= float64(r.recipientLimit.Burst) {
+			delete(r.recipients, recipient)
+		}
+	}
+}
+
+// recipientOf returns who msg is sent to: the address of the recipient, or the sender tag of a reply
+func recipientOf(msg NymMessage) string {
+	switch m := msg.(type) {
+	case NymSend:
+		return m.Recipient
+	case NymSendAnonymous:
+		return m.Recipient
+	case NymReply:
+		return m.SenderTag
+	default:
+		return ""
+	}
+}
+
+// rateLimited reports whether the sends are rate limited, see waitForRate
+func (n *NymSocketManager) rateLimited() bool {
+	return nil != n.rateLimiter.limit || nil != n.rateLimiter.recipientLimit
+}
+
+// waitForRate waits until msg can be sent within the rate limits. The wait is aborted with ErrNotStarted when the manager stops,
+// the tokens being given back
+func (n *NymSocketManager) waitForRate(msg NymMessage, messageID string) error {
+	wait := n.rateLimiter.reserve(msg)
+	if wait <= 0 {
+		return nil
+	}
+
+	n.messageLogger.Trace().Str(MessageIDField, messageID).Msgf("rate limited, waiting %v to send %v", wait, msg.Name())
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-n.handlerContext.get().Done():
+		n.rateLimiter.refund(msg)
+		err := xerrors.Errorf("cannot send %v, stopped while rate limited: %w", msg.Name(), ErrNotStarted)
+		n.logger.Warn().Str(MessageIDField, messageID).Msg(err.Error())
+		return err
+	}
+}
@@ -0,0 +1,104 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerRateLimit(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithRateLimit(lib.RateLimit{Rate: 20, Burst: 2}))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend("burst", fakePeerAddress)))
+	}
+	require.Less(t, time.Since(start), 40*time.Millisecond)
+
+	// One message every 50ms once the burst is used up
+	for i := 0; i < 2; i++ {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend("limited", fakePeerAddress)))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestNymSocketManagerRecipientRateLimit(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithRecipientRateLimit(lib.RateLimit{Rate: 10, Burst: 1}))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	start := time.Now()
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("again", fakePeerAddress)))
+	require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	for _, limit := range []lib.RateLimit{{Rate: 0, Burst: 1}, {Rate: 1, Burst: 0}} {
+		_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithRateLimit(limit))
+		require.Error(t, e)
+	}
+}
+
+func TestNymSocketManagerRateLimitedSendStopped(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithRateLimit(lib.RateLimit{Rate: 0.1, Burst: 1}))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("burst", fakePeerAddress)))
+	sentChan := make(chan error, 1)
+	go func() {
+		sentChan <- nymSocketManager.Send(lib.NewNymSend("limited", fakePeerAddress))
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Stop does not wait for the 10s reservation, the Send is aborted
+	start := time.Now()
+	nymSocketManager.Stop()
+	select {
+	case e = <-sentChan:
+		require.ErrorIs(t, e, lib.ErrNotStarted)
+	case <-time.After(time.Second):
+		require.Fail(t, "rate limited Send not aborted by Stop")
+	}
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestNymSocketManagerRateLimitTokensOfRejectedSends(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithRateLimit(lib.RateLimit{Rate: 0.1, Burst: 1}))
+	require.NoError(t, e)
+
+	// Neither the Send before Start nor the one to an invalid recipient use the token up
+	require.ErrorIs(t, nymSocketManager.Send(lib.NewNymSend("early", fakePeerAddress)), lib.ErrNotStarted)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Error(t, nymSocketManager.Send(lib.NewNymSend("invalid", "invalid")))
+
+	start := time.Now()
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("burst", fakePeerAddress)))
+	require.Less(t, time.Since(start), time.Second)
+}
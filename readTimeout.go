@@ -0,0 +1,68 @@
+package nymsocketmanager
+
+import (
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/xerrors"
+)
+
+// ErrReadTimeout is the reason of the closure of a connection from which nothing was read, not even the pong of a probe, see WithReadTimeout
+var ErrReadTimeout = xerrors.New("connection looks dead, the read timeout expired")
+
+// SetReadTimeout makes the SocketListener tell the idle sockets from the dead ones: once nothing was read for idleTimeout,
+// the socket is probed with a ping, and closed if nothing, not even the pong, is read within probeTimeout.
+// The closure then has ErrReadTimeout as reason. It must be called before Listen
+func (s *SocketListener) SetReadTimeout(idleTimeout, probeTimeout time.Duration) {
+	s.readIdleTimeout = idleTimeout
+	s.readProbeTimeout = probeTimeout
+}
+
+// extendReadDeadline pushes the read deadline back once a frame was read, if a read timeout is set
+func (s *SocketListener) extendReadDeadline() {
+	if s.readIdleTimeout > 0 {
+		_ = s.socket.SetReadDeadline(time.Now().Add(s.readIdleTimeout + s.readProbeTimeout))
+	}
+}
+
+// readTimeoutError returns the error wrapping ErrReadTimeout if e comes from the expiry of the read deadline, nil otherwise
+func (s *SocketListener) readTimeoutError(e error) error {
+	var netError net.Error
+	if s.readIdleTimeout <= 0 || !xerrors.As(e, &netError) || !netError.Timeout() {
+		return nil
+	}
+	return xerrors.Errorf("nothing read for %v: %w", s.readIdleTimeout+s.readProbeTimeout, ErrReadTimeout)
+}
+
+// probeIdleSocket pings the socket once nothing was read from it for the idle timeout, until stopChan is closed
+func (s *SocketListener) probeIdleSocket(stopChan chan struct{}) {
+	ticker := time.NewTicker(s.readIdleTimeout / 4)
+	defer ticker.Stop()
+
+	probed := false
+	for {
+		select {
+		case <-stopChan:
+			return
+
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, s.lastReadAt.Load()))
+			if idle < s.readIdleTimeout {
+				probed = false
+				continue
+			}
+			if probed {
+				continue
+			}
+			probed = true
+
+			s.logger.Debug().Msgf("nothing read from socket for %v, probing it", idle)
+			// WriteControl can be called concurrently with the other writes
+			e := s.socket.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.readProbeTimeout))
+			if nil != e {
+				s.logger.Debug().Msgf("failed to probe socket: %v", e)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerReadTimeoutKeepsIdleConnections(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithReadTimeout(100*time.Millisecond, 200*time.Millisecond))
+	require.NoError(t, e)
+
+	disconnected := make(chan error, 1)
+	nymSocketManager.OnDisconnect(func(_ string, reason error) { disconnected <- reason })
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The pongs of the probes keep the connection open
+	select {
+	case reason := <-disconnected:
+		require.Fail(t, "idle connection closed", reason.Error())
+	case <-time.After(time.Second):
+	}
+	require.True(t, nymSocketManager.IsReady())
+	require.Equal(t, 1, fake.connectionCount())
+}
+
+func TestNymSocketManagerReadTimeoutReplacesDeadConnections(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.hangAfterSelfAddress = true
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithReadTimeout(100*time.Millisecond, 100*time.Millisecond))
+	require.NoError(t, e)
+
+	disconnected := make(chan error, 1)
+	nymSocketManager.OnDisconnect(func(_ string, reason error) {
+		select {
+		case disconnected <- reason:
+		default:
+		}
+	})
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	select {
+	case reason := <-disconnected:
+		require.ErrorContains(t, reason, lib.ErrReadTimeout.Error())
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "dead connection not closed")
+	}
+
+	// Reconnected to the same nym-client, the only one
+	require.Eventually(t, func() bool {
+		return fake.connectionCount() >= 2 && nymSocketManager.IsReady()
+	}, 2*time.Second, 10*time.Millisecond)
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithReadTimeout(time.Second, 0))
+	require.Error(t, e)
+}
@@ -0,0 +1,170 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// Number of sender tags whose connection is remembered, the oldest ones are forgotten first
+const maxShardedSenderTags = 4096
+
+// recipientShards holds a connection to each of the nym-clients besides the main one, see WithRecipientSharding
+type recipientShards struct {
+	sync.RWMutex
+
+	enabled bool
+	// Connection to the nym-client of each connection URI, nil for the main one and for the unreachable ones
+	connections []*pooledConnection
+	// Connection which received the last message of each sender tag, for the replies to find their SURBs there
+	senderTags     map[string]*pooledConnection
+	senderTagOrder []string
+}
+
+// connectionFor returns the connection msg is to be written on, nil meaning the main connection.
+// The messages are spread by the hash of their recipient, the replies sent where the messages of their sender tag were received
+func (s *recipientShards) connectionFor(msg NymMessage) *pooledConnection {
+	if !s.enabled {
+		return nil
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	if 0 == len(s.connections) {
+		return nil
+	}
+	if reply, ok := msg.(NymReply); ok {
+		return s.senderTags[reply.SenderTag]
+	}
+
+	recipient := recipientOf(msg)
+	if len(recipient) == 0 {
+		return nil
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(recipient))
+	return s.connections[hash.Sum32()%uint32(len(s.connections))]
+}
+
+// received remembers the connection of the sender tag of received, if it is the one of a shard.
+// The messages dispatched after their shard was removed or closed are not remembered
+func (s *recipientShards) received(received NymReceived, origin *pooledConnection) {
+	if !s.enabled || len(received.SenderTag) == 0 {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if nil == origin {
+		delete(s.senderTags, received.SenderTag)
+		return
+	}
+	if !s.isShard(origin) {
+		return
+	}
+	if _, known := s.senderTags[received.SenderTag]; !known {
+		s.senderTagOrder = append(s.senderTagOrder, received.SenderTag)
+	}
+	s.senderTags[received.SenderTag] = origin
+
+	for len(s.senderTagOrder) > maxShardedSenderTags {
+		delete(s.senderTags, s.senderTagOrder[0])
+		s.senderTagOrder = s.senderTagOrder[1:]
+	}
+}
+
+// isShard returns whether connection is one of the shards
+// called from methods that already acquired the lock
+func (s *recipientShards) isShard(connection *pooledConnection) bool {
+	for _, shard := range s.connections {
+		if connection == shard {
+			return true
+		}
+	}
+	return false
+}
+
+// remove takes a connection out of the shards, returning false if it was not one of them
+func (s *recipientShards) remove(shard *pooledConnection) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	removed := false
+	for i, connection := range s.connections {
+		if shard == connection {
+			s.connections[i] = nil
+			removed = true
+		}
+	}
+	for senderTag, connection := range s.senderTags {
+		if shard == connection {
+			delete(s.senderTags, senderTag)
+		}
+	}
+	return removed
+}
+
+// openShards opens a connection to each of the nym-clients other than the one of the main connection, if sharding is enabled.
+// Failing to open some of them is not fatal, their messages go through the main connection
+// called from methods that already acquired the lock
+func (n *NymSocketManager) openShards(ctx context.Context) {
+	if !n.shards.enabled || len(n.connectionURIs) < 2 {
+		return
+	}
+
+	connections := make([]*pooledConnection, len(n.connectionURIs))
+	for i, connectionURI := range n.connectionURIs {
+		if i == n.connectionURIIndex {
+			continue
+		}
+		shard, e := n.openPooledConnection(ctx, connectionURI, n.shardLost)
+		if nil != e {
+			n.logger.Warn().Msgf("failed to open the shard connection to %v, its recipients go through %v: %v", connectionURI, n.connectionURIs[n.connectionURIIndex], e)
+			continue
+		}
+		connections[i] = shard
+	}
+
+	n.shards.Lock()
+	n.shards.connections = connections
+	n.shards.senderTags = make(map[string]*pooledConnection)
+	n.shards.senderTagOrder = nil
+	n.shards.Unlock()
+}
+
+// shardLost is called by the socketListener of a shard connection once it is closed.
+// Its recipients go through the main connection until the next reconnection
+func (n *NymSocketManager) shardLost(shard *pooledConnection) {
+	// Otherwise the closure was requested by closeShards
+	if n.shards.remove(shard) {
+		shard.connection.Close()
+		n.logger.Warn().Msgf("lost a shard connection: %v", shard.socketListener.closeReason)
+	}
+}
+
+// closeShards closes the connections to the other nym-clients, and forgets their sender tags once their socketListeners are done
+// called from methods that already acquired the lock
+func (n *NymSocketManager) closeShards(ctx context.Context) {
+	// The sends go through the main connection from now on
+	n.shards.Lock()
+	var connections []*pooledConnection
+	for _, shard := range n.shards.connections {
+		if nil != shard {
+			connections = append(connections, shard)
+		}
+	}
+	n.shards.connections = nil
+	n.shards.Unlock()
+
+	n.closePooledConnections(ctx, connections)
+	for _, shard := range connections {
+		<-shard.closedSocketListenerChan
+	}
+
+	n.shards.Lock()
+	n.shards.senderTags = make(map[string]*pooledConnection)
+	n.shards.senderTagOrder = nil
+	n.shards.Unlock()
+}
@@ -0,0 +1,133 @@
+package nymsocketmanager_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/notrustverify/nymsocketmanager/address"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// shardedRecipients returns count valid addresses, different from the ones of the fake nym-clients
+func shardedRecipients(t *testing.T, count int) []string {
+	var recipients []string
+	for i := 0; i < count; i++ {
+		raw := make([]byte, address.Length)
+		raw[0], raw[address.KeyLength], raw[2*address.KeyLength] = byte(i+1), 0xaa, 0xbb
+		recipient, e := address.FromBytes(raw)
+		require.NoError(t, e)
+		recipients = append(recipients, recipient.String())
+	}
+	return recipients
+}
+
+// recipientsOf returns the recipients of the messages "<recipient index>/<message index>" received by fake
+func recipientsOf(fake *fakeNymClient) map[string]int {
+	recipients := make(map[string]int)
+	for _, message := range fake.receivedSends() {
+		recipient, _, _ := strings.Cut(message, "/")
+		recipients[recipient]++
+	}
+	return recipients
+}
+
+func TestNymSocketManagerRecipientSharding(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithFallbackURIs(second.URI()), lib.WithRecipientSharding())
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, 1, second.connectionCount())
+
+	recipients := shardedRecipients(t, 16)
+	for round := 0; round < 3; round++ {
+		for i, recipient := range recipients {
+			require.NoError(t, nymSocketManager.Send(lib.NewNymSend(fmt.Sprintf("%d/%d", i, round), recipient)))
+		}
+	}
+	require.Eventually(t, func() bool {
+		return first.sendRequests.Load()+second.sendRequests.Load() == 3*16
+	}, time.Second, 10*time.Millisecond)
+
+	// Both nym-clients got recipients, each recipient all its messages on the same one
+	onFirst, onSecond := recipientsOf(first), recipientsOf(second)
+	require.NotEmpty(t, onFirst)
+	require.NotEmpty(t, onSecond)
+	for recipient, count := range onFirst {
+		require.Equal(t, 3, count)
+		require.NotContains(t, onSecond, recipient)
+	}
+	for _, count := range onSecond {
+		require.Equal(t, 3, count)
+	}
+}
+
+func TestNymSocketManagerRecipientShardingReplies(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+
+	repliedChan := make(chan error, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), func(received lib.NymReceived, reply func(lib.NymMessage) error) {
+		repliedChan <- reply(lib.NewNymReply("", "pong"))
+	}, lib.ZerologLogger(&logger), lib.WithFallbackURIs(second.URI()), lib.WithRecipientSharding())
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// Received through the second nym-client, which holds the SURBs of the sender
+	second.deliver(map[string]interface{}{"type": "received", "message": "ping", "senderTag": fakeNymClientSenderTag})
+	select {
+	case e := <-repliedChan:
+		require.NoError(t, e)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+	require.Eventually(t, func() bool { return 1 == second.sendRequests.Load() }, time.Second, 10*time.Millisecond)
+	require.Zero(t, first.sendRequests.Load())
+}
+
+func TestNymSocketManagerRecipientShardingStopDuringTraffic(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithFallbackURIs(second.URI()), lib.WithRecipientSharding())
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	// Messages keep coming through the shard while it is closed
+	stopChan := make(chan struct{})
+	doneChan := make(chan struct{})
+	go func() {
+		defer close(doneChan)
+		for i := 0; ; i++ {
+			select {
+			case <-stopChan:
+				return
+			default:
+			}
+			second.deliver(map[string]interface{}{"type": "received", "message": "ping", "senderTag": fmt.Sprintf("%v%d", fakeNymClientSenderTag, i)})
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	nymSocketManager.Stop()
+	close(stopChan)
+	<-doneChan
+}
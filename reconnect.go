@@ -0,0 +1,110 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"time"
+)
+
+// ReconnectDoneEvent is sent once the attempts to replace a lost connection are over, successful or not
+type ReconnectDoneEvent struct {
+	EventCommon
+	// The nym-client connected to, empty if all the attempts failed
+	ConnectionURI string
+	Attempts      int
+	// nil if reconnected, the error of the last attempt otherwise, or ErrNotStarted if the manager was stopped meanwhile
+	Err error
+}
+
+func (ReconnectDoneEvent) Name() string {
+	return "reconnectDone"
+}
+
+// reconnection describes the attempts in progress to replace a lost connection
+type reconnection struct {
+	// Number of the next attempt, 0 when not reconnecting
+	attempt int
+	// Waited before the next attempt
+	delay time.Duration
+	// Why the previous attempt failed, or why the connection was lost before the first one
+	err error
+}
+
+// SubscribeReconnects returns a channel receiving the ReconnectAttemptEvent and ReconnectDoneEvent only, e.g. to display a
+// "reconnecting…" state in a UI from the first attempt until the last one. Events are dropped if the channel is not consumed fast enough.
+// The channel is closed by UnsubscribeReconnects
+func (n *NymSocketManager) SubscribeReconnects() <-chan Event {
+	n.events.Lock()
+	defer n.events.Unlock()
+
+	subscriber := make(chan Event, eventsBufferSize)
+	n.events.reconnectSubscribers = append(n.events.reconnectSubscribers, subscriber)
+	return subscriber
+}
+
+// UnsubscribeReconnects stops sending events to a channel obtained with SubscribeReconnects, and closes it
+func (n *NymSocketManager) UnsubscribeReconnects(subscription <-chan Event) {
+	n.events.Lock()
+	defer n.events.Unlock()
+
+	for i, subscriber := range n.events.reconnectSubscribers {
+		if subscription == subscriber {
+			close(subscriber)
+			n.events.reconnectSubscribers = append(n.events.reconnectSubscribers[:i], n.events.reconnectSubscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// reconnect replaces the connection lost because of reason, trying the connection URIs from the next one,
+// for as many passes as the reconnect policy allows. stopped is set if the manager was stopped during a backoff delay
+// called from methods that already acquired the lock, which is released during the backoff delays so that Stop is not held up
+func (n *NymSocketManager) reconnect(reason error) (stopped bool, err error) {
+	n.reconnection = reconnection{attempt: 1, err: reason}
+	defer func() { n.reconnection = reconnection{} }()
+
+	for pass := 1; ; pass++ {
+		e := n.connect(context.Background(), n.connectionURIIndex+1)
+		if nil == e {
+			n.events.reconnectDone(n.connectionURIs[n.connectionURIIndex], n.reconnection.attempt-1, nil)
+			return false, nil
+		}
+		if nil == n.reconnectPolicy || !n.reconnectPolicy.allowsRetry(pass) {
+			n.events.reconnectDone("", n.reconnection.attempt-1, e)
+			return false, e
+		}
+
+		delay := n.reconnectPolicy.delay(pass)
+		n.logger.Debug().Msgf("failed to reconnect, retrying in %v", delay)
+
+		stoppedChan := n.selfInstanceStoppedChan
+		n.Unlock()
+		time.Sleep(delay)
+		n.Lock()
+		// Stopped, and maybe started again, meanwhile
+		if stoppedChan != n.selfInstanceStoppedChan {
+			n.logger.Debug().Msg("manager stopped while reconnecting, giving up")
+			n.events.reconnectDone("", n.reconnection.attempt-1, ErrNotStarted)
+			return true, nil
+		}
+		n.reconnection.delay = delay
+	}
+}
+
+// reconnectAttempted reports the attempt about to be made to connect to connectionURI, if reconnecting
+// called from methods that already acquired the lock
+func (n *NymSocketManager) reconnectAttempted(connectionURI string) {
+	if 0 == n.reconnection.attempt {
+		return
+	}
+	n.events.reconnectAttempt(connectionURI, n.reconnection.attempt, n.reconnection.delay, n.reconnection.err)
+	n.reconnection.attempt++
+	n.reconnection.delay = 0
+}
+
+// reconnectFailed records why the last attempt to reconnect failed, if reconnecting
+// called from methods that already acquired the lock
+func (n *NymSocketManager) reconnectFailed(err error) {
+	if 0 != n.reconnection.attempt {
+		n.reconnection.err = err
+	}
+}
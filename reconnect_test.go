@@ -0,0 +1,91 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerReconnectPolicy(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithReconnectPolicy(lib.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond}))
+	require.NoError(t, e)
+	reconnects := nymSocketManager.SubscribeReconnects()
+
+	stoppedChan, e := nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The nym-client goes away for good
+	fake.server.Close()
+	fake.dropConnections()
+
+	first, ok := nextEvent(t, reconnects).(lib.ReconnectAttemptEvent)
+	require.True(t, ok)
+	require.Equal(t, fake.URI(), first.ConnectionURI)
+	require.Equal(t, 1, first.Attempt)
+	require.Zero(t, first.Delay)
+	require.ErrorIs(t, first.Err, lib.ErrConnectionClosed)
+
+	second, ok := nextEvent(t, reconnects).(lib.ReconnectAttemptEvent)
+	require.True(t, ok)
+	require.Equal(t, 2, second.Attempt)
+	require.Equal(t, 10*time.Millisecond, second.Delay)
+	require.Error(t, second.Err)
+	require.NotErrorIs(t, second.Err, lib.ErrConnectionClosed)
+
+	third, ok := nextEvent(t, reconnects).(lib.ReconnectAttemptEvent)
+	require.True(t, ok)
+	require.Equal(t, 3, third.Attempt)
+	require.Equal(t, 20*time.Millisecond, third.Delay)
+
+	done, ok := nextEvent(t, reconnects).(lib.ReconnectDoneEvent)
+	require.True(t, ok)
+	require.Empty(t, done.ConnectionURI)
+	require.Equal(t, 3, done.Attempts)
+	require.Error(t, done.Err)
+
+	select {
+	case <-stoppedChan:
+	case <-time.After(time.Second):
+		require.Fail(t, "manager not stopped once the reconnect policy gave up")
+	}
+
+	nymSocketManager.UnsubscribeReconnects(reconnects)
+	_, open := <-reconnects
+	require.False(t, open)
+}
+
+func TestNymSocketManagerReconnectPolicyStop(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithReconnectPolicy(lib.RetryPolicy{BaseDelay: 200 * time.Millisecond}))
+	require.NoError(t, e)
+	reconnects := nymSocketManager.SubscribeReconnects()
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+
+	fake.server.Close()
+	fake.dropConnections()
+	_, ok := nextEvent(t, reconnects).(lib.ReconnectAttemptEvent)
+	require.True(t, ok)
+
+	// Stop is not held up by the backoff delay
+	stoppedAt := time.Now()
+	nymSocketManager.Stop()
+	require.Less(t, time.Since(stoppedAt), 200*time.Millisecond)
+	require.Equal(t, lib.StateStopped, nymSocketManager.GetState())
+
+	done, ok := nextEvent(t, reconnects).(lib.ReconnectDoneEvent)
+	require.True(t, ok)
+	require.ErrorIs(t, done.Err, lib.ErrNotStarted)
+}
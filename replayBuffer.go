@@ -0,0 +1,195 @@
+package nymsocketmanager
+
+import (
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// OverflowPolicy defines what happens when a message is added to a full buffer
+type OverflowPolicy int
+
+const (
+	// Drop the oldest message of the buffer to make room for the new one
+	OverflowDropOldest OverflowPolicy = iota
+	// Refuse the new message
+	OverflowDropNewest
+	// Wait until there is room for the new message
+	OverflowBlock
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropOldest:
+		return "DropOldest"
+	case OverflowDropNewest:
+		return "DropNewest"
+	case OverflowBlock:
+		return "Block"
+	default:
+		return "Unknown"
+	}
+}
+
+// replayBuffer stores the messages sent while the connection is down, until it is restored
+type replayBuffer struct {
+	sync.Mutex
+
+	capacity int
+	policy   OverflowPolicy
+	messages []bufferedMessage
+	dropped  uint64
+	// Signaled when room is made in the buffer, or when the connection is restored, nil until needed
+	roomCond *sync.Cond
+}
+
+type bufferedMessage struct {
+	msg     NymMessage
+	options *sendOptions
+}
+
+// push adds a message to the buffer, after the ones of the same or higher priority, applying the overflow policy if it is full.
+// It returns the message dropped if any, along with an error if it is msg
+// called with the buffer locked
+func (r *replayBuffer) push(msg NymMessage, options *sendOptions) (*bufferedMessage, error) {
+	var dropped *bufferedMessage
+	if len(r.messages) >= r.capacity {
+		switch r.policy {
+		case OverflowDropNewest:
+			r.dropped++
+			return &bufferedMessage{msg, options}, xerrors.Errorf("replay buffer is full (%d messages), dropping %v", r.capacity, msg.Name())
+		case OverflowBlock:
+			r.dropped++
+			return &bufferedMessage{msg, options}, xerrors.Errorf("NymSocketManager stopped while waiting for room in the replay buffer, dropping %v", msg.Name())
+		}
+		r.dropped++
+		oldest := r.messages[0]
+		r.messages = r.messages[1:]
+		oldest.options.complete(xerrors.Errorf("replay buffer is full (%d messages), dropped %v", r.capacity, oldest.msg.Name()))
+		dropped = &oldest
+	}
+
+	position := len(r.messages)
+	for position > 0 && r.messages[position-1].options.priority.urgency() < options.priority.urgency() {
+		position--
+	}
+	r.messages = append(r.messages, bufferedMessage{})
+	copy(r.messages[position+1:], r.messages[position:])
+	r.messages[position] = bufferedMessage{msg, options}
+	return dropped, nil
+}
+
+// room returns the condition signaled when room is made in the buffer
+// called with the buffer locked
+func (r *replayBuffer) room() *sync.Cond {
+	if nil == r.roomCond {
+		r.roomCond = sync.NewCond(&r.Mutex)
+	}
+	return r.roomCond
+}
+
+// signalRoom wakes up the Sends waiting for room in the buffer, if any
+// called with the buffer locked
+func (r *replayBuffer) signalRoom() {
+	if nil != r.roomCond {
+		r.roomCond.Broadcast()
+	}
+}
+
+// sendOrBuffer sends the message if connected, or keeps it for when the connection is restored.
+// It returns true if the message was buffered or queued for the writer goroutine
+func (n *NymSocketManager) sendOrBuffer(msg NymMessage, options *sendOptions) (bool, error) {
+	for {
+		buffered, e := n.bufferIfDisconnected(msg, options)
+		if buffered || nil != e {
+			return buffered, e
+		}
+
+		// Written with the buffer unlocked, so that the Sends, Stats and HealthStatus do not wait for the writes
+		queued, e := n.sendOrQueue(msg, options)
+		if !xerrors.Is(e, ErrConnectionClosed) || n.isConnected() {
+			return queued, e
+		}
+		// The connection was lost since it was checked, the message is buffered instead
+	}
+}
+
+// bufferIfDisconnected keeps the message for when the connection is restored if it is down.
+// It returns false if the message is to be written, the connection being up
+func (n *NymSocketManager) bufferIfDisconnected(msg NymMessage, options *sendOptions) (bool, error) {
+	n.replayBuffer.Lock()
+	defer n.replayBuffer.Unlock()
+
+	// Checked with the buffer locked, so that the message cannot be buffered after the replay
+	for !n.isConnected() {
+		if OverflowBlock != n.replayBuffer.policy || len(n.replayBuffer.messages) < n.replayBuffer.capacity || !n.started.Load() {
+			break
+		}
+		n.replayBuffer.room().Wait()
+	}
+	if n.isConnected() {
+		return false, nil
+	}
+
+	dropped, e := n.replayBuffer.push(msg, options)
+	if nil != dropped {
+		n.messageDropped(DropReplayBuffer, dropped.msg, dropped.options.id(), nil, "replay buffer is full")
+	}
+	if nil != e {
+		n.logger.Warn().Str(MessageIDField, options.id()).Msg(e.Error())
+		return false, e
+	}
+	n.messageLogger.Debug().Str(MessageIDField, options.id()).Msgf("connection is down, buffered %v for replay (%d buffered)", msg.Name(), len(n.replayBuffer.messages))
+	return true, nil
+}
+
+// replay sends the messages buffered while the connection was down
+// called from methods that already acquired the lock
+func (n *NymSocketManager) replay() {
+	if nil == n.replayBuffer {
+		return
+	}
+
+	n.replayBuffer.Lock()
+	defer n.replayBuffer.Unlock()
+	// Once replayed, the Sends waiting for room can go through the restored connection
+	defer n.replayBuffer.signalRoom()
+
+	if len(n.replayBuffer.messages) > 0 {
+		n.logger.Debug().Msgf("replaying %d buffered message(s)", len(n.replayBuffer.messages))
+	}
+
+	for len(n.replayBuffer.messages) > 0 {
+		buffered := n.replayBuffer.messages[0]
+		e := n.send(buffered.msg, buffered.options)
+		if nil != e {
+			n.logger.Warn().Msgf("failed to replay buffered messages, %d left: %v", len(n.replayBuffer.messages), e)
+			return
+		}
+		buffered.options.complete(nil)
+		n.replayBuffer.messages[0] = bufferedMessage{}
+		n.replayBuffer.messages = n.replayBuffer.messages[1:]
+	}
+}
+
+// clearReplayBuffer drops the messages that could not be replayed before the manager stopped
+// called from methods that already acquired the lock
+func (n *NymSocketManager) clearReplayBuffer() {
+	if nil == n.replayBuffer {
+		return
+	}
+
+	n.replayBuffer.Lock()
+	defer n.replayBuffer.Unlock()
+	// The Sends waiting for room fail, the manager being stopped
+	defer n.replayBuffer.signalRoom()
+
+	if len(n.replayBuffer.messages) > 0 {
+		n.logger.Warn().Msgf("dropping %d buffered message(s) that could not be replayed", len(n.replayBuffer.messages))
+	}
+	for _, buffered := range n.replayBuffer.messages {
+		buffered.options.complete(xerrors.Errorf("NymSocketManager stopped before %v could be replayed", buffered.msg.Name()))
+		n.messageDropped(DropStopping, buffered.msg, buffered.options.id(), nil, "stopped before it could be replayed")
+	}
+	n.replayBuffer.messages = nil
+}
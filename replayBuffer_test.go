@@ -0,0 +1,141 @@
+package nymsocketmanager_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerReplaysMessagesSentDuringFailover(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+	second.upgradeDelay = 300 * time.Millisecond
+
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithFallbackURIs(second.URI()), lib.WithReplayBuffer(2, lib.OverflowDropOldest))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	first.dropConnections()
+	require.Eventually(t, func() bool {
+		return nymSocketManager.GetState() == lib.StateConnecting
+	}, time.Second, time.Millisecond)
+
+	// The oldest one is dropped
+	for i := 0; i < 3; i++ {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)))
+	}
+
+	require.Eventually(t, func() bool {
+		return second.sendRequests.Load() == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(0), first.sendRequests.Load())
+}
+
+func TestNymSocketManagerReplayBufferDropNewest(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+	second.upgradeDelay = 300 * time.Millisecond
+
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithFallbackURIs(second.URI()), lib.WithReplayBuffer(1, lib.OverflowDropNewest))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	first.dropConnections()
+	require.Eventually(t, func() bool {
+		return nymSocketManager.GetState() == lib.StateConnecting
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)))
+	require.Error(t, nymSocketManager.Send(lib.NewNymSend("hello", fakePeerAddress)))
+	require.Equal(t, uint64(1), nymSocketManager.Stats().ReplayBufferDropped)
+}
+
+func TestNymSocketManagerReplayBufferBlock(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+	second.upgradeDelay = 300 * time.Millisecond
+
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithFallbackURIs(second.URI()), lib.WithReplayBuffer(1, lib.OverflowBlock))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	first.dropConnections()
+	require.Eventually(t, func() bool {
+		return nymSocketManager.GetState() == lib.StateConnecting
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("buffered", fakePeerAddress)))
+	// Waits for the connection to be restored
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("blocked", fakePeerAddress)))
+	require.True(t, nymSocketManager.IsReady())
+
+	require.Eventually(t, func() bool {
+		return second.sendRequests.Load() == 2
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"buffered", "blocked"}, second.receivedSends())
+	require.Zero(t, nymSocketManager.Stats().ReplayBufferDropped)
+}
+
+func TestNymSocketManagerReplayBufferCapacityMustBePositive(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1", emptyProcessing, lib.ZerologLogger(&logger), lib.WithReplayBuffer(0, lib.OverflowDropOldest))
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerReplayBufferNotLockedWhileWriting(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.readsHeld = make(chan struct{})
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithReplayBuffer(8, lib.OverflowDropOldest))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	var releaseOnce sync.Once
+	release := func() { releaseOnce.Do(func() { close(fake.readsHeld) }) }
+	// Before Stop, which waits for the write
+	defer release()
+
+	// Large enough to fill the buffers of the connection, so that the write blocks until the nym-client reads again
+	sentChan := make(chan error, 1)
+	go func() {
+		sentChan <- nymSocketManager.Send(lib.NewNymSend(strings.Repeat("a", 8<<20), fakePeerAddress))
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	statusChan := make(chan lib.HealthStatus, 1)
+	go func() {
+		statusChan <- nymSocketManager.HealthStatus()
+	}()
+	select {
+	case status := <-statusChan:
+		require.True(t, status.Ready)
+	case <-time.After(500 * time.Millisecond):
+		require.Fail(t, "HealthStatus waiting for the write")
+	}
+
+	release()
+	require.NoError(t, <-sentChan)
+}
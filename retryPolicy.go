@@ -0,0 +1,98 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// RetryPolicy defines how failed connection attempts are retried
+type RetryPolicy struct {
+	// Maximum number of attempts, the first one included. 0 means no limit
+	MaxAttempts int
+	// Delay before the first retry, doubled on each subsequent one
+	BaseDelay time.Duration
+	// Upper bound of the delay between two attempts, 0 means no bound
+	MaxDelay time.Duration
+	// Fraction of the delay randomly added or removed, between 0 and 1, so that several managers do not retry in lockstep
+	Jitter float64
+}
+
+// Validate checks that the policy can be used
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return xerrors.Errorf("retry policy max attempts cannot be negative, got %d", p.MaxAttempts)
+	}
+	if p.BaseDelay <= 0 {
+		return xerrors.Errorf("retry policy base delay must be positive, got %v", p.BaseDelay)
+	}
+	if p.MaxDelay < 0 {
+		return xerrors.Errorf("retry policy max delay cannot be negative, got %v", p.MaxDelay)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return xerrors.Errorf("retry policy jitter must be between 0 and 1, got %v", p.Jitter)
+	}
+	return nil
+}
+
+// allowsRetry tells whether another attempt can be made after attempt failed
+func (p RetryPolicy) allowsRetry(attempt int) bool {
+	return 0 == p.MaxAttempts || attempt < p.MaxAttempts
+}
+
+// delay returns how long to wait after the given failed attempt (starting at 1)
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (0 == p.MaxDelay || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if 0 != p.MaxDelay && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if 0 != p.Jitter {
+		delay = time.Duration(float64(delay) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	return delay
+}
+
+// connectWithRetries connects to the nym-clients, retrying according to the startup retry policy, if any,
+// for at most the wait for ready duration, if any
+// called from methods that already acquired the lock
+func (n *NymSocketManager) connectWithRetries(ctx context.Context) error {
+	if nil == n.startupRetryPolicy {
+		return n.connect(ctx, 0)
+	}
+
+	if 0 != n.readyMaxWait {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.readyMaxWait)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		e := n.connect(ctx, 0)
+		if nil == e {
+			return nil
+		}
+
+		if !n.startupRetryPolicy.allowsRetry(attempt) {
+			err := xerrors.Errorf("failed to start after %d attempt(s): %w", attempt, e)
+			n.logger.Warn().Msg(err.Error())
+			return err
+		}
+
+		delay := n.startupRetryPolicy.delay(attempt)
+		n.logger.Debug().Msgf("nym-client not ready yet, retrying in %v", delay)
+
+		select {
+		case <-ctx.Done():
+			err := xerrors.Errorf("nym-client still not ready after %d attempt(s): %w", attempt, e)
+			n.logger.Warn().Msg(err.Error())
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package nymsocketmanager_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerWaitsForNymClient(t *testing.T) {
+	logger := zerolog.Logger{}
+	socketPath := filepath.Join(t.TempDir(), "nym-client.sock")
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithUnixSocket(socketPath), lib.WithWaitForReady(2*time.Second, 20*time.Millisecond))
+	require.NoError(t, e)
+
+	// The nym-client boots after the manager
+	time.AfterFunc(200*time.Millisecond, func() {
+		newFakeNymClientOnUnixSocket(t, socketPath)
+	})
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+}
+
+func TestNymSocketManagerWaitForReadyGivesUp(t *testing.T) {
+	logger := zerolog.Logger{}
+	socketPath := filepath.Join(t.TempDir(), "nym-client.sock")
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithUnixSocket(socketPath), lib.WithWaitForReady(100*time.Millisecond, 20*time.Millisecond))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.Error(t, e)
+}
+
+func TestNymSocketManagerStartupRetryPolicy(t *testing.T) {
+	logger := zerolog.Logger{}
+	fakeNymClient := newFakeNymClient(t)
+	// The first handshakes time out, as a nym-client still connecting to its gateway would
+	fakeNymClient.selfAddressRequestsToIgnore = 2
+
+	nymSocketManager, e := lib.NewNymSocketManager(fakeNymClient.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithSelfAddressTimeout(50*time.Millisecond),
+		lib.WithStartupRetryPolicy(lib.RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Millisecond, Jitter: 0.5}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+	require.Equal(t, fakeNymClientAddress, nymSocketManager.GetNymClientId())
+}
+
+func TestNymSocketManagerStartupRetryPolicyGivesUp(t *testing.T) {
+	logger := zerolog.Logger{}
+	socketPath := filepath.Join(t.TempDir(), "nym-client.sock")
+
+	nymSocketManager, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithUnixSocket(socketPath),
+		lib.WithStartupRetryPolicy(lib.RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.ErrorContains(t, e, "3 attempt(s)")
+	require.False(t, nymSocketManager.IsRunning())
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	for _, policy := range []lib.RetryPolicy{
+		{BaseDelay: 0},
+		{MaxAttempts: -1, BaseDelay: time.Second},
+		{BaseDelay: time.Second, MaxDelay: -time.Second},
+		{BaseDelay: time.Second, Jitter: 1.5},
+	} {
+		_, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger), lib.WithStartupRetryPolicy(policy))
+		require.Error(t, e, "%+v", policy)
+	}
+}
@@ -0,0 +1,133 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"golang.org/x/xerrors"
+)
+
+// RouteHandler processes a received message whose payload is a JSON object of the kind it was registered for.
+// payload is the whole JSON object, to be decoded into the type matching the kind
+type RouteHandler func(received NymReceived, payload json.RawMessage, reply func(NymMessage) error)
+
+// route is the handler of a kind, whatever the way it was registered
+type route func(ctx context.Context, received NymReceived, payload json.RawMessage, reply func(NymMessage) error) error
+
+// Router dispatches the received messages according to the "kind" field of their JSON payload.
+// Its Dispatch method is to be given as messageHandler to NewNymSocketManager,
+// or its DispatchContext method to NewNymSocketManagerWithContext
+type Router struct {
+	sync.RWMutex
+
+	routes       map[string]route
+	fallback     Handler
+	errorHandler func(received NymReceived, err error)
+}
+
+type routeContextKey struct{}
+
+// routeContext is what RegisterHandler handlers can get from their context with RouteContext
+type routeContext struct {
+	received NymReceived
+	reply    func(NymMessage) error
+}
+
+func NewRouter() *Router {
+	return &Router{routes: make(map[string]route)}
+}
+
+// Handle registers the handler of the messages of the given kind, replacing the previous one if any
+func (r *Router) Handle(kind string, handler RouteHandler) {
+	r.handle(kind, func(_ context.Context, received NymReceived, payload json.RawMessage, reply func(NymMessage) error) error {
+		handler(received, payload, reply)
+		return nil
+	})
+}
+
+func (r *Router) handle(kind string, handler route) {
+	r.Lock()
+	defer r.Unlock()
+	r.routes[kind] = handler
+}
+
+// RegisterHandler registers the handler of the messages of the given kind, which receives their payload decoded into a T.
+// The received message and the function to reply are available from ctx with RouteContext
+func RegisterHandler[T any](r *Router, kind string, handler func(ctx context.Context, payload T) error) {
+	r.handle(kind, func(ctx context.Context, received NymReceived, payload json.RawMessage, reply func(NymMessage) error) error {
+		var decoded T
+		e := json.Unmarshal(payload, &decoded)
+		if nil != e {
+			return xerrors.Errorf("failed to decode %q message: %w", kind, e)
+		}
+		return handler(context.WithValue(ctx, routeContextKey{}, routeContext{received, reply}), decoded)
+	})
+}
+
+// RouteContext returns the message being handled and the function to reply to it, from the context of a RegisterHandler handler
+func RouteContext(ctx context.Context) (NymReceived, func(NymMessage) error, bool) {
+	routeContext, ok := ctx.Value(routeContextKey{}).(routeContext)
+	return routeContext.received, routeContext.reply, ok
+}
+
+// HandleUnrouted registers the handler of the messages which are not JSON objects, have no kind,
+// or a kind without handler. Without it, these messages are dropped
+func (r *Router) HandleUnrouted(handler Handler) {
+	r.Lock()
+	defer r.Unlock()
+	r.fallback = handler
+}
+
+// HandleErrors registers the function called by Dispatch with the errors of the RegisterHandler handlers.
+// Without it, these errors are dropped
+func (r *Router) HandleErrors(errorHandler func(received NymReceived, err error)) {
+	r.Lock()
+	defer r.Unlock()
+	r.errorHandler = errorHandler
+}
+
+// Dispatch passes received to the handler of its kind, see DispatchContext
+func (r *Router) Dispatch(received NymReceived, reply func(NymMessage) error) {
+	e := r.DispatchContext(context.Background(), received, reply)
+	if nil == e {
+		return
+	}
+
+	r.RLock()
+	errorHandler := r.errorHandler
+	r.RUnlock()
+	if nil != errorHandler {
+		errorHandler(received, e)
+	}
+}
+
+// DispatchContext passes received to the handler of its kind, with ctx for the RegisterHandler handlers,
+// and returns the error of the handler
+func (r *Router) DispatchContext(ctx context.Context, received NymReceived, reply func(NymMessage) error) error {
+	kind := RouteKind(received)
+	kindFound := len(kind) != 0
+
+	r.RLock()
+	handler, routed := r.routes[kind]
+	fallback := r.fallback
+	r.RUnlock()
+
+	if kindFound && routed {
+		return handler(ctx, received, json.RawMessage(received.Message), reply)
+	}
+	if nil != fallback {
+		fallback(received, reply)
+	}
+	return nil
+}
+
+// RouteKind returns the "kind" field of the JSON payload of received, which a Router dispatches it by.
+// It is empty if the payload is not a JSON object or has no kind
+func RouteKind(received NymReceived) string {
+	var envelope struct {
+		Kind string `json:"kind"`
+	}
+	_ = json.Unmarshal([]byte(received.Message), &envelope)
+	return envelope.Kind
+}
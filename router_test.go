@@ -0,0 +1,89 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestRouter(t *testing.T) {
+	type chat struct {
+		Text string `json:"text"`
+	}
+
+	var chats []string
+	var unrouted []string
+	router := lib.NewRouter()
+	router.Handle("chat", func(_ lib.NymReceived, payload json.RawMessage, _ func(lib.NymMessage) error) {
+		var c chat
+		require.NoError(t, json.Unmarshal(payload, &c))
+		chats = append(chats, c.Text)
+	})
+
+	noReply := func(lib.NymMessage) error { return nil }
+
+	// Without fallback, the unrouted messages are dropped
+	router.Dispatch(lib.NymReceived{Message: `{"kind":"file"}`}, noReply)
+
+	router.HandleUnrouted(func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		unrouted = append(unrouted, received.Message)
+	})
+
+	for _, message := range []string{`{"kind":"chat","text":"hello"}`, `{"kind":"file"}`, `{"text":"no kind"}`, "not JSON", `["kind"]`} {
+		router.Dispatch(lib.NymReceived{Message: message}, noReply)
+	}
+
+	require.Equal(t, []string{"hello"}, chats)
+	require.Equal(t, []string{`{"kind":"file"}`, `{"text":"no kind"}`, "not JSON", `["kind"]`}, unrouted)
+}
+
+func TestRouterRegisterHandler(t *testing.T) {
+	type chat struct {
+		Text string `json:"text"`
+	}
+
+	errFailed := xerrors.New("failed")
+	var replied []lib.NymMessage
+	var failures []error
+	router := lib.NewRouter()
+	lib.RegisterHandler(router, "chat", func(ctx context.Context, c chat) error {
+		if "fail" == c.Text {
+			return errFailed
+		}
+		received, reply, ok := lib.RouteContext(ctx)
+		require.True(t, ok)
+		return reply(lib.NewNymReply(received.SenderTag, "echo "+c.Text))
+	})
+	router.HandleErrors(func(_ lib.NymReceived, err error) {
+		failures = append(failures, err)
+	})
+
+	reply := func(msg lib.NymMessage) error {
+		replied = append(replied, msg)
+		return nil
+	}
+	router.Dispatch(lib.NymReceived{Message: `{"kind":"chat","text":"hello"}`, SenderTag: "tag"}, reply)
+	router.Dispatch(lib.NymReceived{Message: `{"kind":"chat","text":"fail"}`}, reply)
+	router.Dispatch(lib.NymReceived{Message: `{"kind":"chat","text":42}`}, reply)
+
+	require.Equal(t, []lib.NymMessage{lib.NewNymReply("tag", "echo hello")}, replied)
+	require.Len(t, failures, 2)
+	require.ErrorIs(t, failures[0], errFailed)
+
+	// DispatchContext returns the errors instead
+	require.ErrorIs(t, router.DispatchContext(context.Background(), lib.NymReceived{Message: `{"kind":"chat","text":"fail"}`}, reply), errFailed)
+	require.Len(t, failures, 2)
+
+	_, _, ok := lib.RouteContext(context.Background())
+	require.False(t, ok)
+}
+
+func TestRouteKind(t *testing.T) {
+	require.Equal(t, "ping", lib.RouteKind(lib.NymReceived{Message: `{"kind":"ping","seq":1}`}))
+	require.Empty(t, lib.RouteKind(lib.NymReceived{Message: `{"seq":1}`}))
+	require.Empty(t, lib.RouteKind(lib.NymReceived{Message: "not JSON"}))
+}
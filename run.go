@@ -0,0 +1,36 @@
+package nymsocketmanager
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+)
+
+// Run starts the manager and blocks until ctx is done or the manager stops, then cleans up.
+// It returns nil once stopped because ctx is done or Stop was called,
+// and the reason of the stop if the manager stopped on its own (e.g. the connection to the nym-client was lost)
+func (n *NymSocketManager) Run(ctx context.Context) error {
+	stopped, e := n.StartContext(ctx)
+	if nil != e {
+		return e
+	}
+
+	if nil == stopped {
+		err := xerrors.Errorf("NymSocketManager is already started")
+		n.logger.Warn().Msg(err.Error())
+		return err
+	}
+
+	select {
+	case <-stopped:
+		n.Lock()
+		defer n.Unlock()
+		return n.stopReason
+
+	// ctx is done, so do not bind the shutdown to it
+	case <-ctx.Done():
+		n.logger.Debug().Msgf("context done (%v), stopping", ctx.Err())
+		n.Stop()
+		return nil
+	}
+}
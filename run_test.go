@@ -0,0 +1,62 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerRunUntilContextDone(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- nymSocketManager.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return nymSocketManager.GetState() == lib.StateRunning
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+
+	select {
+	case e = <-done:
+		require.NoError(t, e)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+	require.Equal(t, lib.StateStopped, nymSocketManager.GetState())
+}
+
+func TestNymSocketManagerRunReturnsConnectionLoss(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	nymSocketManager.OnHandshake(func(string) {
+		go fake.dropConnections()
+	})
+
+	require.Error(t, nymSocketManager.Run(context.Background()))
+}
+
+func TestNymSocketManagerRunFailsToStart(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.server.Close()
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	require.Error(t, nymSocketManager.Run(context.Background()))
+}
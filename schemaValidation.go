@@ -0,0 +1,75 @@
+package nymsocketmanager
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"golang.org/x/xerrors"
+)
+
+// SchemaPolicy defines what happens to the incoming messages not matching the schema of their type, see WithSchemaValidation
+type SchemaPolicy int
+
+const (
+	// Log the violation, and handle the message as far as it can be parsed
+	SchemaPolicyLog SchemaPolicy = iota
+	// Log the violation, and drop the message
+	SchemaPolicyDrop
+	// Handle the message as far as it can be parsed, the ones that cannot be are passed to the OnControlMessage hooks
+	SchemaPolicyDeliver
+)
+
+func (p SchemaPolicy) String() string {
+	switch p {
+	case SchemaPolicyLog:
+		return "Log"
+	case SchemaPolicyDrop:
+		return "Drop"
+	case SchemaPolicyDeliver:
+		return "Deliver"
+	default:
+		return "Unknown"
+	}
+}
+
+// parseTextMessageAs unmarshals s as a T, checking it against the schema of T if set with WithSchemaValidation.
+// It returns nil if the message cannot be handled
+func parseTextMessageAs[T NymMessage](n *NymSocketManager, s []byte, msgType string) NymMessage {
+	var msg T
+
+	if n.schemaValidation {
+		// Wrong types are reported by the decoding itself
+		decoder := json.NewDecoder(bytes.NewReader(s))
+		decoder.DisallowUnknownFields()
+		e := decoder.Decode(&msg)
+		if nil == e {
+			return msg
+		}
+
+		violation := xerrors.Errorf("%v does not match its schema: %v", msg.Name(), e)
+		switch n.schemaPolicy {
+		case SchemaPolicyDrop:
+			n.logger.Warn().Msgf("dropping message: %v", violation)
+			n.messageDropped(DropMalformed, nil, "", s, violation.Error())
+			return nil
+		case SchemaPolicyLog:
+			n.logger.Warn().Msg(violation.Error())
+		default:
+			n.logger.Debug().Msg(violation.Error())
+		}
+		msg = *new(T)
+	}
+
+	e := json.Unmarshal(s, &msg)
+	if nil != e {
+		if n.schemaValidation && SchemaPolicyDeliver == n.schemaPolicy {
+			raw := make(json.RawMessage, len(s))
+			copy(raw, s)
+			return NymControlMessage{NymMessageCommon{Type: msgType}, raw}
+		}
+		n.logger.Warn().Msgf("failed to unmarshal %v: %v", msg.Name(), e)
+		n.messageDropped(DropMalformed, nil, "", s, e.Error())
+		return nil
+	}
+	return msg
+}
@@ -0,0 +1,68 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// startWithGreeting starts a manager whose fake nym-client first sends greeting, and returns the messages passed to the handler
+// and to the OnControlMessage hooks
+func startWithGreeting(t *testing.T, greeting map[string]interface{}, options ...lib.Option) (chan lib.NymReceived, chan lib.NymControlMessage) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	fake.greeting = greeting
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	controlChan := make(chan lib.NymControlMessage, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger), options...)
+	require.NoError(t, e)
+	nymSocketManager.OnControlMessage(func(msg lib.NymControlMessage) { controlChan <- msg })
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	t.Cleanup(func() { nymSocketManager.Stop() })
+
+	return receivedChan, controlChan
+}
+
+func TestNymSocketManagerSchemaValidation(t *testing.T) {
+	withUnknownField := map[string]interface{}{"type": "received", "message": "hello", "priority": 1}
+
+	// Without validation, the unknown field is ignored
+	receivedChan, _ := startWithGreeting(t, withUnknownField)
+	requireReceived(t, receivedChan)
+
+	receivedChan, _ = startWithGreeting(t, withUnknownField, lib.WithSchemaValidation(lib.SchemaPolicyLog))
+	requireReceived(t, receivedChan)
+
+	receivedChan, _ = startWithGreeting(t, withUnknownField, lib.WithSchemaValidation(lib.SchemaPolicyDrop))
+	select {
+	case <-receivedChan:
+		require.Fail(t, "invalid message not dropped")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Messages that cannot be parsed go to the control hooks
+	_, controlChan := startWithGreeting(t, map[string]interface{}{"type": "received", "message": 42}, lib.WithSchemaValidation(lib.SchemaPolicyDeliver))
+	select {
+	case msg := <-controlChan:
+		require.Equal(t, lib.NymReceivedType, msg.Type)
+	case <-time.After(time.Second):
+		require.Fail(t, "invalid message not delivered")
+	}
+}
+
+func requireReceived(t *testing.T, receivedChan chan lib.NymReceived) {
+	select {
+	case received := <-receivedChan:
+		require.Equal(t, "hello", received.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+}
@@ -0,0 +1,62 @@
+package nymsocketmanager
+
+import "sync"
+
+// sendGate keeps track of the Sends in progress, so that they can be flushed before the connection is closed.
+// It keeps track of the messageHandlers in progress the same way
+type sendGate struct {
+	sync.Mutex
+
+	closed  bool
+	pending int
+	// Closed once the gate is closed and no Send is pending anymore
+	flushedChan chan struct{}
+}
+
+// enter registers a Send, unless the gate is closed
+func (g *sendGate) enter() bool {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.closed {
+		return false
+	}
+	g.pending++
+	return true
+}
+
+// leave unregisters a Send registered with enter
+func (g *sendGate) leave() {
+	g.Lock()
+	defer g.Unlock()
+
+	g.pending--
+	if g.closed && 0 == g.pending && nil != g.flushedChan {
+		close(g.flushedChan)
+		g.flushedChan = nil
+	}
+}
+
+// close refuses any new Send and returns a channel closed once the pending ones are done
+func (g *sendGate) close() <-chan struct{} {
+	g.Lock()
+	defer g.Unlock()
+
+	flushedChan := make(chan struct{})
+	g.closed = true
+	if 0 == g.pending {
+		close(flushedChan)
+	} else {
+		g.flushedChan = flushedChan
+	}
+	return flushedChan
+}
+
+// open accepts Sends again
+func (g *sendGate) open() {
+	g.Lock()
+	defer g.Unlock()
+
+	g.closed = false
+	g.flushedChan = nil
+}
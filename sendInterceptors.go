@@ -0,0 +1,36 @@
+package nymsocketmanager
+
+import "sync"
+
+// Sender sends a message to the nym-client, as Send does
+type Sender func(msg NymMessage) error
+
+// SendInterceptor wraps a Sender, e.g. to add envelope headers, enforce a policy or measure the sent messages.
+// It can replace msg before calling next, or not call next at all to block the message, returning an error or not
+type SendInterceptor func(next Sender) Sender
+
+// sendInterceptors holds the interceptors registered with Intercept
+type sendInterceptors struct {
+	sync.Mutex
+	interceptors []SendInterceptor
+}
+
+// Intercept adds interceptors on the send path of Send and of the methods built on it.
+// The interceptors run in the order they are registered, before the message is validated and written.
+// The cover traffic and the requests of the library to the nym-client are not intercepted
+func (n *NymSocketManager) Intercept(interceptors ...SendInterceptor) {
+	n.sendInterceptors.Lock()
+	defer n.sendInterceptors.Unlock()
+	n.sendInterceptors.interceptors = append(n.sendInterceptors.interceptors, interceptors...)
+}
+
+// intercepted returns send wrapped by the registered interceptors
+func (n *NymSocketManager) intercepted(send Sender) Sender {
+	n.sendInterceptors.Lock()
+	defer n.sendInterceptors.Unlock()
+
+	for i := len(n.sendInterceptors.interceptors) - 1; i >= 0; i-- {
+		send = n.sendInterceptors.interceptors[i](send)
+	}
+	return send
+}
@@ -0,0 +1,60 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+)
+
+func TestNymSocketManagerSendInterceptors(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	receivedChan := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		receivedChan <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	errBlocked := xerrors.New("blocked")
+	var observed []string
+	nymSocketManager.Intercept(func(next lib.Sender) lib.Sender {
+		return func(msg lib.NymMessage) error {
+			observed = append(observed, msg.Name())
+			return next(msg)
+		}
+	}, func(next lib.Sender) lib.Sender {
+		return func(msg lib.NymMessage) error {
+			send, ok := msg.(lib.NymSend)
+			if !ok {
+				return next(msg)
+			}
+			if "forbidden" == send.Message {
+				return errBlocked
+			}
+			return next(lib.NewNymSend("header|"+send.Message, send.Recipient))
+		}
+	})
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.ErrorIs(t, nymSocketManager.Send(lib.NewNymSend("forbidden", nymSocketManager.GetNymClientId())), errBlocked)
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", nymSocketManager.GetNymClientId())))
+
+	select {
+	case received := <-receivedChan:
+		require.Equal(t, "header|hello", received.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+
+	// The selfAddress request of the handshake is not intercepted
+	require.Equal(t, []string{"NymSend", "NymSend"}, observed)
+	require.Equal(t, int32(1), fake.sendRequests.Load())
+}
@@ -0,0 +1,107 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"time"
+)
+
+// SendPriority hints how urgent a message is
+type SendPriority int
+
+const (
+	PriorityNormal SendPriority = iota
+	// Replayed before the messages of normal priority once the connection is restored
+	PriorityHigh
+	// Sent after the other messages by the priority queue, e.g. for large transfers, see WithPriorityQueue
+	PriorityBulk
+	// Above PriorityHigh, e.g. for the requests to the nym-client: sent first by the priority queue, and replayed first
+	PriorityControl
+)
+
+func (p SendPriority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "Normal"
+	case PriorityHigh:
+		return "High"
+	case PriorityBulk:
+		return "Bulk"
+	case PriorityControl:
+		return "Control"
+	default:
+		return "Unknown"
+	}
+}
+
+// urgency ranks the priorities replayed ahead of the messages of normal priority, 0 for the others
+func (p SendPriority) urgency() int {
+	switch p {
+	case PriorityControl:
+		return 2
+	case PriorityHigh:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// SendOption overrides the manager-wide behaviour for a single message, see SendWithOptions
+type SendOption func(*sendOptions)
+
+type sendOptions struct {
+	writeTimeout time.Duration
+	onComplete   func(err error)
+	priority     SendPriority
+	encoding     WireEncoding
+	confirmation *deliveryConfirmation
+	// Context the span of the Send is a child of, see SendContext
+	traceContext context.Context
+	// Attached to the logs and events of the message, see WithMessageID
+	messageID string
+}
+
+// WithWriteTimeout bounds the time to write the message on the connection, instead of the timeout set with WithDefaultWriteTimeout.
+// Note that the connection cannot be used anymore once a write timed out, it is then replaced as if it was lost
+func WithWriteTimeout(timeout time.Duration) SendOption {
+	return func(o *sendOptions) {
+		o.writeTimeout = timeout
+	}
+}
+
+// WithCompletion registers a callback called once the message is written on the connection, or could not be.
+// Unlike the error returned by SendWithOptions, it accounts for the messages buffered for replay.
+// It is called synchronously by the manager: it must return quickly and must not call Start, Stop or Restart
+func WithCompletion(onComplete func(err error)) SendOption {
+	return func(o *sendOptions) {
+		o.onComplete = onComplete
+	}
+}
+
+// WithPriority sets the priority of the message
+func WithPriority(priority SendPriority) SendOption {
+	return func(o *sendOptions) {
+		o.priority = priority
+	}
+}
+
+// WithEncoding sets how the message is written on the connection, instead of the encoding of the manager
+func WithEncoding(encoding WireEncoding) SendOption {
+	return func(o *sendOptions) {
+		o.encoding = encoding
+	}
+}
+
+func newSendOptions(options []SendOption) *sendOptions {
+	o := &sendOptions{}
+	for _, option := range options {
+		option(o)
+	}
+	return o
+}
+
+// complete calls the completion callback, if any
+func (o *sendOptions) complete(err error) {
+	if nil != o && nil != o.onComplete {
+		o.onComplete(err)
+	}
+}
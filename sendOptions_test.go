@@ -0,0 +1,76 @@
+package nymsocketmanager_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerSendWithOptions(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	// Completions are notified for failed sends too
+	completions := make(chan error, 2)
+	require.Error(t, nymSocketManager.SendWithOptions(lib.NewNymSend("hello", fakePeerAddress),
+		lib.WithCompletion(func(err error) { completions <- err })))
+	require.Error(t, <-completions)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend("hello", fakePeerAddress),
+		lib.WithWriteTimeout(time.Second), lib.WithCompletion(func(err error) { completions <- err })))
+	require.NoError(t, <-completions)
+}
+
+func TestNymSocketManagerReplaysHighPriorityFirst(t *testing.T) {
+	logger := zerolog.Logger{}
+	first := newFakeNymClient(t)
+	second := newFakeNymClient(t)
+	second.upgradeDelay = 300 * time.Millisecond
+
+	nymSocketManager, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithFallbackURIs(second.URI()), lib.WithReplayBuffer(4, lib.OverflowDropOldest))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	first.dropConnections()
+	require.Eventually(t, func() bool {
+		return nymSocketManager.GetState() == lib.StateConnecting
+	}, time.Second, time.Millisecond)
+
+	var mutex sync.Mutex
+	var replayed []string
+	for _, message := range []struct {
+		name     string
+		priority lib.SendPriority
+	}{{"first", lib.PriorityNormal}, {"second", lib.PriorityNormal}, {"urgent", lib.PriorityHigh}, {"control", lib.PriorityControl}} {
+		name := message.name
+		require.NoError(t, nymSocketManager.SendWithOptions(lib.NewNymSend(name, fakePeerAddress), lib.WithPriority(message.priority),
+			lib.WithCompletion(func(err error) {
+				require.NoError(t, err)
+				mutex.Lock()
+				defer mutex.Unlock()
+				replayed = append(replayed, name)
+			})))
+	}
+
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(replayed) == 4
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"control", "urgent", "first", "second"}, replayed)
+}
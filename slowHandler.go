@@ -0,0 +1,55 @@
+package nymsocketmanager
+
+import "time"
+
+// SlowHandler configures the detection of the calls of the messageHandler taking too long, see WithSlowHandlerDetection
+type SlowHandler struct {
+	// Duration above which a call is slow
+	Threshold time.Duration
+	// Returns the message type reported, the kind the messages are routed by (see RouteKind) if nil
+	MessageType func(NymReceived) string
+}
+
+// SlowHandlerEvent is sent each time a call of the messageHandler, middlewares included, took longer than the threshold,
+// see WithSlowHandlerDetection
+type SlowHandlerEvent struct {
+	EventCommon
+	MessageID   string
+	MessageType string
+	// Size of the payload handled
+	Size      int
+	Duration  time.Duration
+	Threshold time.Duration
+}
+
+func (SlowHandlerEvent) Name() string {
+	return "slowHandler"
+}
+
+// watchHandler starts timing the handling of received, and returns the function to call once it is handled.
+// A warning is logged as soon as the threshold is exceeded, so that a handler which never returns is noticed too
+func (n *NymSocketManager) watchHandler(received NymReceived) func() {
+	if nil == n.slowHandler {
+		return func() {}
+	}
+
+	threshold := n.slowHandler.Threshold
+	messageType := n.slowHandler.MessageType(received)
+	handledAt := time.Now()
+	timer := time.AfterFunc(threshold, func() {
+		n.logger.Warn().Str(MessageIDField, received.MessageID).Str("messageType", messageType).Int("size", len(received.Message)).
+			Msgf("messageHandler still running after %v", threshold)
+	})
+
+	return func() {
+		timer.Stop()
+		duration := time.Since(handledAt)
+		if duration <= threshold {
+			return
+		}
+		n.logger.Warn().Str(MessageIDField, received.MessageID).Str("messageType", messageType).Int("size", len(received.Message)).
+			Msgf("messageHandler took %v, more than %v", duration, threshold)
+		n.stats.slowHandled()
+		n.events.publish(SlowHandlerEvent{EventCommon{time.Now()}, received.MessageID, messageType, len(received.Message), duration, threshold})
+	}
+}
@@ -0,0 +1,59 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerSlowHandlerDetection(t *testing.T) {
+	output := &lockedBuffer{}
+	logger := zerolog.New(output)
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		if received.Message == `{"kind":"slow"}` {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}, lib.ZerologLogger(&logger), lib.WithSlowHandlerDetection(lib.SlowHandler{Threshold: 20 * time.Millisecond}))
+	require.NoError(t, e)
+	events := nymSocketManager.Events()
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(`{"kind":"fast"}`, nymSocketManager.GetNymClientId())))
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend(`{"kind":"slow"}`, nymSocketManager.GetNymClientId())))
+
+	var slow lib.SlowHandlerEvent
+	require.Eventually(t, func() bool {
+		select {
+		case event := <-events:
+			var ok bool
+			slow, ok = event.(lib.SlowHandlerEvent)
+			return ok
+		default:
+			return false
+		}
+	}, time.Second, time.Millisecond)
+	require.Equal(t, "slow", slow.MessageType)
+	require.Equal(t, len(`{"kind":"slow"}`), slow.Size)
+	require.NotEmpty(t, slow.MessageID)
+	require.GreaterOrEqual(t, slow.Duration, 50*time.Millisecond)
+	require.Equal(t, 20*time.Millisecond, slow.Threshold)
+
+	require.Equal(t, uint64(1), nymSocketManager.Stats().SlowHandlerCalls)
+	require.Equal(t, 1, output.count("messageHandler still running after 20ms"))
+	require.Equal(t, 1, output.count("more than 20ms"))
+}
+
+func TestNymSocketManagerSlowHandlerDetectionInvalid(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://127.0.0.1:1977", emptyProcessing, lib.ZerologLogger(&logger), lib.WithSlowHandlerDetection(lib.SlowHandler{}))
+	require.Error(t, e)
+}
@@ -1,12 +1,14 @@
 package nymsocketmanager
 
 import (
+	"sync/atomic"
+	"time"
+
 	"github.com/gorilla/websocket"
-	"github.com/rs/zerolog"
 	"golang.org/x/xerrors"
 )
 
-func NewSocketListener(socket *websocket.Conn, messageHandler func([]byte), toCallWhenClosed func(), parentLogger *zerolog.Logger) (*SocketListener, chan struct{}, error) {
+func NewSocketListener(socket *websocket.Conn, messageHandler func([]byte), toCallWhenClosed func(), parentLogger Logger) (*SocketListener, chan struct{}, error) {
 
 	if nil == socket {
 		err := xerrors.Errorf("websocket connection cannot be undefined")
@@ -27,12 +29,13 @@ func NewSocketListener(socket *websocket.Conn, messageHandler func([]byte), toCa
 
 	closedSocketChan := make(chan struct{}, 1)
 
-	localLogger := parentLogger.With().Str(ComponentField, "SocketListener").Logger()
+	localLogger := newComponentLogger(parentLogger, "SocketListener")
 
 	return &SocketListener{
 		socket:           socket,
 		closedSocketChan: closedSocketChan,
-		logger:           &localLogger,
+		logger:           localLogger,
+		messageLogger:    localLogger,
 		messageHandler:   messageHandler,
 		toCallWhenClosed: toCallWhenClosed,
 	}, closedSocketChan, nil
@@ -46,7 +49,43 @@ type SocketListener struct {
 	toCallWhenClosed func()
 
 	closedSocketChan chan struct{}
-	logger           *zerolog.Logger
+	// Why the socket was closed, set before closedSocketChan is closed
+	closeReason error
+	// Set by the owner of the socket once it sent a close frame
+	closeRequested atomic.Bool
+	// Whether to consider the abnormal closure reported by gorilla after a requested closure as a normal one
+	ignoreAbnormalClosure bool
+	// Whether to call messageHandler from the listening goroutine, in the order the messages are read,
+	// for owners which bound the time it takes
+	dispatchInline bool
+
+	// Related to the read-stall watchdog
+	lastReadAt         atomic.Int64
+	readStallThreshold time.Duration
+	onReadStall        func(idle time.Duration)
+
+	// Related to the read timeout, disabled while readIdleTimeout is 0
+	readIdleTimeout  time.Duration
+	readProbeTimeout time.Duration
+
+	// Related to the maximum message size, not enforced while maxMessageSize is 0
+	maxMessageSize int64
+	oversizePolicy OversizePolicy
+	onOversized    func(size int64)
+
+	logger *componentLogger
+	// logger, sampled for the logs written for each message read, see SetLogSampler
+	messageLogger *componentLogger
+	// Log the size and hash of the frames read instead of their content, see SetPayloadRedaction
+	redactPayloads bool
+}
+
+// SetReadStallWatchdog makes the SocketListener call onStall when no frame (pongs included) was read from the socket for threshold,
+// which detects peers that stop delivering traffic without closing the socket.
+// onStall is called once per stall, from its own goroutine. It must be called before Listen
+func (s *SocketListener) SetReadStallWatchdog(threshold time.Duration, onStall func(idle time.Duration)) {
+	s.readStallThreshold = threshold
+	s.onReadStall = onStall
 }
 
 func (s *SocketListener) Listen() {
@@ -57,16 +96,54 @@ func (s *SocketListener) Listen() {
 		defer s.toCallWhenClosed()
 	}
 
+	s.markRead()
+	s.extendReadDeadline()
+	watchReadStalls := s.readStallThreshold > 0 && nil != s.onReadStall
+	if watchReadStalls || s.readIdleTimeout > 0 {
+		// Pongs are read within ReadMessage, without being returned: they are spotted through the pong handler
+		pongHandler := s.socket.PongHandler()
+		s.socket.SetPongHandler(func(appData string) error {
+			s.markRead()
+			s.extendReadDeadline()
+			return pongHandler(appData)
+		})
+
+		watchdogStopChan := make(chan struct{})
+		defer close(watchdogStopChan)
+		if watchReadStalls {
+			go s.watchReadStalls(watchdogStopChan)
+		}
+		if s.readIdleTimeout > 0 {
+			go s.probeIdleSocket(watchdogStopChan)
+		}
+	}
+
 	for nil != s.socket {
-		_, receivedMessage, e := s.socket.ReadMessage()
+		_, receivedMessage, e := s.readMessage()
 		if nil != e {
+			// gorilla reports an abnormal closure even when the closure was requested (ref: https://github.com/gorilla/websocket/pull/487)
+			if s.ignoreAbnormalClosure && s.closeRequested.Load() && websocket.IsCloseError(e, websocket.CloseAbnormalClosure) {
+				s.logger.Debug().Msg("socket closed on request")
+				break
+			}
+			if timeoutError := s.readTimeoutError(e); nil != timeoutError {
+				s.logger.Warn().Msg(timeoutError.Error())
+				e = timeoutError
+			}
 			s.logger.Debug().Msgf("Read: \"%v\"", e)
+			s.closeReason = e
 			break
 		}
+		s.markRead()
+		s.extendReadDeadline()
 
 		// Process msg: start a goroutine to handle the request
-		s.logger.Trace().Msgf("recv: \"%s\"", string(receivedMessage))
-		go s.messageHandler(receivedMessage)
+		s.messageLogger.Trace().Msgf("recv: \"%s\"", loggableFrame(receivedMessage, s.redactPayloads))
+		if s.dispatchInline {
+			s.messageHandler(receivedMessage)
+		} else {
+			go s.messageHandler(receivedMessage)
+		}
 	}
 
 	// When the connection will be closed, will close the chan
@@ -76,3 +153,33 @@ func (s *SocketListener) Listen() {
 
 	s.logger.Debug().Msg("socketListener shut down")
 }
+
+func (s *SocketListener) markRead() {
+	s.lastReadAt.Store(time.Now().UnixNano())
+}
+
+// watchReadStalls periodically checks when the socket was last read, until stopChan is closed
+func (s *SocketListener) watchReadStalls(stopChan chan struct{}) {
+	ticker := time.NewTicker(s.readStallThreshold / 4)
+	defer ticker.Stop()
+
+	stalled := false
+	for {
+		select {
+		case <-stopChan:
+			return
+
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, s.lastReadAt.Load()))
+			if idle < s.readStallThreshold {
+				stalled = false
+				continue
+			}
+			if !stalled {
+				stalled = true
+				s.logger.Warn().Msgf("nothing read from socket for %v", idle)
+				s.onReadStall(idle)
+			}
+		}
+	}
+}
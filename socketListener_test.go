@@ -0,0 +1,54 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerReadStallCallback(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	stalled := make(chan time.Duration, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithReadStallWatchdog(100*time.Millisecond, func(idle time.Duration) { stalled <- idle }))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	select {
+	case idle := <-stalled:
+		require.GreaterOrEqual(t, idle, 100*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("read stall was not detected")
+	}
+}
+
+func TestNymSocketManagerReadStallReconnects(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithReadStallWatchdog(100*time.Millisecond, nil))
+	require.NoError(t, e)
+
+	stopped, e := nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.Eventually(t, func() bool {
+		return fake.connectionCount() >= 2 && nymSocketManager.GetState() == lib.StateRunning
+	}, time.Second, 10*time.Millisecond)
+
+	select {
+	case <-stopped:
+		t.Fatal("manager should not stop when the stalled connection can be replaced")
+	default:
+	}
+}
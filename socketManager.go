@@ -5,11 +5,10 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/rs/zerolog"
 	"golang.org/x/xerrors"
 )
 
-func NewSocketManager(connectionURI string, messageHandler func([]byte, func([]byte) error), parentLogger *zerolog.Logger) (*SocketManager, error) {
+func NewSocketManager(connectionURI string, messageHandler func([]byte, func([]byte) error), parentLogger Logger) (*SocketManager, error) {
 	if len(connectionURI) == 0 {
 		err := xerrors.Errorf("connection URI cannot be empty")
 		return nil, err
@@ -20,12 +19,12 @@ func NewSocketManager(connectionURI string, messageHandler func([]byte, func([]b
 		return nil, err
 	}
 
-	socketLogger := parentLogger.With().Str(ComponentField, "SocketManager").Logger()
+	socketLogger := newComponentLogger(parentLogger, "SocketManager")
 
 	return &SocketManager{
 		connectionURI:  connectionURI,
 		messageHandler: messageHandler,
-		logger:         &socketLogger,
+		logger:         socketLogger,
 	}, nil
 }
 
@@ -44,7 +43,7 @@ type SocketManager struct {
 	// Related to sending
 	senderMutex sync.Mutex
 
-	logger *zerolog.Logger
+	logger *componentLogger
 }
 
 func (s *SocketManager) IsRunning() bool {
@@ -78,7 +77,7 @@ func (s *SocketManager) Start() (chan struct{}, error) {
 	// After which we start a listener for the packets
 	s.socketListener, s.closedSocketListenerChan, e = NewSocketListener(s.connection, func(msg []byte) {
 		s.messageHandler(msg, s.Send)
-	}, s.Stop, s.logger)
+	}, s.Stop, s.logger.output)
 	if nil != e {
 		err := xerrors.Errorf("failed to initiate the socketListener: %v", e)
 		s.logger.Warn().Msg(err.Error())
@@ -154,7 +153,7 @@ func (s *SocketManager) selfDestruct() {
 		s.logger.Trace().Msg("closing local connection")
 		e := s.connection.Close()
 		if e != nil {
-			s.logger.Err(e).Msg("")
+			s.logger.Error().Msg(e.Error())
 		}
 		s.connection = nil
 	}
@@ -174,14 +173,14 @@ func (s *SocketManager) Send(message []byte) error {
 	defer s.senderMutex.Unlock()
 
 	if nil == s.connection {
-		err := xerrors.Errorf("connection is closed, cannot send to %v", s.connectionURI)
+		err := xerrors.Errorf("cannot send to %v: %w", s.connectionURI, ErrConnectionClosed)
 		s.logger.Warn().Msg(err.Error())
 		return err
 	}
 
 	e := s.connection.WriteMessage(websocket.TextMessage, message)
 	if nil != e {
-		err := xerrors.Errorf("failed to send message: %v", e)
+		err := xerrors.Errorf("%w: %v", ErrWriteFailed, e)
 		s.logger.Warn().Msg(err.Error())
 		return err
 	}
@@ -196,7 +195,7 @@ func (s *SocketManager) sendCloseSignal() error {
 	defer s.senderMutex.Unlock()
 
 	if nil == s.connection {
-		err := xerrors.Errorf("connection is undefined. Is the SocketManager started?")
+		err := xerrors.Errorf("cannot close the connection to %v: %w", s.connectionURI, ErrConnectionClosed)
 		s.logger.Warn().Msg(err.Error())
 		return err
 	}
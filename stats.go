@@ -0,0 +1,237 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of the activity of the manager, see Stats
+type Stats struct {
+	// Messages written to and read from the nym-clients, by the Name of their type, the ones of the library included
+	MessagesSent     map[string]uint64
+	MessagesReceived map[string]uint64
+	// Received messages dropped, by the name of the filter rejecting them, see AddFilter
+	MessagesFiltered map[string]uint64
+	// Received messages dropped as duplicates, see WithDeduplication
+	DuplicatesDropped uint64
+	// Calls of the messageHandler slower than the threshold, see WithSlowHandlerDetection
+	SlowHandlerCalls uint64
+	// Messages and frames dropped, received or sent, by DropReason
+	MessagesDropped map[DropReason]uint64
+	// Size of the frames written to and read from the nym-clients
+	BytesSent     uint64
+	BytesReceived uint64
+
+	// Connections established after the first one, after a failover, a lost connection or a restart
+	Reconnects uint64
+	// Time taken by the nym-client to answer the selfAddress request of the last connection
+	HandshakeDuration time.Duration
+	// Zero if nothing was sent or received yet
+	LastSentAt     time.Time
+	LastReceivedAt time.Time
+
+	// Messages waiting in the replay buffer, see WithReplayBuffer
+	ReplayBufferDepth int
+	// Messages waiting for a worker of the handler pool, see WithHandlerPool
+	HandlerQueueDepth int
+	// Messages waiting for the writer goroutine, see WithAsyncSend
+	SendQueueDepth int
+	// Messages held while paused, see Pause and WithPauseBuffer
+	PausedMessages int
+
+	// Messages dropped by the overflow policy of each queue, or when the manager stopped
+	ReplayBufferDropped uint64
+	HandlerQueueDropped uint64
+	SendQueueDropped    uint64
+
+	// Messages per second currently allowed by the congestion control, zero if disabled, see WithCongestionControl
+	SendThrottleRate float64
+	// Times the congestion control slowed the sends down
+	CongestionSlowdowns uint64
+
+	// Round-trip time through the mixnet and its jitter, smoothed over the probes received back, see WithLatencyProbe
+	ProbeRTT    time.Duration
+	ProbeJitter time.Duration
+	// Share of the probes lost, smoothed, between 0 and 1
+	ProbeLoss  float64
+	ProbesSent uint64
+	ProbesLost uint64
+
+	// Quality of the current connection, and the smoothed estimates it is derived from, see WithConnectionQuality
+	Quality Quality
+	// Round-trip time of the websocket pings to the nym-client
+	PingRTT      time.Duration
+	WriteLatency time.Duration
+	// Share of the writes and pings failing, between 0 and 1
+	ErrorRate float64
+}
+
+// statsCollector holds the counters of Stats
+type statsCollector struct {
+	sync.Mutex
+
+	messagesSent      map[string]uint64
+	messagesReceived  map[string]uint64
+	messagesFiltered  map[string]uint64
+	messagesDropped   map[DropReason]uint64
+	duplicatesDropped uint64
+	slowHandlerCalls  uint64
+	bytesSent         uint64
+	bytesReceived     uint64
+	connections       uint64
+	handshakeDuration time.Duration
+	lastSentAt        time.Time
+	lastReceivedAt    time.Time
+	lastHandshakeAt   time.Time
+	// Of the last connection established, the URI being redacted, see DebugHandler
+	connectionURI string
+	clientID      string
+	// Zero while stopped, see HealthStatus
+	startedAt time.Time
+}
+
+func (s *statsCollector) sent(msg NymMessage, size int) {
+	s.Lock()
+	defer s.Unlock()
+
+	if nil == s.messagesSent {
+		s.messagesSent = make(map[string]uint64)
+	}
+	s.messagesSent[msg.Name()]++
+	s.bytesSent += uint64(size)
+	s.lastSentAt = time.Now()
+}
+
+func (s *statsCollector) received(msg NymMessage, size int, at time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	if nil == s.messagesReceived {
+		s.messagesReceived = make(map[string]uint64)
+	}
+	s.messagesReceived[msg.Name()]++
+	s.bytesReceived += uint64(size)
+	s.lastReceivedAt = at
+}
+
+func (s *statsCollector) filteredBy(name string) {
+	s.Lock()
+	defer s.Unlock()
+
+	if nil == s.messagesFiltered {
+		s.messagesFiltered = make(map[string]uint64)
+	}
+	s.messagesFiltered[name]++
+}
+
+func (s *statsCollector) dropped(reason DropReason) {
+	s.Lock()
+	defer s.Unlock()
+
+	if nil == s.messagesDropped {
+		s.messagesDropped = make(map[DropReason]uint64)
+	}
+	s.messagesDropped[reason]++
+}
+
+func (s *statsCollector) duplicateDropped() {
+	s.Lock()
+	defer s.Unlock()
+	s.duplicatesDropped++
+}
+
+func (s *statsCollector) slowHandled() {
+	s.Lock()
+	defer s.Unlock()
+	s.slowHandlerCalls++
+}
+
+func (s *statsCollector) connected(connectionURI string, clientID string, handshakeDuration time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.connections++
+	s.connectionURI = redactURI(connectionURI)
+	s.clientID = clientID
+	s.handshakeDuration = handshakeDuration
+	s.lastHandshakeAt = time.Now()
+}
+
+// setStarted records when the manager was started, or clears it once stopped
+func (s *statsCollector) setStarted(startedAt time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	s.startedAt = startedAt
+}
+
+// Stats returns a snapshot of the counters of the manager since its creation, and of its current queues
+func (n *NymSocketManager) Stats() Stats {
+	n.stats.Lock()
+	stats := Stats{
+		MessagesSent:      make(map[string]uint64, len(n.stats.messagesSent)),
+		MessagesReceived:  make(map[string]uint64, len(n.stats.messagesReceived)),
+		MessagesFiltered:  make(map[string]uint64, len(n.stats.messagesFiltered)),
+		MessagesDropped:   make(map[DropReason]uint64, len(n.stats.messagesDropped)),
+		DuplicatesDropped: n.stats.duplicatesDropped,
+		SlowHandlerCalls:  n.stats.slowHandlerCalls,
+		BytesSent:         n.stats.bytesSent,
+		BytesReceived:     n.stats.bytesReceived,
+		HandshakeDuration: n.stats.handshakeDuration,
+		LastSentAt:        n.stats.lastSentAt,
+		LastReceivedAt:    n.stats.lastReceivedAt,
+	}
+	for name, count := range n.stats.messagesSent {
+		stats.MessagesSent[name] = count
+	}
+	for name, count := range n.stats.messagesReceived {
+		stats.MessagesReceived[name] = count
+	}
+	for name, count := range n.stats.messagesFiltered {
+		stats.MessagesFiltered[name] = count
+	}
+	for reason, count := range n.stats.messagesDropped {
+		stats.MessagesDropped[reason] = count
+	}
+	if n.stats.connections > 1 {
+		stats.Reconnects = n.stats.connections - 1
+	}
+	n.stats.Unlock()
+
+	n.pauseGate.Lock()
+	stats.PausedMessages = len(n.pauseGate.held)
+	n.pauseGate.Unlock()
+
+	if nil != n.replayBuffer {
+		n.replayBuffer.Lock()
+		stats.ReplayBufferDepth = len(n.replayBuffer.messages)
+		stats.ReplayBufferDropped = n.replayBuffer.dropped
+		n.replayBuffer.Unlock()
+	}
+	if nil != n.handlerPool {
+		stats.HandlerQueueDepth = n.handlerPool.depth()
+		stats.HandlerQueueDropped = n.handlerPool.dropped.Load()
+	}
+	if nil != n.asyncSender {
+		n.asyncSender.Lock()
+		stats.SendQueueDepth = len(n.asyncSender.queue)
+		stats.SendQueueDropped = n.asyncSender.dropped.Load()
+		n.asyncSender.Unlock()
+	}
+	if nil != n.throttle {
+		stats.SendThrottleRate, stats.CongestionSlowdowns = n.throttle.rate(time.Now())
+	}
+	if nil != n.probe {
+		n.probe.Lock()
+		stats.ProbeRTT, stats.ProbeJitter = seconds(n.probe.rtt), seconds(n.probe.jitter)
+		stats.ProbeLoss, stats.ProbesSent, stats.ProbesLost = n.probe.loss, n.probe.sent, n.probe.lost
+		n.probe.Unlock()
+	}
+	if nil != n.quality {
+		n.quality.Lock()
+		stats.Quality, stats.PingRTT, stats.WriteLatency = n.quality.quality, seconds(n.quality.rtt), seconds(n.quality.writeLatency)
+		stats.ErrorRate = n.quality.errorRate
+		n.quality.Unlock()
+	}
+
+	return stats
+}
@@ -0,0 +1,48 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerStats(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithReplayBuffer(2, lib.OverflowDropOldest))
+	require.NoError(t, e)
+
+	stats := nymSocketManager.Stats()
+	require.Empty(t, stats.MessagesSent)
+	require.True(t, stats.LastSentAt.IsZero())
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", nymSocketManager.GetNymClientId())))
+	require.Eventually(t, func() bool { return 1 == nymSocketManager.Stats().MessagesReceived[lib.NymReceivedType] }, time.Second, 10*time.Millisecond)
+
+	stats = nymSocketManager.Stats()
+	require.Equal(t, map[string]uint64{"NymSelfAddressRequest": 1, "NymSend": 1}, stats.MessagesSent)
+	require.Equal(t, map[string]uint64{"NymSelfAddressReply": 1, lib.NymReceivedType: 1}, stats.MessagesReceived)
+	require.NotZero(t, stats.BytesSent)
+	require.NotZero(t, stats.BytesReceived)
+	require.NotZero(t, stats.HandshakeDuration)
+	require.False(t, stats.LastSentAt.IsZero())
+	require.False(t, stats.LastReceivedAt.IsZero())
+	require.Zero(t, stats.Reconnects)
+	require.Zero(t, stats.ReplayBufferDepth)
+
+	_, e = nymSocketManager.Restart()
+	require.NoError(t, e)
+	require.Equal(t, uint64(1), nymSocketManager.Stats().Reconnects)
+
+	// The snapshot is not altered by the manager
+	stats.MessagesSent["NymSend"] = 42
+	require.Equal(t, uint64(1), nymSocketManager.Stats().MessagesSent["NymSend"])
+}
@@ -0,0 +1,489 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/notrustverify/nymsocketmanager/address"
+	"golang.org/x/xerrors"
+)
+
+const (
+	// Marks the messages holding a frame of a stream, followed by "<id>:<sequence>:<kind>:<return address>:<base64 data>"
+	streamPrefix = "\x1eNSMSTREAM:"
+	// Kinds of frame
+	streamData  = "D"
+	streamClose = "F"
+
+	// Bytes written to a stream are sent once they fill a frame...
+	streamFrameSize = 32 * 1024
+	// ... or after this delay, unless flushed meanwhile
+	streamFlushDelay = 10 * time.Millisecond
+	// Number of incoming streams waiting for AcceptStream, the frames of the other ones are dropped
+	streamAcceptBacklog = 16
+	// Number of frames past the next one to read which are kept until it is received, a frame further ahead fails the stream
+	streamReceiveWindow = 64
+	// Number of streams closed locally whose identifiers are remembered, the oldest ones are forgotten first
+	maxClosedStreams = 4096
+)
+
+// ErrStreamClosed is returned by the operations on a Stream after Close, or once the manager stopped
+var ErrStreamClosed = xerrors.New("stream closed")
+
+// ErrStreamWindowExceeded is returned by the operations on a Stream once a frame was received too far ahead of the next one to read,
+// the frames in between being deemed lost, as they are not retransmitted
+var ErrStreamWindowExceeded = xerrors.New("stream receive window exceeded")
+
+// Stream is a bidirectional byte stream with another NymSocketManager, opened with OpenStream or AcceptStream.
+// The bytes are framed into mixnet messages, numbered so that they are read in the order they were written
+type Stream struct {
+	manager *NymSocketManager
+	id      string
+	remote  string
+
+	// Related to writing
+	writeMutex   sync.Mutex
+	writeBuffer  []byte
+	nextWriteSeq uint64
+	flushTimer   *time.Timer
+	// Error of the last automatic flush, returned by the next Write
+	writeErr    error
+	writeClosed bool
+	// Unix time in nanoseconds after which Write fails, 0 for none
+	writeDeadline atomic.Int64
+
+	// Related to reading
+	sync.Mutex
+	readBuffer  []byte
+	pending     map[uint64][]byte
+	nextReadSeq uint64
+	// Sequence number of the close frame of the remote, valid if remoteClosed is set
+	closeSeq     uint64
+	remoteClosed bool
+	readErr      error
+	// Time after which Read fails, zero for none
+	readDeadline time.Time
+	// Signaled each time the reading state changes
+	readableChan chan struct{}
+}
+
+// streamTracker routes the frames received to their Stream
+type streamTracker struct {
+	sync.Mutex
+
+	streams map[string]*Stream
+	// Identifiers of the streams closed locally, so that their late frames do not open new ones
+	closed      map[string]struct{}
+	closedOrder []string
+	acceptChan  chan *Stream
+}
+
+func (t *streamTracker) init() {
+	if nil == t.streams {
+		t.streams = make(map[string]*Stream)
+		t.closed = make(map[string]struct{})
+		t.acceptChan = make(chan *Stream, streamAcceptBacklog)
+	}
+}
+
+func (t *streamTracker) accepted() chan *Stream {
+	t.Lock()
+	defer t.Unlock()
+	t.init()
+	return t.acceptChan
+}
+
+func (t *streamTracker) add(stream *Stream) {
+	t.Lock()
+	defer t.Unlock()
+	t.init()
+	t.streams[stream.id] = stream
+}
+
+func (t *streamTracker) remove(id string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.streams, id)
+	if _, known := t.closed[id]; !known {
+		t.closedOrder = append(t.closedOrder, id)
+	}
+	t.closed[id] = struct{}{}
+
+	for len(t.closedOrder) > maxClosedStreams {
+		delete(t.closed, t.closedOrder[0])
+		t.closedOrder = t.closedOrder[1:]
+	}
+}
+
+// get returns the stream of a received frame, or a new incoming one queued for AcceptStream
+func (t *streamTracker) get(manager *NymSocketManager, id string, remote string) (*Stream, error) {
+	t.Lock()
+	defer t.Unlock()
+	t.init()
+
+	if stream, ok := t.streams[id]; ok {
+		if remote != stream.remote {
+			return nil, xerrors.Errorf("frame of stream %v comes from %v instead of %v", id, remote, stream.remote)
+		}
+		return stream, nil
+	}
+	if _, ok := t.closed[id]; ok {
+		return nil, xerrors.Errorf("stream %v is closed", id)
+	}
+
+	stream := newStream(manager, id, remote)
+	select {
+	case t.acceptChan <- stream:
+		t.streams[id] = stream
+		return stream, nil
+	default:
+		return nil, xerrors.Errorf("%d incoming streams are waiting to be accepted, refusing stream %v", streamAcceptBacklog, id)
+	}
+}
+
+// closeAll makes the operations on the streams fail, the manager being stopped
+func (t *streamTracker) closeAll() {
+	t.Lock()
+	streams := t.streams
+	t.streams = make(map[string]*Stream)
+	t.Unlock()
+
+	for _, stream := range streams {
+		stream.fail(ErrStreamClosed)
+	}
+}
+
+// OpenStream opens a Stream with the NymSocketManager at recipient, which gets it through AcceptStream.
+// Nothing is sent until the first Write
+func (n *NymSocketManager) OpenStream(recipient string) (*Stream, error) {
+	e := address.Validate(recipient)
+	if nil != e {
+		err := xerrors.Errorf("cannot open stream: %v", e)
+		n.logger.Warn().Msg(err.Error())
+		return nil, err
+	}
+
+	id, e := newRandomID()
+	if nil != e {
+		n.logger.Warn().Msg(e.Error())
+		return nil, e
+	}
+
+	stream := newStream(n, id, recipient)
+	n.streams.add(stream)
+	return stream, nil
+}
+
+// AcceptStream waits for a Stream opened by a peer with OpenStream, until ctx is done
+func (n *NymSocketManager) AcceptStream(ctx context.Context) (*Stream, error) {
+	select {
+	case stream := <-n.streams.accepted():
+		return stream, nil
+	case <-ctx.Done():
+		err := xerrors.Errorf("no stream accepted: %w", ctx.Err())
+		return nil, err
+	}
+}
+
+func newStream(manager *NymSocketManager, id string, remote string) *Stream {
+	return &Stream{
+		manager:      manager,
+		id:           id,
+		remote:       remote,
+		pending:      make(map[uint64][]byte),
+		readableChan: make(chan struct{}, 1),
+	}
+}
+
+// RemoteAddress returns the Nym address of the other end of the stream
+func (s *Stream) RemoteAddress() string {
+	return s.remote
+}
+
+// Read reads the bytes received in order, and returns io.EOF once the remote closed the stream and everything was read
+func (s *Stream) Read(p []byte) (int, error) {
+	for {
+		s.Lock()
+		if len(s.readBuffer) > 0 {
+			read := copy(p, s.readBuffer)
+			s.readBuffer = s.readBuffer[read:]
+			s.Unlock()
+			return read, nil
+		}
+		if nil != s.readErr {
+			s.Unlock()
+			return 0, s.readErr
+		}
+		if s.remoteClosed && s.nextReadSeq > s.closeSeq {
+			s.Unlock()
+			return 0, io.EOF
+		}
+		deadline := s.readDeadline
+		s.Unlock()
+
+		if deadline.IsZero() {
+			<-s.readableChan
+			continue
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-s.readableChan:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// setReadDeadline makes the pending and next Reads fail with os.ErrDeadlineExceeded after deadline, zero meaning never
+func (s *Stream) setReadDeadline(deadline time.Time) {
+	s.Lock()
+	s.readDeadline = deadline
+	s.Unlock()
+	// So that the pending Read takes the new deadline into account
+	s.signalReadable()
+}
+
+// setWriteDeadline makes the next Writes fail with os.ErrDeadlineExceeded after deadline, zero meaning never.
+// A Write in progress is not interrupted
+func (s *Stream) setWriteDeadline(deadline time.Time) {
+	if deadline.IsZero() {
+		s.writeDeadline.Store(0)
+	} else {
+		s.writeDeadline.Store(deadline.UnixNano())
+	}
+}
+
+// Write buffers p and sends the frames filled. The rest is sent after a short delay, or by Flush or Close.
+// If sending a frame fails, the bytes of p which were not sent are not buffered, and their number is not counted as written
+func (s *Stream) Write(p []byte) (int, error) {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if s.writeClosed {
+		return 0, ErrStreamClosed
+	}
+	if nil != s.writeErr {
+		return 0, s.writeErr
+	}
+	if deadline := s.writeDeadline.Load(); 0 != deadline && time.Now().UnixNano() >= deadline {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	buffered := len(s.writeBuffer)
+	s.writeBuffer = append(s.writeBuffer, p...)
+	sent := 0
+	for len(s.writeBuffer) >= streamFrameSize {
+		e := s.sendFrame(streamData, s.writeBuffer[:streamFrameSize])
+		if nil != e {
+			// The bytes buffered by the previous Writes are kept, those of p are written if they were sent
+			if sent < buffered {
+				s.writeBuffer = s.writeBuffer[:buffered-sent]
+				return 0, e
+			}
+			s.writeBuffer = nil
+			return sent - buffered, e
+		}
+		s.writeBuffer = s.writeBuffer[streamFrameSize:]
+		sent += streamFrameSize
+	}
+
+	if len(s.writeBuffer) > 0 && nil == s.flushTimer {
+		s.flushTimer = time.AfterFunc(streamFlushDelay, s.autoFlush)
+	}
+	return len(p), nil
+}
+
+// Flush sends the bytes written and not sent yet
+func (s *Stream) Flush() error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if s.writeClosed {
+		return ErrStreamClosed
+	}
+	return s.flush()
+}
+
+func (s *Stream) autoFlush() {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	s.flushTimer = nil
+	if !s.writeClosed && nil == s.writeErr {
+		s.writeErr = s.flush()
+	}
+}
+
+// called with writeMutex locked
+func (s *Stream) flush() error {
+	if nil != s.flushTimer {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	if len(s.writeBuffer) == 0 {
+		return nil
+	}
+
+	e := s.sendFrame(streamData, s.writeBuffer)
+	if nil != e {
+		return e
+	}
+	s.writeBuffer = nil
+	return nil
+}
+
+// Close flushes the bytes written and tells the remote that nothing more will be written.
+// The bytes already received can still be read, the next ones are dropped
+func (s *Stream) Close() error {
+	s.writeMutex.Lock()
+	if s.writeClosed {
+		s.writeMutex.Unlock()
+		return ErrStreamClosed
+	}
+	e := s.flush()
+	if nil == e {
+		e = s.sendFrame(streamClose, nil)
+	}
+	s.writeClosed = true
+	s.writeMutex.Unlock()
+
+	s.Lock()
+	if nil == s.readErr {
+		s.readErr = ErrStreamClosed
+	}
+	s.Unlock()
+	s.signalReadable()
+
+	s.manager.streams.remove(s.id)
+	return e
+}
+
+// called with writeMutex locked
+func (s *Stream) sendFrame(kind string, data []byte) error {
+	frame := streamPrefix + s.id + ":" + strconv.FormatUint(s.nextWriteSeq, 10) + ":" + kind + ":" +
+		s.manager.GetNymClientId() + ":" + base64.StdEncoding.EncodeToString(data)
+
+	e := s.manager.Send(NewNymSend(frame, s.remote))
+	if nil != e {
+		return e
+	}
+	s.nextWriteSeq++
+	return nil
+}
+
+// frameReceived stores the data of a frame until the previous ones are received.
+// It returns an error wrapping ErrStreamWindowExceeded if the frame is too far ahead of the next one to read, the stream is then to fail
+func (s *Stream) frameReceived(seq uint64, kind string, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if seq < s.nextReadSeq || nil != s.readErr {
+		return nil
+	}
+	if seq-s.nextReadSeq >= streamReceiveWindow {
+		return xerrors.Errorf("frame %v of stream %v is outside of the receive window, waiting for frame %v: %w", seq, s.id, s.nextReadSeq, ErrStreamWindowExceeded)
+	}
+
+	if streamClose == kind {
+		s.closeSeq = seq
+		s.remoteClosed = true
+	} else {
+		s.pending[seq] = data
+	}
+
+	for {
+		next, ok := s.pending[s.nextReadSeq]
+		if !ok {
+			break
+		}
+		delete(s.pending, s.nextReadSeq)
+		s.readBuffer = append(s.readBuffer, next...)
+		s.nextReadSeq++
+	}
+	// The close frame takes a sequence number too
+	if s.remoteClosed && s.nextReadSeq == s.closeSeq {
+		s.nextReadSeq++
+	}
+
+	s.signalReadable()
+	return nil
+}
+
+// fail makes the pending and next operations on the stream return err
+func (s *Stream) fail(err error) {
+	s.writeMutex.Lock()
+	s.writeClosed = true
+	if nil != s.flushTimer {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	s.writeMutex.Unlock()
+
+	s.Lock()
+	if nil == s.readErr {
+		s.readErr = err
+	}
+	s.Unlock()
+	s.signalReadable()
+}
+
+func (s *Stream) signalReadable() {
+	select {
+	case s.readableChan <- struct{}{}:
+	default:
+	}
+}
+
+// receiveStreamFrame hands the stream frames to their Stream.
+// It returns true if msg was consumed and must not be passed to the messageHandler
+func (n *NymSocketManager) receiveStreamFrame(msg NymReceived) bool {
+	if !strings.HasPrefix(msg.Message, streamPrefix) {
+		return false
+	}
+
+	fields := strings.SplitN(strings.TrimPrefix(msg.Message, streamPrefix), ":", 5)
+	if len(fields) != 5 {
+		n.logger.Warn().Msg("dropping malformed stream frame")
+		n.messageDropped(DropMalformed, msg, msg.MessageID, nil, "malformed stream frame")
+		return true
+	}
+	seq, e := strconv.ParseUint(fields[1], 10, 64)
+	if nil != e {
+		n.logger.Warn().Msgf("dropping stream frame with malformed sequence number: %v", e)
+		n.messageDropped(DropMalformed, msg, msg.MessageID, nil, "stream frame with malformed sequence number")
+		return true
+	}
+	data, e := base64.StdEncoding.DecodeString(fields[4])
+	if nil != e {
+		n.logger.Warn().Msgf("dropping stream frame with malformed data: %v", e)
+		n.messageDropped(DropMalformed, msg, msg.MessageID, nil, "stream frame with malformed data")
+		return true
+	}
+
+	stream, e := n.streams.get(n, fields[0], fields[3])
+	if nil != e {
+		n.messageLogger.Debug().Msgf("dropping stream frame: %v", e)
+		n.messageDropped(DropUnmatched, msg, msg.MessageID, nil, e.Error())
+		return true
+	}
+	e = stream.frameReceived(seq, fields[2], data)
+	if nil != e {
+		// Nothing retransmits the frames in between, Read would wait for them forever
+		n.logger.Warn().Msgf("dropping stream frame, failing the stream: %v", e)
+		n.messageDropped(DropStreamWindow, msg, msg.MessageID, nil, e.Error())
+		stream.fail(e)
+		n.streams.remove(stream.id)
+	}
+	return true
+}
@@ -0,0 +1,170 @@
+package nymsocketmanager_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// startLinkedManagers starts a NymSocketManager on each of two linked fake nym-clients
+func startLinkedManagers(t *testing.T) (*lib.NymSocketManager, *lib.NymSocketManager) {
+	logger := zerolog.Logger{}
+	first, second := newLinkedFakeNymClients(t)
+
+	var managers []*lib.NymSocketManager
+	for _, fake := range []*fakeNymClient{first, second} {
+		nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+		require.NoError(t, e)
+		_, e = nymSocketManager.Start()
+		require.NoError(t, e)
+		t.Cleanup(nymSocketManager.Stop)
+		managers = append(managers, nymSocketManager)
+	}
+	return managers[0], managers[1]
+}
+
+func TestNymSocketManagerStream(t *testing.T) {
+	client, server := startLinkedManagers(t)
+
+	// Spans several frames
+	request := bytes.Repeat([]byte("0123456789"), 10000)
+
+	stream, e := client.OpenStream(server.GetNymClientId())
+	require.NoError(t, e)
+	_, e = stream.Write(request)
+	require.NoError(t, e)
+	require.NoError(t, stream.Flush())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	accepted, e := server.AcceptStream(ctx)
+	require.NoError(t, e)
+	require.Equal(t, client.GetNymClientId(), accepted.RemoteAddress())
+
+	received := make([]byte, len(request))
+	_, e = io.ReadFull(accepted, received)
+	require.NoError(t, e)
+	require.Equal(t, request, received)
+
+	// Small writes are sent without flushing
+	_, e = accepted.Write([]byte("response"))
+	require.NoError(t, e)
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, accepted.Close())
+
+	response, e := io.ReadAll(stream)
+	require.NoError(t, e)
+	require.Equal(t, "response", string(response))
+
+	require.NoError(t, stream.Close())
+	_, e = stream.Write([]byte("late"))
+	require.ErrorIs(t, e, lib.ErrStreamClosed)
+	require.ErrorIs(t, stream.Close(), lib.ErrStreamClosed)
+}
+
+func TestNymSocketManagerStreamClosedOnStop(t *testing.T) {
+	client, server := startLinkedManagers(t)
+
+	stream, e := client.OpenStream(server.GetNymClientId())
+	require.NoError(t, e)
+
+	_, e = client.OpenStream("not an address")
+	require.Error(t, e)
+
+	readErrChan := make(chan error, 1)
+	go func() {
+		_, e := stream.Read(make([]byte, 1))
+		readErrChan <- e
+	}()
+
+	client.Stop()
+	select {
+	case e := <-readErrChan:
+		require.ErrorIs(t, e, lib.ErrStreamClosed)
+	case <-time.After(time.Second):
+		require.Fail(t, "read not aborted")
+	}
+}
+
+func TestNymSocketManagerStreamWriteFailed(t *testing.T) {
+	client, server := startLinkedManagers(t)
+
+	// The second frame cannot be sent
+	var sent atomic.Int32
+	sendErr := errors.New("blocked")
+	client.Intercept(func(next lib.Sender) lib.Sender {
+		return func(msg lib.NymMessage) error {
+			if sent.Add(1) > 1 {
+				return sendErr
+			}
+			return next(msg)
+		}
+	})
+
+	stream, e := client.OpenStream(server.GetNymClientId())
+	require.NoError(t, e)
+	written, e := stream.Write(make([]byte, 80*1024))
+	require.ErrorIs(t, e, sendErr)
+	require.Equal(t, 32*1024, written)
+
+	// The bytes not written are not buffered either
+	require.NoError(t, stream.Flush())
+	require.EqualValues(t, 2, sent.Load())
+}
+
+func TestNymSocketManagerStreamReceiveWindow(t *testing.T) {
+	logger := zerolog.Logger{}
+	first, second := newLinkedFakeNymClients(t)
+
+	// Dispatched in order, for the frames to be received as they are sent
+	server, e := lib.NewNymSocketManager(second.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithHandlerPool(lib.HandlerPool{Workers: 1}))
+	require.NoError(t, e)
+	_, e = server.Start()
+	require.NoError(t, e)
+	defer server.Stop()
+	client, e := lib.NewNymSocketManager(first.URI(), emptyProcessing, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	_, e = client.Start()
+	require.NoError(t, e)
+	defer client.Stop()
+
+	sendFrame := func(seq int, kind string, data string) {
+		frame := fmt.Sprintf("\x1eNSMSTREAM:window:%d:%v:%v:%v", seq, kind, client.GetNymClientId(), base64.StdEncoding.EncodeToString([]byte(data)))
+		require.NoError(t, client.Send(lib.NewNymSend(frame, server.GetNymClientId())))
+	}
+
+	// The first frame is late, the last one is too far ahead of it
+	for seq := 1; seq <= 64; seq++ {
+		sendFrame(seq, "D", "a")
+	}
+	sendFrame(0, "D", "a")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	accepted, e := server.AcceptStream(ctx)
+	require.NoError(t, e)
+	readChan := make(chan error, 1)
+	go func() {
+		_, e := io.ReadAll(accepted)
+		readChan <- e
+	}()
+	select {
+	case e = <-readChan:
+		require.ErrorIs(t, e, lib.ErrStreamWindowExceeded)
+	case <-time.After(time.Second):
+		require.Fail(t, "Read still waiting for the frames outside of the window")
+	}
+	require.Eventually(t, func() bool {
+		return 1 == server.Stats().MessagesDropped[lib.DropStreamWindow]
+	}, time.Second, 10*time.Millisecond)
+}
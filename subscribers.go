@@ -0,0 +1,76 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Default size of the buffer of each subscription to the received messages
+const DefaultSubscriptionBufferSize = 64
+
+// Subscription receives every NymReceived passed to the messageHandler, independently of the other subscriptions
+type Subscription struct {
+	messages chan NymReceived
+	dropped  atomic.Uint64
+}
+
+// Messages returns the channel of the received messages, closed by Unsubscribe
+func (s *Subscription) Messages() <-chan NymReceived {
+	return s.messages
+}
+
+// Dropped returns the number of messages dropped because the subscription was not consumed fast enough
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// receivedSubscribers fans the received messages out to the subscriptions
+// It has its own lock so that delivering messages does not contend with the NymSocketManager
+type receivedSubscribers struct {
+	sync.Mutex
+
+	subscriptions []*Subscription
+}
+
+// Subscribe returns a Subscription receiving every message passed to the messageHandler, e.g. to record metrics alongside it.
+// Each subscription has its own buffer of bufferSize messages, DefaultSubscriptionBufferSize if zero: a subscription lagging
+// behind drops the messages it has no room for, without delaying the messageHandler or the other subscriptions
+func (n *NymSocketManager) Subscribe(bufferSize int) *Subscription {
+	if 0 == bufferSize {
+		bufferSize = DefaultSubscriptionBufferSize
+	}
+	subscription := &Subscription{messages: make(chan NymReceived, bufferSize)}
+
+	n.subscribers.Lock()
+	defer n.subscribers.Unlock()
+	n.subscribers.subscriptions = append(n.subscribers.subscriptions, subscription)
+	return subscription
+}
+
+// Unsubscribe stops sending messages to a Subscription obtained with Subscribe, and closes its channel
+func (n *NymSocketManager) Unsubscribe(subscription *Subscription) {
+	n.subscribers.Lock()
+	defer n.subscribers.Unlock()
+
+	for i, s := range n.subscribers.subscriptions {
+		if subscription == s {
+			close(s.messages)
+			n.subscribers.subscriptions = append(n.subscribers.subscriptions[:i], n.subscribers.subscriptions[i+1:]...)
+			return
+		}
+	}
+}
+
+// publish passes received to every subscription with room for it
+func (s *receivedSubscribers) publish(received NymReceived) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, subscription := range s.subscriptions {
+		select {
+		case subscription.messages <- received:
+		default:
+			subscription.dropped.Add(1)
+		}
+	}
+}
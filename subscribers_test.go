@@ -0,0 +1,61 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerSubscribe(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	handled := make(chan lib.NymReceived, 3)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		handled <- received
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+
+	fast := nymSocketManager.Subscribe(0)
+	// Never consumed, so it can only hold the first message
+	slow := nymSocketManager.Subscribe(1)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	for _, message := range []string{"first", "second", "third"} {
+		require.NoError(t, nymSocketManager.Send(lib.NewNymSend(message, fakeNymClientAddress)))
+	}
+
+	received := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		select {
+		case m := <-fast.Messages():
+			received[m.Message] = true
+		case <-time.After(time.Second):
+			t.Fatal("the subscription did not receive every message")
+		}
+	}
+	require.Equal(t, map[string]bool{"first": true, "second": true, "third": true}, received)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Fatal("the slow subscription blocked the messageHandler")
+		}
+	}
+	require.Len(t, slow.Messages(), 1)
+	require.Equal(t, uint64(2), slow.Dropped())
+	require.Zero(t, fast.Dropped())
+
+	nymSocketManager.Unsubscribe(slow)
+	_, open := <-slow.Messages()
+	require.True(t, open)
+	_, open = <-slow.Messages()
+	require.False(t, open)
+}
@@ -0,0 +1,186 @@
+package nymsocketmanager
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Marks the messages asking the peer for more reply SURBs, followed by "<count>:<address to send them to>"
+	surbRequestPrefix = "\x1eNSMSURBREQ:"
+	// Marks the anonymous messages carrying the reply SURBs asked for, followed by their count
+	surbTopUpPrefix = "\x1eNSMSURBTOPUP:"
+	// Approximate plaintext size of a Sphinx packet, each packet of a reply consuming one reply SURB
+	surbPayloadSize = 2000
+)
+
+// SurbManagement configures the tracking of the reply SURBs received from the peers sending anonymous messages
+type SurbManagement struct {
+	// Number of reply SURBs assumed to come with each anonymous message received
+	SurbsPerMessage uint
+	// Once the estimated number of reply SURBs left for a peer falls to LowWatermark, more are asked to it
+	LowWatermark uint
+	// Number of reply SURBs asked for, and attached when asked for
+	TopUp uint
+}
+
+// SurbBudget estimates the reply SURBs available to answer a peer
+type SurbBudget struct {
+	SenderTag string
+	Received  uint
+	Consumed  uint
+	// Whether more reply SURBs were asked to the peer, and not received yet
+	TopUpRequested bool
+}
+
+// Remaining returns the estimated number of reply SURBs left
+func (s SurbBudget) Remaining() uint {
+	if s.Consumed > s.Received {
+		return 0
+	}
+	return s.Received - s.Consumed
+}
+
+// surbTracker holds the SurbBudget of each peer
+type surbTracker struct {
+	sync.Mutex
+
+	budgets map[string]*SurbBudget
+}
+
+// called with the tracker locked
+func (s *surbTracker) budget(senderTag string) *SurbBudget {
+	if nil == s.budgets {
+		s.budgets = make(map[string]*SurbBudget)
+	}
+	budget, ok := s.budgets[senderTag]
+	if !ok {
+		budget = &SurbBudget{SenderTag: senderTag}
+		s.budgets[senderTag] = budget
+	}
+	return budget
+}
+
+func (s *surbTracker) received(senderTag string, count uint, topUp bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	budget := s.budget(senderTag)
+	budget.Received += count
+	if topUp {
+		budget.TopUpRequested = false
+	}
+}
+
+// consumed returns true if more reply SURBs should be asked to the peer
+func (s *surbTracker) consumed(senderTag string, count uint, lowWatermark uint) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	budget := s.budget(senderTag)
+	budget.Consumed += count
+	if budget.TopUpRequested || budget.Remaining() > lowWatermark {
+		return false
+	}
+	budget.TopUpRequested = true
+	return true
+}
+
+func (s *surbTracker) get(senderTag string) (SurbBudget, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	budget, ok := s.budgets[senderTag]
+	if !ok {
+		return SurbBudget{}, false
+	}
+	return *budget, true
+}
+
+func (s *surbTracker) getAll() []SurbBudget {
+	s.Lock()
+	defer s.Unlock()
+
+	budgets := make([]SurbBudget, 0, len(s.budgets))
+	for _, budget := range s.budgets {
+		budgets = append(budgets, *budget)
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].SenderTag < budgets[j].SenderTag })
+	return budgets
+}
+
+// GetSurbBudget returns the reply SURBs estimated to be left to answer the peer of senderTag, see WithSurbManagement
+func (n *NymSocketManager) GetSurbBudget(senderTag string) (SurbBudget, bool) {
+	return n.surbs.get(senderTag)
+}
+
+// GetSurbBudgets returns the SurbBudget of all the peers which sent anonymous messages, sorted by senderTag
+func (n *NymSocketManager) GetSurbBudgets() []SurbBudget {
+	return n.surbs.getAll()
+}
+
+// trackReceivedSurbs accounts for the reply SURBs of a received message, and handles the requests for more of them.
+// It returns false if msg was consumed and must not be passed to the messageHandler
+func (n *NymSocketManager) trackReceivedSurbs(msg NymReceived) bool {
+	if nil == n.surbManagement {
+		return true
+	}
+
+	// A peer we sent anonymous messages to is running low on reply SURBs
+	if strings.HasPrefix(msg.Message, surbRequestPrefix) {
+		count, recipient, found := strings.Cut(strings.TrimPrefix(msg.Message, surbRequestPrefix), ":")
+		topUp, e := strconv.ParseUint(count, 10, 32)
+		if !found || nil != e || 0 == topUp {
+			n.logger.Warn().Msg("dropping malformed request for reply SURBs")
+			n.messageDropped(DropMalformed, msg, msg.MessageID, nil, "malformed request for reply SURBs")
+			return false
+		}
+		n.logger.Debug().Msgf("sending %d reply SURBs on request", topUp)
+		e = n.Send(NewNymSendAnonymous(surbTopUpPrefix+count, recipient, uint(topUp)))
+		if nil != e {
+			n.logger.Warn().Msgf("failed to send the reply SURBs requested: %v", e)
+		}
+		return false
+	}
+
+	if !msg.IsReplyable() {
+		return true
+	}
+
+	if strings.HasPrefix(msg.Message, surbTopUpPrefix) {
+		count, e := strconv.ParseUint(strings.TrimPrefix(msg.Message, surbTopUpPrefix), 10, 32)
+		if nil != e {
+			n.logger.Warn().Msg("dropping malformed reply SURBs top-up")
+			n.messageDropped(DropMalformed, msg, msg.MessageID, nil, "malformed reply SURBs top-up")
+			return false
+		}
+		n.surbs.received(msg.SenderTag, uint(count), true)
+		return false
+	}
+
+	n.surbs.received(msg.SenderTag, n.surbManagement.SurbsPerMessage, false)
+	return true
+}
+
+// trackConsumedSurbs accounts for the reply SURBs consumed by a reply, and asks the peer for more if needed
+func (n *NymSocketManager) trackConsumedSurbs(senderTag string, message string) {
+	if nil == n.surbManagement {
+		return
+	}
+
+	packets := uint(len(message)/surbPayloadSize + 1)
+	if !n.surbs.consumed(senderTag, packets, n.surbManagement.LowWatermark) {
+		return
+	}
+
+	n.logger.Debug().Msgf("running low on reply SURBs for %v, asking for %d more", senderTag, n.surbManagement.TopUp)
+	request := surbRequestPrefix + strconv.FormatUint(uint64(n.surbManagement.TopUp), 10) + ":" + n.GetNymClientId()
+	e := n.Send(NewNymReply(senderTag, request))
+	if nil != e {
+		n.logger.Warn().Msgf("failed to ask for reply SURBs: %v", e)
+		return
+	}
+	n.surbs.consumed(senderTag, 1, n.surbManagement.LowWatermark)
+}
@@ -0,0 +1,50 @@
+package nymsocketmanager_test
+
+import (
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerSurbManagement(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger),
+		lib.WithSurbManagement(lib.SurbManagement{SurbsPerMessage: 3, LowWatermark: 1, TopUp: 5}))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// The anonymous message looped back comes with reply SURBs
+	require.NoError(t, nymSocketManager.SendAnonymous("hello", nymSocketManager.GetNymClientId(), 3))
+	require.Eventually(t, func() bool {
+		budget, ok := nymSocketManager.GetSurbBudget(fakeNymClientSenderTag)
+		return ok && 3 == budget.Remaining()
+	}, time.Second, 10*time.Millisecond)
+
+	// The second reply reaches the low watermark: more reply SURBs are asked for, and sent back by ourselves
+	require.NoError(t, nymSocketManager.Reply(fakeNymClientSenderTag, "first"))
+	require.NoError(t, nymSocketManager.Reply(fakeNymClientSenderTag, "second"))
+	require.Eventually(t, func() bool {
+		budget, _ := nymSocketManager.GetSurbBudget(fakeNymClientSenderTag)
+		return 8 == budget.Received && !budget.TopUpRequested
+	}, time.Second, 10*time.Millisecond)
+
+	budgets := nymSocketManager.GetSurbBudgets()
+	require.Len(t, budgets, 1)
+	require.Equal(t, uint(3), budgets[0].Consumed)
+	require.Equal(t, uint(5), budgets[0].Remaining())
+}
+
+func TestNymSocketManagerSurbManagementValidation(t *testing.T) {
+	logger := zerolog.Logger{}
+
+	_, e := lib.NewNymSocketManager("ws://localhost", emptyProcessing, lib.ZerologLogger(&logger), lib.WithSurbManagement(lib.SurbManagement{TopUp: 1}))
+	require.Error(t, e)
+}
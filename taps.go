@@ -0,0 +1,91 @@
+package nymsocketmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Direction tells whether a frame was read from or written to a nym-client, see RegisterTap
+type Direction int
+
+const (
+	DirectionIn Direction = iota
+	DirectionOut
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirectionIn:
+		return "in"
+	case DirectionOut:
+		return "out"
+	default:
+		return "unknown"
+	}
+}
+
+// Tap observes the raw frames exchanged with the nym-clients, see RegisterTap
+type Tap func(direction Direction, raw []byte)
+
+// frameTaps holds the taps registered with RegisterTap and the redaction applied before them
+type frameTaps struct {
+	sync.Mutex
+
+	taps []Tap
+	// nil if the frames are passed as is, see WithTapRedaction
+	redact func(direction Direction, raw []byte) []byte
+}
+
+// RegisterTap adds a tap called with every frame read from or written to the nym-clients, the pooled connections included,
+// e.g. for debugging or audit tooling. The frames are passed through the redaction set with WithTapRedaction, if any.
+// Taps are called synchronously from the goroutine reading or writing: they must return quickly, and copy raw to keep it
+func (n *NymSocketManager) RegisterTap(tap Tap) {
+	n.taps.Lock()
+	defer n.taps.Unlock()
+	n.taps.taps = append(n.taps.taps, tap)
+}
+
+// observe passes a frame to the taps, once redacted
+func (t *frameTaps) observe(direction Direction, raw []byte) {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.taps) == 0 {
+		return
+	}
+	if nil != t.redact {
+		raw = t.redact(direction, raw)
+	}
+	for _, tap := range t.taps {
+		tap(direction, raw)
+	}
+}
+
+// RedactPayloads is a redaction for WithTapRedaction, hiding the payloads of the frames: the message field of the JSON frames
+// is replaced by its length, and the binary frames are cut after their tag
+func RedactPayloads(_ Direction, raw []byte) []byte {
+	if len(raw) == 0 {
+		return raw
+	}
+	// The tags of the binary requests and responses are below the characters JSON documents start with
+	if raw[0] <= binaryLaneQueueLengthTag {
+		return raw[:1]
+	}
+
+	var fields map[string]json.RawMessage
+	if nil != json.Unmarshal(raw, &fields) {
+		return []byte(fmt.Sprintf(`"<unparsable frame of %d bytes>"`, len(raw)))
+	}
+	message, ok := fields["message"]
+	if !ok {
+		return raw
+	}
+	fields["message"] = json.RawMessage(fmt.Sprintf(`"<%d bytes redacted>"`, len(message)))
+
+	redacted, e := json.Marshal(fields)
+	if nil != e {
+		return []byte(fmt.Sprintf(`"<unparsable frame of %d bytes>"`, len(raw)))
+	}
+	return redacted
+}
@@ -0,0 +1,101 @@
+package nymsocketmanager_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// tapRecorder keeps the frames observed by a tap
+type tapRecorder struct {
+	sync.Mutex
+	frames map[lib.Direction][]string
+}
+
+func (r *tapRecorder) tap(direction lib.Direction, raw []byte) {
+	r.Lock()
+	defer r.Unlock()
+	if nil == r.frames {
+		r.frames = make(map[lib.Direction][]string)
+	}
+	r.frames[direction] = append(r.frames[direction], string(raw))
+}
+
+// framesContaining returns the frames of direction containing s
+func (r *tapRecorder) framesContaining(direction lib.Direction, s string) []string {
+	r.Lock()
+	defer r.Unlock()
+	var frames []string
+	for _, frame := range r.frames[direction] {
+		if strings.Contains(frame, s) {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+func TestNymSocketManagerTap(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	received := make(chan lib.NymReceived, 1)
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), func(r lib.NymReceived, _ func(lib.NymMessage) error) {
+		received <- r
+	}, lib.ZerologLogger(&logger))
+	require.NoError(t, e)
+	recorder := &tapRecorder{}
+	nymSocketManager.RegisterTap(recorder.tap)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	select {
+	case r := <-received:
+		require.Equal(t, "hello", r.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "message not received")
+	}
+
+	require.Len(t, recorder.framesContaining(lib.DirectionOut, `"selfAddress"`), 1)
+	require.Len(t, recorder.framesContaining(lib.DirectionIn, fakeNymClientAddress), 1)
+	require.Len(t, recorder.framesContaining(lib.DirectionOut, `"hello"`), 1)
+	require.Len(t, recorder.framesContaining(lib.DirectionIn, `"hello"`), 1)
+}
+
+func TestNymSocketManagerTapRedaction(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithTapRedaction(lib.RedactPayloads))
+	require.NoError(t, e)
+	recorder := &tapRecorder{}
+	nymSocketManager.RegisterTap(recorder.tap)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("secret", fakeNymClientAddress)))
+	require.Eventually(t, func() bool { return len(recorder.framesContaining(lib.DirectionIn, "bytes redacted")) == 1 }, time.Second, 10*time.Millisecond)
+
+	require.Empty(t, recorder.framesContaining(lib.DirectionOut, "secret"))
+	require.Empty(t, recorder.framesContaining(lib.DirectionIn, "secret"))
+	// The other fields are kept
+	require.Len(t, recorder.framesContaining(lib.DirectionOut, fakeNymClientAddress), 1)
+}
+
+func TestRedactPayloads(t *testing.T) {
+	require.JSONEq(t, `{"type":"send","message":"<8 bytes redacted>","recipient":"r"}`,
+		string(lib.RedactPayloads(lib.DirectionOut, []byte(`{"type":"send","message":"secret","recipient":"r"}`))))
+	require.Equal(t, `{"type":"selfAddress"}`, string(lib.RedactPayloads(lib.DirectionOut, []byte(`{"type":"selfAddress"}`))))
+	require.Equal(t, []byte{0x01}, lib.RedactPayloads(lib.DirectionIn, []byte{0x01, 's', 'e', 'c', 'r', 'e', 't'}))
+	require.NotContains(t, string(lib.RedactPayloads(lib.DirectionIn, []byte(`{"message":"secret`))), "secret")
+	require.Equal(t, "out", lib.DirectionOut.String())
+}
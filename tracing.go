@@ -0,0 +1,180 @@
+package nymsocketmanager
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Names of the spans started by the manager, see WithTracing
+const (
+	// Around SendWithOptions and the methods built on it
+	SendSpanName = "nym.send"
+	// Around the processing of a message received, until it is passed to the handler pool or handled
+	DispatchSpanName = "nym.dispatch"
+	// Around the call of the middlewares and of the messageHandler
+	HandleSpanName = "nym.handle"
+)
+
+// Prefix of the payloads carrying a trace context, followed by "<url-encoded fields>:<payload>", see WithTracePropagation
+const tracePrefix = "\x1eNSMTRC:"
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SetError records the error the traced operation failed with
+	SetError(err error)
+	End()
+}
+
+// Tracer starts the spans of the manager and propagates their context, see WithTracing.
+// It maps directly on OpenTelemetry: Start on trace.Tracer.Start with the attributes as attribute.String,
+// Inject and Extract on the TextMapPropagator of otel with propagation.MapCarrier(carrier)
+type Tracer interface {
+	// Start starts a span, child of the span held by ctx if any, and returns the context holding it
+	Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span)
+	// Inject writes the trace context of ctx in carrier, e.g. the traceparent field of W3C Trace Context
+	Inject(ctx context.Context, carrier map[string]string)
+	// Extract returns ctx along with the trace context read from carrier
+	Extract(ctx context.Context, carrier map[string]string) context.Context
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetError(error) {}
+
+func (noopSpan) End() {}
+
+// receivedTrace is the trace a received message is processed in
+type receivedTrace struct {
+	ctx  context.Context
+	span Span
+}
+
+// traceContextOption sets the context the span of the Send is a child of, see SendContext
+func traceContextOption(ctx context.Context) SendOption {
+	return func(o *sendOptions) {
+		o.traceContext = ctx
+	}
+}
+
+// SendContext sends msg like SendWithOptions, within the trace of ctx: its span is a child of the span held by ctx,
+// and its trace context is propagated to the recipient if WithTracePropagation is set. ctx does not cancel the send
+func (n *NymSocketManager) SendContext(ctx context.Context, msg NymMessage, options ...SendOption) error {
+	return n.SendWithOptions(msg, append(options, traceContextOption(ctx))...)
+}
+
+// startSpan starts a span with the tracer if any, attributes being only called then
+func (n *NymSocketManager) startSpan(ctx context.Context, name string, attributes func() map[string]string) (context.Context, Span) {
+	if nil == n.tracer {
+		return ctx, noopSpan{}
+	}
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	return n.tracer.Start(ctx, name, attributes())
+}
+
+// endSpan records e in span, if any, and ends it
+func endSpan(span Span, e error) {
+	if nil != e {
+		span.SetError(e)
+	}
+	span.End()
+}
+
+func sendSpanAttributes(msg NymMessage) func() map[string]string {
+	return func() map[string]string {
+		attributes := map[string]string{"nym.message.type": msg.Name()}
+		if recipient := recipientOf(msg); len(recipient) != 0 {
+			attributes["nym.recipient"] = recipient
+		}
+		return attributes
+	}
+}
+
+func receivedSpanAttributes(received NymReceived) map[string]string {
+	return map[string]string{"nym.message.size": strconv.Itoa(len(received.Message))}
+}
+
+// injectTraceContext prefixes the payload of msg with the trace context of ctx, if propagated
+func (n *NymSocketManager) injectTraceContext(ctx context.Context, msg NymMessage) NymMessage {
+	if nil == n.tracer || !n.tracePropagation {
+		return msg
+	}
+	carrier := make(map[string]string)
+	n.tracer.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return msg
+	}
+
+	fields := make(url.Values, len(carrier))
+	for key, value := range carrier {
+		fields.Set(key, value)
+	}
+	header := tracePrefix + fields.Encode() + ":"
+
+	switch m := msg.(type) {
+	case NymSend:
+		m.Message = header + m.Message
+		return m
+	case NymSendAnonymous:
+		m.Message = header + m.Message
+		return m
+	case NymReply:
+		m.Message = header + m.Message
+		return m
+	default:
+		return msg
+	}
+}
+
+// stripTraceContext removes the trace context header of received, if any, and returns its fields
+func stripTraceContext(received *NymReceived) map[string]string {
+	if !strings.HasPrefix(received.Message, tracePrefix) {
+		return nil
+	}
+	header, message, found := strings.Cut(strings.TrimPrefix(received.Message, tracePrefix), ":")
+	if !found {
+		return nil
+	}
+	fields, e := url.ParseQuery(header)
+	if nil != e {
+		return nil
+	}
+
+	received.Message = message
+	carrier := make(map[string]string, len(fields))
+	for key := range fields {
+		carrier[key] = fields.Get(key)
+	}
+	return carrier
+}
+
+// traceReceived strips the trace context header of received and starts the span of its dispatch,
+// continuing the trace of the sender if propagated
+func (n *NymSocketManager) traceReceived(received *NymReceived) Span {
+	carrier := stripTraceContext(received)
+	if nil == n.tracer {
+		return noopSpan{}
+	}
+
+	ctx := n.handlerContext.get()
+	if n.tracePropagation && len(carrier) != 0 {
+		ctx = n.tracer.Extract(ctx, carrier)
+	}
+	ctx, span := n.tracer.Start(ctx, DispatchSpanName, receivedSpanAttributes(*received))
+	received.trace = &receivedTrace{ctx: ctx}
+	return span
+}
+
+// traceHandled starts the span of the handling of received, child of the span of its dispatch
+func (n *NymSocketManager) traceHandled(received *NymReceived) Span {
+	if nil == n.tracer || nil == received.trace {
+		return noopSpan{}
+	}
+
+	ctx, span := n.tracer.Start(received.trace.ctx, HandleSpanName, receivedSpanAttributes(*received))
+	received.trace = &receivedTrace{ctx: ctx, span: span}
+	return span
+}
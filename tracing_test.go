@@ -0,0 +1,191 @@
+package nymsocketmanager_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSpan struct {
+	tracer *fakeTracer
+	name   string
+	id     string
+	// Empty for the root spans
+	parent string
+	err    error
+}
+
+func (s *fakeSpan) SetError(err error) {
+	s.tracer.Lock()
+	defer s.tracer.Unlock()
+	s.err = err
+}
+
+func (s *fakeSpan) End() {
+	s.tracer.Lock()
+	defer s.tracer.Unlock()
+	s.tracer.ended = append(s.tracer.ended, *s)
+}
+
+type fakeSpanKey struct{}
+
+// fakeTracer records the spans ended, shared by the two ends of a trace
+type fakeTracer struct {
+	sync.Mutex
+
+	ids   int
+	ended []fakeSpan
+}
+
+func (f *fakeTracer) Start(ctx context.Context, name string, _ map[string]string) (context.Context, lib.Span) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.ids++
+	span := &fakeSpan{tracer: f, name: name, id: strconv.Itoa(f.ids)}
+	if parent, ok := ctx.Value(fakeSpanKey{}).(string); ok {
+		span.parent = parent
+	}
+	return context.WithValue(ctx, fakeSpanKey{}, span.id), span
+}
+
+func (f *fakeTracer) Inject(ctx context.Context, carrier map[string]string) {
+	if id, ok := ctx.Value(fakeSpanKey{}).(string); ok {
+		carrier["traceparent"] = id
+	}
+}
+
+func (f *fakeTracer) Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if id, ok := carrier["traceparent"]; ok {
+		return context.WithValue(ctx, fakeSpanKey{}, id)
+	}
+	return ctx
+}
+
+// span returns the ended span named name whose parent is parent
+func (f *fakeTracer) span(t *testing.T, name string, parent string) fakeSpan {
+	f.Lock()
+	defer f.Unlock()
+	for _, span := range f.ended {
+		if span.name == name && span.parent == parent {
+			return span
+		}
+	}
+	require.Failf(t, "span not found", "no %v span child of %q in %+v", name, parent, f.ended)
+	return fakeSpan{}
+}
+
+func TestNymSocketManagerTracing(t *testing.T) {
+	logger := zerolog.Logger{}
+	first, second := newLinkedFakeNymClients(t)
+	tracer := &fakeTracer{}
+
+	responseChan := make(chan lib.NymReceived, 1)
+	client, e := lib.NewNymSocketManager(first.URI(), func(received lib.NymReceived, _ func(lib.NymMessage) error) {
+		responseChan <- received
+	}, lib.ZerologLogger(&logger), lib.WithTracing(tracer), lib.WithTracePropagation())
+	require.NoError(t, e)
+
+	handlerSpanChan := make(chan string, 1)
+	server, e := lib.NewNymSocketManagerWithContext(second.URI(), func(ctx context.Context, received lib.NymReceived, reply func(lib.NymMessage) error) error {
+		handlerSpanChan <- ctx.Value(fakeSpanKey{}).(string)
+		return reply(lib.NewNymSend("pong", fakeNymClientAddress))
+	}, lib.ZerologLogger(&logger), lib.WithTracing(tracer), lib.WithTracePropagation())
+	require.NoError(t, e)
+
+	for _, nymSocketManager := range []*lib.NymSocketManager{client, server} {
+		_, e = nymSocketManager.Start()
+		require.NoError(t, e)
+		defer nymSocketManager.Stop()
+	}
+
+	ctx := context.WithValue(context.Background(), fakeSpanKey{}, "request")
+	require.NoError(t, client.SendContext(ctx, lib.NewNymSend("ping", fakePeerAddress)))
+
+	var handlerSpan string
+	select {
+	case handlerSpan = <-handlerSpanChan:
+	case <-time.After(time.Second):
+		require.Fail(t, "request not handled")
+	}
+	select {
+	case response := <-responseChan:
+		// The header is stripped
+		require.Equal(t, "pong", response.Message)
+	case <-time.After(time.Second):
+		require.Fail(t, "response not received")
+	}
+
+	// request > send > dispatch > handle > send of the reply > dispatch > handle
+	require.Eventually(t, func() bool {
+		tracer.Lock()
+		defer tracer.Unlock()
+		return len(tracer.ended) == 6
+	}, time.Second, 10*time.Millisecond)
+	send := tracer.span(t, lib.SendSpanName, "request")
+	dispatch := tracer.span(t, lib.DispatchSpanName, send.id)
+	handle := tracer.span(t, lib.HandleSpanName, dispatch.id)
+	require.Equal(t, handle.id, handlerSpan)
+	replySend := tracer.span(t, lib.SendSpanName, handle.id)
+	replyDispatch := tracer.span(t, lib.DispatchSpanName, replySend.id)
+	tracer.span(t, lib.HandleSpanName, replyDispatch.id)
+}
+
+func TestNymSocketManagerTracingErrors(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	tracer := &fakeTracer{}
+
+	handled := make(chan struct{})
+	nymSocketManager, e := lib.NewNymSocketManagerWithContext(fake.URI(), func(context.Context, lib.NymReceived, func(lib.NymMessage) error) error {
+		defer close(handled)
+		return context.Canceled
+	}, lib.ZerologLogger(&logger), lib.WithTracing(tracer))
+	require.NoError(t, e)
+
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	// Looped back, but without header the trace of the send is not continued
+	require.NoError(t, nymSocketManager.Send(lib.NewNymSend("hello", fakeNymClientAddress)))
+	require.Error(t, nymSocketManager.Send(lib.NewNymSend("hello", "invalid")))
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		require.Fail(t, "message not handled")
+	}
+
+	require.Eventually(t, func() bool {
+		tracer.Lock()
+		defer tracer.Unlock()
+		return len(tracer.ended) == 4
+	}, time.Second, 10*time.Millisecond)
+	tracer.Lock()
+	defer tracer.Unlock()
+	var failed []string
+	for _, span := range tracer.ended {
+		if lib.HandleSpanName != span.name {
+			require.Empty(t, span.parent, "%+v", span)
+		}
+		if nil != span.err {
+			failed = append(failed, span.name)
+		}
+	}
+	require.ElementsMatch(t, []string{lib.SendSpanName, lib.HandleSpanName}, failed)
+	require.False(t, strings.Contains(fake.receivedSends()[0], "NSMTRC"))
+}
+
+func TestWithTracingNil(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+	_, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithTracing(nil))
+	require.Error(t, e)
+}
@@ -0,0 +1,68 @@
+package nymsocketmanager
+
+import "unicode/utf8"
+
+// WireEncoding defines how a message is written on the websocket connection
+type WireEncoding int
+
+const (
+	// The encoding of the manager, text unless set with WithWireEncoding or WithBinaryProtocol
+	EncodingDefault WireEncoding = iota
+	// JSON in a text frame
+	EncodingText
+	// Binary protocol of the nym-client in a binary frame
+	EncodingBinary
+	// Binary for the payloads that are not valid UTF-8, which JSON cannot carry, text otherwise
+	EncodingAuto
+)
+
+func (w WireEncoding) String() string {
+	switch w {
+	case EncodingDefault:
+		return "Default"
+	case EncodingText:
+		return "Text"
+	case EncodingBinary:
+		return "Binary"
+	case EncodingAuto:
+		return "Auto"
+	default:
+		return "Unknown"
+	}
+}
+
+// useBinaryEncoding tells whether msg is to be written with the binary protocol, options can be nil
+func (n *NymSocketManager) useBinaryEncoding(msg NymMessage, options *sendOptions) bool {
+	// Raw messages are JSON by definition
+	if _, raw := msg.(rawNymMessage); raw {
+		return false
+	}
+
+	encoding := n.wireEncoding
+	if nil != options && EncodingDefault != options.encoding {
+		encoding = options.encoding
+	}
+
+	switch encoding {
+	case EncodingBinary:
+		return true
+	case EncodingAuto:
+		return !utf8.ValidString(payloadOf(msg))
+	default:
+		return false
+	}
+}
+
+// payloadOf returns the payload carried by msg, empty for the messages without payload
+func payloadOf(msg NymMessage) string {
+	switch m := msg.(type) {
+	case NymSend:
+		return m.Message
+	case NymSendAnonymous:
+		return m.Message
+	case NymReply:
+		return m.Message
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,63 @@
+package nymsocketmanager
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// writeCoalescer gathers the messages sent within a window, to write them in a row, see WithWriteCoalescing
+type writeCoalescer struct {
+	sync.Mutex
+
+	window time.Duration
+	batch  []*queuedMessage
+}
+
+// write adds a message to the current batch, starting it if needed, and waits until the batch is written
+func (c *writeCoalescer) write(msg NymMessage, options *sendOptions, writeBatch func([]*queuedMessage)) error {
+	queued := &queuedMessage{msg, options, make(chan error, 1)}
+
+	c.Lock()
+	c.batch = append(c.batch, queued)
+	if 1 == len(c.batch) {
+		time.AfterFunc(c.window, func() {
+			c.Lock()
+			batch := c.batch
+			c.batch = nil
+			c.Unlock()
+
+			writeBatch(batch)
+		})
+	}
+	c.Unlock()
+
+	return <-queued.done
+}
+
+// writeBatch writes the messages of a batch in a row, holding the lock of the connection once
+func (n *NymSocketManager) writeBatch(batch []*queuedMessage) {
+	if pooled := n.pool.next(); nil != pooled {
+		pooled.Lock()
+		defer pooled.Unlock()
+
+		for _, queued := range batch {
+			queued.done <- n.writeMessage(pooled.connection, queued.msg, queued.options)
+		}
+		return
+	}
+
+	n.senderMutex.Lock()
+	defer n.senderMutex.Unlock()
+
+	for _, queued := range batch {
+		if nil == n.connection {
+			err := xerrors.Errorf("cannot write %v: %w", queued.msg.Name(), n.disconnectedMode())
+			n.logger.Warn().Msg(err.Error())
+			queued.done <- err
+			continue
+		}
+		queued.done <- n.writeMessage(n.connection, queued.msg, queued.options)
+	}
+}
@@ -0,0 +1,43 @@
+package nymsocketmanager_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	lib "github.com/notrustverify/nymsocketmanager"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNymSocketManagerWriteCoalescing(t *testing.T) {
+	logger := zerolog.Logger{}
+	fake := newFakeNymClient(t)
+
+	nymSocketManager, e := lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithWriteCoalescing(50*time.Millisecond))
+	require.NoError(t, e)
+	_, e = nymSocketManager.Start()
+	require.NoError(t, e)
+	defer nymSocketManager.Stop()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			require.NoError(t, nymSocketManager.Send(lib.NewNymSend(fmt.Sprint(i), fakePeerAddress)))
+		}(i)
+	}
+	wg.Wait()
+
+	// Written together once the window elapsed, rather than one window each
+	elapsed := time.Since(start)
+	require.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+	require.Less(t, elapsed, 150*time.Millisecond)
+	require.Eventually(t, func() bool { return 5 == len(fake.receivedSends()) }, time.Second, 10*time.Millisecond)
+
+	_, e = lib.NewNymSocketManager(fake.URI(), emptyProcessing, lib.ZerologLogger(&logger), lib.WithWriteCoalescing(0))
+	require.Error(t, e)
+}